@@ -10,6 +10,9 @@ import (
 	"strings"
 )
 
+// CmdExit 退出指令, 与服务端 model.go 中的定义保持一致;
+const CmdExit = "exit"
+
 type TCPClient struct {
 	addr string   // 服务器地址（如 127.0.0.1:8888）
 	conn net.Conn // TCP连接
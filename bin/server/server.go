@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"time"
 )
 
 type CmdHandler func(req *Request, session *sql.Session) *Response
@@ -40,10 +41,15 @@ func (s *TCPServer) registerHandler(cmd string, handler CmdHandler) {
 	s.handlers[cmd] = handler
 }
 
-func (s *TCPServer) Start(diskPath string) error {
+func (s *TCPServer) Start(diskPath string, indexWaitTimeout time.Duration) error {
 	// 1.本地服务起来;
 	storage := storage.NewDiskStorage(diskPath)
-	serverManager := sql.NewServer(storage)
+	serverManager, err := sql.NewServer(storage)
+	if err != nil {
+		storage.Close()
+		return fmt.Errorf("初始化失败;%v", err)
+	}
+	serverManager.IndexWaitTimeout = indexWaitTimeout
 	defer serverManager.Close()
 	// 2.监听TCP端口;
 	listener, err := net.Listen("tcp", s.addr)
@@ -177,6 +183,8 @@ func main() {
 	var diskPath string
 	flag.StringVar(&diskPath, "d", "/user/trainsql_data/", "数据存放位置")
 	port := flag.Int("p", 8888, "服务端口")
+	indexWaitTimeout := flag.Duration("index-wait", 3*time.Second, "创建索引时等待旧事务结束的最长时间")
+	flag.Parse()
 	addr := fmt.Sprintf(":%d", *port)
 	server := NewTCPServer(addr)
 
@@ -187,7 +195,7 @@ func main() {
 	}()
 
 	// 启动服务器
-	if err := server.Start(diskPath); err != nil {
+	if err := server.Start(diskPath, *indexWaitTimeout); err != nil {
 		fmt.Printf("服务器启动失败：%v\n", err)
 		os.Exit(1)
 	}
@@ -222,6 +222,8 @@ func (s *SumCal) Calc(colName string, cols []string, rows []types.Row) (types.Va
 		return nil, util.Error("AggregateExecutor.CountCal: can not find column")
 	}
 	sum := 0.0
+	// DECIMAL 列精确求和, 结果仍为 DECIMAL;
+	var decimalSum types.Value
 	for _, row := range rows {
 		value := row[pos]
 		switch value.(type) {
@@ -230,10 +232,22 @@ func (s *SumCal) Calc(colName string, cols []string, rows []types.Row) (types.Va
 			sum += float64(value.(*types.ConstInt).Value)
 		case *types.ConstFloat:
 			sum += value.(*types.ConstFloat).Value
+		case *types.ConstDecimal:
+			if decimalSum == nil {
+				decimalSum = value
+				continue
+			}
+			var err error
+			if decimalSum, err = types.ArithValue(types.ArithAdd, decimalSum, value); err != nil {
+				return nil, err
+			}
 		default:
 			return nil, util.Error("AggregateExecutor.SumCal: not support value type")
 		}
 	}
+	if decimalSum != nil {
+		return types.ArithValue(types.ArithAdd, decimalSum, &types.ConstFloat{Value: sum})
+	}
 	if sum == 0.0 {
 		return &types.ConstNull{}, nil
 	} else {
@@ -260,6 +274,9 @@ func (a *AvgCal) Calc(colName string, cols []string, rows []types.Row) (types.Va
 			return &types.ConstFloat{Value: s.Value / float64(c.Value)}, nil
 		}
 	}
+	if _, ok := sum.(*types.ConstDecimal); ok {
+		return types.ArithValue(types.ArithDiv, sum, count)
+	}
 	return &types.ConstNull{}, nil
 }
 
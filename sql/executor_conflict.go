@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"slices"
+)
+
+// INSERT ... ON CONFLICT: 写入每一行之前按冲突列查找已有的行;
+// 没有冲突时正常插入; DO NOTHING 跳过这一行; DO UPDATE 按 SET 更新已有的行,
+// 更新通过 Service.UpdateRow 完成, 与 UPDATE 语句一样只维护值发生变化的索引;
+
+// validateOnConflict 写入之前校验 ON CONFLICT 子句: 冲突列必须是主键或唯一索引的列, SET 引用的列必须存在;
+func validateOnConflict(table *types.Table, onConflict *OnConflictData) error {
+	if onConflict == nil || onConflict.Columns == nil {
+		return nil
+	}
+	if !table.IsPrimaryKey(onConflict.Columns) && table.GetUniqueIndex(onConflict.Columns) == nil {
+		return util.Error("[Insert] there is no unique or primary key constraint matching the ON CONFLICT (%s) on table %s",
+			util.Join(onConflict.Columns, ", "), table.Name)
+	}
+	cols := conflictColumns(table)
+	for colName, expr := range onConflict.Update {
+		if table.GetColumnIndex(colName) == -1 {
+			return util.Error("[Insert] table %s column %s not exists", table.Name, colName)
+		}
+		if expr.Field != "" && !slices.Contains(cols, expr.Field) {
+			return util.Error("[Insert] table %s column %s not exists", table.Name, expr.Field)
+		}
+	}
+	return nil
+}
+
+// resolveConflict 处理 row 与已有行的冲突; handled 为 true 时这一行已处理, 调用方不再插入;
+// 已有的行被 DO UPDATE 更新时返回更新后的行; 同一条语句不能两次修改同一行;
+func resolveConflict(s Service, table *types.Table, onConflict *OnConflictData, row types.Row, affected map[string]bool) (handled bool, updated types.Row, err error) {
+	var existing types.Row
+	if onConflict.Columns != nil {
+		existing, err = findConflictRow(s, table, onConflict.Columns, row)
+	} else {
+		// 省略冲突列时检查主键和全部唯一索引;
+		existing, err = findConflictRow(s, table, table.GetPrimaryKeys(), row)
+		indexes := table.GetIndexes()
+		for i := 0; err == nil && existing == nil && i < len(indexes); i++ {
+			if indexes[i].Unique && indexes[i].State == types.IndexPublic {
+				existing, err = findIndexConflictRow(s, table, &indexes[i], row)
+			}
+		}
+	}
+	if err != nil || existing == nil {
+		return false, nil, err
+	}
+	if onConflict.Update == nil {
+		return true, nil, nil
+	}
+	pk := table.GetPrimaryKeyOfValue(existing)
+	if affected[string(types.EncodeKey(pk...))] {
+		return false, nil, util.Error("[Insert] ON CONFLICT DO UPDATE command cannot affect row a second time")
+	}
+	newRow := make(types.Row, len(existing))
+	copy(newRow, existing)
+	// SET 中的列取已有行的值, EXCLUDED.col 取本次要插入的值;
+	cols := conflictColumns(table)
+	values := append(append(types.Row{}, existing...), row...)
+	for colName, expr := range onConflict.Update {
+		value, err := types.EvaluateExpr(expr, cols, values, cols, values)
+		if err != nil {
+			return false, nil, err
+		}
+		column := table.Columns[table.GetColumnIndex(colName)]
+		if value, err = column.CastValue(value); err != nil {
+			return false, nil, err
+		}
+		if value.DateType() != types.Null && value.DateType() != column.DataType {
+			return false, nil, util.Error("[Insert] column %s expects %s, got %s", column.Name,
+				types.GetDataTypeInfo(column.DataType), types.GetDataTypeInfo(value.DateType()))
+		}
+		newRow[table.GetColumnIndex(colName)] = value
+	}
+	if err = checkForeignKeys(s, table, existing, newRow); err != nil {
+		return false, nil, err
+	}
+	if err = updateReferences(s, table, existing, newRow); err != nil {
+		return false, nil, err
+	}
+	if err = s.UpdateRow(table, pk, newRow); err != nil {
+		return false, nil, err
+	}
+	affected[string(types.EncodeKey(table.GetPrimaryKeyOfValue(newRow)...))] = true
+	return true, newRow, nil
+}
+
+// findConflictRow 通过主键或唯一索引查找 columns 上与 row 取值相同的已有行; 冲突列中含有 NULL 时不冲突;
+func findConflictRow(s Service, table *types.Table, columns []string, row types.Row) (types.Row, error) {
+	values := table.GetColumnValues(columns, row)
+	if hasNull(values) {
+		return nil, nil
+	}
+	if table.IsPrimaryKey(columns) {
+		return s.ReadById(table.Name, values)
+	}
+	index := table.GetUniqueIndex(columns)
+	if index == nil {
+		return nil, util.Error("[Insert] table %s has no unique constraint on (%s)", table.Name, util.Join(columns, ", "))
+	}
+	return findIndexConflictRow(s, table, index, row)
+}
+
+// findIndexConflictRow 唯一索引上与 row 取值相同的已有行, 表达式索引项按 row 计算;
+func findIndexConflictRow(s Service, table *types.Table, index *types.Index, row types.Row) (types.Row, error) {
+	values, err := table.GetIndexValues(index, row)
+	if err != nil || hasNull(values) {
+		return nil, err
+	}
+	pks, err := s.ScanIndex(table.Name, index.Name, values, nil)
+	if err != nil || len(pks) == 0 {
+		return nil, err
+	}
+	return s.ReadById(table.Name, pks[0])
+}
+
+// conflictColumns DO UPDATE 中可以引用的列: 表的全部列, 以及 EXCLUDED.col;
+func conflictColumns(table *types.Table) []string {
+	cols := table.GetColumnNames()
+	for _, column := range table.Columns {
+		cols = append(cols, types.ExcludedField(column.Name))
+	}
+	return cols
+}
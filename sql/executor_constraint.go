@@ -0,0 +1,274 @@
+package sql
+
+import (
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+)
+
+// 外键约束的检查与级联动作, 由 Insert/Update/Delete 执行器在当前事务中调用;
+// 级联产生的修改直接调用 Service.UpdateRow/DeleteRow, 不再经过执行器;
+
+// reference 引用了某张表的外键, 以及外键所在的子表;
+type reference struct {
+	child      *types.Table
+	foreignKey *types.ForeignKey
+}
+
+// referencingForeignKeys 查找引用 tableName 的全部外键(包括自引用);
+func referencingForeignKeys(s Service, tableName string) ([]*reference, error) {
+	references := make([]*reference, 0)
+	for _, name := range s.GetTableNames() {
+		child, err := s.GetTable(name)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			continue
+		}
+		for i := range child.ForeignKeys {
+			if child.ForeignKeys[i].RefTable == tableName {
+				references = append(references, &reference{child: child, foreignKey: &child.ForeignKeys[i]})
+			}
+		}
+	}
+	return references, nil
+}
+
+// checkForeignKeys 校验行引用的父行存在; oldRow 不为空时(更新)只校验外键列发生变化的约束;
+// 外键列中含有 NULL 时不校验; 父行存在时登记依赖被引用的值, 并发删除父行的事务与当前事务之一写冲突;
+func checkForeignKeys(s Service, table *types.Table, oldRow types.Row, row types.Row) error {
+	for i := range table.ForeignKeys {
+		foreignKey := &table.ForeignKeys[i]
+		values := table.GetColumnValues(foreignKey.Columns, row)
+		if hasNull(values) {
+			continue
+		}
+		if oldRow != nil && valuesEqual(table.GetColumnValues(foreignKey.Columns, oldRow), values) {
+			continue
+		}
+		// 自引用的行引用自己;
+		if foreignKey.RefTable == table.Name && valuesEqual(table.GetColumnValues(foreignKey.RefColumns, row), values) {
+			continue
+		}
+		parent, err := s.MustGetTable(foreignKey.RefTable)
+		if err != nil {
+			return err
+		}
+		exists, err := existsReferenced(s, parent, foreignKey.RefColumns, values)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return util.Error("[ForeignKey] insert or update on table \"%s\" violates foreign key constraint \"%s\": (%s)=(%s) is not present in table \"%s\"",
+				table.Name, foreignKey.Name, util.Join(foreignKey.Columns, ", "), formatValues(values), parent.Name)
+		}
+		if err = s.HoldReference(parent, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existsReferenced 通过被引用表的主键或唯一索引查找父行;
+func existsReferenced(s Service, parent *types.Table, columns []string, values []types.Value) (bool, error) {
+	if parent.IsPrimaryKey(columns) {
+		row, err := s.ReadById(parent.Name, values)
+		return row != nil, err
+	}
+	index := parent.GetUniqueIndex(columns)
+	if index == nil {
+		return false, util.Error("[ForeignKey] table %s has no unique constraint on (%s)", parent.Name, util.Join(columns, ", "))
+	}
+	pks, err := s.ScanIndex(parent.Name, index.Name, values, nil)
+	return len(pks) > 0, err
+}
+
+// deleteReferences 删除 row 之前, 按 ON DELETE 动作处理引用它的子行;
+// 被引用的值先经 ChangeReference 改写, 与并发插入子行的事务之一写冲突;
+func deleteReferences(s Service, table *types.Table, row types.Row) error {
+	references, err := referencingForeignKeys(s, table.Name)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references {
+		values := table.GetColumnValues(ref.foreignKey.RefColumns, row)
+		if err = changeReference(s, table, values); err != nil {
+			return err
+		}
+		children, err := findReferencingRows(s, ref, table, row, values)
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			continue
+		}
+		switch ref.foreignKey.OnDelete {
+		case types.ActionCascade:
+			// 先删除子行再递归, 循环引用时不会重复处理同一行;
+			for _, childRow := range children {
+				if err = s.DeleteRow(ref.child, ref.child.GetPrimaryKeyOfValue(childRow)); err != nil {
+					return err
+				}
+				if err = deleteReferences(s, ref.child, childRow); err != nil {
+					return err
+				}
+			}
+		case types.ActionSetNull:
+			if err = setReferencingRows(s, ref, children, nil); err != nil {
+				return err
+			}
+		default:
+			return referencedError(table, ref, values)
+		}
+	}
+	return nil
+}
+
+// updateReferences 更新 oldRow 为 newRow 之前, 被引用列发生变化时按 ON UPDATE 动作处理引用它的子行;
+func updateReferences(s Service, table *types.Table, oldRow types.Row, newRow types.Row) error {
+	references, err := referencingForeignKeys(s, table.Name)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references {
+		values := table.GetColumnValues(ref.foreignKey.RefColumns, oldRow)
+		newValues := table.GetColumnValues(ref.foreignKey.RefColumns, newRow)
+		if valuesEqual(values, newValues) {
+			continue
+		}
+		if err = changeReference(s, table, values); err != nil {
+			return err
+		}
+		children, err := findReferencingRows(s, ref, table, oldRow, values)
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			continue
+		}
+		switch ref.foreignKey.OnUpdate {
+		case types.ActionCascade:
+			if err = setReferencingRows(s, ref, children, newValues); err != nil {
+				return err
+			}
+		case types.ActionSetNull:
+			if err = setReferencingRows(s, ref, children, nil); err != nil {
+				return err
+			}
+		default:
+			return referencedError(table, ref, values)
+		}
+	}
+	return nil
+}
+
+// changeReference 被引用的值中含有 NULL 时不会被引用;
+func changeReference(s Service, table *types.Table, values []types.Value) error {
+	if hasNull(values) {
+		return nil
+	}
+	return s.ChangeReference(table, values)
+}
+
+// setReferencingRows 将子行的外键列改为 values, values 为空时置为 NULL;
+func setReferencingRows(s Service, ref *reference, children []types.Row, values []types.Value) error {
+	for _, childRow := range children {
+		newRow := make(types.Row, len(childRow))
+		copy(newRow, childRow)
+		for i, colName := range ref.foreignKey.Columns {
+			if values == nil {
+				newRow[ref.child.GetColumnIndex(colName)] = &types.ConstNull{}
+			} else {
+				newRow[ref.child.GetColumnIndex(colName)] = values[i]
+			}
+		}
+		// 子表的外键列也可能被其他表引用;
+		if err := updateReferences(s, ref.child, childRow, newRow); err != nil {
+			return err
+		}
+		if err := s.UpdateRow(ref.child, ref.child.GetPrimaryKeyOfValue(childRow), newRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findReferencingRows 查找外键值等于 values 的子行, 自引用时排除 row 本身;
+// 子表的主键或索引以外键列开头时按前缀扫描, 否则全表扫描;
+func findReferencingRows(s Service, ref *reference, table *types.Table, row types.Row, values []types.Value) ([]types.Row, error) {
+	if hasNull(values) {
+		return nil, nil
+	}
+	child, columns := ref.child, ref.foreignKey.Columns
+	var candidates []types.Row
+	var err error
+	if hasPrefix(child.GetPrimaryKeys(), columns) {
+		candidates, err = s.ScanPrimaryKey(child.Name, values)
+	} else if index := findPrefixIndex(child, columns); index != nil {
+		var pks [][]types.Value
+		if pks, err = s.ScanIndex(child.Name, index.Name, values, nil); err == nil {
+			for _, pk := range pks {
+				var childRow types.Row
+				if childRow, err = s.ReadById(child.Name, pk); err != nil {
+					break
+				}
+				if childRow != nil {
+					candidates = append(candidates, childRow)
+				}
+			}
+		}
+	} else {
+		candidates, err = s.ScanTable(child.Name, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]types.Row, 0)
+	for _, childRow := range candidates {
+		if !valuesEqual(child.GetColumnValues(columns, childRow), values) {
+			continue
+		}
+		if child.Name == table.Name && valuesEqual(child.GetPrimaryKeyOfValue(childRow), table.GetPrimaryKeyOfValue(row)) {
+			continue
+		}
+		rows = append(rows, childRow)
+	}
+	return rows, nil
+}
+
+// findPrefixIndex 查找以 columns 开头的可用索引;
+func findPrefixIndex(table *types.Table, columns []string) *types.Index {
+	indexes := table.GetIndexes()
+	for i := range indexes {
+		if indexes[i].State == types.IndexPublic && hasPrefix(indexes[i].Columns, columns) {
+			return &indexes[i]
+		}
+	}
+	return nil
+}
+
+func hasPrefix(columns []string, prefix []string) bool {
+	if len(columns) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if columns[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasNull(values []types.Value) bool {
+	for _, value := range values {
+		if value.DateType() == types.Null {
+			return true
+		}
+	}
+	return false
+}
+
+func referencedError(table *types.Table, ref *reference, values []types.Value) error {
+	return util.Error("[ForeignKey] update or delete on table \"%s\" violates foreign key constraint \"%s\" on table \"%s\": (%s)=(%s) is still referenced",
+		table.Name, ref.foreignKey.Name, ref.child.Name, util.Join(ref.foreignKey.RefColumns, ", "), formatValues(values))
+}
@@ -9,6 +9,11 @@ type InsertTableExecutor struct {
 	TableName string
 	Columns   []string
 	Values    [][]*types.Expression
+	Source    Executor // INSERT ... SELECT 的数据来源, 为空时使用 Values;
+	// ON CONFLICT 子句, 为空时冲突直接报错;
+	OnConflict *OnConflictData
+	// RETURNING 子句, 为空时只返回行数;
+	Returning []*SelectCol
 }
 
 func NewInsertTableExecutor(tableName string, columns []string, values [][]*types.Expression) *InsertTableExecutor {
@@ -19,18 +24,54 @@ func NewInsertTableExecutor(tableName string, columns []string, values [][]*type
 	}
 }
 
+// NewInsertSelectExecutor INSERT ... SELECT;
+func NewInsertSelectExecutor(tableName string, columns []string, source Executor) *InsertTableExecutor {
+	return &InsertTableExecutor{
+		TableName: tableName,
+		Columns:   columns,
+		Source:    source,
+	}
+}
+
+// insertValue 计算 VALUES 中的一个值; 除常量外支持 now()、nextval('seq') 等标量函数与运算, VALUES 中没有可以引用的列;
+func insertValue(expression *types.Expression) (types.Value, error) {
+	if expression.ConstVal != nil {
+		return expression.ConstVal, nil
+	}
+	if expression.Function != nil && !types.IsScalarFunction(expression.Function.FuncName) {
+		return nil, util.Error("[Insert] function %s is not allowed in values", expression.Function.FuncName)
+	}
+	return types.EvaluateExpr(expression, nil, nil, nil, nil)
+}
+
+// missingValue 未指定值的列: AUTO_INCREMENT 列取序列的下一个值, 否则取默认值;
+func missingValue(s Service, column types.ColumnV) (types.Value, error) {
+	if column.Sequence != "" {
+		value, err := s.NextVal(column.Sequence)
+		if err != nil {
+			return nil, err
+		}
+		return &types.ConstInt{Value: value}, nil
+	}
+	return column.DefaultValue, nil
+}
+
 // 列对齐自动填充;
 // tbl:
 // insert into tbl values(1, 2, 3);
 // a       b       c      d
 // 1       2       3     无指定值,则 default 填充;
-func padRow(table *types.Table, row types.Row) (types.Row, error) {
+func padRow(s Service, table *types.Table, row types.Row) (types.Row, error) {
 	for id, column := range table.Columns {
 		if id >= len(row) {
-			if column.DefaultValue == nil {
+			value, err := missingValue(s, column)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
 				return nil, util.Error("[padRow] Column %s has no default value;\n", column.Name)
 			} else {
-				row = append(row, column.DefaultValue)
+				row = append(row, value)
 			}
 		}
 	}
@@ -43,7 +84,7 @@ func padRow(table *types.Table, row types.Row) (types.Row, error) {
 //	a          b       c          d
 //
 // default   default   2          1
-func makeRow(table *types.Table, columns []string, row types.Row) (types.Row, error) {
+func makeRow(s Service, table *types.Table, columns []string, row types.Row) (types.Row, error) {
 	// 判断列数是否和value数一致
 	if len(columns) != len(row) {
 		return nil, util.Error("[makeRow] Columns and values count not match;\n")
@@ -55,10 +96,14 @@ func makeRow(table *types.Table, columns []string, row types.Row) (types.Row, er
 	var newRow []types.Value
 	for _, column := range table.Columns {
 		if input[column.Name] == nil {
-			if column.DefaultValue == nil {
+			value, err := missingValue(s, column)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
 				return nil, util.Error("[makeRow] Column %s has no default value;\n", column.Name)
 			} else {
-				input[column.Name] = column.DefaultValue
+				input[column.Name] = value
 				newRow = append(newRow, input[column.Name])
 			}
 		} else {
@@ -77,16 +122,25 @@ func (i *InsertTableExecutor) Execute(s Service) types.ResultSet {
 			ErrorMessage: err.Error(),
 		}
 	}
+	if err = validateOnConflict(mustGetTable, i.OnConflict); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	if err = validateReturning(mustGetTable, i.Returning); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	// 插入或更新后的行, 用于 RETURNING;
+	written := make([]types.Row, 0)
+	rows, err := i.sourceRows(s, mustGetTable)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	// 本条语句插入或更新过的行的主键;
+	affected := make(map[string]bool)
 	// 每一行数据;
-	for _, expressions := range i.Values {
-		var row []types.Value
-		// 每一行的多个列;
-		for _, expression := range expressions {
-			row = append(row, expression.ConstVal)
-		}
+	for _, row := range rows {
 		// 如果没有指定插入的列;
 		if i.Columns == nil {
-			row, err = padRow(mustGetTable, row)
+			row, err = padRow(s, mustGetTable, row)
 			if err != nil {
 				return &types.ErrorResult{
 					ErrorMessage: err.Error(),
@@ -94,28 +148,113 @@ func (i *InsertTableExecutor) Execute(s Service) types.ResultSet {
 			}
 		} else {
 			// 指定了插入的列，需要对 value 信息进行整理
-			row, err = makeRow(mustGetTable, i.Columns, row)
+			row, err = makeRow(s, mustGetTable, i.Columns, row)
 			if err != nil {
 				return &types.ErrorResult{
 					ErrorMessage: err.Error(),
 				}
 			}
 		}
+		// 先按列的类型转换, 冲突检测与外键检查使用与写入相同的值;
+		if err = mustGetTable.CastValues(row); err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
+		if i.OnConflict != nil {
+			handled, updated, err := resolveConflict(s, mustGetTable, i.OnConflict, row, affected)
+			if err != nil {
+				return &types.ErrorResult{ErrorMessage: err.Error()}
+			}
+			if updated != nil {
+				written = append(written, updated)
+				count++
+			}
+			if handled {
+				continue
+			}
+		}
+		if err = checkForeignKeys(s, mustGetTable, nil, row); err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
 		err = s.CreateRow(i.TableName, row)
 		if err != nil {
 			return &types.ErrorResult{ErrorMessage: err.Error()}
 		}
+		affected[string(types.EncodeKey(mustGetTable.GetPrimaryKeyOfValue(row)...))] = true
+		written = append(written, row)
 		count++
 	}
+	if i.Returning != nil {
+		return returningResult(mustGetTable, written, i.Returning)
+	}
 	return &types.InsertTableResult{
 		Count: count,
 	}
 }
 
+// sourceRows 取出待插入的全部行;
+// INSERT ... SELECT 先执行完查询再写入, 查询不会读到本条语句新插入的行(Halloween problem);
+// 写入前按目标表的列逐一校验类型, 不匹配时一行都不写入;
+func (i *InsertTableExecutor) sourceRows(s Service, table *types.Table) ([]types.Row, error) {
+	if i.Source == nil {
+		rows := make([]types.Row, 0, len(i.Values))
+		for _, expressions := range i.Values {
+			var row types.Row
+			// 每一行的多个列;
+			for _, expression := range expressions {
+				value, err := insertValue(expression)
+				if err != nil {
+					return nil, err
+				}
+				row = append(row, value)
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+	result := i.Source.Execute(s)
+	scanResult, ok := result.(*types.ScanTableResult)
+	if !ok {
+		if errorResult, ok := result.(*types.ErrorResult); ok {
+			return nil, util.Error("%s", errorResult.ErrorMessage)
+		}
+		return nil, util.Error("[Insert] unsupported result type: %T", result)
+	}
+	columns := i.Columns
+	if columns == nil {
+		columns = make([]string, 0, len(table.Columns))
+		for _, column := range table.Columns {
+			columns = append(columns, column.Name)
+		}
+	}
+	if len(scanResult.Columns) > len(columns) || (i.Columns != nil && len(scanResult.Columns) != len(columns)) {
+		return nil, util.Error("[Insert] select returns %d columns, table %s expects %d", len(scanResult.Columns), table.Name, len(columns))
+	}
+	for _, row := range scanResult.Rows {
+		for j, value := range row {
+			pos := table.GetColumnIndex(columns[j])
+			if pos == -1 {
+				return nil, util.Error("[Insert] table %s column %s not exists", table.Name, columns[j])
+			}
+			column := table.Columns[pos]
+			value, err := column.CastValue(value)
+			if err != nil {
+				return nil, err
+			}
+			row[j] = value
+			if value.DateType() != types.Null && value.DateType() != column.DataType {
+				return nil, util.Error("[Insert] column %s expects %s, got %s", column.Name,
+					types.GetDataTypeInfo(column.DataType), types.GetDataTypeInfo(value.DateType()))
+			}
+		}
+	}
+	return scanResult.Rows, nil
+}
+
 type UpdateTableExecutor struct {
 	TableName string
 	Source    Executor
 	columns   map[string]*types.Expression
+	Returning []*SelectCol // RETURNING 子句, 返回更新后的行;
 }
 
 func NewUpdateTableExecutor(tableName string, source Executor, columns map[string]*types.Expression) *UpdateTableExecutor {
@@ -137,28 +276,47 @@ func (u *UpdateTableExecutor) Execute(s Service) types.ResultSet {
 				ErrorMessage: err.Error(),
 			}
 		}
+		if err = validateReturning(table, u.Returning); err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
 		selectTableResult := result.(*types.ScanTableResult)
 		// 遍历所有需要更新的行;
 		for _, row := range selectTableResult.Rows {
 			// update user set name='kk' where id = 1; // 可能存在多行需要更新;
 			pKValue := table.GetPrimaryKeyOfValue(row)
+			oldRow := make(types.Row, len(row))
+			copy(oldRow, row)
 			// 不清楚要具体更新哪些列,因此需要全部判断;
 			for i, column := range selectTableResult.Columns {
 				if expr, ok := u.columns[column]; ok {
-					// 只更新特定列的值;
-					row[i] = expr.ConstVal
+					// 只更新特定列的值; SET 中引用的列取更新前的值;
+					value, err := types.EvaluateExpr(expr, selectTableResult.Columns, oldRow, selectTableResult.Columns, oldRow)
+					if err != nil {
+						return &types.ErrorResult{ErrorMessage: err.Error()}
+					}
+					row[i] = value
 				}
 			}
 			// 执行更新操作;
 			// 1.如果有主键更新: 删除原来的数据, 新增一条新的数据;
 			// 2.否则就 table_name + primary key => 更新数据;
 			// 所有行的存储结构是: tableName_primaryKey_
+			// 3.外键: 新值引用的父行必须存在; 被引用的列变化时按 ON UPDATE 处理子行;
+			if err = checkForeignKeys(s, table, oldRow, row); err != nil {
+				return &types.ErrorResult{ErrorMessage: err.Error()}
+			}
+			if err = updateReferences(s, table, oldRow, row); err != nil {
+				return &types.ErrorResult{ErrorMessage: err.Error()}
+			}
 			err = s.UpdateRow(table, pKValue, row)
 			if err != nil {
 				return &types.ErrorResult{ErrorMessage: err.Error()}
 			}
 			update++
 		}
+		if u.Returning != nil {
+			return returningResult(table, selectTableResult.Rows, u.Returning)
+		}
 	default:
 		return &types.ErrorResult{
 			ErrorMessage: util.Error("#UpdateTableExecutor Unsupported result type: %T\n", result).Error(),
@@ -172,6 +330,7 @@ func (u *UpdateTableExecutor) Execute(s Service) types.ResultSet {
 type DeleteTableExecutor struct {
 	TableName string
 	Source    Executor
+	Returning []*SelectCol // RETURNING 子句, 返回删除前的行;
 }
 
 func NewDeleteTableExecutor(tableName string, source Executor) *DeleteTableExecutor {
@@ -193,17 +352,27 @@ func (d *DeleteTableExecutor) Execute(s Service) types.ResultSet {
 				ErrorMessage: err.Error(),
 			}
 		}
+		if err = validateReturning(table, d.Returning); err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
 		selectTableResult := result.(*types.ScanTableResult)
 		// 遍历所有需要更新的行;
 		for _, row := range selectTableResult.Rows {
 			// update user set name='kk' where id = 1; // 可能存在多行需要更新;
 			pKValue := table.GetPrimaryKeyOfValue(row)
+			// 按 ON DELETE 处理引用此行的子行;
+			if err = deleteReferences(s, table, row); err != nil {
+				return &types.ErrorResult{ErrorMessage: err.Error()}
+			}
 			err = s.DeleteRow(table, pKValue)
 			if err != nil {
 				return &types.ErrorResult{ErrorMessage: err.Error()}
 			}
 			count++
 		}
+		if d.Returning != nil {
+			return returningResult(table, selectTableResult.Rows, d.Returning)
+		}
 	default:
 		return &types.ErrorResult{
 			ErrorMessage: util.Error("[UpdateTableExecutor] Unsupported result type: %T\n", result).Error(),
@@ -213,3 +382,33 @@ func (d *DeleteTableExecutor) Execute(s Service) types.ResultSet {
 		Count: count,
 	}
 }
+
+// validateReturning 写入之前校验 RETURNING 子句: 表达式只能引用表中的列, 只能调用标量函数;
+func validateReturning(table *types.Table, exprs []*SelectCol) error {
+	for _, selectCol := range exprs {
+		for _, field := range types.ExpressionFields(selectCol.Expr) {
+			if table.GetColumnIndex(field) == -1 {
+				return util.Error("[Returning] table %s column %s not exists", table.Name, field)
+			}
+		}
+		for _, funcName := range types.ExpressionFunctions(selectCol.Expr) {
+			if !types.IsScalarFunction(funcName) {
+				return util.Error("[Returning] function %s is not allowed in RETURNING", funcName)
+			}
+		}
+	}
+	return nil
+}
+
+// returningResult 按 RETURNING 子句对写入的行逐行计算, 与 SELECT 的投影相同, 别名作为列名; RETURNING * 返回全部列;
+func returningResult(table *types.Table, rows []types.Row, exprs []*SelectCol) types.ResultSet {
+	if len(exprs) == 0 {
+		return &types.ScanTableResult{TableName: table.Name, Columns: table.GetColumnNames(), Rows: rows}
+	}
+	result, err := projectRows(table.GetColumnNames(), rows, exprs)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	result.TableName = table.Name
+	return result
+}
@@ -40,15 +40,17 @@ func (scan *ScanTableExecutor) Execute(s Service) types.ResultSet {
 
 type IndexScanTableExecutor struct {
 	TableName string
-	Filed     string
-	Value     types.Value
+	IndexName string
+	Values    []types.Value
+	Range     *types.IndexRange
 }
 
-func NewIndexScanExecutor(tableName string, filed string, value types.Value) *IndexScanTableExecutor {
+func NewIndexScanExecutor(tableName string, indexName string, values []types.Value, indexRange *types.IndexRange) *IndexScanTableExecutor {
 	return &IndexScanTableExecutor{
 		TableName: tableName,
-		Filed:     filed,
-		Value:     value,
+		IndexName: indexName,
+		Values:    values,
+		Range:     indexRange,
 	}
 }
 func (scan *IndexScanTableExecutor) Execute(s Service) types.ResultSet {
@@ -60,22 +62,14 @@ func (scan *IndexScanTableExecutor) Execute(s Service) types.ResultSet {
 	for _, column := range table.Columns {
 		columnNames = append(columnNames, column.Name)
 	}
-	loadIndex, err := s.LoadIndex(table.Name, scan.Filed, scan.Value)
+	// 主键按索引顺序返回;
+	pks, err := s.ScanIndex(table.Name, scan.IndexName, scan.Values, scan.Range)
 	if err != nil {
 		return &types.ErrorResult{ErrorMessage: fmt.Sprintf("#IndexScanTableExecutor.Execute error: %s", err.Error())}
 	}
-	sort.Slice(loadIndex, func(i, j int) bool {
-		if ok, cmp := loadIndex[i].PartialCmp(loadIndex[j]); ok {
-			// true:  表示索引 i 的元素应该排在索引 j 的元素之前
-			// false: 表示索引 i 的元素应该排在索引 j 的元素之后
-			// return numbers[i] < numbers[j]  // i < j 返回 true，表示 i 应该在前;
-			return cmp == 1
-		}
-		return false
-	})
 	rows := make([]types.Row, 0)
-	for _, index := range loadIndex {
-		if row, err := s.ReadById(scan.TableName, index); row != nil {
+	for _, pk := range pks {
+		if row, err := s.ReadById(scan.TableName, pk); row != nil {
 			rows = append(rows, row)
 		} else if err != nil {
 			return &types.ErrorResult{ErrorMessage: fmt.Sprintf("#IndexScanTableExecutor.Execute error: %s", err.Error())}
@@ -89,13 +83,13 @@ func (scan *IndexScanTableExecutor) Execute(s Service) types.ResultSet {
 
 type PrimaryKeyScanExecutor struct {
 	TableName string
-	Value     types.Value
+	Values    []types.Value
 }
 
-func NewPrimaryKeyScanExecutor(tableName string, value types.Value) *PrimaryKeyScanExecutor {
+func NewPrimaryKeyScanExecutor(tableName string, values []types.Value) *PrimaryKeyScanExecutor {
 	return &PrimaryKeyScanExecutor{
 		TableName: tableName,
-		Value:     value,
+		Values:    values,
 	}
 }
 func (scan *PrimaryKeyScanExecutor) Execute(s Service) types.ResultSet {
@@ -108,17 +102,19 @@ func (scan *PrimaryKeyScanExecutor) Execute(s Service) types.ResultSet {
 	for _, column := range table.Columns {
 		columnNames = append(columnNames, column.Name)
 	}
-	value := scan.Value
-	if v, ok := value.(*types.ConstFloat); ok {
-		value = &types.ConstInt{
-			Value: int64(v.Value),
-		}
-	}
 	rows := make([]types.Row, 0)
-	if row, err := s.ReadById(scan.TableName, value); row != nil {
-		rows = append(rows, row)
-	} else if err != nil {
-		return &types.ErrorResult{ErrorMessage: fmt.Sprintf("#PrimaryKeyScanExecutor.Execute error: %s", err.Error())}
+	if len(scan.Values) == len(table.GetPrimaryKeys()) {
+		// 完整主键: 点查;
+		if row, err := s.ReadById(scan.TableName, scan.Values); row != nil {
+			rows = append(rows, row)
+		} else if err != nil {
+			return &types.ErrorResult{ErrorMessage: fmt.Sprintf("#PrimaryKeyScanExecutor.Execute error: %s", err.Error())}
+		}
+	} else {
+		// 主键前缀: 范围扫描;
+		if rows, err = s.ScanPrimaryKey(scan.TableName, scan.Values); err != nil {
+			return &types.ErrorResult{ErrorMessage: fmt.Sprintf("#PrimaryKeyScanExecutor.Execute error: %s", err.Error())}
+		}
 	}
 	return &types.ScanTableResult{
 		Columns: columnNames,
@@ -179,43 +175,69 @@ func NewProjectExecutor(source Executor, exprs []*SelectCol) *ProjectExecutor {
 func (project *ProjectExecutor) Execute(s Service) types.ResultSet {
 	resultSet := project.Source.Execute(s)
 	if set, ok := resultSet.(*types.ScanTableResult); ok {
-		selected := make([]int, 0)
-		newColumnNanes := make([]string, 0)
-		for _, selectCol := range project.Exprs {
-			alias := selectCol.Alis
-			expression := selectCol.Expr
-			if expression.Field != "" {
-				for index, column := range set.Columns {
-					if column == expression.Field {
-						selected = append(selected, index)
-						if alias == "" {
-							alias = column
-						}
-						newColumnNanes = append(newColumnNanes, alias)
+		result, err := projectRows(set.Columns, set.Rows, project.Exprs)
+		if err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
+		return result
+	}
+	return &types.ErrorResult{
+		ErrorMessage: util.Error("ProjectExecutor.Execute error resultSet type").Error(),
+	}
+}
+
+// projectRows 按 exprs 从 rows 中选取列或计算表达式, 列名优先使用别名, 表达式没有别名时使用表达式本身; SELECT 与 RETURNING 共用;
+func projectRows(columns []string, rows []types.Row, exprs []*SelectCol) (*types.ScanTableResult, error) {
+	selected := make([]int, 0)
+	computed := make(map[int]*types.Expression) // selected 中为 -1 的位置需要逐行计算的表达式;
+	newColumnNanes := make([]string, 0)
+	for _, selectCol := range exprs {
+		alias := selectCol.Alis
+		expression := selectCol.Expr
+		if expression.Field != "" {
+			for index, column := range columns {
+				if column == expression.Field {
+					selected = append(selected, index)
+					if alias == "" {
+						alias = column
 					}
+					newColumnNanes = append(newColumnNanes, alias)
 				}
 			}
+		} else {
+			computed[len(selected)] = expression
+			selected = append(selected, -1)
+			if alias == "" {
+				alias = expression.ToString()
+			}
+			newColumnNanes = append(newColumnNanes, alias)
 		}
-		newRows := make([]types.Row, 0)
-		for _, row := range set.Rows {
-			newRowColumns := make([]types.Value, 0)
-			for _, i2 := range selected {
+	}
+	newRows := make([]types.Row, 0)
+	for _, row := range rows {
+		newRowColumns := make([]types.Value, 0)
+		for i, i2 := range selected {
+			if i2 != -1 {
 				newRowColumns = append(newRowColumns, row[i2])
+				continue
 			}
-			newRows = append(newRows, newRowColumns)
-		}
-		return &types.ScanTableResult{
-			Columns: newColumnNanes,
-			Rows:    newRows,
+			value, err := types.EvaluateExpr(computed[i], columns, row, columns, row)
+			if err != nil {
+				return nil, err
+			}
+			newRowColumns = append(newRowColumns, value)
 		}
+		newRows = append(newRows, newRowColumns)
 	}
-	return &types.ErrorResult{
-		ErrorMessage: util.Error("ProjectExecutor.Execute error resultSet type").Error(),
-	}
+	return &types.ScanTableResult{
+		Columns: newColumnNanes,
+		Rows:    newRows,
+	}, nil
 }
 
 type OrderDirection struct {
 	colName   string
+	expr      *types.Expression // 按表达式排序时不为 nil, colName 为表达式的文本;
 	direction OrderType
 }
 
@@ -233,42 +255,35 @@ func NewOrderExecutor(source Executor, orderBy []*OrderDirection) *OrderExecutor
 func (order *OrderExecutor) Execute(s Service) types.ResultSet {
 	resultSet := order.Source.Execute(s)
 	if set, ok := resultSet.(*types.ScanTableResult); ok {
-		// 找到 order by 的列对应表中的列的位置;
-		orderColIndex := make(map[string]int)
+		// 先算出每一行参与排序的值: 结果中的列直接取值, 表达式逐行计算;
+		keys := make([][]types.Value, len(set.Rows))
+		for i := range keys {
+			keys[i] = make([]types.Value, 0, len(order.OrderBy))
+		}
 		for _, orderDirection := range order.OrderBy {
+			colIndex := -1
 			for index, column := range set.Columns {
 				if column == orderDirection.colName {
-					orderColIndex[column] = index
+					colIndex = index
 				}
 			}
-			if len(orderColIndex) == 0 {
+			if colIndex == -1 && orderDirection.expr == nil {
 				return &types.ErrorResult{ErrorMessage: "#OrderExecutor.Execute error column name"}
 			}
-		}
-		// 多个行(容器)参与比较;
-		sort.Slice(set.Rows, func(i, j int) bool {
-			// select a,b from user order by c,d desc e asc;
-			// 迭代 order_by 参数, 可能存在多个 desc asc 列值;
-			for _, orderDirection := range order.OrderBy {
-				// 每一行的固定列值来参与 排序;
-				iValue := set.Rows[i][orderColIndex[orderDirection.colName]]
-				jValue := set.Rows[j][orderColIndex[orderDirection.colName]]
-				allow, cmp := iValue.PartialCmp(jValue)
-				if !allow {
+			for i, row := range set.Rows {
+				if colIndex != -1 {
+					keys[i] = append(keys[i], row[colIndex])
 					continue
 				}
-				if cmp == 0 {
-					continue // 判断下一个比较条件;
-				}
-				if orderDirection.direction == OrderAsc {
-					return cmp < 0
-				} else {
-					return cmp > 0
+				value, err := types.EvaluateExpr(orderDirection.expr, set.Columns, row, set.Columns, row)
+				if err != nil {
+					return &types.ErrorResult{ErrorMessage: err.Error()}
 				}
+				keys[i] = append(keys[i], value)
 			}
-			// 比较完毕, 默认返回 true, 不改动位置;
-			return true
-		})
+		}
+		// 多个行(容器)参与比较; 行与排序值一起交换位置;
+		sort.Stable(&orderRows{rows: set.Rows, keys: keys, orderBy: order.OrderBy})
 
 		return &types.ScanTableResult{
 			Columns: set.Columns,
@@ -278,6 +293,42 @@ func (order *OrderExecutor) Execute(s Service) types.ResultSet {
 	return &types.ErrorResult{ErrorMessage: "#OrderExecutor.Execute error resultSet type"}
 }
 
+// orderRows 按预先算出的排序值对行排序;
+type orderRows struct {
+	rows    []types.Row
+	keys    [][]types.Value
+	orderBy []*OrderDirection
+}
+
+func (o *orderRows) Len() int {
+	return len(o.rows)
+}
+
+func (o *orderRows) Swap(i, j int) {
+	o.rows[i], o.rows[j] = o.rows[j], o.rows[i]
+	o.keys[i], o.keys[j] = o.keys[j], o.keys[i]
+}
+
+func (o *orderRows) Less(i, j int) bool {
+	// select a,b from user order by c,d desc e asc;
+	// 迭代 order_by 参数, 可能存在多个 desc asc 列值;
+	for k, orderDirection := range o.orderBy {
+		allow, cmp := o.keys[i][k].PartialCmp(o.keys[j][k])
+		if !allow {
+			continue
+		}
+		if cmp == 0 {
+			continue // 判断下一个比较条件;
+		}
+		if orderDirection.direction == OrderAsc {
+			return cmp < 0
+		} else {
+			return cmp > 0
+		}
+	}
+	return false
+}
+
 type LimitExecutor struct {
 	Source Executor
 	Limit  int
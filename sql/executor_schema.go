@@ -2,6 +2,7 @@ package sql
 
 import (
 	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
 )
 
 type CreatTableExecutor struct {
@@ -24,18 +25,258 @@ func (c *CreatTableExecutor) Execute(s Service) types.ResultSet {
 
 type DropTableExecutor struct {
 	TableName string
+	Cascade   bool
 }
 
-func NewDropTableExecutor(tableName string) *DropTableExecutor {
+func NewDropTableExecutor(tableName string, cascade bool) *DropTableExecutor {
 	return &DropTableExecutor{
 		TableName: tableName,
+		Cascade:   cascade,
 	}
 }
 func (d *DropTableExecutor) Execute(s Service) types.ResultSet {
 	tableName := d.TableName
-	err := s.DropTable(tableName)
+	err := s.DropTable(tableName, d.Cascade)
 	if err != nil {
 		return &types.ErrorResult{ErrorMessage: err.Error()}
 	}
 	return &types.DropTableResult{TableName: tableName}
 }
+
+type TruncateTableExecutor struct {
+	TableName string
+}
+
+func NewTruncateTableExecutor(tableName string) *TruncateTableExecutor {
+	return &TruncateTableExecutor{
+		TableName: tableName,
+	}
+}
+func (t *TruncateTableExecutor) Execute(s Service) types.ResultSet {
+	if err := s.TruncateTable(t.TableName); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.TruncateTableResult{TableName: t.TableName}
+}
+
+type CreateIndexExecutor struct {
+	TableName string
+	Index     *types.Index
+}
+
+func NewCreateIndexExecutor(tableName string, index *types.Index) *CreateIndexExecutor {
+	return &CreateIndexExecutor{
+		TableName: tableName,
+		Index:     index,
+	}
+}
+
+// Execute 在显式事务中创建索引: 登记、回填、发布都在同一个事务中完成;
+// 回填只能看到事务的快照, 因此先与并发写入行的事务互斥, 避免漏掉它们写入的行;
+// 自动提交模式下由 ServerManager.CreateIndex 分多个事务在线构建;
+func (c *CreateIndexExecutor) Execute(s Service) types.ResultSet {
+	if err := s.LockTable(c.TableName); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	c.Index.State = types.IndexWriteOnly
+	err := s.CreateIndex(c.TableName, c.Index)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	// limit 为 0 时一次回填全部行;
+	if _, err = s.BackfillIndex(c.TableName, c.Index.Name, nil, 0); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	if err = s.SetIndexState(c.TableName, c.Index.Name, types.IndexPublic); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.CreateIndexResult{IndexName: c.Index.Name}
+}
+
+type DropIndexExecutor struct {
+	IndexName string
+}
+
+func NewDropIndexExecutor(indexName string) *DropIndexExecutor {
+	return &DropIndexExecutor{
+		IndexName: indexName,
+	}
+}
+func (d *DropIndexExecutor) Execute(s Service) types.ResultSet {
+	err := s.DropIndex(d.IndexName)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.DropIndexResult{IndexName: d.IndexName}
+}
+
+type AlterTableExecutor struct {
+	Node *AlterTableNode
+}
+
+func NewAlterTableExecutor(node *AlterTableNode) *AlterTableExecutor {
+	return &AlterTableExecutor{
+		Node: node,
+	}
+}
+func (a *AlterTableExecutor) Execute(s Service) types.ResultSet {
+	node := a.Node
+	var err error
+	switch node.Action {
+	case AlterAddColumn:
+		err = s.AddColumn(node.TableName, node.Definition)
+	case AlterDropColumn:
+		err = s.DropColumn(node.TableName, node.ColumnName)
+	case AlterRenameColumn:
+		err = s.RenameColumn(node.TableName, node.ColumnName, node.NewName)
+	case AlterRenameTable:
+		err = s.RenameTable(node.TableName, node.NewName)
+	case AlterSetDefault:
+		err = s.SetColumnDefault(node.TableName, node.ColumnName, node.Default)
+	case AlterDropDefault:
+		err = s.SetColumnDefault(node.TableName, node.ColumnName, nil)
+	case AlterSetNotNull:
+		err = s.SetColumnNotNull(node.TableName, node.ColumnName, true)
+	case AlterDropNotNull:
+		err = s.SetColumnNotNull(node.TableName, node.ColumnName, false)
+	}
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	tableName := node.TableName
+	if node.Action == AlterRenameTable {
+		tableName = node.NewName
+	}
+	return &types.AlterTableResult{TableName: tableName}
+}
+
+type CreateSequenceExecutor struct {
+	Sequence *types.Sequence
+}
+
+func NewCreateSequenceExecutor(sequence *types.Sequence) *CreateSequenceExecutor {
+	return &CreateSequenceExecutor{
+		Sequence: sequence,
+	}
+}
+func (c *CreateSequenceExecutor) Execute(s Service) types.ResultSet {
+	err := s.CreateSequence(c.Sequence)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.CreateSequenceResult{SequenceName: c.Sequence.Name}
+}
+
+type DropSequenceExecutor struct {
+	SequenceName string
+}
+
+func NewDropSequenceExecutor(sequenceName string) *DropSequenceExecutor {
+	return &DropSequenceExecutor{
+		SequenceName: sequenceName,
+	}
+}
+func (d *DropSequenceExecutor) Execute(s Service) types.ResultSet {
+	err := s.DropSequence(d.SequenceName)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.DropSequenceResult{SequenceName: d.SequenceName}
+}
+
+type CreateViewExecutor struct {
+	View    *types.View
+	Replace bool
+}
+
+func NewCreateViewExecutor(view *types.View, replace bool) *CreateViewExecutor {
+	return &CreateViewExecutor{
+		View:    view,
+		Replace: replace,
+	}
+}
+func (c *CreateViewExecutor) Execute(s Service) types.ResultSet {
+	// 物化视图创建时即计算一次结果, 查询出错时不保存视图;
+	var set *types.ScanTableResult
+	var columns []types.ColumnV
+	var err error
+	if c.View.Materialized {
+		if set, columns, err = queryView(s, c.View); err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
+	}
+	if err = s.CreateView(c.View, c.Replace); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	if set != nil {
+		if err = s.MaterializeView(c.View.Name, columns, set.Rows); err != nil {
+			return &types.ErrorResult{ErrorMessage: err.Error()}
+		}
+	}
+	return &types.CreateViewResult{ViewName: c.View.Name}
+}
+
+type DropViewExecutor struct {
+	ViewName string
+	Cascade  bool
+}
+
+func NewDropViewExecutor(viewName string, cascade bool) *DropViewExecutor {
+	return &DropViewExecutor{
+		ViewName: viewName,
+		Cascade:  cascade,
+	}
+}
+func (d *DropViewExecutor) Execute(s Service) types.ResultSet {
+	err := s.DropView(d.ViewName, d.Cascade)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.DropViewResult{ViewName: d.ViewName}
+}
+
+type RefreshViewExecutor struct {
+	ViewName string
+}
+
+func NewRefreshViewExecutor(viewName string) *RefreshViewExecutor {
+	return &RefreshViewExecutor{
+		ViewName: viewName,
+	}
+}
+func (r *RefreshViewExecutor) Execute(s Service) types.ResultSet {
+	view, err := s.GetView(r.ViewName)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	if view == nil || !view.Materialized {
+		return &types.ErrorResult{ErrorMessage: util.Error("#RefreshView materialized view %s not exists", r.ViewName).Error()}
+	}
+	set, columns, err := queryView(s, view)
+	if err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	if err = s.MaterializeView(view.Name, columns, set.Rows); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.RefreshViewResult{ViewName: r.ViewName}
+}
+
+// queryView 在当前事务中执行视图保存的查询, 同时返回由查询计划推断的输出列; 物化视图同样重新计算, 而不是读取隐藏表;
+func queryView(s Service, view *types.View) (*types.ScanTableResult, []types.ColumnV, error) {
+	plan := NewPlan(nil, s)
+	node, err := plan.buildView(&types.View{Name: view.Name, Query: view.Query}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, err := plan.outputColumns(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	resultSet := plan.BuildExecutor(node).Execute(s)
+	set, ok := resultSet.(*types.ScanTableResult)
+	if !ok {
+		return nil, nil, util.Error("#queryView view %s: %s", view.Name, resultSet.ToString())
+	}
+	return set, columns, nil
+}
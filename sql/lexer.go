@@ -6,6 +6,7 @@ import (
 	"errors"
 	"github.com/kebukeYi/TrainSQL/sql/util"
 	"io"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -15,16 +16,26 @@ type Lexer struct {
 	keyWords   map[string]*Token // 关键词字典
 	readOffset int
 	tokenStack []*Token
+	input      string          // 原始 sql, 视图按原文保存查询语句;
+	source     *strings.Reader // reader 的数据源, 用于计算已读取的位置;
 }
 
 func NewLexer(sql string) *Lexer {
+	source := strings.NewReader(sql)
 	return &Lexer{
-		reader:     bufio.NewReader(strings.NewReader(sql)),
+		reader:     bufio.NewReader(source),
 		keyWords:   InitWord(),
 		tokenStack: []*Token{},
 		readOffset: 0,
+		input:      sql,
+		source:     source,
 	}
 }
+
+// rest 返回尚未解析的原始输入; 有回退的 token 时这些 token 已被读出, 不包含在内;
+func (le *Lexer) rest() string {
+	return le.input[len(le.input)-le.source.Len()-le.reader.Buffered():]
+}
 func (le *Lexer) peek(n int) ([]byte, error) {
 	if readCh, err := le.reader.Peek(n); err != nil {
 		// 如果是读到末尾了, 那么就直接正常结束;
@@ -148,6 +159,8 @@ func (le *Lexer) Scan() (*Token, error) {
 	} else {
 		if unicode.IsDigit(rune(peek[0])) {
 			token, err = le.scanNumber()
+		} else if prefix, _ := le.peek(2); len(prefix) == 2 && (prefix[0] == 'x' || prefix[0] == 'X') && prefix[1] == '\'' {
+			token, err = le.scanHexString()
 		} else if unicode.IsLetter(rune(peek[0])) {
 			token, err = le.scanIdent()
 		} else if peek[0] == '"' || peek[0] == '\'' {
@@ -188,6 +201,8 @@ func (le *Lexer) scanString() (*Token, error) {
 		return nil, nil
 	}
 	isOver = !isOver
+	// 字符串以起始的引号结束, 其中可以包含另一种引号: '{"size": 1}';
+	quote := nextIf[0]
 	var result []byte
 	var ch []byte
 	for {
@@ -197,9 +212,15 @@ func (le *Lexer) scanString() (*Token, error) {
 			}
 			break
 		}
-		if ch[0] == '"' || ch[0] == '\'' {
+		if ch[0] == quote {
 			isOver = !isOver
 			break
+		} else if ch[0] == '\\' {
+			escaped, err := le.scanEscape()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, escaped)
 		} else {
 			result = append(result, ch...)
 		}
@@ -209,6 +230,58 @@ func (le *Lexer) scanString() (*Token, error) {
 	}
 	return &Token{Type: STRING, Value: TokenValue(result)}, nil
 }
+
+// scanEscape 反斜杠之后的转义字符: \n \t \r \0 \\ \' \" 与 \xHH, 其他字符原样保留;
+func (le *Lexer) scanEscape() (byte, error) {
+	ch, _ := le.readCh()
+	if ch == nil {
+		return 0, util.Error("#scanString Mismatch ' ")
+	}
+	switch ch[0] {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '0':
+		return 0x00, nil
+	case 'x':
+		digits := make([]byte, 0, 2)
+		for len(digits) < 2 {
+			if digit, _ := le.readCh(); digit != nil && isHexDigit(digit[0]) {
+				digits = append(digits, digit[0])
+			} else {
+				return 0, util.Error("#scanEscape invalid escape \\x%s", digits)
+			}
+		}
+		value, _ := strconv.ParseUint(string(digits), 16, 8)
+		return byte(value), nil
+	default:
+		return ch[0], nil
+	}
+}
+
+// scanHexString 十六进制字面量 X'ABCD', 引号内只能是十六进制字符, 个数为偶数;
+func (le *Lexer) scanHexString() (*Token, error) {
+	le.readCh()
+	le.readCh()
+	digits, _ := le.nextWhile(func(r byte) bool {
+		return isHexDigit(r)
+	})
+	if end, _ := le.readCh(); end == nil || end[0] != '\'' {
+		return nil, util.Error("#scanHexString invalid hexadecimal literal X'%s", digits)
+	}
+	if len(digits)%2 != 0 {
+		return nil, util.Error("#scanHexString odd number of digits in X'%s'", digits)
+	}
+	return &Token{Type: HEXSTRING, Value: TokenValue(digits)}, nil
+}
+
+func isHexDigit(r byte) bool {
+	return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
 func (le *Lexer) scanNumber() (*Token, error) {
 	// 数字可能包含小数点,或者只有一个数字,或者只是一个整数;
 	// 1.先扫描出前面一部分数字;
@@ -299,13 +372,38 @@ func (le *Lexer) scanSymbol() (*Token, error) {
 		case '<':
 			token.Type = LESSTHAN
 			token.Value = LessThan
+		case '.':
+			token.Type = PERIOD
+			token.Value = Period
 		default:
 			return nil
 		}
 		return token
 	}
 	token, err := le.nextIfToken(fc)
-	return token, err
+	if err != nil || token == nil {
+		return token, err
+	}
+	// >= 和 <= 由两个字符组成;
+	if token.Type == GREATERTHAN || token.Type == LESSTHAN {
+		if eq, _ := le.nextIf(func(r byte) bool { return r == '=' }); eq != nil {
+			if token.Type == GREATERTHAN {
+				token.Type, token.Value = GREATEREQUAL, GreaterEq
+			} else {
+				token.Type, token.Value = LESSEQUAL, LessEq
+			}
+		}
+	}
+	// -> 和 ->> 用于 JSON 取值;
+	if token.Type == MINUS {
+		if gt, _ := le.nextIf(func(r byte) bool { return r == '>' }); gt != nil {
+			token.Type, token.Value = ARROW, Arrow
+			if gt, _ = le.nextIf(func(r byte) bool { return r == '>' }); gt != nil {
+				token.Type, token.Value = LONGARROW, LongArrow
+			}
+		}
+	}
+	return token, nil
 }
 
 // 检查单个字符是否为字母或数字;
@@ -95,3 +95,51 @@ func TestScanIdent(t *testing.T) {
 		fmt.Println(token.ToString())
 	}
 }
+
+func TestScanEscapeAndHex(t *testing.T) {
+	// 字符串中的转义字符, 以及 X'..' 十六进制字面量; x 开头的标识符不受影响;
+	lexer := NewLexer(`'a\'b\x00\n\\' X'DEadBE' x'' xval;`)
+	expects := []*Token{
+		{Type: STRING, Value: TokenValue("a'b\x00\n\\")},
+		{Type: HEXSTRING, Value: "DEadBE"},
+		{Type: HEXSTRING, Value: ""},
+		{Type: IDENT, Value: "xval"},
+	}
+	for _, expect := range expects {
+		token, err := lexer.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token == nil || !token.equal(expect) {
+			t.Fatalf("expect %s, got %v", expect.ToString(), token)
+		}
+	}
+	for _, sql := range []string{`X'ABC'`, `X'AZ'`, `'\x0'`} {
+		if _, err := NewLexer(sql).next(); err == nil {
+			t.Errorf("%s expect error", sql)
+		}
+	}
+}
+func TestScanJsonArrow(t *testing.T) {
+	// -> 与 ->> 是 JSON 路径操作符, 单独的 - 仍是减号; 字符串中可以包含另一种引号;
+	lexer := NewLexer(`attrs->'a'->>0 - 1 '{"k": "v"}';`)
+	expects := []*Token{
+		{Type: IDENT, Value: "attrs"},
+		{Type: ARROW, Value: Arrow},
+		{Type: STRING, Value: "a"},
+		{Type: LONGARROW, Value: LongArrow},
+		{Type: NUMBER, Value: "0"},
+		{Type: MINUS, Value: Minus},
+		{Type: NUMBER, Value: "1"},
+		{Type: STRING, Value: `{"k": "v"}`},
+	}
+	for _, expect := range expects {
+		token, err := lexer.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token == nil || !token.equal(expect) {
+			t.Fatalf("expect %s, got %v", expect.ToString(), token)
+		}
+	}
+}
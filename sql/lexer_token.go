@@ -9,21 +9,27 @@ import (
 type TokenType int
 
 const (
-	KEYWORD     TokenType = iota
-	IDENT                 // 其他类型的字符串Token，比如表名、列名
-	STRING                // 字符串类型的数据
-	NUMBER                // 数字
-	OPENPAREN             // 左括号 (
-	CLOSEPAREN            // 右括号 )
-	COMMA                 // 逗号
-	SEMICOLON             // 分号 ;
-	ASTERISK              // 星号 *
-	PLUS                  // 加号 +
-	MINUS                 // 减号 -
-	SLASH                 // 斜杠 /
-	EQUAL                 // 等号 =
-	GREATERTHAN           // 大于 >
-	LESSTHAN              // 小于 <
+	KEYWORD      TokenType = iota
+	IDENT                  // 其他类型的字符串Token，比如表名、列名
+	STRING                 // 字符串类型的数据
+	NUMBER                 // 数字
+	OPENPAREN              // 左括号 (
+	CLOSEPAREN             // 右括号 )
+	COMMA                  // 逗号
+	SEMICOLON              // 分号 ;
+	ASTERISK               // 星号 *
+	PLUS                   // 加号 +
+	MINUS                  // 减号 -
+	SLASH                  // 斜杠 /
+	EQUAL                  // 等号 =
+	GREATERTHAN            // 大于 >
+	LESSTHAN               // 小于 <
+	GREATEREQUAL           // 大于等于 >=
+	LESSEQUAL              // 小于等于 <=
+	PERIOD                 // 点 .
+	HEXSTRING              // 十六进制字面量 X'ABCD', Value 为引号内的十六进制串
+	ARROW                  // JSON 取子文档 ->
+	LONGARROW              // JSON 取文本 ->>
 )
 
 type TokenValue string
@@ -35,53 +41,112 @@ const (
 	Create  TokenValue = "CREATE"
 	Table   TokenValue = "TABLE"
 	Index   TokenValue = "INDEX"
+	Unique  TokenValue = "UNIQUE"
 	Int     TokenValue = "INT"
 	Integer TokenValue = "INTEGER"
 	Boolean TokenValue = "BOOLEAN"
 	Bool    TokenValue = "BOOL"
 	String  TokenValue = "STRING"
 	Text    TokenValue = "TEXT"
-	Varchar TokenValue = "VARCHAR"
-	Char    TokenValue = "CHAR"
-	Float   TokenValue = "FLOAT"
-	Double  TokenValue = "DOUBLE"
-	Select  TokenValue = "SELECT"
-	From    TokenValue = "FROM"
-	Insert  TokenValue = "INSERT"
-	Into    TokenValue = "INTO"
-	Values  TokenValue = "VALUES"
-	True    TokenValue = "TRUE"
-	False   TokenValue = "FALSE"
-	Default TokenValue = "DEFAULT"
-	Not     TokenValue = "NOT"
-	Null    TokenValue = "NULL"
-	Primary TokenValue = "PRIMARY"
-	Key     TokenValue = "KEY"
-	Update  TokenValue = "UPDATE"
-	Set     TokenValue = "SET"
-	Where   TokenValue = "WHERE"
-	Delete  TokenValue = "DELETE"
-	Drop    TokenValue = "DROP"
-	On      TokenValue = "ON"
-	Asc     TokenValue = "ASC"
-	As      TokenValue = "AS"
-	Desc    TokenValue = "DESC"
-	Limit   TokenValue = "LIMIT"
-	Offset  TokenValue = "OFFSET"
-	Group   TokenValue = "GROUP"
-	By      TokenValue = "BY"
-	Having  TokenValue = "HAVING"
-	Order   TokenValue = "ORDER"
+
+	Date      TokenValue = "DATE"
+	Time      TokenValue = "TIME"
+	Timestamp TokenValue = "TIMESTAMP"
+	Interval  TokenValue = "INTERVAL"
+	Decimal   TokenValue = "DECIMAL"
+	Numeric   TokenValue = "NUMERIC"
+	Blob      TokenValue = "BLOB"
+	Bytea     TokenValue = "BYTEA"
+	Json      TokenValue = "JSON"
+	Extract   TokenValue = "EXTRACT"
+	Cast      TokenValue = "CAST"
+	Varchar   TokenValue = "VARCHAR"
+	Char      TokenValue = "CHAR"
+	Float     TokenValue = "FLOAT"
+	Double    TokenValue = "DOUBLE"
+	Select    TokenValue = "SELECT"
+	From      TokenValue = "FROM"
+	Insert    TokenValue = "INSERT"
+	Into      TokenValue = "INTO"
+	Values    TokenValue = "VALUES"
+	True      TokenValue = "TRUE"
+	False     TokenValue = "FALSE"
+	Default   TokenValue = "DEFAULT"
+	Not       TokenValue = "NOT"
+	Null      TokenValue = "NULL"
+	Primary   TokenValue = "PRIMARY"
+	Key       TokenValue = "KEY"
+	Update    TokenValue = "UPDATE"
+	Set       TokenValue = "SET"
+	Where     TokenValue = "WHERE"
+	Delete    TokenValue = "DELETE"
+	Drop      TokenValue = "DROP"
+	On        TokenValue = "ON"
+	Asc       TokenValue = "ASC"
+	As        TokenValue = "AS"
+	Desc      TokenValue = "DESC"
+	Limit     TokenValue = "LIMIT"
+	Offset    TokenValue = "OFFSET"
+	Group     TokenValue = "GROUP"
+	By        TokenValue = "BY"
+	Having    TokenValue = "HAVING"
+	Order     TokenValue = "ORDER"
+	And       TokenValue = "AND"
+	Or        TokenValue = "OR"
+
+	Foreign  TokenValue = "FOREIGN"
+	Refer    TokenValue = "REFERENCES"
+	Cascade  TokenValue = "CASCADE"
+	Restrict TokenValue = "RESTRICT"
+	Check    TokenValue = "CHECK"
+	Collate  TokenValue = "COLLATE"
+
+	Alter  TokenValue = "ALTER"
+	Add    TokenValue = "ADD"
+	Column TokenValue = "COLUMN"
+	Rename TokenValue = "RENAME"
+	To     TokenValue = "TO"
+
+	Sequence      TokenValue = "SEQUENCE"
+	Start         TokenValue = "START"
+	With          TokenValue = "WITH"
+	Increment     TokenValue = "INCREMENT"
+	AutoIncrement TokenValue = "AUTO_INCREMENT"
+	Serial        TokenValue = "SERIAL"
+
+	Conflict TokenValue = "CONFLICT"
+	Do       TokenValue = "DO"
+	Nothing  TokenValue = "NOTHING"
+	Excluded TokenValue = "EXCLUDED"
+
+	Returning TokenValue = "RETURNING"
+	Truncate  TokenValue = "TRUNCATE"
+
+	View         TokenValue = "VIEW"
+	Replace      TokenValue = "REPLACE"
+	Materialized TokenValue = "MATERIALIZED"
+	Refresh      TokenValue = "REFRESH"
 
 	Cross TokenValue = "CROSS"
 	Join  TokenValue = "JOIN"
 	Left  TokenValue = "LEFT"
 	Right TokenValue = "RIGHT"
 
-	Begin    TokenValue = "BEGIN"
-	Commit   TokenValue = "COMMIT"
-	Rollback TokenValue = "ROLLBACK"
-	Explain  TokenValue = "EXPLAIN"
+	Begin           TokenValue = "BEGIN"
+	Commit          TokenValue = "COMMIT"
+	Rollback        TokenValue = "ROLLBACK"
+	Savepoint       TokenValue = "SAVEPOINT"
+	Release         TokenValue = "RELEASE"
+	Isolation       TokenValue = "ISOLATION"
+	Level           TokenValue = "LEVEL"
+	Serializable    TokenValue = "SERIALIZABLE"
+	Repeatable      TokenValue = "REPEATABLE"
+	Read            TokenValue = "READ"
+	Committed       TokenValue = "COMMITTED"
+	SessionKeyword  TokenValue = "SESSION"
+	Characteristics TokenValue = "CHARACTERISTICS"
+	Transaction     TokenValue = "TRANSACTION"
+	Explain         TokenValue = "EXPLAIN"
 
 	OpenPar     TokenValue = "("
 	ClosePar    TokenValue = ")"
@@ -94,6 +159,11 @@ const (
 	Equal       TokenValue = "="
 	GreaterThan TokenValue = ">"
 	LessThan    TokenValue = "<"
+	GreaterEq   TokenValue = ">="
+	LessEq      TokenValue = "<="
+	Period      TokenValue = "."
+	Arrow       TokenValue = "->"
+	LongArrow   TokenValue = "->>"
 )
 
 type Token struct {
@@ -109,7 +179,7 @@ func NewToken(t TokenType, v TokenValue) *Token {
 }
 
 func (t *Token) isOperator() bool {
-	if t.Type == MINUS || t.Type == PLUS || t.Type == ASTERISK || t.Type == SLASH {
+	if t.Type == MINUS || t.Type == PLUS || t.Type == ASTERISK || t.Type == SLASH || t.Type == ARROW || t.Type == LONGARROW {
 		return true
 	}
 	return false
@@ -122,49 +192,54 @@ func (t *Token) precedence() int32 {
 	if t.Type == ASTERISK || t.Type == SLASH {
 		return 2
 	}
+	if t.Type == ARROW || t.Type == LONGARROW {
+		return 3
+	}
 	return 0
 }
 
+// computeExpr 两侧都是常量时直接算出结果; 含有列或函数时生成 OperationArith, 执行时逐行计算;
+// -> 与 ->> 生成同名的标量函数调用;
 func (t *Token) computeExpr(l, r *types.Expression) (*types.Expression, error) {
-	var val float64
-	var err error
-	var con types.Const
-	if l.ConstVal != nil && r.ConstVal != nil {
-		left, ok := l.ConstVal.Into().(float64)
-		if !ok {
-			return nil, util.Error("#computeExpr cannot compute the left Expression %s", l.ConstVal.Into())
-		}
-		right, ok := r.ConstVal.Into().(float64)
-		if !ok {
-			return nil, util.Error("#computeExpr cannot compute the right Expression %s", r.ConstVal.Into())
+	if t.Type == ARROW || t.Type == LONGARROW {
+		function := &types.Function{FuncName: string(t.Value), Args: []*types.Expression{l, r}}
+		if l.ConstVal == nil || r.ConstVal == nil {
+			return &types.Expression{Function: function}, nil
 		}
-		val, err = t.compute(left, right)
+		value, err := types.CallScalarFunction(function.FuncName, []types.Value{l.ConstVal, r.ConstVal})
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		// 存在一方的值为空;
-		return nil, util.Error("#computeExpr cannot compute the left right Expression")
+		return types.NewExpression(value), nil
 	}
-	con = &types.ConstFloat{Value: val}
-	return types.NewExpression(con), nil
-}
-
-func (t *Token) compute(l, r float64) (float64, error) {
-	if t.Type == MINUS {
-		return l - r, nil
+	operator, err := t.arithOperator()
+	if err != nil {
+		return nil, err
 	}
-	if t.Type == ASTERISK {
-		return l * r, nil
+	if l.ConstVal == nil || r.ConstVal == nil {
+		return &types.Expression{OperationVal: &types.OperationArith{Operator: operator, Left: l, Right: r}}, nil
 	}
-	if t.Type == SLASH {
-		return l / r, nil
+	value, err := types.ArithValue(operator, l.ConstVal, r.ConstVal)
+	if err != nil {
+		return nil, err
 	}
-	if t.Type == PLUS {
-		return l + r, nil
+	return types.NewExpression(value), nil
+}
+
+func (t *Token) arithOperator() (types.ArithOperator, error) {
+	switch t.Type {
+	case PLUS:
+		return types.ArithAdd, nil
+	case MINUS:
+		return types.ArithSub, nil
+	case ASTERISK:
+		return types.ArithMul, nil
+	case SLASH:
+		return types.ArithDiv, nil
 	}
-	return -1, util.Error("#compute Unexpected operator: %s", t.ToString())
+	return 0, util.Error("#computeExpr Unexpected operator: %s", t.ToString())
 }
+
 func (t *Token) equal(s *Token) bool {
 	return t.Type == s.Type && t.Value == s.Value
 }
@@ -206,17 +281,74 @@ func InitWord() map[string]*Token {
 		"STRING":  NewToken(KEYWORD, String),
 		"TEXT":    NewToken(KEYWORD, Text),
 
+		"DATE":      NewToken(KEYWORD, Date),
+		"TIME":      NewToken(KEYWORD, Time),
+		"TIMESTAMP": NewToken(KEYWORD, Timestamp),
+		"INTERVAL":  NewToken(KEYWORD, Interval),
+		"DECIMAL":   NewToken(KEYWORD, Decimal),
+		"NUMERIC":   NewToken(KEYWORD, Numeric),
+		"BLOB":      NewToken(KEYWORD, Blob),
+		"BYTEA":     NewToken(KEYWORD, Bytea),
+		"JSON":      NewToken(KEYWORD, Json),
+		"EXTRACT":   NewToken(KEYWORD, Extract),
+		"CAST":      NewToken(KEYWORD, Cast),
+
 		"NULL":    NewToken(KEYWORD, Null),
 		"NOT":     NewToken(KEYWORD, Not),
 		"DEFAULT": NewToken(KEYWORD, Default),
 		"TRUE":    NewToken(KEYWORD, True),
 		"FALSE":   NewToken(KEYWORD, False),
 
-		"BEGIN":    NewToken(KEYWORD, Begin),
-		"COMMIT":   NewToken(KEYWORD, Commit),
-		"ROLLBACK": NewToken(KEYWORD, Rollback),
-		"EXPLAIN":  NewToken(KEYWORD, Explain),
-		"INDEX":    NewToken(KEYWORD, Index),
+		"BEGIN":           NewToken(KEYWORD, Begin),
+		"COMMIT":          NewToken(KEYWORD, Commit),
+		"ROLLBACK":        NewToken(KEYWORD, Rollback),
+		"SAVEPOINT":       NewToken(KEYWORD, Savepoint),
+		"RELEASE":         NewToken(KEYWORD, Release),
+		"ISOLATION":       NewToken(KEYWORD, Isolation),
+		"LEVEL":           NewToken(KEYWORD, Level),
+		"SERIALIZABLE":    NewToken(KEYWORD, Serializable),
+		"REPEATABLE":      NewToken(KEYWORD, Repeatable),
+		"READ":            NewToken(KEYWORD, Read),
+		"COMMITTED":       NewToken(KEYWORD, Committed),
+		"SESSION":         NewToken(KEYWORD, SessionKeyword),
+		"CHARACTERISTICS": NewToken(KEYWORD, Characteristics),
+		"TRANSACTION":     NewToken(KEYWORD, Transaction),
+		"EXPLAIN":         NewToken(KEYWORD, Explain),
+		"INDEX":           NewToken(KEYWORD, Index),
+		"UNIQUE":          NewToken(KEYWORD, Unique),
+
+		"FOREIGN":    NewToken(KEYWORD, Foreign),
+		"REFERENCES": NewToken(KEYWORD, Refer),
+		"CASCADE":    NewToken(KEYWORD, Cascade),
+		"RESTRICT":   NewToken(KEYWORD, Restrict),
+		"CHECK":      NewToken(KEYWORD, Check),
+		"COLLATE":    NewToken(KEYWORD, Collate),
+
+		"ALTER":  NewToken(KEYWORD, Alter),
+		"ADD":    NewToken(KEYWORD, Add),
+		"COLUMN": NewToken(KEYWORD, Column),
+		"RENAME": NewToken(KEYWORD, Rename),
+		"TO":     NewToken(KEYWORD, To),
+
+		"SEQUENCE":       NewToken(KEYWORD, Sequence),
+		"START":          NewToken(KEYWORD, Start),
+		"WITH":           NewToken(KEYWORD, With),
+		"INCREMENT":      NewToken(KEYWORD, Increment),
+		"AUTO_INCREMENT": NewToken(KEYWORD, AutoIncrement),
+		"SERIAL":         NewToken(KEYWORD, Serial),
+
+		"CONFLICT": NewToken(KEYWORD, Conflict),
+		"DO":       NewToken(KEYWORD, Do),
+		"NOTHING":  NewToken(KEYWORD, Nothing),
+		"EXCLUDED": NewToken(KEYWORD, Excluded),
+
+		"RETURNING": NewToken(KEYWORD, Returning),
+		"TRUNCATE":  NewToken(KEYWORD, Truncate),
+
+		"VIEW":         NewToken(KEYWORD, View),
+		"REPLACE":      NewToken(KEYWORD, Replace),
+		"MATERIALIZED": NewToken(KEYWORD, Materialized),
+		"REFRESH":      NewToken(KEYWORD, Refresh),
 
 		"ON":     NewToken(KEYWORD, On),
 		"ASC":    NewToken(KEYWORD, Asc),
@@ -228,6 +360,8 @@ func InitWord() map[string]*Token {
 		"BY":     NewToken(KEYWORD, By),
 		"HAVING": NewToken(KEYWORD, Having),
 		"ORDER":  NewToken(KEYWORD, Order),
+		"AND":    NewToken(KEYWORD, And),
+		"OR":     NewToken(KEYWORD, Or),
 		"CROSS":  NewToken(KEYWORD, Cross),
 		"JOIN":   NewToken(KEYWORD, Join),
 		"LEFT":   NewToken(KEYWORD, Left),
@@ -0,0 +1,115 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"sort"
+)
+
+// migrations 存储格式的迁移列表, 格式版本号记录在 Meta_version 中;
+// 每次改变 key 的布局时, 在末尾追加一个迁移函数, 不能修改已有的迁移;
+var migrations = []func(s *KVService) error{
+	migrateIndexEntries, // 1: 索引由 "索引值 => 主键集合" 改为每条索引项一个 key;
+	migrateRowKeys,      // 2: 行 key 由 Row_<table><pk> 改为 Row_<table>\x00<enc(pk...)>;
+	migrateUniqueIndex,  // 3: 唯一索引项的 key 不再带主键, 主键存放在 value 中;
+}
+
+var metaVersion = "version"
+
+// migrate 依次执行尚未执行过的迁移, 所有迁移在同一个事务中完成;
+func (s *KVService) migrate() error {
+	version := uint64(0)
+	if value := s.txn.Get(GetMetaKey(metaVersion)); value != nil {
+		version = binary.BigEndian.Uint64(value)
+	}
+	if version > uint64(len(migrations)) {
+		return util.Error("#migrate storage version %d is newer than %d", version, len(migrations))
+	}
+	if version == uint64(len(migrations)) {
+		return nil
+	}
+	for ; version < uint64(len(migrations)); version++ {
+		if err := migrations[version](s); err != nil {
+			return util.Error("#migrate to version %d error: %s", version+1, err)
+		}
+	}
+	return s.txn.Set(GetMetaKey(metaVersion), binary.BigEndian.AppendUint64(nil, version))
+}
+
+// migrateIndexEntries 删除全部旧格式的索引数据, 再根据表中的行重建索引;
+func migrateIndexEntries(s *KVService) error {
+	return s.rebuildIndexes()
+}
+
+// migrateRowKeys 将行数据迁移到保序编码的主键 key 下;
+// 旧 key 没有分隔符, 表 t 的前缀会扫到表 t1 的行, 因此用行数据重新计算旧 key, 完全一致才认领;
+// 表名长的先认领, 已被认领的 key 不再处理; 行 key 变化后主键后缀随之变化, 需要重建索引;
+func migrateRowKeys(s *KVService) error {
+	tables := make([]*types.Table, 0)
+	for _, tableName := range s.GetTableNames() {
+		table, err := s.GetTable(tableName)
+		if err != nil {
+			return err
+		}
+		if table != nil {
+			tables = append(tables, table)
+		}
+	}
+	sort.SliceStable(tables, func(i, j int) bool {
+		return len(tables[i].Name) > len(tables[j].Name)
+	})
+	claimed := make(map[string]bool)
+	for _, table := range tables {
+		for _, resultPair := range s.txn.ScanPrefix([]byte(Row_+table.Name), true) {
+			if claimed[string(resultPair.Key)] {
+				continue
+			}
+			row := types.Row{}
+			if err := gob.NewDecoder(bytes.NewReader(resultPair.Value)).Decode(&row); err != nil || len(row) != len(table.Columns) {
+				continue
+			}
+			pk := table.GetPrimaryKeyOfValue(row)
+			if !bytes.Equal(resultPair.Key, []byte(Row_+table.Name+string(pk[0].Bytes()))) {
+				continue
+			}
+			claimed[string(resultPair.Key)] = true
+			if err := s.txn.Delete(resultPair.Key); err != nil {
+				return err
+			}
+			if err := s.txn.Set(GetRowKey(table.Name, pk), resultPair.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return s.rebuildIndexes()
+}
+
+// migrateUniqueIndex 唯一索引项的格式变化, 重建全部索引;
+func migrateUniqueIndex(s *KVService) error {
+	return s.rebuildIndexes()
+}
+
+// rebuildIndexes 删除全部索引数据, 再根据表中的行重建;
+func (s *KVService) rebuildIndexes() error {
+	if err := s.deletePrefix([]byte(Index_)); err != nil {
+		return err
+	}
+	for _, tableName := range s.GetTableNames() {
+		table, err := s.GetTable(tableName)
+		if err != nil {
+			return err
+		}
+		if table == nil {
+			continue
+		}
+		for _, index := range table.GetIndexes() {
+			if _, err = s.BackfillIndex(table.Name, index.Name, nil, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
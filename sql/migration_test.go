@@ -0,0 +1,97 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/storage"
+	"testing"
+)
+
+// 模拟旧版本写入的数据: "Row_<table><pk>" 格式的行数据 + "Index_<table><col><val> => 主键集合" 格式的索引;
+// 表 m10 的行 key 以 Row_m1 开头, 迁移时不能被 m1 认领;
+func writeLegacyIndexData(t *testing.T, sto storage.Storage) []byte {
+	txn := storage.NewTransactionManager(sto).Begin()
+	service := NewKVService(txn)
+	for _, name := range []string{"m1", "m10"} {
+		err := service.CreateTable(&types.Table{
+			Name: name,
+			Columns: []types.ColumnV{
+				{Name: "a", DataType: types.Integer, PrimaryKey: true},
+				{Name: "b", DataType: types.String, Nullable: true, IsIndex: true},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := map[string][]types.Row{
+		"m1": {
+			{types.NewConstInt(1), types.NewConstString("x")},
+			{types.NewConstInt(2), types.NewConstString("y")},
+			{types.NewConstInt(3), types.NewConstString("x")},
+		},
+		"m10": {
+			{types.NewConstInt(1), types.NewConstString("x")},
+		},
+	}
+	var err error
+	for name, tableRows := range rows {
+		for _, row := range tableRows {
+			var buffer bytes.Buffer
+			if err = gob.NewEncoder(&buffer).Encode(row); err != nil {
+				t.Fatal(err)
+			}
+			if err = txn.Set([]byte(Row_+name+string(row[0].Bytes())), buffer.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	legacyKey := []byte(Index_ + "m1" + "b" + "x")
+	var buffer bytes.Buffer
+	if err = gob.NewEncoder(&buffer).Encode([]types.Value{types.NewConstInt(1), types.NewConstInt(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = txn.Set(legacyKey, buffer.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	txn.Commit()
+	return legacyKey
+}
+
+func TestMigrateIndexEntries(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	legacyKey := writeLegacyIndexData(t, memoryStorage)
+
+	server, err := NewServer(memoryStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := server.Session()
+	resultSet := session.Execute("select * from m1 where b = 'x';")
+	scan, ok := resultSet.(*types.ScanTableResult)
+	if !ok || len(scan.Rows) != 2 {
+		t.Fatalf("expect 2 rows from migrated index, got: %s", resultSet.ToString())
+	}
+	for sql, count := range map[string]int{
+		"select * from m1;":                3,
+		"select * from m10;":               1,
+		"select * from m1 where a = 3;":    1,
+		"select * from m10 where b = 'x';": 1,
+	} {
+		resultSet = session.Execute(sql)
+		if scan, ok = resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != count {
+			t.Fatalf("%s expect %d rows after migration, got: %s", sql, count, resultSet.ToString())
+		}
+	}
+
+	service := server.Begin().(*KVService)
+	defer service.Commit()
+	if service.txn.Get(legacyKey) != nil {
+		t.Errorf("legacy index key should be removed by migration")
+	}
+	// 再次启动不会重复迁移;
+	if err := service.migrate(); err != nil {
+		t.Error(err)
+	}
+}
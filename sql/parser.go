@@ -3,7 +3,11 @@ package sql
 import (
 	"github.com/kebukeYi/TrainSQL/sql/types"
 	"github.com/kebukeYi/TrainSQL/sql/util"
+	"github.com/kebukeYi/TrainSQL/storage"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
@@ -116,6 +120,12 @@ func (p *Parser) parseStatement() (Statement, error) {
 				return p.parseDdl()
 			case Drop:
 				return p.parseDdl()
+			case Alter:
+				return p.parseDdlAlterTable()
+			case Truncate:
+				return p.parseDdlTruncateTable()
+			case Refresh:
+				return p.parseDdlRefreshView()
 			case Insert:
 				return p.parseInsert()
 			case Select:
@@ -130,8 +140,14 @@ func (p *Parser) parseStatement() (Statement, error) {
 				return p.parseTransaction()
 			case Rollback:
 				return p.parseTransaction()
+			case Savepoint:
+				return p.parseTransaction()
+			case Release:
+				return p.parseTransaction()
 			case Explain:
 				return p.parseExplain()
+			case Set:
+				return p.parseSetSession()
 			default:
 				return nil, util.Error("#parseStatement: Unhandled default case: %s", token.ToString())
 			}
@@ -151,13 +167,24 @@ func (p *Parser) parseDdl() (Statement, error) {
 		} else {
 			if token2.Value == Table {
 				return p.parseDdlCreateTable()
-			} else if token2.Value == Index {
+			} else if token2.Value == Index || token2.Value == Unique {
 				return p.parseDdlCreateIndex()
+			} else if token2.Value == Sequence {
+				return p.parseDdlCreateSequence()
+			} else if token2.Value == View || token2.Value == Or || token2.Value == Materialized {
+				return p.parseDdlCreateView()
 			} else {
 				return nil, util.Error("#parseDdl: Unhandled default case: %s", token2.ToString())
 			}
 		}
 	} else if value == Drop {
+		if token2, _ := p.peek(); token2 != nil && token2.Value == Index {
+			return p.parseDdlDropIndex()
+		} else if token2 != nil && token2.Value == Sequence {
+			return p.parseDdlDropSequence()
+		} else if token2 != nil && (token2.Value == View || token2.Value == Materialized) {
+			return p.parseDdlDropView()
+		}
 		return p.parseDdlDropTable()
 	} else {
 		return nil, util.Error("#parseDdl: unhandled parseDdl default case")
@@ -175,13 +202,59 @@ func (p *Parser) parseDdlCreateTable() (Statement, error) {
 		return nil, err
 	}
 	var columns []*types.Column
+	var primaryKeys []string
+	var uniques [][]string
+	var foreignKeys []*types.ForeignKey
+	var checks []*types.Expression
 	// CREATE TABLE user (id INT, name VARCHAR NOT NULL, age INT DEFAULT 0);
+	// CREATE TABLE user (a INT, b INT, PRIMARY KEY (a, b), UNIQUE (b));
+	// CREATE TABLE orders (id INT PRIMARY KEY, uid INT, FOREIGN KEY (uid) REFERENCES user (id) ON DELETE CASCADE);
 	for {
-		column, err := p.parseDdlColumn()
-		if err != nil {
-			return nil, err
+		if token := p.nextIfToken(&Token{Type: KEYWORD, Value: Check}); token != nil {
+			check, err := p.parseCheck()
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, check)
+		} else if token := p.nextIfToken(&Token{Type: KEYWORD, Value: Foreign}); token != nil {
+			if err = p.nextExpect(&Token{Type: KEYWORD, Value: Key}); err != nil {
+				return nil, err
+			}
+			columns, err := p.parseColumnNames()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.nextExpect(&Token{Type: KEYWORD, Value: Refer}); err != nil {
+				return nil, err
+			}
+			foreignKey, err := p.parseReferences(columns)
+			if err != nil {
+				return nil, err
+			}
+			foreignKeys = append(foreignKeys, foreignKey)
+		} else if token := p.nextIfToken(&Token{Type: KEYWORD, Value: Unique}); token != nil {
+			unique, err := p.parseColumnNames()
+			if err != nil {
+				return nil, err
+			}
+			uniques = append(uniques, unique)
+		} else if token := p.nextIfToken(&Token{Type: KEYWORD, Value: Primary}); token != nil {
+			if len(primaryKeys) > 0 {
+				return nil, util.Error("#parseDdlCreateTable: multiple primary key constraints")
+			}
+			if err = p.nextExpect(&Token{Type: KEYWORD, Value: Key}); err != nil {
+				return nil, err
+			}
+			if primaryKeys, err = p.parseColumnNames(); err != nil {
+				return nil, err
+			}
+		} else {
+			column, err := p.parseDdlColumn()
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
 		}
-		columns = append(columns, column)
 		if token := p.nextIfToken(&Token{Type: COMMA, Value: Comma}); token == nil {
 			break
 		}
@@ -190,9 +263,17 @@ func (p *Parser) parseDdlCreateTable() (Statement, error) {
 	if err != nil {
 		return nil, err
 	}
+	// 列上的 PRIMARY KEY 与表约束 PRIMARY KEY (...) 不能同时出现, 否则列上声明的唯一性会被复合主键掩盖;
+	if len(primaryKeys) > 0 && slices.ContainsFunc(columns, func(column *types.Column) bool { return column.PrimaryKey }) {
+		return nil, util.Error("#parseDdlCreateTable: multiple primary key constraints")
+	}
 	creatTableData := &CreatTableData{
-		TableName: tableName,
-		Columns:   columns,
+		TableName:   tableName,
+		Columns:     columns,
+		PrimaryKeys: primaryKeys,
+		Uniques:     uniques,
+		ForeignKeys: foreignKeys,
+		Checks:      checks,
 	}
 	return creatTableData, nil
 }
@@ -205,20 +286,362 @@ func (p *Parser) parseDdlDropTable() (Statement, error) {
 	dropTableData := &DropTableData{
 		TableName: tableName,
 	}
+	// DROP TABLE table_name CASCADE; 同时删除依赖这张表的视图;
+	dropTableData.Cascade = p.nextIfToken(&Token{Type: KEYWORD, Value: Cascade}) != nil
 	return dropTableData, nil
 }
+
+// TRUNCATE [TABLE] table_name;
+func (p *Parser) parseDdlTruncateTable() (Statement, error) {
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Truncate}); err != nil {
+		return nil, err
+	}
+	p.nextIfToken(&Token{Type: KEYWORD, Value: Table})
+	tableName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	return &TruncateTableData{TableName: tableName}, nil
+}
+
+// ALTER TABLE table_name ADD [COLUMN] col_def;
+// ALTER TABLE table_name DROP [COLUMN] col_name;
+// ALTER TABLE table_name RENAME [COLUMN] col_name TO new_name;
+// ALTER TABLE table_name RENAME TO new_name;
+// ALTER TABLE table_name ALTER [COLUMN] col_name SET DEFAULT expr | DROP DEFAULT | SET NOT NULL | DROP NOT NULL;
+func (p *Parser) parseDdlAlterTable() (Statement, error) {
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Alter}); err != nil {
+		return nil, err
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Table}); err != nil {
+		return nil, err
+	}
+	tableName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	alterTableData := &AlterTableData{TableName: tableName}
+	token, _ := p.nextIfKeyWord()
+	if token == nil {
+		return nil, util.Error("#parseDdlAlterTable: Expect ADD, DROP, RENAME or ALTER")
+	}
+	switch token.Value {
+	case Add:
+		p.nextIfToken(&Token{Type: KEYWORD, Value: Column})
+		alterTableData.Action = AlterAddColumn
+		if alterTableData.Column, err = p.parseDdlColumn(); err != nil {
+			return nil, err
+		}
+	case Drop:
+		p.nextIfToken(&Token{Type: KEYWORD, Value: Column})
+		alterTableData.Action = AlterDropColumn
+		if alterTableData.ColumnName, err = p.nextIdent(); err != nil {
+			return nil, err
+		}
+	case Rename:
+		if p.nextIfToken(&Token{Type: KEYWORD, Value: To}) != nil {
+			alterTableData.Action = AlterRenameTable
+		} else {
+			p.nextIfToken(&Token{Type: KEYWORD, Value: Column})
+			alterTableData.Action = AlterRenameColumn
+			if alterTableData.ColumnName, err = p.nextIdent(); err != nil {
+				return nil, err
+			}
+			if err = p.nextExpect(&Token{Type: KEYWORD, Value: To}); err != nil {
+				return nil, err
+			}
+		}
+		if alterTableData.NewName, err = p.nextIdent(); err != nil {
+			return nil, err
+		}
+	case Alter:
+		p.nextIfToken(&Token{Type: KEYWORD, Value: Column})
+		if alterTableData.ColumnName, err = p.nextIdent(); err != nil {
+			return nil, err
+		}
+		if err = p.parseAlterColumn(alterTableData); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, util.Error("#parseDdlAlterTable: Unexpected keyword: %s", token.ToString())
+	}
+	return alterTableData, nil
+}
+
+// parseAlterColumn 解析 SET DEFAULT expr | DROP DEFAULT | SET NOT NULL | DROP NOT NULL;
+func (p *Parser) parseAlterColumn(alterTableData *AlterTableData) error {
+	token, _ := p.nextIfKeyWord()
+	if token == nil || (token.Value != Set && token.Value != Drop) {
+		return util.Error("#parseAlterColumn: Expect SET or DROP")
+	}
+	set := token.Value == Set
+	if p.nextIfToken(&Token{Type: KEYWORD, Value: Default}) != nil {
+		if !set {
+			alterTableData.Action = AlterDropDefault
+			return nil
+		}
+		expr, err := p.parseExpression()
+		if err != nil {
+			return err
+		}
+		if expr.ConstVal == nil {
+			return util.Error("#parseAlterColumn: default value of column %s must be a constant", alterTableData.ColumnName)
+		}
+		alterTableData.Action = AlterSetDefault
+		alterTableData.Default = expr
+		return nil
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Not}); err != nil {
+		return err
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Null}); err != nil {
+		return err
+	}
+	if set {
+		alterTableData.Action = AlterSetNotNull
+	} else {
+		alterTableData.Action = AlterDropNotNull
+	}
+	return nil
+}
+
+// CREATE [UNIQUE] INDEX idx_name ON table_name (col_name, (expr));
 func (p *Parser) parseDdlCreateIndex() (Statement, error) {
-	return nil, nil
+	unique := p.nextIfToken(&Token{Type: KEYWORD, Value: Unique}) != nil
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Index}); err != nil {
+		return nil, err
+	}
+	indexName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.nextExpect(&Token{Type: KEYWORD, Value: On}); err != nil {
+		return nil, err
+	}
+	tableName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	createIndexData := &CreateIndexData{
+		TableName: tableName,
+		IndexName: indexName,
+		Unique:    unique,
+	}
+	// 索引项可以是列名, 也可以是括号中的表达式: (attrs ->> 'color');
+	expressions, hasExpression := make([]*types.Expression, 0), false
+	if err = p.nextExpect(&Token{Type: OPENPAREN, Value: OpenPar}); err != nil {
+		return nil, err
+	}
+	for {
+		expr, err := p.computeMathOperator(1)
+		if err != nil {
+			return nil, err
+		}
+		if expr.Field != "" {
+			createIndexData.Columns = append(createIndexData.Columns, expr.Field)
+			expressions = append(expressions, nil)
+		} else {
+			createIndexData.Columns = append(createIndexData.Columns, expr.ToString())
+			expressions = append(expressions, expr)
+			hasExpression = true
+		}
+		if p.nextIfToken(&Token{Type: COMMA, Value: Comma}) == nil {
+			break
+		}
+	}
+	if hasExpression {
+		createIndexData.Expressions = expressions
+	}
+	if err = p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return nil, err
+	}
+	return createIndexData, nil
 }
-func (p *Parser) parseExpression() (*types.Expression, error) {
+
+// parseColumnNames 解析括号中的列名列表: (a, b, c);
+func (p *Parser) parseColumnNames() ([]string, error) {
+	if err := p.nextExpect(&Token{Type: OPENPAREN, Value: OpenPar}); err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0)
+	for {
+		colName, err := p.nextIdent()
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, colName)
+		if token := p.nextIfToken(&Token{Type: COMMA, Value: Comma}); token == nil {
+			break
+		}
+	}
+	if err := p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// parseReferences 解析 REFERENCES 之后的部分: parent [(col, ...)] [ON DELETE action] [ON UPDATE action];
+// 省略被引用列时引用父表的主键;
+func (p *Parser) parseReferences(columns []string) (*types.ForeignKey, error) {
+	refTable, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	foreignKey := &types.ForeignKey{
+		Columns:  columns,
+		RefTable: refTable,
+		OnDelete: types.ActionRestrict,
+		OnUpdate: types.ActionRestrict,
+	}
+	if token, _ := p.peek(); token != nil && token.Type == OPENPAREN {
+		if foreignKey.RefColumns, err = p.parseColumnNames(); err != nil {
+			return nil, err
+		}
+	}
+	for p.nextIfToken(&Token{Type: KEYWORD, Value: On}) != nil {
+		token, _ := p.nextIfKeyWord()
+		if token == nil || (token.Value != Delete && token.Value != Update) {
+			return nil, util.Error("#parseReferences: Expect DELETE or UPDATE after ON")
+		}
+		action, err := p.parseReferentialAction()
+		if err != nil {
+			return nil, err
+		}
+		if token.Value == Delete {
+			foreignKey.OnDelete = action
+		} else {
+			foreignKey.OnUpdate = action
+		}
+	}
+	return foreignKey, nil
+}
+
+// parseCheck 解析 CHECK 之后括号中的条件表达式;
+func (p *Parser) parseCheck() (*types.Expression, error) {
+	if err := p.nextExpect(&Token{Type: OPENPAREN, Value: OpenPar}); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseOperationExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// CASCADE | RESTRICT | SET NULL
+func (p *Parser) parseReferentialAction() (types.ReferentialAction, error) {
+	token, _ := p.nextIfKeyWord()
+	if token == nil {
+		return 0, util.Error("#parseReferentialAction: Expect CASCADE, RESTRICT or SET NULL")
+	}
+	switch token.Value {
+	case Cascade:
+		return types.ActionCascade, nil
+	case Restrict:
+		return types.ActionRestrict, nil
+	case Set:
+		if err := p.nextExpect(&Token{Type: KEYWORD, Value: Null}); err != nil {
+			return 0, err
+		}
+		return types.ActionSetNull, nil
+	default:
+		return 0, util.Error("#parseReferentialAction: Unexpected keyword: %s", token.ToString())
+	}
+}
+
+// CREATE SEQUENCE seq_name [START [WITH] n] [INCREMENT [BY] n];
+func (p *Parser) parseDdlCreateSequence() (Statement, error) {
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Sequence}); err != nil {
+		return nil, err
+	}
+	sequenceName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	createSequenceData := &CreateSequenceData{SequenceName: sequenceName, Start: 1, Increment: 1}
+	for {
+		if p.nextIfToken(&Token{Type: KEYWORD, Value: Start}) != nil {
+			p.nextIfToken(&Token{Type: KEYWORD, Value: With})
+			if createSequenceData.Start, err = p.parseInteger(); err != nil {
+				return nil, err
+			}
+		} else if p.nextIfToken(&Token{Type: KEYWORD, Value: Increment}) != nil {
+			p.nextIfToken(&Token{Type: KEYWORD, Value: By})
+			if createSequenceData.Increment, err = p.parseInteger(); err != nil {
+				return nil, err
+			}
+		} else {
+			break
+		}
+	}
+	return createSequenceData, nil
+}
+
+// parseInteger 解析可带负号的整数;
+func (p *Parser) parseInteger() (int64, error) {
+	negative := p.nextIfToken(&Token{Type: MINUS, Value: Minus}) != nil
 	token, _ := p.next()
+	if token == nil || token.Type != NUMBER || !util.IsIntegerStrict(string(token.Value)) {
+		return 0, util.Error("#parseInteger: Expect integer")
+	}
+	value, err := strconv.ParseInt(string(token.Value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// DROP SEQUENCE seq_name;
+func (p *Parser) parseDdlDropSequence() (Statement, error) {
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Sequence}); err != nil {
+		return nil, err
+	}
+	sequenceName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	return &DropSequenceData{SequenceName: sequenceName}, nil
+}
+
+// DROP INDEX idx_name;
+func (p *Parser) parseDdlDropIndex() (Statement, error) {
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Index}); err != nil {
+		return nil, err
+	}
+	indexName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	return &DropIndexData{IndexName: indexName}, nil
+}
+func (p *Parser) parseExpression() (*types.Expression, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, util.Error("#parseExpression: unexpected end of input")
+	}
 	var con types.Const
 	switch token.Type {
 	case IDENT:
 		// 函数
-		// count(col_name)
+		// count(col_name); now()、nextval('seq_name') 等标量函数见 parseFunction;
 		if p.nextIfToken(&Token{Type: OPENPAREN, Value: OpenPar}) != nil {
-			colName, _ := p.nextIdent()
+			if types.IsScalarFunction(string(token.Value)) {
+				return p.parseFunction(string(token.Value))
+			}
+			// 参数为列名或字符串, count(*) 的参数为空;
+			colName := ""
+			if arg, _ := p.next(); arg != nil && (arg.Type == IDENT || arg.Type == STRING) {
+				colName = string(arg.Value)
+			}
 			err := p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar})
 			if err != nil {
 				return nil, err
@@ -253,6 +676,12 @@ func (p *Parser) parseExpression() (*types.Expression, error) {
 		con = &types.ConstString{
 			Value: string(token.Value),
 		}
+	case HEXSTRING:
+		blob, err := types.ParseHex(string(token.Value))
+		if err != nil {
+			return nil, err
+		}
+		con = blob
 	case KEYWORD:
 		switch token.Value {
 		case Null:
@@ -265,6 +694,23 @@ func (p *Parser) parseExpression() (*types.Expression, error) {
 			con = &types.ConstBool{
 				Value: false,
 			}
+		case Date, Time, Timestamp, Interval, Decimal, Numeric, Blob, Bytea, Json:
+			// DATE '2024-01-01', TIMESTAMP '2024-01-01 12:00:00', INTERVAL '1 day', DECIMAL '0.1', BLOB 'a\x00b', JSON '{"a": 1}';
+			return p.parseTypedLiteral(token)
+		case Extract:
+			return p.parseExtract()
+		case Cast:
+			return p.parseCast()
+		case Excluded:
+			// EXCLUDED.col: ON CONFLICT DO UPDATE 中本次要插入的值;
+			if err := p.nextExpect(&Token{Type: PERIOD, Value: Period}); err != nil {
+				return nil, err
+			}
+			colName, err := p.nextIdent()
+			if err != nil {
+				return nil, err
+			}
+			return &types.Expression{Field: types.ExcludedField(colName)}, nil
 		default:
 			return nil, util.Error("#parseExpression: Unhandled default case: %s", token.ToString())
 		}
@@ -274,6 +720,147 @@ func (p *Parser) parseExpression() (*types.Expression, error) {
 	return types.NewExpression(con), nil
 }
 
+// parseFunction 解析标量函数的参数列表, 左括号已读取: date_trunc('month', created_at);
+// 参数都是常量且结果稳定时直接算出结果;
+func (p *Parser) parseFunction(funcName string) (*types.Expression, error) {
+	args := make([]*types.Expression, 0)
+	if p.nextIfToken(&Token{Type: CLOSEPAREN, Value: ClosePar}) == nil {
+		for {
+			arg, err := p.computeMathOperator(1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.nextIfToken(&Token{Type: COMMA, Value: Comma}) == nil {
+				break
+			}
+		}
+		if err := p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+			return nil, err
+		}
+	}
+	expression := &types.Expression{Function: &types.Function{FuncName: strings.ToLower(funcName), Args: args}}
+	if types.IsVolatileFunction(funcName) {
+		return expression, nil
+	}
+	values := make([]types.Value, 0, len(args))
+	for _, arg := range args {
+		if arg.ConstVal == nil {
+			return expression, nil
+		}
+		values = append(values, arg.ConstVal)
+	}
+	value, err := types.CallScalarFunction(funcName, values)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewExpression(value), nil
+}
+
+// parseTypedLiteral 解析带类型的字面量: 类型关键字后跟一个字符串;
+func (p *Parser) parseTypedLiteral(token *Token) (*types.Expression, error) {
+	literal, _ := p.next()
+	if literal == nil || literal.Type != STRING {
+		return nil, util.Error("#parseTypedLiteral: %s expect a string literal", token.Value)
+	}
+	var value types.Value
+	var err error
+	switch token.Value {
+	case Date:
+		value, err = types.ParseDate(string(literal.Value))
+	case Time:
+		value, err = types.ParseTime(string(literal.Value))
+	case Timestamp:
+		value, err = types.ParseTimestamp(string(literal.Value))
+	case Decimal, Numeric:
+		value, err = types.ParseDecimal(string(literal.Value))
+	case Blob, Bytea:
+		value = types.NewConstBlob([]byte(literal.Value))
+	case Json:
+		value, err = types.ParseJson(string(literal.Value))
+	default:
+		value, err = types.ParseInterval(string(literal.Value))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types.NewExpression(value), nil
+}
+
+// parseCast CAST(expr AS type), 等价于 cast(expr, '类型名'); 常量在解析时直接转换;
+func (p *Parser) parseCast() (*types.Expression, error) {
+	if err := p.nextExpect(&Token{Type: OPENPAREN, Value: OpenPar}); err != nil {
+		return nil, err
+	}
+	source, err := p.computeMathOperator(1)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.nextExpect(&Token{Type: KEYWORD, Value: As}); err != nil {
+		return nil, err
+	}
+	dataTypeToken, _ := p.next()
+	if dataTypeToken == nil || dataTypeToken.Type != KEYWORD {
+		return nil, util.Error("#parseCast: expect a data type")
+	}
+	target := types.ColumnV{}
+	if target.DataType, err = p.parserDataType(dataTypeToken); err != nil {
+		return nil, err
+	}
+	if target.DataType == types.Decimal {
+		if target.Precision, target.Scale, err = p.parseDecimalPrecision(); err != nil {
+			return nil, err
+		}
+	}
+	if dataTypeToken.Value == Varchar || dataTypeToken.Value == Char {
+		if target.Length, err = p.parseStringLength(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return nil, err
+	}
+	if source.ConstVal != nil {
+		value, err := types.CastTo(source.ConstVal, target)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewExpression(value), nil
+	}
+	typeName := types.NewExpression(&types.ConstString{Value: types.CastTypeName(target)})
+	return &types.Expression{Function: &types.Function{FuncName: types.CastFunction, Args: []*types.Expression{source, typeName}}}, nil
+}
+
+// parseExtract EXTRACT(field FROM expr), 等价于 date_part('field', expr);
+func (p *Parser) parseExtract() (*types.Expression, error) {
+	if err := p.nextExpect(&Token{Type: OPENPAREN, Value: OpenPar}); err != nil {
+		return nil, err
+	}
+	field, _ := p.next()
+	if field == nil || (field.Type != IDENT && field.Type != KEYWORD && field.Type != STRING) {
+		return nil, util.Error("#parseExtract: expect a field name")
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: From}); err != nil {
+		return nil, err
+	}
+	unit := types.NewExpression(&types.ConstString{Value: strings.ToLower(string(field.Value))})
+	source, err := p.computeMathOperator(1)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return nil, err
+	}
+	if source.ConstVal != nil {
+		value, err := types.Extract(string(unit.ConstVal.Bytes()), source.ConstVal)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewExpression(value), nil
+	}
+	return &types.Expression{Function: &types.Function{FuncName: "date_part", Args: []*types.Expression{unit, source}}}, nil
+}
+
 // parseDdlColumn 解析列定义;
 func (p *Parser) parseDdlColumn() (*types.Column, error) {
 	// CREATE TABLE user (id INT, name VARCHAR NOT NULL, age INT DEFAULT 0);
@@ -296,6 +883,17 @@ func (p *Parser) parseDdlColumn() (*types.Column, error) {
 		DefaultValue: nil,
 		PrimaryKey:   false,
 		IsIndex:      false,
+		Serial:       dataTypeToken.Value == Serial,
+	}
+	if dataType == types.Decimal {
+		if column.Precision, column.Scale, err = p.parseDecimalPrecision(); err != nil {
+			return nil, err
+		}
+	}
+	if dataTypeToken.Value == Varchar || dataTypeToken.Value == Char {
+		if column.Length, err = p.parseStringLength(); err != nil {
+			return nil, err
+		}
 	}
 	// 解析列的默认值，以及是否可以为空;
 	for {
@@ -315,6 +913,9 @@ func (p *Parser) parseDdlColumn() (*types.Column, error) {
 				if err != nil {
 					return nil, err
 				}
+				if expr.ConstVal == nil {
+					return nil, util.Error("#parseDdlColumn: default value of column %s must be a constant", filedName)
+				}
 				column.DefaultValue = expr
 			case Primary:
 				err = p.nextExpect(&Token{Type: KEYWORD, Value: Key})
@@ -324,6 +925,26 @@ func (p *Parser) parseDdlColumn() (*types.Column, error) {
 				column.PrimaryKey = true
 			case Index:
 				column.IsIndex = true
+			case Unique:
+				column.Unique = true
+			case AutoIncrement:
+				column.Serial = true
+			case Refer:
+				if column.References, err = p.parseReferences([]string{filedName}); err != nil {
+					return nil, err
+				}
+			case Check:
+				if column.Check, err = p.parseCheck(); err != nil {
+					return nil, err
+				}
+			case Collate:
+				name, err := p.nextIdent()
+				if err != nil {
+					return nil, err
+				}
+				if column.Collation, err = types.ParseCollation(name); err != nil {
+					return nil, err
+				}
 			default:
 				return nil, util.Error("#parseDdlColumn: Unexpected keyword: %s", token.ToString())
 			}
@@ -333,6 +954,48 @@ func (p *Parser) parseDdlColumn() (*types.Column, error) {
 	}
 	return column, nil
 }
+
+// parseDecimalPrecision 解析 DECIMAL 后面可选的 (p[, s]); 省略时不限制精度, 省略 s 时为 0;
+func (p *Parser) parseDecimalPrecision() (int32, int32, error) {
+	if p.nextIfToken(&Token{Type: OPENPAREN, Value: OpenPar}) == nil {
+		return 0, 0, nil
+	}
+	precision, err := p.parseInteger()
+	if err != nil {
+		return 0, 0, err
+	}
+	scale := int64(0)
+	if p.nextIfToken(&Token{Type: COMMA, Value: Comma}) != nil {
+		if scale, err = p.parseInteger(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err = p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return 0, 0, err
+	}
+	if precision < 1 || precision > types.DecimalMaxPrecision || scale < 0 || scale > precision {
+		return 0, 0, util.Error("#parseDecimalPrecision: invalid DECIMAL(%d, %d)", precision, scale)
+	}
+	return int32(precision), int32(scale), nil
+}
+
+// parseStringLength 解析 VARCHAR / CHAR 后面可选的 (n); 省略时不限制长度;
+func (p *Parser) parseStringLength() (int32, error) {
+	if p.nextIfToken(&Token{Type: OPENPAREN, Value: OpenPar}) == nil {
+		return 0, nil
+	}
+	length, err := p.parseInteger()
+	if err != nil {
+		return 0, err
+	}
+	if err = p.nextExpect(&Token{Type: CLOSEPAREN, Value: ClosePar}); err != nil {
+		return 0, err
+	}
+	if length < 1 || length > math.MaxInt32 {
+		return 0, util.Error("#parseStringLength: length for type varchar must be at least 1, got %d", length)
+	}
+	return int32(length), nil
+}
 func (p *Parser) parserDataType(token *Token) (types.DataType, error) {
 	// todo 根据输入字符串来定义数据类型;
 	switch token.Value {
@@ -356,6 +1019,26 @@ func (p *Parser) parserDataType(token *Token) (types.DataType, error) {
 		return types.Float, nil
 	case Double:
 		return types.Float, nil
+	case Serial:
+		return types.Integer, nil
+	case Date:
+		return types.Date, nil
+	case Time:
+		return types.Time, nil
+	case Timestamp:
+		return types.Timestamp, nil
+	case Interval:
+		return types.Interval, nil
+	case Decimal:
+		return types.Decimal, nil
+	case Numeric:
+		return types.Decimal, nil
+	case Blob:
+		return types.Blob, nil
+	case Bytea:
+		return types.Blob, nil
+	case Json:
+		return types.Json, nil
 	default:
 		return -1, util.Error("#parserDataType: token.dataType[%s] is not support", token.ToString())
 	}
@@ -390,6 +1073,25 @@ func (p *Parser) parseInsert() (Statement, error) {
 		}
 		columns = cols
 	}
+	// insert into tbl (a, b) select x, y from src;
+	if token, _ := p.peek(); token != nil && token.Type == KEYWORD && token.Value == Select {
+		selectData, err := p.parseSelect()
+		if err != nil {
+			return nil, err
+		}
+		insertData := &InsertData{
+			TableName: tableName,
+			Columns:   columns,
+			Select:    selectData.(*SelectData),
+		}
+		if insertData.OnConflict, err = p.parseOnConflict(); err != nil {
+			return nil, err
+		}
+		if insertData.Returning, err = p.parseReturningClause(); err != nil {
+			return nil, err
+		}
+		return insertData, nil
+	}
 	err = p.nextExpect(&Token{Type: KEYWORD, Value: Values})
 	if err != nil {
 		return nil, err
@@ -403,7 +1105,7 @@ func (p *Parser) parseInsert() (Statement, error) {
 		}
 		var exprs []*types.Expression
 		for {
-			expression, err := p.parseExpression()
+			expression, err := p.computeMathOperator(1)
 			if err != nil {
 				return nil, err
 			}
@@ -422,11 +1124,74 @@ func (p *Parser) parseInsert() (Statement, error) {
 			break
 		}
 	}
-	return &InsertData{
+	insertData := &InsertData{
 		TableName: tableName,
 		Values:    values,
 		Columns:   columns,
-	}, nil
+	}
+	if insertData.OnConflict, err = p.parseOnConflict(); err != nil {
+		return nil, err
+	}
+	if insertData.Returning, err = p.parseReturningClause(); err != nil {
+		return nil, err
+	}
+	return insertData, nil
+}
+
+// parseOnConflict 解析 ON CONFLICT [(col, ...)] DO NOTHING | DO UPDATE SET col = expr [, ...];
+// 省略冲突列时只能 DO NOTHING; SET 中的 EXCLUDED.col 表示本次要插入的值;
+func (p *Parser) parseOnConflict() (*OnConflictData, error) {
+	if p.nextIfToken(&Token{Type: KEYWORD, Value: On}) == nil {
+		return nil, nil
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Conflict}); err != nil {
+		return nil, err
+	}
+	onConflict := &OnConflictData{}
+	if token, _ := p.peek(); token != nil && token.Type == OPENPAREN {
+		columns, err := p.parseColumnNames()
+		if err != nil {
+			return nil, err
+		}
+		onConflict.Columns = columns
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Do}); err != nil {
+		return nil, err
+	}
+	if p.nextIfToken(&Token{Type: KEYWORD, Value: Nothing}) != nil {
+		return onConflict, nil
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Update}); err != nil {
+		return nil, err
+	}
+	if onConflict.Columns == nil {
+		return nil, util.Error("#parseOnConflict ON CONFLICT DO UPDATE requires conflict columns")
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Set}); err != nil {
+		return nil, err
+	}
+	onConflict.Update = make(map[string]*types.Expression)
+	for {
+		colName, err := p.nextIdent()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.nextExpect(&Token{Type: EQUAL, Value: Equal}); err != nil {
+			return nil, err
+		}
+		value, err := p.computeMathOperator(1)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := onConflict.Update[colName]; ok {
+			return nil, util.Error("#parseOnConflict: column[%s] is already exists", colName)
+		}
+		onConflict.Update[colName] = value
+		if p.nextIfToken(&Token{Type: COMMA, Value: Comma}) == nil {
+			break
+		}
+	}
+	return onConflict, nil
 }
 func (p *Parser) parseSelect() (Statement, error) {
 	selectData := &SelectData{}
@@ -472,12 +1237,25 @@ func (p *Parser) parseSelectClause() ([]*SelectCol, error) {
 	if err != nil {
 		return nil, err
 	}
+	return p.parseSelectCols()
+}
+
+// parseReturningClause 解析 INSERT/UPDATE/DELETE 的 RETURNING 子句, 没有时返回 nil;
+func (p *Parser) parseReturningClause() ([]*SelectCol, error) {
+	if token := p.nextIfToken(&Token{Type: KEYWORD, Value: Returning}); token == nil {
+		return nil, nil
+	}
+	return p.parseSelectCols()
+}
+
+// parseSelectCols 解析 * 或者 expr [AS alias] 列表; * 返回不含元素的切片;
+func (p *Parser) parseSelectCols() ([]*SelectCol, error) {
 	SeqSelectCol := make([]*SelectCol, 0)
 	if token := p.nextIfToken(&Token{Type: ASTERISK, Value: Asterisk}); token != nil {
 		return SeqSelectCol, nil
 	}
 	for {
-		expression, err := p.parseExpression()
+		expression, err := p.computeMathOperator(1)
 		if err != nil {
 			return nil, err
 		}
@@ -623,11 +1401,18 @@ func (p *Parser) parseOrderByClause() ([]*OrderDirection, error) {
 		return nil, err
 	}
 	for {
-		col, _ := p.nextIdent()
+		// order by attrs -> 'size': 除了列名, 也可以按表达式排序;
+		expr, err := p.computeMathOperator(1)
+		if err != nil {
+			return nil, err
+		}
 		token, _ := p.nextIf(func(token *Token) bool {
 			return token.equal(&Token{Type: KEYWORD, Value: Asc}) || token.equal(&Token{Type: KEYWORD, Value: Desc})
 		})
-		orderDirection := &OrderDirection{colName: col}
+		orderDirection := &OrderDirection{colName: expr.Field}
+		if expr.Field == "" {
+			orderDirection.colName, orderDirection.expr = expr.ToString(), expr
+		}
 		if token != nil {
 			if token.equal(&Token{Type: KEYWORD, Value: Asc}) {
 				orderDirection.direction = OrderAsc
@@ -672,9 +1457,14 @@ func (p *Parser) parseDelete() (Statement, error) {
 	}
 	tableName, _ := p.nextIdent()
 	whereClause, _ := p.parseWhereClause()
+	returning, err := p.parseReturningClause()
+	if err != nil {
+		return nil, err
+	}
 	return &DeleteData{
 		TableName:   tableName,
 		WhereClause: whereClause,
+		Returning:   returning,
 	}, nil
 
 }
@@ -700,7 +1490,7 @@ func (p *Parser) parseUpdate() (Statement, error) {
 		if err != nil {
 			return nil, err
 		}
-		value, err := p.parseExpression()
+		value, err := p.computeMathOperator(1)
 		if err != nil {
 			return nil, err
 		}
@@ -713,10 +1503,15 @@ func (p *Parser) parseUpdate() (Statement, error) {
 		}
 	}
 	whereClause, _ := p.parseWhereClause()
+	returning, err := p.parseReturningClause()
+	if err != nil {
+		return nil, err
+	}
 	return &UpdateData{
 		TableName:   tableName,
 		WhereClause: whereClause,
 		Columns:     columns,
+		Returning:   returning,
 	}, nil
 }
 func (p *Parser) parseWhereClause() (*types.Expression, error) {
@@ -727,8 +1522,38 @@ func (p *Parser) parseWhereClause() (*types.Expression, error) {
 	return p.parseOperationExpr()
 }
 
+// parseOperationExpr 解析条件表达式; 优先级: OR < AND < 比较运算;
+// a = 1 and b > 2 or c < 3  =>  (a = 1 and b > 2) or (c < 3)
 func (p *Parser) parseOperationExpr() (*types.Expression, error) {
-	left, err := p.parseExpression()
+	left, err := p.parseAndExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.nextIfToken(&Token{Type: KEYWORD, Value: Or}) != nil {
+		right, err := p.parseAndExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &types.Expression{OperationVal: &types.OperationOr{Left: left, Right: right}}
+	}
+	return left, nil
+}
+func (p *Parser) parseAndExpr() (*types.Expression, error) {
+	left, err := p.parseCompareExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.nextIfToken(&Token{Type: KEYWORD, Value: And}) != nil {
+		right, err := p.parseCompareExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &types.Expression{OperationVal: &types.OperationAnd{Left: left, Right: right}}
+	}
+	return left, nil
+}
+func (p *Parser) parseCompareExpr() (*types.Expression, error) {
+	left, err := p.computeMathOperator(1)
 	if err != nil {
 		return nil, err
 	}
@@ -759,27 +1584,120 @@ func (p *Parser) parseOperationExpr() (*types.Expression, error) {
 					Right: right,
 				},
 			}, nil
+		case GREATEREQUAL:
+			return &types.Expression{
+				OperationVal: &types.OperationGreaterEqual{
+					Left:  left,
+					Right: right,
+				},
+			}, nil
+		case LESSEQUAL:
+			return &types.Expression{
+				OperationVal: &types.OperationLessEqual{
+					Left:  left,
+					Right: right,
+				},
+			}, nil
 		default:
-			return nil, util.Error("#parseOperationExpr unhandled default case %s", next.ToString())
+			return nil, util.Error("#parseCompareExpr unhandled default case %s", next.ToString())
 		}
 	} else {
 		return nil, err
 	}
 }
+
+// parseTransaction BEGIN, COMMIT, ROLLBACK [TO [SAVEPOINT] name], SAVEPOINT name, RELEASE [SAVEPOINT] name;
 func (p *Parser) parseTransaction() (Statement, error) {
 	if next, _ := p.next(); next != nil {
 		if next.Value == Begin {
-			return &BeginData{}, nil
+			return p.parseBegin()
 		} else if next.Value == Commit {
 			return &CommitData{}, nil
 		} else if next.Value == Rollback {
-			return &RollbackData{}, nil
+			if p.nextIfToken(&Token{Type: KEYWORD, Value: To}) == nil {
+				return &RollbackData{}, nil
+			}
+			name, err := p.parseSavepointName()
+			if err != nil {
+				return nil, err
+			}
+			return &RollbackData{Savepoint: name}, nil
+		} else if next.Value == Savepoint {
+			name, err := p.nextIdent()
+			if err != nil {
+				return nil, err
+			}
+			return &SavepointData{Name: name}, nil
+		} else if next.Value == Release {
+			name, err := p.parseSavepointName()
+			if err != nil {
+				return nil, err
+			}
+			return &ReleaseSavepointData{Name: name}, nil
 		} else {
 			return nil, util.Error("#parseTransaction unhandled default case %s\n;", next.ToString())
 		}
 	}
 	return nil, nil
 }
+
+// parseBegin BEGIN [ISOLATION LEVEL {SERIALIZABLE | REPEATABLE READ | READ COMMITTED}]; 省略时使用会话默认的隔离级别;
+func (p *Parser) parseBegin() (Statement, error) {
+	if p.nextIfToken(&Token{Type: KEYWORD, Value: Isolation}) == nil {
+		return &BeginData{}, nil
+	}
+	level, err := p.parseIsolationLevel()
+	if err != nil {
+		return nil, err
+	}
+	return &BeginData{Isolation: &level}, nil
+}
+
+// parseSetSession SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL ...; 修改会话默认的隔离级别;
+func (p *Parser) parseSetSession() (Statement, error) {
+	for _, keyword := range []TokenValue{Set, SessionKeyword, Characteristics, As, Transaction, Isolation} {
+		if err := p.nextExpect(&Token{Type: KEYWORD, Value: keyword}); err != nil {
+			return nil, err
+		}
+	}
+	level, err := p.parseIsolationLevel()
+	if err != nil {
+		return nil, err
+	}
+	return &SetIsolationData{Isolation: level}, nil
+}
+
+// parseIsolationLevel ISOLATION 之后的 LEVEL {SERIALIZABLE | REPEATABLE READ | READ COMMITTED};
+func (p *Parser) parseIsolationLevel() (storage.IsolationLevel, error) {
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: Level}); err != nil {
+		return 0, err
+	}
+	next, _ := p.next()
+	if next == nil {
+		return 0, util.Error("#parseIsolationLevel expect isolation level")
+	}
+	switch next.Value {
+	case Serializable:
+		return storage.Serializable, nil
+	case Repeatable:
+		if err := p.nextExpect(&Token{Type: KEYWORD, Value: Read}); err != nil {
+			return 0, err
+		}
+		return storage.SnapshotIsolation, nil
+	case Read:
+		if err := p.nextExpect(&Token{Type: KEYWORD, Value: Committed}); err != nil {
+			return 0, err
+		}
+		return storage.ReadCommitted, nil
+	}
+	return 0, util.Error("#parseIsolationLevel unsupported isolation level %s", next.ToString())
+}
+
+// parseSavepointName ROLLBACK TO 与 RELEASE 之后的 SAVEPOINT 关键字可以省略;
+func (p *Parser) parseSavepointName() (string, error) {
+	p.nextIfToken(&Token{Type: KEYWORD, Value: Savepoint})
+	return p.nextIdent()
+}
 func (p *Parser) parseExplain() (Statement, error) {
 	err := p.nextExpect(&Token{Type: KEYWORD, Value: Explain})
 	if err != nil {
@@ -800,9 +1718,71 @@ func (p *Parser) parseExplain() (Statement, error) {
 	}, nil
 }
 
-func (p *Parser) parseDdlCreateView() Statement {
-	// todo 有待实现
-	return nil
+// CREATE [OR REPLACE] VIEW view_name AS SELECT ...;
+// CREATE MATERIALIZED VIEW view_name AS SELECT ...;
+func (p *Parser) parseDdlCreateView() (Statement, error) {
+	createViewData := &CreateViewData{}
+	if p.nextIfToken(&Token{Type: KEYWORD, Value: Or}) != nil {
+		if err := p.nextExpect(&Token{Type: KEYWORD, Value: Replace}); err != nil {
+			return nil, err
+		}
+		createViewData.Replace = true
+	}
+	if p.nextIfToken(&Token{Type: KEYWORD, Value: Materialized}) != nil {
+		if createViewData.Replace {
+			return nil, util.Error("#parseDdlCreateView: materialized view does not support OR REPLACE")
+		}
+		createViewData.Materialized = true
+	}
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: View}); err != nil {
+		return nil, err
+	}
+	viewName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	createViewData.ViewName = viewName
+	if err = p.nextExpect(&Token{Type: KEYWORD, Value: As}); err != nil {
+		return nil, err
+	}
+	// 视图保存查询语句的原文, 使用时重新解析展开;
+	query := p.lexer.rest()
+	statement, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	createViewData.Select = statement.(*SelectData)
+	createViewData.Query = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), string(Semicolon)))
+	return createViewData, nil
+}
+
+// DROP [MATERIALIZED] VIEW view_name [CASCADE];
+func (p *Parser) parseDdlDropView() (Statement, error) {
+	p.nextIfToken(&Token{Type: KEYWORD, Value: Materialized})
+	if err := p.nextExpect(&Token{Type: KEYWORD, Value: View}); err != nil {
+		return nil, err
+	}
+	viewName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	dropViewData := &DropViewData{ViewName: viewName}
+	dropViewData.Cascade = p.nextIfToken(&Token{Type: KEYWORD, Value: Cascade}) != nil
+	return dropViewData, nil
+}
+
+// REFRESH MATERIALIZED VIEW view_name;
+func (p *Parser) parseDdlRefreshView() (Statement, error) {
+	for _, keyword := range []TokenValue{Refresh, Materialized, View} {
+		if err := p.nextExpect(&Token{Type: KEYWORD, Value: keyword}); err != nil {
+			return nil, err
+		}
+	}
+	viewName, err := p.nextIdent()
+	if err != nil {
+		return nil, err
+	}
+	return &RefreshViewData{ViewName: viewName}, nil
 }
 
 func (p *Parser) parseShow() (Statement, error) {
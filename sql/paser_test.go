@@ -1,6 +1,10 @@
 package sql
 
 import (
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/storage"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,6 +30,24 @@ func TestParserInsert(t *testing.T) {
 	insertData := statement.(*InsertData)
 	insertData.Statement()
 }
+
+func TestParserInsertSelect(t *testing.T) {
+	sql := "INSERT INTO user (id, name) SELECT id, name FROM member WHERE age > 18;"
+	statement, err := NewParser(sql).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	insertData := statement.(*InsertData)
+	if insertData.Select == nil || insertData.Values != nil || len(insertData.Columns) != 2 {
+		t.Errorf("unexpected %+v", insertData)
+		return
+	}
+	if len(insertData.Select.SelectCols) != 2 || insertData.Select.WhereClause == nil {
+		t.Errorf("unexpected select %+v", insertData.Select)
+	}
+	insertData.Statement()
+}
 func TestParserSelect(t *testing.T) {
 	sql := "SELECT * FROM user;"
 	parser := NewParser(sql)
@@ -61,3 +83,554 @@ func TestParserExplain(t *testing.T) {
 	explainData := statement.(*ExplainData)
 	explainData.Statement()
 }
+
+func TestParserCreateIndex(t *testing.T) {
+	sql := "CREATE UNIQUE INDEX idx_name ON user (name);"
+	parser := NewParser(sql)
+	statement, err := parser.Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	createIndexData := statement.(*CreateIndexData)
+	if !createIndexData.Unique || createIndexData.TableName != "user" || createIndexData.Columns[0] != "name" {
+		t.Errorf("unexpected create index statement: %+v", createIndexData)
+	}
+	createIndexData.Statement()
+
+	statement, err = NewParser("DROP INDEX idx_name;").Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	statement.(*DropIndexData).Statement()
+}
+
+func TestParserCompositePrimaryKey(t *testing.T) {
+	sql := "CREATE TABLE user (tenant INT, id INT, name STRING, PRIMARY KEY (tenant, id));"
+	statement, err := NewParser(sql).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	createTableData := statement.(*CreatTableData)
+	if len(createTableData.Columns) != 3 || len(createTableData.PrimaryKeys) != 2 || createTableData.PrimaryKeys[1] != "id" {
+		t.Errorf("unexpected create table statement: %+v", createTableData)
+	}
+	for _, sql := range []string{
+		"CREATE TABLE user (a INT PRIMARY KEY, b INT, PRIMARY KEY (a, b));",
+		"CREATE TABLE user (PRIMARY KEY (a, b), a INT, b INT PRIMARY KEY);",
+		"CREATE TABLE user (a INT, b INT, PRIMARY KEY (a), PRIMARY KEY (b));",
+	} {
+		if _, err = NewParser(sql).Parse(); err == nil || !strings.Contains(err.Error(), "multiple primary key") {
+			t.Errorf("%s expect multiple primary key error, got: %v", sql, err)
+		}
+	}
+}
+
+func TestParserUnique(t *testing.T) {
+	sql := "CREATE TABLE user (id INT PRIMARY KEY, email STRING UNIQUE, a INT, b INT, UNIQUE (a, b));"
+	statement, err := NewParser(sql).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	createTableData := statement.(*CreatTableData)
+	if !createTableData.Columns[1].Unique || len(createTableData.Uniques) != 1 || len(createTableData.Uniques[0]) != 2 {
+		t.Errorf("unexpected create table statement: %+v", createTableData)
+	}
+}
+
+func TestParserForeignKey(t *testing.T) {
+	sql := "CREATE TABLE orders (id INT PRIMARY KEY, uid INT REFERENCES users (id) ON DELETE CASCADE, " +
+		"pid INT, FOREIGN KEY (pid) REFERENCES products ON UPDATE SET NULL);"
+	statement, err := NewParser(sql).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	createTableData := statement.(*CreatTableData)
+	references := createTableData.Columns[1].References
+	if references == nil || references.RefTable != "users" || references.OnDelete != types.ActionCascade || references.OnUpdate != types.ActionRestrict {
+		t.Errorf("unexpected column references: %+v", references)
+	}
+	foreignKey := createTableData.ForeignKeys[0]
+	if foreignKey.RefTable != "products" || len(foreignKey.RefColumns) != 0 || foreignKey.OnUpdate != types.ActionSetNull {
+		t.Errorf("unexpected foreign key: %+v", foreignKey)
+	}
+}
+
+func TestParserCheck(t *testing.T) {
+	sql := "CREATE TABLE product (id INT PRIMARY KEY, price FLOAT CHECK (price > 0), lo INT, hi INT, CHECK (lo <= hi AND hi < 100));"
+	statement, err := NewParser(sql).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	createTableData := statement.(*CreatTableData)
+	if createTableData.Columns[1].Check == nil || createTableData.Columns[1].Check.ToString() != "price > 0" {
+		t.Errorf("unexpected column check: %+v", createTableData.Columns[1])
+	}
+	if len(createTableData.Checks) != 1 || createTableData.Checks[0].ToString() != "lo <= hi AND hi < 100" {
+		t.Errorf("unexpected table checks: %+v", createTableData.Checks)
+	}
+}
+
+func TestParserWhereAndOr(t *testing.T) {
+	sql := "SELECT * FROM user where a = 1 and b >= 2 or c <= 3;"
+	parser := NewParser(sql)
+	statement, err := parser.Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	selectData := statement.(*SelectData)
+	// OR 的优先级低于 AND;
+	if selectData.WhereClause.ToString() != "(a = 1 AND b >= 2 OR c <= 3)" {
+		t.Errorf("unexpected where clause: %s", selectData.WhereClause.ToString())
+	}
+}
+
+func TestParserAlterTable(t *testing.T) {
+	tests := []struct {
+		sql    string
+		expect AlterTableData
+	}{
+		{"ALTER TABLE user ADD COLUMN age INT DEFAULT 0;", AlterTableData{TableName: "user", Action: AlterAddColumn}},
+		{"ALTER TABLE user DROP age;", AlterTableData{TableName: "user", Action: AlterDropColumn, ColumnName: "age"}},
+		{"ALTER TABLE user RENAME COLUMN age TO years;", AlterTableData{TableName: "user", Action: AlterRenameColumn, ColumnName: "age", NewName: "years"}},
+		{"ALTER TABLE user RENAME TO member;", AlterTableData{TableName: "user", Action: AlterRenameTable, NewName: "member"}},
+		{"ALTER TABLE user ALTER COLUMN age SET DEFAULT 1;", AlterTableData{TableName: "user", Action: AlterSetDefault, ColumnName: "age"}},
+		{"ALTER TABLE user ALTER age DROP DEFAULT;", AlterTableData{TableName: "user", Action: AlterDropDefault, ColumnName: "age"}},
+		{"ALTER TABLE user ALTER COLUMN age SET NOT NULL;", AlterTableData{TableName: "user", Action: AlterSetNotNull, ColumnName: "age"}},
+		{"ALTER TABLE user ALTER COLUMN age DROP NOT NULL;", AlterTableData{TableName: "user", Action: AlterDropNotNull, ColumnName: "age"}},
+	}
+	for _, test := range tests {
+		statement, err := NewParser(test.sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", test.sql, err)
+			continue
+		}
+		alterTableData := statement.(*AlterTableData)
+		if alterTableData.TableName != test.expect.TableName || alterTableData.Action != test.expect.Action ||
+			alterTableData.ColumnName != test.expect.ColumnName || alterTableData.NewName != test.expect.NewName {
+			t.Errorf("%s: unexpected %+v", test.sql, alterTableData)
+		}
+		if test.expect.Action == AlterAddColumn && (alterTableData.Column == nil || alterTableData.Column.Name != "age") {
+			t.Errorf("%s: unexpected column %+v", test.sql, alterTableData.Column)
+		}
+		if test.expect.Action == AlterSetDefault && alterTableData.Default == nil {
+			t.Errorf("%s: expect default value", test.sql)
+		}
+	}
+	if _, err := NewParser("ALTER TABLE user MODIFY age INT;").Parse(); err == nil {
+		t.Errorf("expect error for unsupported alter action")
+	}
+}
+
+func TestParserSequence(t *testing.T) {
+	tests := []struct {
+		sql    string
+		expect CreateSequenceData
+	}{
+		{"CREATE SEQUENCE seq;", CreateSequenceData{SequenceName: "seq", Start: 1, Increment: 1}},
+		{"CREATE SEQUENCE seq START WITH 100 INCREMENT BY 10;", CreateSequenceData{SequenceName: "seq", Start: 100, Increment: 10}},
+		{"CREATE SEQUENCE seq INCREMENT -1 START 0;", CreateSequenceData{SequenceName: "seq", Start: 0, Increment: -1}},
+	}
+	for _, test := range tests {
+		statement, err := NewParser(test.sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", test.sql, err)
+			continue
+		}
+		if data := statement.(*CreateSequenceData); *data != test.expect {
+			t.Errorf("%s: unexpected %+v", test.sql, data)
+		}
+	}
+	statement, err := NewParser("DROP SEQUENCE seq;").Parse()
+	if err != nil || statement.(*DropSequenceData).SequenceName != "seq" {
+		t.Errorf("DROP SEQUENCE: unexpected %+v, %v", statement, err)
+	}
+	statement, err = NewParser("CREATE TABLE t (id SERIAL PRIMARY KEY, code INT AUTO_INCREMENT);").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range statement.(*CreatTableData).Columns {
+		if !column.Serial || column.DateType != types.Integer {
+			t.Errorf("column %s expect auto increment integer, got %+v", column.Name, column)
+		}
+	}
+}
+
+func TestParserOnConflict(t *testing.T) {
+	statement, err := NewParser("INSERT INTO user VALUES (1, 'a') ON CONFLICT DO NOTHING;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onConflict := statement.(*InsertData).OnConflict; onConflict == nil || onConflict.Columns != nil || onConflict.Update != nil {
+		t.Errorf("unexpected %+v", onConflict)
+	}
+	sql := "INSERT INTO user (id, name) VALUES (1, 'a') ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = 1;"
+	statement, err = NewParser(sql).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	onConflict := statement.(*InsertData).OnConflict
+	if onConflict == nil || len(onConflict.Columns) != 1 || len(onConflict.Update) != 2 {
+		t.Fatalf("unexpected %+v", onConflict)
+	}
+	if field := onConflict.Update["name"].Field; field != types.ExcludedField("name") {
+		t.Errorf("unexpected field %s", field)
+	}
+	if _, err = NewParser("INSERT INTO user VALUES (1) ON CONFLICT DO UPDATE SET id = 2;").Parse(); err == nil {
+		t.Errorf("expect error for DO UPDATE without conflict columns")
+	}
+}
+
+func TestParserReturning(t *testing.T) {
+	tests := []struct {
+		sql       string
+		returning int
+	}{
+		{"INSERT INTO user VALUES (1, 'a') RETURNING *;", 0},
+		{"INSERT INTO user VALUES (1, 'a') ON CONFLICT DO NOTHING RETURNING id, name AS n;", 2},
+		{"UPDATE user SET name = 'b' WHERE id = 1 RETURNING id;", 1},
+		{"DELETE FROM user WHERE id = 1 RETURNING *;", 0},
+	}
+	for _, test := range tests {
+		statement, err := NewParser(test.sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", test.sql, err)
+			continue
+		}
+		var returning []*SelectCol
+		switch data := statement.(type) {
+		case *InsertData:
+			returning = data.Returning
+		case *UpdateData:
+			returning = data.Returning
+		case *DeleteData:
+			returning = data.Returning
+		}
+		if returning == nil || len(returning) != test.returning {
+			t.Errorf("%s: unexpected returning %v", test.sql, returning)
+		}
+	}
+}
+
+func TestParserTruncate(t *testing.T) {
+	for _, sql := range []string{"TRUNCATE TABLE user;", "TRUNCATE user;"} {
+		statement, err := NewParser(sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", sql, err)
+			continue
+		}
+		if data := statement.(*TruncateTableData); data.TableName != "user" {
+			t.Errorf("%s: unexpected %+v", sql, data)
+		}
+	}
+}
+
+func TestParserView(t *testing.T) {
+	statement, err := NewParser("CREATE OR REPLACE VIEW v AS SELECT id, name FROM user WHERE id > 1 ;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createViewData := statement.(*CreateViewData)
+	if createViewData.ViewName != "v" || !createViewData.Replace || createViewData.Select == nil {
+		t.Errorf("unexpected %+v", createViewData)
+	}
+	// 保存的查询语句是原文, 不含结尾的分号;
+	if createViewData.Query != "SELECT id, name FROM user WHERE id > 1" {
+		t.Errorf("unexpected query %q", createViewData.Query)
+	}
+	if _, err = NewParser(createViewData.Query + ";").Parse(); err != nil {
+		t.Errorf("query %q can not be parsed: %s", createViewData.Query, err)
+	}
+	statement, err = NewParser("DROP VIEW v CASCADE;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := statement.(*DropViewData); data.ViewName != "v" || !data.Cascade {
+		t.Errorf("unexpected %+v", data)
+	}
+	statement, err = NewParser("DROP TABLE user CASCADE;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := statement.(*DropTableData); data.TableName != "user" || !data.Cascade {
+		t.Errorf("unexpected %+v", data)
+	}
+}
+
+func TestParserMaterializedView(t *testing.T) {
+	statement, err := NewParser("CREATE MATERIALIZED VIEW v AS SELECT b, count(a) FROM t GROUP BY b;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := statement.(*CreateViewData); data.ViewName != "v" || !data.Materialized || data.Replace {
+		t.Errorf("unexpected %+v", data)
+	}
+	statement, err = NewParser("REFRESH MATERIALIZED VIEW v;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := statement.(*RefreshViewData); data.ViewName != "v" {
+		t.Errorf("unexpected %+v", data)
+	}
+	statement, err = NewParser("DROP MATERIALIZED VIEW v;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := statement.(*DropViewData); data.ViewName != "v" {
+		t.Errorf("unexpected %+v", data)
+	}
+	if _, err = NewParser("CREATE OR REPLACE MATERIALIZED VIEW v AS SELECT * FROM t;").Parse(); err == nil {
+		t.Errorf("expect OR REPLACE to be rejected for materialized view")
+	}
+}
+
+func TestParserTemporal(t *testing.T) {
+	statement, err := NewParser("SELECT a FROM t WHERE created >= DATE '2024-01-01' + INTERVAL '1 month' AND extract(year FROM created) = 2024;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	and := statement.(*SelectData).WhereClause.OperationVal.(*types.OperationAnd)
+	// 常量之间的运算在解析时算出结果;
+	ge := and.Left.OperationVal.(*types.OperationGreaterEqual)
+	if value, ok := ge.Right.ConstVal.(*types.ConstTimestamp); !ok || string(value.Bytes()) != "2024-02-01 00:00:00" {
+		t.Errorf("unexpected %s", ge.Right.ToString())
+	}
+	eq := and.Right.OperationVal.(*types.OperationEqual)
+	if function := eq.Left.Function; function == nil || function.FuncName != "date_part" || len(function.Args) != 2 {
+		t.Errorf("unexpected %s", eq.Left.ToString())
+	}
+	statement, err = NewParser("SELECT now() - created AS age FROM t;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arith, ok := statement.(*SelectData).SelectCols[0].Expr.OperationVal.(*types.OperationArith); !ok || arith.Operator != types.ArithSub {
+		t.Errorf("unexpected %s", statement.(*SelectData).SelectCols[0].Expr.ToString())
+	}
+	if _, err = NewParser("SELECT a FROM t WHERE created > TIMESTAMP 'yesterday';").Parse(); err == nil {
+		t.Errorf("expect invalid timestamp literal to be rejected")
+	}
+}
+
+func TestParserDecimal(t *testing.T) {
+	statement, err := NewParser("CREATE TABLE t (id INT PRIMARY KEY, price DECIMAL(10, 2) NOT NULL, rate NUMERIC(4), total DECIMAL);").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := statement.(*CreatTableData).Columns
+	if columns[1].DateType != types.Decimal || columns[1].Precision != 10 || columns[1].Scale != 2 || columns[1].Nullable {
+		t.Errorf("unexpected %+v", columns[1])
+	}
+	if columns[2].Precision != 4 || columns[2].Scale != 0 || columns[3].Precision != 0 {
+		t.Errorf("unexpected %+v %+v", columns[2], columns[3])
+	}
+	if _, err = NewParser("CREATE TABLE t (id INT PRIMARY KEY, price DECIMAL(2, 3));").Parse(); err == nil {
+		t.Errorf("expect scale larger than precision to be rejected")
+	}
+	statement, err = NewParser("SELECT a FROM t WHERE price = DECIMAL '0.1' + DECIMAL '0.2';").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal := statement.(*SelectData).WhereClause.OperationVal.(*types.OperationEqual)
+	if value, ok := equal.Right.ConstVal.(*types.ConstDecimal); !ok || string(value.Bytes()) != "0.3" {
+		t.Errorf("unexpected %s", equal.Right.ToString())
+	}
+}
+
+func TestParserBlob(t *testing.T) {
+	statement, err := NewParser("CREATE TABLE t (id INT PRIMARY KEY, hash BLOB INDEX, data BYTEA);").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := statement.(*CreatTableData).Columns
+	if columns[1].DateType != types.Blob || columns[2].DateType != types.Blob {
+		t.Errorf("unexpected %+v %+v", columns[1], columns[2])
+	}
+	statement, err = NewParser("INSERT INTO t VALUES (1, X'00ff', BLOB 'a\\x00');").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := statement.(*InsertData).Values[0]
+	if blob, ok := values[1].ConstVal.(*types.ConstBlob); !ok || string(blob.Value) != "\x00\xff" {
+		t.Errorf("unexpected %s", values[1].ToString())
+	}
+	if blob, ok := values[2].ConstVal.(*types.ConstBlob); !ok || string(blob.Value) != "a\x00" || values[2].ToString() != "X'6100'" {
+		t.Errorf("unexpected %s", values[2].ToString())
+	}
+	// 参数都是常量时解析阶段直接算出结果;
+	statement, err = NewParser("SELECT substring(X'010203', 2), length('ab') FROM t;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectCols := statement.(*SelectData).SelectCols
+	if selectCols[0].Expr.ToString() != "X'0203'" || selectCols[1].Expr.ToString() != "2" {
+		t.Errorf("unexpected %s, %s", selectCols[0].Expr.ToString(), selectCols[1].Expr.ToString())
+	}
+}
+func TestParserJson(t *testing.T) {
+	statement, err := NewParser("CREATE TABLE t (id INT PRIMARY KEY, attrs JSON);").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns := statement.(*CreatTableData).Columns; columns[1].DateType != types.Json {
+		t.Errorf("unexpected %+v", columns[1])
+	}
+	// -> 与 ->> 从左到右结合, 显示时路径中的字符串加上引号;
+	statement, err = NewParser("SELECT attrs -> 'tags' ->> 0, json_keys(attrs) FROM t WHERE attrs ->> 'color' = 'red' ORDER BY attrs -> 'size' DESC;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectData := statement.(*SelectData)
+	if text := selectData.SelectCols[0].Expr.ToString(); text != "attrs -> 'tags' ->> 0" {
+		t.Errorf("unexpected %s", text)
+	}
+	if text := selectData.WhereClause.ToString(); !strings.HasPrefix(text, "attrs ->> 'color'") {
+		t.Errorf("unexpected %s", text)
+	}
+	if order := selectData.OrderBy[0]; order.colName != "attrs -> 'size'" || order.expr == nil || order.direction != OrderDesc {
+		t.Errorf("unexpected order by %+v", order)
+	}
+	// 常量文档上的路径在解析时算出结果;
+	statement, err = NewParser(`SELECT JSON '{"b": [1, 2], "a": null}' -> 'b' FROM t;`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := statement.(*SelectData).SelectCols[0].Expr.ToString(); text != "[1,2]" {
+		t.Errorf("unexpected %s", text)
+	}
+	if _, err = NewParser(`SELECT JSON '{"b": }' FROM t;`).Parse(); err == nil {
+		t.Errorf("expect invalid json error")
+	}
+	// 表达式索引: 列名与括号中的表达式可以混用;
+	statement, err = NewParser("CREATE UNIQUE INDEX t_color ON t (id, (attrs ->> 'color'));").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createIndex := statement.(*CreateIndexData)
+	if len(createIndex.Columns) != 2 || createIndex.Columns[0] != "id" || createIndex.Columns[1] != "attrs ->> 'color'" ||
+		createIndex.Expressions[0] != nil || createIndex.Expressions[1] == nil {
+		t.Errorf("unexpected %+v", createIndex)
+	}
+}
+func TestParserStringLength(t *testing.T) {
+	statement, err := NewParser("CREATE TABLE t (id INT PRIMARY KEY, code CHAR(3), name VARCHAR(20) COLLATE NoCase NOT NULL, note TEXT);").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := statement.(*CreatTableData).Columns
+	if columns[1].Length != 3 || columns[1].Collation != "" {
+		t.Errorf("unexpected %+v", columns[1])
+	}
+	if columns[2].Length != 20 || columns[2].Collation != types.CollateNocase || columns[2].Nullable {
+		t.Errorf("unexpected %+v", columns[2])
+	}
+	if columns[3].Length != 0 {
+		t.Errorf("unexpected %+v", columns[3])
+	}
+	for _, sql := range []string{
+		"CREATE TABLE t (id INT PRIMARY KEY, name VARCHAR(0));",
+		"CREATE TABLE t (id INT PRIMARY KEY, name VARCHAR(a));",
+		"CREATE TABLE t (id INT PRIMARY KEY, name TEXT COLLATE latin1);",
+	} {
+		if _, err = NewParser(sql).Parse(); err == nil {
+			t.Errorf("%s expect error", sql)
+		}
+	}
+}
+
+func TestParserCast(t *testing.T) {
+	statement, err := NewParser("SELECT CAST(price AS DECIMAL(10, 2)), CAST(name AS VARCHAR(3)), CAST('12' AS INT) FROM t;").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectCols := statement.(*SelectData).SelectCols
+	if text := selectCols[0].Expr.ToString(); text != "CAST(price AS Decimal(10, 2))" {
+		t.Errorf("unexpected %s", text)
+	}
+	if text := selectCols[1].Expr.ToString(); text != "CAST(name AS String(3))" {
+		t.Errorf("unexpected %s", text)
+	}
+	// 常量在解析时直接转换;
+	if value, ok := selectCols[2].Expr.ConstVal.(*types.ConstInt); !ok || value.Value != 12 {
+		t.Errorf("unexpected %s", selectCols[2].Expr.ToString())
+	}
+	for _, sql := range []string{
+		"SELECT CAST(1.5 AS INT) FROM t;",
+		"SELECT CAST(price DECIMAL) FROM t;",
+		"SELECT CAST(price AS t) FROM t;",
+	} {
+		if _, err = NewParser(sql).Parse(); err == nil {
+			t.Errorf("%s expect error", sql)
+		}
+	}
+}
+
+func TestParserSavepoint(t *testing.T) {
+	for sql, expect := range map[string]Statement{
+		"SAVEPOINT a;":             &SavepointData{Name: "a"},
+		"ROLLBACK;":                &RollbackData{},
+		"ROLLBACK TO SAVEPOINT a;": &RollbackData{Savepoint: "a"},
+		"ROLLBACK TO a;":           &RollbackData{Savepoint: "a"},
+		"RELEASE SAVEPOINT a;":     &ReleaseSavepointData{Name: "a"},
+		"RELEASE a;":               &ReleaseSavepointData{Name: "a"},
+	} {
+		statement, err := NewParser(sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", sql, err)
+		} else if !reflect.DeepEqual(statement, expect) {
+			t.Errorf("%s: unexpected %+v", sql, statement)
+		}
+	}
+	for _, sql := range []string{"SAVEPOINT;", "ROLLBACK TO;", "RELEASE SAVEPOINT;"} {
+		if _, err := NewParser(sql).Parse(); err == nil {
+			t.Errorf("%s expect error", sql)
+		}
+	}
+}
+
+func TestParserBeginIsolation(t *testing.T) {
+	serializable, snapshot, readCommitted := storage.Serializable, storage.SnapshotIsolation, storage.ReadCommitted
+	for sql, expect := range map[string]*BeginData{
+		"BEGIN;":                                 {},
+		"BEGIN ISOLATION LEVEL SERIALIZABLE;":    {Isolation: &serializable},
+		"BEGIN ISOLATION LEVEL REPEATABLE READ;": {Isolation: &snapshot},
+		"BEGIN ISOLATION LEVEL READ COMMITTED;":  {Isolation: &readCommitted},
+	} {
+		statement, err := NewParser(sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", sql, err)
+		} else if !reflect.DeepEqual(statement, expect) {
+			t.Errorf("%s: unexpected %+v", sql, statement)
+		}
+	}
+	for _, sql := range []string{"BEGIN ISOLATION SERIALIZABLE;", "BEGIN ISOLATION LEVEL REPEATABLE;", "BEGIN ISOLATION LEVEL READ;", "BEGIN ISOLATION LEVEL;"} {
+		if _, err := NewParser(sql).Parse(); err == nil {
+			t.Errorf("%s expect error", sql)
+		}
+	}
+}
+
+func TestParserSetIsolation(t *testing.T) {
+	for sql, expect := range map[string]*SetIsolationData{
+		"SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL SERIALIZABLE;":    {Isolation: storage.Serializable},
+		"SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL REPEATABLE READ;": {Isolation: storage.SnapshotIsolation},
+		"set session characteristics as transaction isolation level read committed;":  {Isolation: storage.ReadCommitted},
+	} {
+		statement, err := NewParser(sql).Parse()
+		if err != nil {
+			t.Errorf("%s: %s", sql, err)
+		} else if !reflect.DeepEqual(statement, expect) {
+			t.Errorf("%s: unexpected %+v", sql, statement)
+		}
+	}
+	for _, sql := range []string{"SET SESSION ISOLATION LEVEL SERIALIZABLE;", "SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL;", "SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL CHAOS;"} {
+		if _, err := NewParser(sql).Parse(); err == nil {
+			t.Errorf("%s expect error", sql)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package sql
 
 import (
+	"fmt"
 	"github.com/kebukeYi/TrainSQL/sql/types"
 	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math/big"
+	"slices"
+	"strings"
 )
 
 type Plan struct {
@@ -33,39 +37,108 @@ func (p *Plan) BuildNode() (Node, error) {
 	var err error
 	var ast Statement
 	ast = p.ast
+	bindStatement(ast, p.Service)
 	switch ast.(type) {
 	case *CreatTableData:
-		columnVs := make([]types.ColumnV, 0)
-		columns := ast.(*CreatTableData).Columns
-		for _, column := range columns {
-			columnV := types.ColumnV{
-				Name:     column.Name,
-				DataType: column.DateType,
-			}
-			columnV.Nullable = !column.PrimaryKey
-			if column.DefaultValue != nil {
-				columnV.DefaultValue = column.DefaultValue.ConstVal
-			}
-			columnV.PrimaryKey = column.PrimaryKey
-			columnV.IsIndex = column.IsIndex
-			columnVs = append(columnVs, columnV)
-		}
 		node = &CreateTableNode{
-			Schema: &types.Table{
-				Name:    ast.(*CreatTableData).TableName,
-				Columns: columnVs,
+			Schema: buildTableSchema(ast.(*CreatTableData)),
+		}
+	case *CreateSequenceData:
+		createSequenceData := ast.(*CreateSequenceData)
+		node = &CreateSequenceNode{
+			Sequence: &types.Sequence{
+				Name:      createSequenceData.SequenceName,
+				Start:     createSequenceData.Start,
+				Increment: createSequenceData.Increment,
 			},
 		}
+	case *DropSequenceData:
+		node = &DropSequenceNode{
+			SequenceName: ast.(*DropSequenceData).SequenceName,
+		}
+	case *AlterTableData:
+		alterTableData := ast.(*AlterTableData)
+		alterTableNode := &AlterTableNode{
+			TableName:  alterTableData.TableName,
+			Action:     alterTableData.Action,
+			ColumnName: alterTableData.ColumnName,
+			NewName:    alterTableData.NewName,
+		}
+		// ADD COLUMN 的列定义连同列约束, 按建表的规则转换为只有这一列的表结构;
+		if alterTableData.Column != nil {
+			alterTableNode.Definition = buildTableSchema(&CreatTableData{
+				TableName: alterTableData.TableName,
+				Columns:   []*types.Column{alterTableData.Column},
+			})
+		}
+		if alterTableData.Default != nil {
+			alterTableNode.Default = alterTableData.Default.ConstVal
+		}
+		node = alterTableNode
+	case *TruncateTableData:
+		node = &TruncateTableNode{
+			TableName: ast.(*TruncateTableData).TableName,
+		}
 	case *DropTableData:
 		node = &DropTableNode{
 			TableName: ast.(*DropTableData).TableName,
+			Cascade:   ast.(*DropTableData).Cascade,
+		}
+	case *CreateViewData:
+		createViewData := ast.(*CreateViewData)
+		// 先按普通查询构建一次, 语句中的错误在创建时就报告;
+		if _, err = NewPlan(createViewData.Select, p.Service).BuildNode(); err != nil {
+			return nil, err
+		}
+		node = &CreateViewNode{
+			View: &types.View{
+				Name:         createViewData.ViewName,
+				Query:        createViewData.Query,
+				Tables:       fromTables(createViewData.Select.From, nil),
+				Materialized: createViewData.Materialized,
+			},
+			Replace: createViewData.Replace,
+		}
+	case *DropViewData:
+		node = &DropViewNode{
+			ViewName: ast.(*DropViewData).ViewName,
+			Cascade:  ast.(*DropViewData).Cascade,
+		}
+	case *RefreshViewData:
+		node = &RefreshViewNode{
+			ViewName: ast.(*RefreshViewData).ViewName,
+		}
+	case *CreateIndexData:
+		createIndexData := ast.(*CreateIndexData)
+		node = &CreateIndexNode{
+			TableName: createIndexData.TableName,
+			Index: &types.Index{
+				Name:        createIndexData.IndexName,
+				Columns:     createIndexData.Columns,
+				Expressions: createIndexData.Expressions,
+				Unique:      createIndexData.Unique,
+			},
+		}
+	case *DropIndexData:
+		node = &DropIndexNode{
+			IndexName: ast.(*DropIndexData).IndexName,
 		}
 	case *InsertData:
-		node = &InsertNode{
-			TableName: ast.(*InsertData).TableName,
-			Columns:   ast.(*InsertData).Columns,
-			Values:    ast.(*InsertData).Values,
+		insertData := ast.(*InsertData)
+		insertNode := &InsertNode{
+			TableName: insertData.TableName,
+			Columns:   insertData.Columns,
+			Values:    insertData.Values,
+		}
+		insertNode.OnConflict = insertData.OnConflict
+		insertNode.Returning = insertData.Returning
+		if insertData.Select != nil {
+			insertNode.Source, err = NewPlan(insertData.Select, p.Service).BuildNode()
+			if err != nil {
+				return nil, err
+			}
 		}
+		node = insertNode
 	case *SelectData:
 		selectData := ast.(*SelectData)
 		node, err = p.BuildFromItem(selectData.From, selectData.WhereClause)
@@ -76,8 +149,8 @@ func (p *Plan) BuildNode() (Node, error) {
 		// aggregate、group by
 		if selectData.SelectCols != nil || len(selectData.SelectCols) != 0 {
 			for _, selectCol := range selectData.SelectCols {
-				// 如果是 Function，说明是 agg
-				if selectCol.Expr.Function != nil {
+				// 如果是聚合函数，说明是 agg; now() 等标量函数由 ProjectNode 逐行计算;
+				if selectCol.Expr.Function != nil && !types.IsScalarFunction(selectCol.Expr.Function.FuncName) {
 					hasAgg = true
 					break
 				}
@@ -145,6 +218,7 @@ func (p *Plan) BuildNode() (Node, error) {
 			TableName: ast.(*UpdateData).TableName,
 			Source:    buildScan,
 			columns:   ast.(*UpdateData).Columns,
+			Returning: ast.(*UpdateData).Returning,
 		}
 	case *DeleteData:
 		buildScan, err := p.buildScan(ast.(*DeleteData).TableName, ast.(*DeleteData).WhereClause)
@@ -154,6 +228,7 @@ func (p *Plan) BuildNode() (Node, error) {
 		node = &DeleteNode{
 			TableName: ast.(*DeleteData).TableName,
 			Source:    buildScan,
+			Returning: ast.(*DeleteData).Returning,
 		}
 	case *BeginData:
 		return nil, util.Error("#BuildNode not support begin command")
@@ -168,11 +243,71 @@ func (p *Plan) BuildNode() (Node, error) {
 	}
 	return node, nil
 }
+
+// bindStatement 将语句中 now() 等结果取决于当前事务的函数绑定到事务; 视图与 INSERT ... SELECT 的查询在各自构建时绑定;
+func bindStatement(ast Statement, service Service) {
+	var exprs []*types.Expression
+	var returning []*SelectCol
+	switch statement := ast.(type) {
+	case *SelectData:
+		for _, selectCol := range statement.SelectCols {
+			exprs = append(exprs, selectCol.Expr)
+		}
+		for _, orderDirection := range statement.OrderBy {
+			exprs = append(exprs, orderDirection.expr)
+		}
+		exprs = append(exprs, statement.WhereClause, statement.GroupBy, statement.Having)
+		exprs = append(exprs, joinPredicates(statement.From, nil)...)
+	case *InsertData:
+		for _, values := range statement.Values {
+			exprs = append(exprs, values...)
+		}
+		if statement.OnConflict != nil {
+			for _, expr := range statement.OnConflict.Update {
+				exprs = append(exprs, expr)
+			}
+		}
+		returning = statement.Returning
+	case *UpdateData:
+		for _, expr := range statement.Columns {
+			exprs = append(exprs, expr)
+		}
+		exprs = append(exprs, statement.WhereClause)
+		returning = statement.Returning
+	case *DeleteData:
+		exprs = append(exprs, statement.WhereClause)
+		returning = statement.Returning
+	}
+	for _, selectCol := range returning {
+		exprs = append(exprs, selectCol.Expr)
+	}
+	for _, expr := range exprs {
+		types.BindFunctions(expr, service)
+	}
+}
+
+// joinPredicates 收集 FROM 中连接的条件;
+func joinPredicates(item FromItem, exprs []*types.Expression) []*types.Expression {
+	if joinItem, ok := item.(*JoinItem); ok {
+		exprs = append(exprs, joinItem.Predicate)
+		exprs = joinPredicates(joinItem.Left, exprs)
+		exprs = joinPredicates(joinItem.Right, exprs)
+	}
+	return exprs
+}
+
 func (p *Plan) BuildFromItem(item FromItem, filter *types.Expression) (Node, error) {
 	switch item.(type) {
 	case *TableItem:
+		tableName := item.(*TableItem).TableName
+		// from view; 视图原地展开为它的查询;
+		if view, err := p.Service.GetView(tableName); err != nil {
+			return nil, err
+		} else if view != nil {
+			return p.buildView(view, filter)
+		}
 		// from user;  构建 全表扫描, 主键扫描, 索引扫描 节点;
-		return p.buildScan(item.(*TableItem).TableName, filter)
+		return p.buildScan(tableName, filter)
 
 		// from user right join order ...
 		// 扫描
@@ -216,23 +351,204 @@ func (p *Plan) BuildFromItem(item FromItem, filter *types.Expression) (Node, err
 	}
 	return nil, nil
 }
+
+// buildView 重新解析视图保存的查询并构建节点, 外层的 where 条件作为过滤节点套在上面;
+// 物化视图直接扫描保存结果的隐藏表;
+func (p *Plan) buildView(view *types.View, filter *types.Expression) (Node, error) {
+	if view.Materialized {
+		return p.buildMaterializedScan(view, filter)
+	}
+	statement, err := NewParser(view.Query + string(Semicolon)).Parse()
+	if err != nil {
+		return nil, util.Error("#buildView view %s: %s", view.Name, err.Error())
+	}
+	selectData, ok := statement.(*SelectData)
+	if !ok {
+		return nil, util.Error("#buildView view %s is not a select", view.Name)
+	}
+	node, err := NewPlan(selectData, p.Service).BuildNode()
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil || isJoinPredicate(filter) {
+		return node, nil
+	}
+	return &FilterNode{
+		Source:    node,
+		Predicate: filter,
+	}, nil
+}
+
+// buildMaterializedScan 扫描物化视图的隐藏表, 去掉作为主键的行号列;
+func (p *Plan) buildMaterializedScan(view *types.View, filter *types.Expression) (Node, error) {
+	tableName := types.MaterializedTableName(view.Name)
+	table, err := p.Service.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	node, err := p.buildScan(tableName, filter)
+	if err != nil {
+		return nil, err
+	}
+	exprs := make([]*SelectCol, 0, len(table.Columns))
+	for _, colName := range table.GetColumnNames() {
+		if colName != types.MaterializedRowColumn {
+			exprs = append(exprs, &SelectCol{Expr: &types.Expression{Field: colName}})
+		}
+	}
+	return &ProjectNode{
+		Source: node,
+		Exprs:  exprs,
+	}, nil
+}
+
+// fromTables 收集 FROM 中引用的表名, 去重;
+func fromTables(item FromItem, tables []string) []string {
+	switch item.(type) {
+	case *TableItem:
+		if !slices.Contains(tables, item.(*TableItem).TableName) {
+			tables = append(tables, item.(*TableItem).TableName)
+		}
+	case *JoinItem:
+		tables = fromTables(item.(*JoinItem).Left, tables)
+		tables = fromTables(item.(*JoinItem).Right, tables)
+	}
+	return tables
+}
+
+// buildTableSchema 将建表语句转换为表结构: 列约束与表约束分别转换为索引、外键与 CHECK 约束;
+func buildTableSchema(data *CreatTableData) *types.Table {
+	columnVs := make([]types.ColumnV, 0)
+	tableName := data.TableName
+	columns := data.Columns
+	primaryKeys := data.PrimaryKeys
+	// UNIQUE 约束由同名的唯一索引实现: 列约束在前, 表约束在后;
+	uniques := make([][]string, 0)
+	for _, column := range columns {
+		if column.Unique {
+			uniques = append(uniques, []string{column.Name})
+		}
+	}
+	uniques = append(uniques, data.Uniques...)
+	indexes := make([]types.Index, 0, len(uniques))
+	for _, unique := range uniques {
+		indexes = append(indexes, types.Index{
+			Name:    types.UniqueConstraintName(tableName, unique),
+			Columns: unique,
+			Unique:  true,
+		})
+	}
+	// 外键: 列约束在前, 表约束在后;
+	foreignKeys := make([]types.ForeignKey, 0)
+	for _, column := range columns {
+		if column.References != nil {
+			foreignKeys = append(foreignKeys, *column.References)
+		}
+	}
+	for _, foreignKey := range data.ForeignKeys {
+		foreignKeys = append(foreignKeys, *foreignKey)
+	}
+	for i := range foreignKeys {
+		foreignKeys[i].Name = types.ForeignKeyConstraintName(tableName, foreignKeys[i].Columns)
+	}
+	// CHECK: 列约束在前, 表约束在后; 重名时追加序号;
+	checkExprs := make([]*types.Expression, 0)
+	for _, column := range columns {
+		if column.Check != nil {
+			checkExprs = append(checkExprs, column.Check)
+		}
+	}
+	checkExprs = append(checkExprs, data.Checks...)
+	checks := make([]types.Check, 0, len(checkExprs))
+	checkNames := make(map[string]bool)
+	for _, expr := range checkExprs {
+		name := types.CheckConstraintName(tableName, expr)
+		for i := 1; checkNames[name]; i++ {
+			name = fmt.Sprintf("%s%d", types.CheckConstraintName(tableName, expr), i)
+		}
+		checkNames[name] = true
+		checks = append(checks, types.Check{Name: name, Expr: expr})
+	}
+	for _, column := range columns {
+		columnV := types.ColumnV{
+			Name:      column.Name,
+			DataType:  column.DateType,
+			Precision: column.Precision,
+			Scale:     column.Scale,
+			Length:    column.Length,
+			Collation: column.Collation,
+		}
+		// 表约束 PRIMARY KEY (a, b) 中的列同样是主键列;
+		primaryKey := column.PrimaryKey || slices.Contains(primaryKeys, column.Name)
+		columnV.Nullable = column.Nullable && !primaryKey
+		if column.DefaultValue != nil {
+			columnV.DefaultValue = column.DefaultValue.ConstVal
+		}
+		columnV.PrimaryKey = primaryKey
+		columnV.IsIndex = column.IsIndex
+		// AUTO_INCREMENT 列的序列随表一起创建;
+		if column.Serial {
+			columnV.Sequence = types.SequenceName(tableName, column.Name)
+		}
+		columnVs = append(columnVs, columnV)
+	}
+	return &types.Table{
+		Name:        tableName,
+		Columns:     columnVs,
+		Indexes:     indexes,
+		PrimaryKeys: primaryKeys,
+		ForeignKeys: foreignKeys,
+		Checks:      checks,
+	}
+}
+
 func (p *Plan) BuildExecutor(node Node) Executor {
 	switch node.(type) {
 	case *CreateTableNode:
 		return NewCreateTableExecutor(node.(*CreateTableNode).Schema)
+	case *TruncateTableNode:
+		return NewTruncateTableExecutor(node.(*TruncateTableNode).TableName)
 	case *DropTableNode:
-		return NewDropTableExecutor(node.(*DropTableNode).TableName)
+		return NewDropTableExecutor(node.(*DropTableNode).TableName, node.(*DropTableNode).Cascade)
+	case *CreateViewNode:
+		return NewCreateViewExecutor(node.(*CreateViewNode).View, node.(*CreateViewNode).Replace)
+	case *DropViewNode:
+		return NewDropViewExecutor(node.(*DropViewNode).ViewName, node.(*DropViewNode).Cascade)
+	case *RefreshViewNode:
+		return NewRefreshViewExecutor(node.(*RefreshViewNode).ViewName)
+	case *CreateIndexNode:
+		return NewCreateIndexExecutor(node.(*CreateIndexNode).TableName, node.(*CreateIndexNode).Index)
+	case *DropIndexNode:
+		return NewDropIndexExecutor(node.(*DropIndexNode).IndexName)
+	case *AlterTableNode:
+		return NewAlterTableExecutor(node.(*AlterTableNode))
+	case *CreateSequenceNode:
+		return NewCreateSequenceExecutor(node.(*CreateSequenceNode).Sequence)
+	case *DropSequenceNode:
+		return NewDropSequenceExecutor(node.(*DropSequenceNode).SequenceName)
 	case *InsertNode:
-		return NewInsertTableExecutor(node.(*InsertNode).TableName,
-			node.(*InsertNode).Columns, node.(*InsertNode).Values)
+		insertNode := node.(*InsertNode)
+		var executor *InsertTableExecutor
+		if insertNode.Source != nil {
+			executor = NewInsertSelectExecutor(insertNode.TableName, insertNode.Columns, p.BuildExecutor(insertNode.Source))
+		} else {
+			executor = NewInsertTableExecutor(insertNode.TableName, insertNode.Columns, insertNode.Values)
+		}
+		executor.OnConflict = insertNode.OnConflict
+		executor.Returning = insertNode.Returning
+		return executor
 	case *ScanNode:
 		return NewScanTableExecutor(node.(*ScanNode).TableName, node.(*ScanNode).Filter)
 	case *UpdateNode:
 		updateNode := node.(*UpdateNode)
 		sourceExecutor := p.BuildExecutor(updateNode.Source)
-		return NewUpdateTableExecutor(updateNode.TableName, sourceExecutor, updateNode.columns)
+		executor := NewUpdateTableExecutor(updateNode.TableName, sourceExecutor, updateNode.columns)
+		executor.Returning = updateNode.Returning
+		return executor
 	case *DeleteNode:
-		return NewDeleteTableExecutor(node.(*DeleteNode).TableName, p.BuildExecutor(node.(*DeleteNode).Source))
+		executor := NewDeleteTableExecutor(node.(*DeleteNode).TableName, p.BuildExecutor(node.(*DeleteNode).Source))
+		executor.Returning = node.(*DeleteNode).Returning
+		return executor
 	case *OrderNode:
 		orderNode := node.(*OrderNode)
 		source := p.BuildExecutor(orderNode.Source)
@@ -257,9 +573,10 @@ func (p *Plan) BuildExecutor(node Node) Executor {
 	case *FilterNode:
 		return NewFilterExecutor(p.BuildExecutor(node.(*FilterNode).Source), node.(*FilterNode).Predicate)
 	case *IndexScanNode:
-		return NewIndexScanExecutor(node.(*IndexScanNode).TableName, node.(*IndexScanNode).Filed, node.(*IndexScanNode).Value)
+		indexScanNode := node.(*IndexScanNode)
+		return NewIndexScanExecutor(indexScanNode.TableName, indexScanNode.IndexName, indexScanNode.Values, indexScanNode.Range)
 	case *PrimaryKeyScanNode:
-		return NewPrimaryKeyScanExecutor(node.(*PrimaryKeyScanNode).TableName, node.(*PrimaryKeyScanNode).Value)
+		return NewPrimaryKeyScanExecutor(node.(*PrimaryKeyScanNode).TableName, node.(*PrimaryKeyScanNode).Values)
 	case *HashJoinNode:
 		return NewHashJoinExecutor(p.BuildExecutor(node.(*HashJoinNode).Left),
 			p.BuildExecutor(node.(*HashJoinNode).Right), node.(*HashJoinNode).Predicate, node.(*HashJoinNode).Outer)
@@ -268,48 +585,229 @@ func (p *Plan) BuildExecutor(node Node) Executor {
 }
 
 func (p *Plan) buildScan(tableName string, whereClause *types.Expression) (Node, error) {
-	// 解析出 左右两边的值;
-	scanFilter := p.parseScanFilter(whereClause)
-	if scanFilter != nil {
-		// 解析 join on id = order_id 时的特殊情况;
-		if scanFilter.field != "" && scanFilter.value == nil {
-			return &ScanNode{
-				TableName: tableName,
-				Filter:    nil,
-			}, nil
-		}
-		table, err := p.Service.MustGetTable(tableName)
-		if err != nil {
-			return nil, err
-		}
-		if table == nil {
-			return nil, err
-		}
-		for _, column := range table.Columns {
-			if column.Name == scanFilter.field && column.PrimaryKey == true && scanFilter.opType == EqualType {
-				return &PrimaryKeyScanNode{
-					TableName: tableName,
-					Value:     scanFilter.value,
-				}, nil
-			}
-			if column.Name == scanFilter.field && column.IsIndex == true && scanFilter.opType == EqualType {
-				return &IndexScanNode{
-					TableName: tableName,
-					Filed:     scanFilter.field,
-					Value:     scanFilter.value,
-				}, nil
-			}
-		}
+	if whereClause == nil {
 		return &ScanNode{
 			TableName: tableName,
-			Filter:    whereClause,
+			Filter:    nil,
 		}, nil
-	} else {
+	}
+	// 解析 join on id = order_id 时的特殊情况;
+	if isJoinPredicate(whereClause) {
 		return &ScanNode{
 			TableName: tableName,
 			Filter:    nil,
 		}, nil
 	}
+	table, err := p.Service.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	// 与列比较的常量先按列的类型隐式转换, 例如日期列上的字符串;
+	if err = table.CoerceComparisons(whereClause); err != nil {
+		return nil, err
+	}
+	// where a = 1 and b > 2 ... 拆成多个 AND 条件, 逐个解析出 列 操作符 常量;
+	conjuncts := splitConjuncts(whereClause)
+	filters := make([]*FilterValue, 0, len(conjuncts))
+	for _, conjunct := range conjuncts {
+		filters = append(filters, p.parseScanFilter(conjunct))
+	}
+	// 主键与二级索引同样按最左前缀打分, 分数相同时优先主键, 省去回表;
+	pkNode, pkUsed := buildPrimaryKeyScan(table, filters)
+	indexNode, indexUsed := buildIndexScan(table, filters)
+	if pkNode != nil && (indexNode == nil || 2*len(pkNode.Values) >= indexNode.score()) {
+		return wrapResidual(pkNode, conjuncts, pkUsed), nil
+	}
+	if indexNode != nil {
+		return wrapResidual(indexNode, conjuncts, indexUsed), nil
+	}
+	return &ScanNode{
+		TableName: tableName,
+		Filter:    whereClause,
+	}, nil
+}
+
+// isJoinPredicate join on 的连接条件 a = b 由连接节点处理, 不作为扫描的过滤条件;
+func isJoinPredicate(expr *types.Expression) bool {
+	equal, ok := expr.OperationVal.(*types.OperationEqual)
+	return ok && equal.Left.Field != "" && equal.Right.Field != ""
+}
+
+// buildPrimaryKeyScan 主键列从左到右依次匹配等值条件, 至少匹配第一列时才走主键扫描;
+func buildPrimaryKeyScan(table *types.Table, filters []*FilterValue) (*PrimaryKeyScanNode, []int) {
+	node := &PrimaryKeyScanNode{
+		TableName: table.Name,
+	}
+	used := make([]int, 0)
+	for _, colName := range table.GetPrimaryKeys() {
+		pos, value := findScanFilter(table.Columns[table.GetColumnIndex(colName)], filters, used, EqualType)
+		if pos == -1 {
+			break
+		}
+		node.Fileds = append(node.Fileds, colName)
+		node.Values = append(node.Values, value)
+		used = append(used, pos)
+	}
+	if len(node.Values) == 0 {
+		return nil, nil
+	}
+	return node, used
+}
+
+// buildIndexScan 按最左前缀原则选择索引: 索引列从左到右依次匹配等值条件,
+// 第一个没有等值条件的列上允许一个范围条件; 匹配的列越多越优先;
+// 返回扫描节点以及被索引消化掉的条件下标;
+func buildIndexScan(table *types.Table, filters []*FilterValue) (*IndexScanNode, []int) {
+	var best *IndexScanNode
+	var bestUsed []int
+	bestScore := 0
+	for _, index := range table.GetIndexes() {
+		// 回填中的索引数据不完整, 不能用于查询;
+		if index.State != types.IndexPublic {
+			continue
+		}
+		node := &IndexScanNode{
+			TableName: table.Name,
+			IndexName: index.Name,
+		}
+		used := make([]int, 0)
+		for i, colName := range index.Columns {
+			pos, value := findScanFilter(table.IndexColumn(&index, i), filters, used, EqualType)
+			if pos == -1 {
+				break
+			}
+			node.Fileds = append(node.Fileds, colName)
+			node.Values = append(node.Values, value)
+			used = append(used, pos)
+		}
+		if len(node.Values) < len(index.Columns) {
+			colName := index.Columns[len(node.Values)]
+			column := table.IndexColumn(&index, len(node.Values))
+			indexRange := &types.IndexRange{}
+			if pos, value := findScanFilter(column, filters, used, GreaterType, GreaterEqualType); pos != -1 {
+				indexRange.Lower = &types.IndexBound{Value: value, Inclusive: filters[pos].opType == GreaterEqualType}
+				used = append(used, pos)
+			}
+			if pos, value := findScanFilter(column, filters, used, LessType, LessEqualType); pos != -1 {
+				indexRange.Upper = &types.IndexBound{Value: value, Inclusive: filters[pos].opType == LessEqualType}
+				used = append(used, pos)
+			}
+			if indexRange.Lower != nil || indexRange.Upper != nil {
+				node.Fileds = append(node.Fileds, colName)
+				node.Range = indexRange
+			}
+		}
+		if score := node.score(); score > bestScore {
+			best, bestUsed, bestScore = node, used, score
+		}
+	}
+	return best, bestUsed
+}
+
+// score 等值匹配的列记 2 分, 范围条件记 1 分;
+func (i *IndexScanNode) score() int {
+	score := 2 * len(i.Values)
+	if i.Range != nil {
+		score++
+	}
+	return score
+}
+
+// findScanFilter 查找列上指定操作符的条件, 常量会被转换为列的类型, 以便与索引中的编码一致;
+// 表达式索引项的列名即表达式文本, 与条件左侧表达式的文本相同时匹配;
+func findScanFilter(column types.ColumnV, filters []*FilterValue, used []int, opTypes ...OperationType) (int, types.Value) {
+	// JSON 的编码先按类型排序, 范围条件会越过其他类型的值, 只用于等值匹配;
+	if column.DataType == types.Json && opTypes[0] != EqualType {
+		return -1, nil
+	}
+	for i, filter := range filters {
+		if filter == nil || filter.field != column.Name || containsInt(used, i) {
+			continue
+		}
+		matched := false
+		for _, opType := range opTypes {
+			if filter.opType == opType {
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+		if value := coerceScanValue(column, filter.value); value != nil {
+			return i, value
+		}
+	}
+	return -1, nil
+}
+
+// coerceScanValue 整数常量可以用于浮点列, 数值常量可以用于定点数列, 日期常量可以用于时间戳列; 其他类型不一致的情况不走索引;
+func coerceScanValue(column types.ColumnV, value types.Value) types.Value {
+	if value.DateType() == column.DataType || value.DateType() == types.Null {
+		// 不区分大小写的列上, 字符串常量按列的排序规则编码;
+		return column.WithCollation(value)
+	}
+	if column.DataType == types.Float && value.DateType() == types.Integer {
+		return types.NewConstFloat(float64(value.(*types.ConstInt).Value))
+	}
+	if column.DataType == types.Timestamp && value.DateType() == types.Date {
+		return value.(*types.ConstDate).Timestamp()
+	}
+	// 定点数的编码与小数位数无关, 数值常量可以直接用于 DECIMAL 列;
+	if column.DataType == types.Decimal {
+		if decimal, ok := types.ToDecimal(value); ok {
+			return decimal
+		}
+	}
+	// JSON 标量与 SQL 标量比较时按值比较, 转换为相同内容的 JSON;
+	if column.DataType == types.Json {
+		if doc, ok := types.ToJson(value); ok {
+			return doc
+		}
+	}
+	if column.DataType == types.Float && value.DateType() == types.Decimal {
+		f, _ := value.Into().(*big.Rat).Float64()
+		return types.NewConstFloat(f)
+	}
+	return nil
+}
+
+// wrapResidual 没有被扫描节点消化掉的条件, 用 Filter 节点继续过滤;
+func wrapResidual(node Node, conjuncts []*types.Expression, used []int) Node {
+	var residual *types.Expression
+	for i, conjunct := range conjuncts {
+		if containsInt(used, i) {
+			continue
+		}
+		if residual == nil {
+			residual = conjunct
+		} else {
+			residual = &types.Expression{OperationVal: &types.OperationAnd{Left: residual, Right: conjunct}}
+		}
+	}
+	if residual == nil {
+		return node
+	}
+	return &FilterNode{
+		Source:    node,
+		Predicate: residual,
+	}
+}
+
+// splitConjuncts 将 AND 连接的条件拆分为多个;
+func splitConjuncts(expr *types.Expression) []*types.Expression {
+	if and, ok := expr.OperationVal.(*types.OperationAnd); ok {
+		return append(splitConjuncts(and.Left), splitConjuncts(and.Right)...)
+	}
+	return []*types.Expression{expr}
+}
+
+func containsInt(list []int, target int) bool {
+	for _, v := range list {
+		if v == target {
+			return true
+		}
+	}
+	return false
 }
 
 type OperationType int32
@@ -328,55 +826,240 @@ type FilterValue struct {
 	value  types.Value
 }
 
+// parseScanFilter 解析 列 操作符 常量 形式的条件; 常量在左侧时交换两侧并翻转操作符;
+// 左侧为函数等表达式时以表达式文本作为列名, 用于匹配表达式索引;
+// 其他形式的条件返回 nil, 只能逐行过滤;
 func (p *Plan) parseScanFilter(filter *types.Expression) *FilterValue {
-	if filter == nil {
+	var left, right *types.Expression
+	var opType OperationType
+	switch filter.OperationVal.(type) {
+	case *types.OperationEqual:
+		left, right = filter.OperationVal.(*types.OperationEqual).Left, filter.OperationVal.(*types.OperationEqual).Right
+		opType = EqualType
+	case *types.OperationGreaterThan:
+		left, right = filter.OperationVal.(*types.OperationGreaterThan).Left, filter.OperationVal.(*types.OperationGreaterThan).Right
+		opType = GreaterType
+	case *types.OperationGreaterEqual:
+		left, right = filter.OperationVal.(*types.OperationGreaterEqual).Left, filter.OperationVal.(*types.OperationGreaterEqual).Right
+		opType = GreaterEqualType
+	case *types.OperationLessThan:
+		left, right = filter.OperationVal.(*types.OperationLessThan).Left, filter.OperationVal.(*types.OperationLessThan).Right
+		opType = LessType
+	case *types.OperationLessEqual:
+		left, right = filter.OperationVal.(*types.OperationLessEqual).Left, filter.OperationVal.(*types.OperationLessEqual).Right
+		opType = LessEqualType
+	default:
 		return nil
 	}
-	if filter.Field != "" {
+	if scanField(left) != "" && right.ConstVal != nil {
 		return &FilterValue{
-			field: filter.Field,
-			value: nil,
+			opType: opType,
+			field:  scanField(left),
+			value:  right.ConstVal,
+		}
+	}
+	if left.ConstVal != nil && scanField(right) != "" {
+		// 1 < a  =>  a > 1
+		flipped := map[OperationType]OperationType{
+			EqualType:        EqualType,
+			GreaterType:      LessType,
+			GreaterEqualType: LessEqualType,
+			LessType:         GreaterType,
+			LessEqualType:    GreaterEqualType,
 		}
-	} else if filter.ConstVal != nil {
 		return &FilterValue{
-			field: "",
-			value: filter.ConstVal,
-		}
-	} else if filter.OperationVal != nil {
-		// 1. where id = 1; on id = 12;  id = null;
-		// 2. on id = order_id;
-		switch filter.OperationVal.(type) {
-		case *types.OperationEqual:
-			equal := filter.OperationVal.(*types.OperationEqual)
-			// 解析左右值;
-			left := p.parseScanFilter(equal.Left)
-			right := p.parseScanFilter(equal.Right)
-			return &FilterValue{
-				opType: EqualType,
-				field:  left.field,
-				value:  right.value,
+			opType: flipped[opType],
+			field:  scanField(right),
+			value:  left.ConstVal,
+		}
+	}
+	return nil
+}
+
+// scanField 条件一侧对应的列名: 列本身, 或者函数表达式的文本;
+func scanField(expr *types.Expression) string {
+	if expr.Field != "" || expr.Function == nil {
+		return expr.Field
+	}
+	return expr.ToString()
+}
+
+// outputColumns 推断查询计划输出的列, 列名与执行结果相同; 类型与排序规则取自引用的表列,
+// 运算与标量函数的类型由 Table.ExprType 推算, 聚合函数按 BuildCal 的计算方式推算; 物化视图据此建立隐藏表;
+func (p *Plan) outputColumns(node Node) ([]types.ColumnV, error) {
+	switch n := node.(type) {
+	case *ScanNode:
+		return p.tableColumns(n.TableName)
+	case *IndexScanNode:
+		return p.tableColumns(n.TableName)
+	case *PrimaryKeyScanNode:
+		return p.tableColumns(n.TableName)
+	case *FilterNode:
+		return p.outputColumns(n.Source)
+	case *OrderNode:
+		return p.outputColumns(n.Source)
+	case *LimitNode:
+		return p.outputColumns(n.Source)
+	case *OffsetNode:
+		return p.outputColumns(n.Source)
+	case *NestedLoopJoinNode:
+		return p.joinColumns(n.Left, n.Right)
+	case *HashJoinNode:
+		return p.joinColumns(n.Left, n.Right)
+	case *ProjectNode:
+		source, err := p.outputColumns(n.Source)
+		if err != nil {
+			return nil, err
+		}
+		return projectColumns(source, n.Exprs)
+	case *AggregateNode:
+		source, err := p.outputColumns(n.Source)
+		if err != nil {
+			return nil, err
+		}
+		return aggregateColumns(source, n.Exprs)
+	}
+	return nil, util.Error("#outputColumns unsupported plan node %T", node)
+}
+
+func (p *Plan) tableColumns(tableName string) ([]types.ColumnV, error) {
+	table, err := p.Service.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return table.Columns, nil
+}
+
+// joinColumns 连接的结果为左右两侧的列依次拼接, 与 NestedLoopJoinExecutor / HashJoinExecutor 相同;
+func (p *Plan) joinColumns(left Node, right Node) ([]types.ColumnV, error) {
+	leftColumns, err := p.outputColumns(left)
+	if err != nil {
+		return nil, err
+	}
+	rightColumns, err := p.outputColumns(right)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]types.ColumnV{}, leftColumns...), rightColumns...), nil
+}
+
+// projectColumns 与 projectRows 相同: 列引用选取同名的全部列, 表达式没有别名时以表达式本身作为列名;
+func projectColumns(source []types.ColumnV, exprs []*SelectCol) ([]types.ColumnV, error) {
+	columns := make([]types.ColumnV, 0, len(exprs))
+	table := &types.Table{Columns: source}
+	for _, selectCol := range exprs {
+		expression := selectCol.Expr
+		if expression.Field != "" {
+			for _, column := range source {
+				if column.Name == expression.Field {
+					columns = append(columns, outputColumn(selectCol.Alis, column))
+				}
 			}
-		case *types.OperationGreaterThan:
-			greaterThan := filter.OperationVal.(*types.OperationGreaterThan)
-			left := p.parseScanFilter(greaterThan.Left)
-			right := p.parseScanFilter(greaterThan.Right)
-			return &FilterValue{
-				opType: GreaterType,
-				field:  left.field,
-				value:  right.value,
+			continue
+		}
+		dataType, err := table.ExprType(expression)
+		if err != nil {
+			return nil, err
+		}
+		name := selectCol.Alis
+		if name == "" {
+			name = expression.ToString()
+		}
+		// 只含 NULL 常量的表达式没有类型, 与之前一样按字符串保存;
+		if dataType == types.Null {
+			dataType = types.String
+		}
+		column := types.ColumnV{Name: name, DataType: dataType}
+		if dataType == types.String {
+			column.Collation = exprCollation(source, expression)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// aggregateColumns 与 AggregateExecutor 相同: 函数没有别名时列名为 <函数名>_<列名>;
+// COUNT 为整数, SUM / AVG 对定点数列为定点数、其他为浮点数, MAX / MIN 与列相同;
+func aggregateColumns(source []types.ColumnV, exprs []*SelectCol) ([]types.ColumnV, error) {
+	columns := make([]types.ColumnV, 0, len(exprs))
+	find := func(colName string) (types.ColumnV, error) {
+		for _, column := range source {
+			if column.Name == colName {
+				return column, nil
 			}
-		case *types.OperationLessThan:
-			greaterThan := filter.OperationVal.(*types.OperationLessThan)
-			left := p.parseScanFilter(greaterThan.Left)
-			right := p.parseScanFilter(greaterThan.Right)
-			return &FilterValue{
-				opType: LessType,
-				field:  left.field,
-				value:  right.value,
+		}
+		return types.ColumnV{}, util.Error("#outputColumns column %s not exists", colName)
+	}
+	for _, selectCol := range exprs {
+		expression := selectCol.Expr
+		if expression.Field != "" {
+			column, err := find(expression.Field)
+			if err != nil {
+				return nil, err
 			}
+			columns = append(columns, outputColumn(selectCol.Alis, column))
+			continue
 		}
-		return nil
-	} else {
-		return nil
+		if expression.Function == nil {
+			return nil, util.Error("AggregateExecutor: not support expression type")
+		}
+		name := selectCol.Alis
+		if name == "" {
+			name = expression.Function.FuncName + "_" + expression.Function.ColName
+		}
+		funcName := strings.ToUpper(expression.Function.FuncName)
+		if funcName == "COUNT" {
+			columns = append(columns, types.ColumnV{Name: name, DataType: types.Integer})
+			continue
+		}
+		column, err := find(expression.Function.ColName)
+		if err != nil {
+			return nil, err
+		}
+		switch funcName {
+		case "SUM", "AVG":
+			if column.DataType == types.Decimal {
+				columns = append(columns, types.ColumnV{Name: name, DataType: types.Decimal})
+			} else {
+				columns = append(columns, types.ColumnV{Name: name, DataType: types.Float})
+			}
+		case "MAX", "MIN":
+			columns = append(columns, outputColumn(name, column))
+		default:
+			return nil, util.Error("AggregateExecutor.BuildCal: not support function name : %s \n", expression.Function.FuncName)
+		}
+	}
+	return columns, nil
+}
+
+// outputColumn 直接输出的表列保留类型、长度、精度与排序规则, 去掉约束与默认值;
+func outputColumn(alias string, column types.ColumnV) types.ColumnV {
+	name := column.Name
+	if alias != "" {
+		name = alias
+	}
+	return types.ColumnV{
+		Name:      name,
+		DataType:  column.DataType,
+		Precision: column.Precision,
+		Scale:     column.Scale,
+		Length:    column.Length,
+		Collation: column.Collation,
+	}
+}
+
+// exprCollation 字符串表达式的排序规则取自第一个参数, 例如 upper(name) 与 name 相同;
+func exprCollation(source []types.ColumnV, expr *types.Expression) string {
+	if expr.Field != "" {
+		for _, column := range source {
+			if column.Name == expr.Field {
+				return column.Collation
+			}
+		}
+		return ""
+	}
+	if expr.Function != nil && len(expr.Function.Args) > 0 {
+		return exprCollation(source, expr.Function.Args[0])
 	}
+	return ""
 }
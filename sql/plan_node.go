@@ -14,6 +14,7 @@ type UpdateNode struct {
 	TableName string
 	Source    Node
 	columns   map[string]*types.Expression
+	Returning []*SelectCol
 }
 
 func (u *UpdateNode) FormatNode(f *strings.Builder, prefix string, root bool) {
@@ -59,6 +60,7 @@ func (c *CreateTableNode) FormatNode(f *strings.Builder, prefix string, root boo
 
 type DropTableNode struct {
 	TableName string
+	Cascade   bool
 }
 
 func (d *DropTableNode) FormatNode(f *strings.Builder, prefix string, root bool) {
@@ -79,10 +81,242 @@ func (d *DropTableNode) FormatNode(f *strings.Builder, prefix string, root bool)
 	f.WriteString(fmt.Sprintf("Drop Table %s;", d.TableName))
 }
 
+type TruncateTableNode struct {
+	TableName string
+}
+
+func (t *TruncateTableNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Truncate Table %s;", t.TableName))
+}
+
+type CreateIndexNode struct {
+	TableName string
+	Index     *types.Index
+}
+
+func (c *CreateIndexNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Create Index %s On %s", c.Index.Name, c.TableName))
+}
+
+type DropIndexNode struct {
+	IndexName string
+}
+
+func (d *DropIndexNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Drop Index %s;", d.IndexName))
+}
+
+type CreateSequenceNode struct {
+	Sequence *types.Sequence
+}
+
+func (c *CreateSequenceNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString("Create Sequence " + c.Sequence.ToString())
+}
+
+type DropSequenceNode struct {
+	SequenceName string
+}
+
+func (d *DropSequenceNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Drop Sequence %s;", d.SequenceName))
+}
+
+type CreateViewNode struct {
+	View    *types.View
+	Replace bool
+}
+
+func (c *CreateViewNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString("Create " + c.View.ToString())
+}
+
+type DropViewNode struct {
+	ViewName string
+	Cascade  bool
+}
+
+func (d *DropViewNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Drop View %s;", d.ViewName))
+}
+
+type RefreshViewNode struct {
+	ViewName string
+}
+
+func (r *RefreshViewNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Refresh Materialized View %s;", r.ViewName))
+}
+
+type AlterTableNode struct {
+	TableName  string
+	Action     AlterAction
+	Definition *types.Table // ADD COLUMN: 只有新增列的表结构, 包含列约束;
+	ColumnName string
+	NewName    string
+	Default    types.Value // SET DEFAULT 的默认值;
+}
+
+func (a *AlterTableNode) FormatNode(f *strings.Builder, prefix string, root bool) {
+	if !root {
+		f.WriteString("\n")
+	} else {
+		f.WriteString("           SQL PLAN           \n")
+		f.WriteString("------------------------------\n")
+	}
+	if prefix == "" {
+		prefix = "  ->  "
+	} else {
+		// 第一步：输出当前前缀
+		f.WriteString(prefix)
+		// 第二步：生成子节点的新前缀
+		prefix = "   " + prefix
+	}
+	f.WriteString(fmt.Sprintf("Alter Table %s %s", a.TableName, a.ToString()))
+}
+
+// ToString 输出 ALTER TABLE 的操作;
+func (a *AlterTableNode) ToString() string {
+	switch a.Action {
+	case AlterAddColumn:
+		return "Add Column " + a.Definition.Columns[0].ToString()
+	case AlterDropColumn:
+		return "Drop Column " + a.ColumnName
+	case AlterRenameColumn:
+		return fmt.Sprintf("Rename Column %s To %s", a.ColumnName, a.NewName)
+	case AlterRenameTable:
+		return "Rename To " + a.NewName
+	case AlterSetDefault:
+		return fmt.Sprintf("Alter Column %s Set Default %s", a.ColumnName, a.Default.Bytes())
+	case AlterDropDefault:
+		return fmt.Sprintf("Alter Column %s Drop Default", a.ColumnName)
+	case AlterSetNotNull:
+		return fmt.Sprintf("Alter Column %s Set Not Null", a.ColumnName)
+	default:
+		return fmt.Sprintf("Alter Column %s Drop Not Null", a.ColumnName)
+	}
+}
+
 type InsertNode struct {
 	TableName string
 	Columns   []string
 	Values    [][]*types.Expression
+	Source    Node // INSERT ... SELECT 的查询计划;
+	Returning []*SelectCol
+	// ON CONFLICT 子句;
+	OnConflict *OnConflictData
 }
 
 func (i *InsertNode) FormatNode(f *strings.Builder, prefix string, root bool) {
@@ -101,6 +335,14 @@ func (i *InsertNode) FormatNode(f *strings.Builder, prefix string, root bool) {
 		prefix = "   " + prefix
 	}
 	f.WriteString(fmt.Sprintf("Insert into  %s;", i.TableName))
+	if i.OnConflict != nil && i.OnConflict.Update == nil {
+		f.WriteString(" On Conflict Do Nothing;")
+	} else if i.OnConflict != nil {
+		f.WriteString(fmt.Sprintf(" On Conflict (%s) Do Update;", strings.Join(i.OnConflict.Columns, ", ")))
+	}
+	if i.Source != nil {
+		i.Source.FormatNode(f, prefix, false)
+	}
 }
 
 type ScanNode struct {
@@ -132,6 +374,7 @@ func (s *ScanNode) FormatNode(f *strings.Builder, prefix string, root bool) {
 type DeleteNode struct {
 	TableName string
 	Source    Node
+	Returning []*SelectCol
 }
 
 func (d *DeleteNode) FormatNode(f *strings.Builder, prefix string, root bool) {
@@ -395,8 +638,10 @@ func (fi *FilterNode) FormatNode(f *strings.Builder, prefix string, root bool) {
 
 type IndexScanNode struct {
 	TableName string
-	Filed     string
-	Value     types.Value
+	IndexName string
+	Fileds    []string          // 命中的索引列;
+	Values    []types.Value     // 最左前缀上的等值条件;
+	Range     *types.IndexRange // 前缀之后一列上的范围条件, 可以为空;
 }
 
 func (i *IndexScanNode) FormatNode(f *strings.Builder, prefix string, root bool) {
@@ -414,12 +659,21 @@ func (i *IndexScanNode) FormatNode(f *strings.Builder, prefix string, root bool)
 		// 第二步：生成子节点的新前缀
 		prefix = "   " + prefix
 	}
-	f.WriteString(fmt.Sprintf("Index Scan On %s %s", i.TableName, i.Filed))
+	conditions := make([]string, 0)
+	for pos, value := range i.Values {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", i.Fileds[pos], value.Bytes()))
+	}
+	if i.Range != nil {
+		conditions = append(conditions, i.Range.ToString(i.Fileds[len(i.Values)]))
+	}
+	f.WriteString(fmt.Sprintf("Index Scan On %s %s (%s)", i.TableName, i.IndexName, strings.Join(conditions, " AND ")))
 }
 
+// PrimaryKeyScanNode 主键扫描: Values 覆盖全部主键列时按主键点查, 否则按主键前缀扫描;
 type PrimaryKeyScanNode struct {
 	TableName string
-	Value     types.Value
+	Fileds    []string
+	Values    []types.Value
 }
 
 func (p *PrimaryKeyScanNode) FormatNode(f *strings.Builder, prefix string, root bool) {
@@ -437,7 +691,11 @@ func (p *PrimaryKeyScanNode) FormatNode(f *strings.Builder, prefix string, root
 		// 第二步：生成子节点的新前缀
 		prefix = "   " + prefix
 	}
-	f.WriteString(fmt.Sprintf("Primary key Scan On %s %s", p.TableName, p.Value.Bytes()))
+	conditions := make([]string, 0, len(p.Values))
+	for pos, value := range p.Values {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", p.Fileds[pos], value.Bytes()))
+	}
+	f.WriteString(fmt.Sprintf("Primary key Scan On %s (%s)", p.TableName, strings.Join(conditions, " AND ")))
 }
 
 type FromItem interface {
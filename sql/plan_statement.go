@@ -3,6 +3,7 @@ package sql
 import (
 	"fmt"
 	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/storage"
 )
 
 type Statement interface {
@@ -26,8 +27,12 @@ func (s *ShowTDataBaseData) Statement() types.ResultSet {
 }
 
 type CreatTableData struct {
-	TableName string
-	Columns   []*types.Column
+	TableName   string
+	Columns     []*types.Column
+	PrimaryKeys []string            // PRIMARY KEY (a, b) 表约束;
+	Uniques     [][]string          // UNIQUE (a, b) 表约束;
+	ForeignKeys []*types.ForeignKey // FOREIGN KEY (a) REFERENCES parent (b) 表约束;
+	Checks      []*types.Expression // CHECK (expr) 表约束;
 }
 
 func (c *CreatTableData) Statement() types.ResultSet {
@@ -58,6 +63,7 @@ func (c *CreatTableData) Statement() types.ResultSet {
 
 type DropTableData struct {
 	TableName string
+	Cascade   bool // 同时删除依赖这张表的视图;
 }
 
 func (d *DropTableData) Statement() types.ResultSet {
@@ -65,15 +71,38 @@ func (d *DropTableData) Statement() types.ResultSet {
 	return nil
 }
 
+type TruncateTableData struct {
+	TableName string
+}
+
+func (t *TruncateTableData) Statement() types.ResultSet {
+	fmt.Println("truncate table", t.TableName)
+	return nil
+}
+
 type InsertData struct {
 	TableName string
 	Columns   []string
 	Values    [][]*types.Expression
+	Select    *SelectData  // INSERT ... SELECT 的数据来源, 为空时使用 Values;
+	Returning []*SelectCol // RETURNING 子句, 为空时只返回行数;
+	// ON CONFLICT 子句, 为空时主键或唯一索引冲突直接报错;
+	OnConflict *OnConflictData
+}
+
+// OnConflictData ON CONFLICT [(col, ...)] DO NOTHING | DO UPDATE SET col = expr;
+// Columns 为冲突列, 必须是主键或唯一索引的列; Update 为空时表示 DO NOTHING;
+type OnConflictData struct {
+	Columns []string
+	Update  map[string]*types.Expression
 }
 
 func (i *InsertData) Statement() types.ResultSet {
 	fmt.Println(" insert into ", i.TableName)
 	fmt.Println(i.Columns)
+	if i.Select != nil {
+		return i.Select.Statement()
+	}
 	// 每行
 	for _, value := range i.Values {
 		for _, expression := range value {
@@ -99,6 +128,7 @@ func (i *InsertData) Statement() types.ResultSet {
 type DeleteData struct {
 	TableName   string
 	WhereClause *types.Expression
+	Returning   []*SelectCol // RETURNING 子句, 为空时只返回行数;
 }
 
 func (d *DeleteData) Statement() types.ResultSet {
@@ -111,6 +141,7 @@ type UpdateData struct {
 	TableName   string
 	Columns     map[string]*types.Expression
 	WhereClause *types.Expression
+	Returning   []*SelectCol // RETURNING 子句, 为空时只返回行数;
 }
 
 func (u *UpdateData) Statement() types.ResultSet {
@@ -176,27 +207,129 @@ func (s *SelectData) Statement() types.ResultSet {
 }
 
 type CreateIndexData struct {
-	TableName string
-	IndexName string
-	Columns   []*types.Column
+	TableName   string
+	IndexName   string
+	Columns     []string
+	Expressions []*types.Expression // 与 Columns 一一对应, 表达式索引项不为空, 此时 Columns 中为表达式的文本;
+	Unique      bool
 }
 
 func (c *CreateIndexData) Statement() types.ResultSet {
 	fmt.Println("create index", c.IndexName, "on", c.TableName)
 	for _, column := range c.Columns {
-		fmt.Println("column:", column.Name)
+		fmt.Println("column:", column)
 	}
-	fmt.Println("index:", c.IndexName)
+	fmt.Println("unique:", c.Unique)
+	return nil
+}
+
+type DropIndexData struct {
+	IndexName string
+}
+
+func (d *DropIndexData) Statement() types.ResultSet {
+	fmt.Println("drop index", d.IndexName)
+	return nil
+}
+
+type CreateSequenceData struct {
+	SequenceName string
+	Start        int64
+	Increment    int64
+}
+
+func (c *CreateSequenceData) Statement() types.ResultSet {
+	fmt.Println("create sequence", c.SequenceName, "start", c.Start, "increment", c.Increment)
+	return nil
+}
+
+type DropSequenceData struct {
+	SequenceName string
+}
+
+func (d *DropSequenceData) Statement() types.ResultSet {
+	fmt.Println("drop sequence", d.SequenceName)
+	return nil
+}
+
+type CreateViewData struct {
+	ViewName     string
+	Replace      bool        // OR REPLACE;
+	Materialized bool        // MATERIALIZED, 查询结果保存在隐藏表中;
+	Select       *SelectData // 解析后的查询, 用于收集依赖的表;
+	Query        string      // 查询语句原文, 不含结尾的分号;
+}
+
+func (c *CreateViewData) Statement() types.ResultSet {
+	fmt.Println("create view", c.ViewName, "as", c.Query)
+	return nil
+}
+
+type DropViewData struct {
+	ViewName string
+	Cascade  bool // 同时删除依赖这个视图的视图;
+}
+
+func (d *DropViewData) Statement() types.ResultSet {
+	fmt.Println("drop view", d.ViewName)
+	return nil
+}
+
+type RefreshViewData struct {
+	ViewName string
+}
+
+func (r *RefreshViewData) Statement() types.ResultSet {
+	fmt.Println("refresh materialized view", r.ViewName)
+	return nil
+}
+
+// AlterAction ALTER TABLE 的操作类型;
+type AlterAction int
+
+const (
+	AlterAddColumn    AlterAction = iota // ADD COLUMN col_def;
+	AlterDropColumn                      // DROP COLUMN col;
+	AlterRenameColumn                    // RENAME COLUMN col TO new_name;
+	AlterRenameTable                     // RENAME TO new_name;
+	AlterSetDefault                      // ALTER COLUMN col SET DEFAULT expr;
+	AlterDropDefault                     // ALTER COLUMN col DROP DEFAULT;
+	AlterSetNotNull                      // ALTER COLUMN col SET NOT NULL;
+	AlterDropNotNull                     // ALTER COLUMN col DROP NOT NULL;
+)
+
+type AlterTableData struct {
+	TableName  string
+	Action     AlterAction
+	Column     *types.Column     // ADD COLUMN 的列定义;
+	ColumnName string            // 被修改的列;
+	NewName    string            // RENAME 的新名字;
+	Default    *types.Expression // SET DEFAULT 的默认值;
+}
+
+func (a *AlterTableData) Statement() types.ResultSet {
+	fmt.Println("alter table", a.TableName, a.Action, a.ColumnName, a.NewName)
 	return nil
 }
 
+// BeginData Isolation 为空时使用会话默认的隔离级别;
 type BeginData struct {
+	Isolation *storage.IsolationLevel
 }
 
 func (b *BeginData) Statement() types.ResultSet {
 	return nil
 }
 
+// SetIsolationData SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL ...; 之后开启的事务使用该隔离级别;
+type SetIsolationData struct {
+	Isolation storage.IsolationLevel
+}
+
+func (s *SetIsolationData) Statement() types.ResultSet {
+	return nil
+}
+
 type CommitData struct {
 }
 
@@ -204,13 +337,31 @@ func (c *CommitData) Statement() types.ResultSet {
 	return nil
 }
 
+// RollbackData Savepoint 不为空时为 ROLLBACK TO SAVEPOINT, 只撤销保存点之后的写入;
 type RollbackData struct {
+	Savepoint string
 }
 
 func (r *RollbackData) Statement() types.ResultSet {
 	return nil
 }
 
+type SavepointData struct {
+	Name string
+}
+
+func (s *SavepointData) Statement() types.ResultSet {
+	return nil
+}
+
+type ReleaseSavepointData struct {
+	Name string
+}
+
+func (r *ReleaseSavepointData) Statement() types.ResultSet {
+	return nil
+}
+
 type ExplainData struct {
 	Statements Statement
 }
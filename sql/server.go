@@ -1,26 +1,180 @@
 package sql
 
 import (
+	"errors"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
 	"github.com/kebukeYi/TrainSQL/storage"
+	"sync"
+	"time"
+)
+
+const (
+	indexBackfillBatch = 128             // 每个回填事务处理的行数;
+	indexBackfillRetry = 5               // 回填与并发写入冲突时的重试次数;
+	indexWaitTimeout   = 3 * time.Second // IndexWaitTimeout 的默认值;
+	garbageBatch       = 128             // 每个清理事务删除的 key 数;
 )
 
 type ServerManager struct {
 	// 统一事务管理器;
 	txnManager *storage.TransactionManager
+	// 序列值的预分配缓存, 所有会话共享;
+	sequences *sequenceCache
+	// 同一时间只有一个清理过程;
+	gcLock sync.Mutex
+	// 创建索引时等待旧事务结束的最长时间, 超时则放弃创建; 有长事务的场景可以调大;
+	IndexWaitTimeout time.Duration
 }
 
 func (s *ServerManager) Begin() Service {
-	return NewKVService(s.txnManager.Begin())
+	return s.begin(make(map[string]int64), storage.SnapshotIsolation)
+}
+
+// begin 以指定的隔离级别开启事务; currValues 记录会话中各序列最近一次 nextval 的值, 跨事务保留;
+func (s *ServerManager) begin(currValues map[string]int64, level storage.IsolationLevel) Service {
+	service := NewKVService(s.txnManager.BeginWith(level))
+	service.sequences = s.sequences
+	service.currValues = currValues
+	return service
+}
+
+// CreateIndex 在线创建索引, 构建期间不阻塞表上的读写:
+// 1. 以 WriteOnly 状态登记索引, 之后开启的事务写入时会同步维护索引, 但查询不会使用它;
+// 2. 等待登记之前开启的事务全部结束, 这些事务看不到索引, 它们写入的行交由回填补齐;
+// 3. 分批回填已有的行, 每批一个事务; 与并发写入冲突时重试该批;
+// 4. 将索引置为 Public, 查询开始使用该索引;
+// 任一步骤失败都会删除已登记的索引;
+func (s *ServerManager) CreateIndex(tableName string, index *types.Index) types.ResultSet {
+	index.State = types.IndexWriteOnly
+	service := s.Begin()
+	if err := service.CreateIndex(tableName, index); err != nil {
+		service.Rollback()
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	service.Commit()
+	if err := s.txnManager.WaitActive(s.txnManager.NextVersion(), s.IndexWaitTimeout); err != nil {
+		return s.abortCreateIndex(index.Name, err)
+	}
+	var from []byte
+	for {
+		next, err := s.backfillIndexBatch(tableName, index.Name, from)
+		if err != nil {
+			return s.abortCreateIndex(index.Name, err)
+		}
+		if next == nil {
+			break
+		}
+		from = next
+	}
+	service = s.Begin()
+	if err := service.SetIndexState(tableName, index.Name, types.IndexPublic); err != nil {
+		service.Rollback()
+		return s.abortCreateIndex(index.Name, err)
+	}
+	service.Commit()
+	return &types.CreateIndexResult{IndexName: index.Name}
+}
+
+func (s *ServerManager) backfillIndexBatch(tableName string, indexName string, from []byte) ([]byte, error) {
+	var err error
+	for i := 0; i < indexBackfillRetry; i++ {
+		service := s.Begin()
+		var next []byte
+		next, err = service.BackfillIndex(tableName, indexName, from, indexBackfillBatch)
+		if err == nil {
+			service.Commit()
+			return next, nil
+		}
+		service.Rollback()
+		if !errors.Is(err, util.WriteConflict) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (s *ServerManager) abortCreateIndex(indexName string, cause error) types.ResultSet {
+	service := s.Begin()
+	if err := service.DropIndex(indexName); err != nil {
+		service.Rollback()
+		return &types.ErrorResult{ErrorMessage: util.Error("#CreateIndex %s, drop index error: %s", cause, err).Error()}
+	}
+	service.Commit()
+	return &types.ErrorResult{ErrorMessage: cause.Error()}
+}
+
+// CollectGarbage 清理 TRUNCATE / DROP TABLE 留下的旧数据, 每批一个事务;
+// 仍有事务在登记之前开启时(可能按旧的元数据读写旧数据)暂不清理, 留到下一次;
+func (s *ServerManager) CollectGarbage() error {
+	s.gcLock.Lock()
+	defer s.gcLock.Unlock()
+	service := s.Begin()
+	keySpaces := service.GarbageKeySpaces()
+	service.Commit()
+	if len(keySpaces) == 0 {
+		return nil
+	}
+	if err := s.txnManager.WaitActive(s.txnManager.NextVersion(), 0); err != nil {
+		return err
+	}
+	for _, keySpace := range keySpaces {
+		for done := false; !done; {
+			var err error
+			if done, err = s.collectGarbageBatch(keySpace); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ServerManager) collectGarbageBatch(keySpace string) (bool, error) {
+	var err error
+	for i := 0; i < indexBackfillRetry; i++ {
+		service := s.Begin()
+		var done bool
+		done, err = service.CollectGarbage(keySpace, garbageBatch)
+		if err == nil {
+			service.Commit()
+			return done, nil
+		}
+		service.Rollback()
+		if !errors.Is(err, util.WriteConflict) {
+			return false, err
+		}
+	}
+	return false, err
 }
 
 func (s *ServerManager) Close() error {
 	return s.txnManager.Close()
 }
 
-func NewServer(sto storage.Storage) *ServerManager {
-	return &ServerManager{
-		txnManager: storage.NewTransactionManager(sto),
+func NewServer(sto storage.Storage) (*ServerManager, error) {
+	server := &ServerManager{
+		txnManager:       storage.NewTransactionManager(sto),
+		sequences:        newSequenceCache(),
+		IndexWaitTimeout: indexWaitTimeout,
+	}
+	// 旧格式的数据无法被正确读取, 迁移失败时不能继续启动;
+	if err := server.migrate(); err != nil {
+		return nil, util.Error("#NewServer migrate error: %s", err)
+	}
+	// 上次退出前没有清理完的旧数据; 清理失败不影响启动, 留到之后再清理;
+	_ = server.CollectGarbage()
+	return server, nil
+}
+
+// migrate 启动时将存储中的数据升级为当前版本的格式;
+func (s *ServerManager) migrate() error {
+	service := NewKVService(s.txnManager.Begin())
+	if err := service.migrate(); err != nil {
+		service.Rollback()
+		return err
 	}
+	service.Commit()
+	return nil
 }
 func (s *ServerManager) Session() *Session {
 	return &Session{
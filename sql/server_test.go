@@ -2,14 +2,53 @@ package sql
 
 import (
 	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/types"
 	"github.com/kebukeYi/TrainSQL/sql/util"
 	"github.com/kebukeYi/TrainSQL/storage"
 	"strings"
 	"testing"
+	"time"
 )
 
 var dirPath = "/usr/golanddata/trainsql/server"
 
+// expectRows 执行查询并检查返回的行数, 行数不符时返回 nil;
+func expectRows(t *testing.T, session *Session, sql string, count int) *types.ScanTableResult {
+	resultSet := session.Execute(sql)
+	fmt.Println(resultSet.ToString())
+	scan, ok := resultSet.(*types.ScanTableResult)
+	if !ok || len(scan.Rows) != count {
+		t.Errorf("%s expect %d rows, got: %s", sql, count, resultSet.ToString())
+		return nil
+	}
+	return scan
+}
+
+// expectValue 执行查询并检查结果只有一行, 且第一列的值为 value;
+func expectValue(t *testing.T, session *Session, sql string, value string) {
+	if scan := expectRows(t, session, sql, 1); scan != nil && types.FormatValue(scan.Rows[0][0]) != value {
+		t.Errorf("%s expect %s, got: %s", sql, value, scan.ToString())
+	}
+}
+
+func expectOk(t *testing.T, session *Session, sql string) types.ResultSet {
+	resultSet := session.Execute(sql)
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); ok {
+		t.Errorf("%s expect ok, got: %s", sql, resultSet.ToString())
+	}
+	return resultSet
+}
+
+// expectError 执行语句并检查返回的错误中包含 message, message 为空时只检查出错;
+func expectError(t *testing.T, session *Session, sql string, message string) {
+	resultSet := session.Execute(sql)
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); !ok || !strings.Contains(resultSet.ToString(), message) {
+		t.Errorf("%s expect error %s, got: %s", sql, message, resultSet.ToString())
+	}
+}
+
 func testCreateTable(t *testing.T, session *Session) {
 	resultSet := session.Execute(`create table t1 (
     									a int primary key, 
@@ -294,6 +333,1339 @@ func testIndexScan(t *testing.T, session *Session) {
 	resultSet = session.Execute("explain select * from i1 where c = 1.1;")
 	fmt.Println(resultSet.ToString())
 }
+func testCreateIndex(t *testing.T, session *Session) {
+	session.Execute("create table ci1 (a int primary key, b text, c int);")
+	session.Execute("insert into ci1 values (1, 'a', 10);")
+	session.Execute("insert into ci1 values (2, 'b', 20);")
+	session.Execute("insert into ci1 values (3, 'a', 30);")
+
+	// CREATE INDEX: ci1_b
+	resultSet := session.Execute("create index ci1_b on ci1 (b);")
+	fmt.Println(resultSet.ToString())
+
+	// 建索引之后写入的数据也要维护索引;
+	session.Execute("insert into ci1 values (4, 'a', 40);")
+	session.Execute("update ci1 set b = 'b' where a = 3;")
+
+	// a |b |c
+	//--+--+----
+	//1 |a |10
+	//4 |a |40
+	resultSet = session.Execute("select * from ci1 where b = 'a';")
+	fmt.Println(resultSet.ToString())
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 2 {
+		t.Errorf("index scan on ci1_b expect 2 rows, got: %s", resultSet.ToString())
+	}
+
+	//           SQL PLAN
+	//------------------------------
+	//Index Scan On ci1 ci1_b (b = a)
+	resultSet = session.Execute("explain select * from ci1 where b = 'a';")
+	fmt.Println(resultSet.ToString())
+
+	// 已有重复值, 唯一索引创建失败, 并且不会残留索引;
+	resultSet = session.Execute("create unique index ci1_b_uniq on ci1 (b);")
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); !ok {
+		t.Errorf("create unique index on duplicate values should fail")
+	}
+
+	resultSet = session.Execute("create unique index ci1_c on ci1 (c);")
+	fmt.Println(resultSet.ToString())
+	resultSet = session.Execute("insert into ci1 values (5, 'c', 10);")
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); !ok {
+		t.Errorf("insert duplicate value into unique index should fail")
+	}
+
+	// INDEXES: {
+	//	ci1_b (b)
+	//	ci1_c (c) UNIQUE
+	//}
+	resultSet = session.Execute("show table ci1;")
+	fmt.Println(resultSet.ToString())
+
+	// DROP INDEX: ci1_b
+	resultSet = session.Execute("drop index ci1_b;")
+	fmt.Println(resultSet.ToString())
+	resultSet = session.Execute("explain select * from ci1 where b = 'a';")
+	fmt.Println(resultSet.ToString())
+
+	// 显式事务中创建索引与并发写入行的事务之间必有一方写冲突, 索引不会漏掉并发写入的行;
+	other := session.Server.Session()
+	session.Execute("create table ci2 (id int primary key, v int);")
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "create unique index ci2_v on ci2 (v);")
+	expectError(t, other, "insert into ci2 values (1, 1), (2, 1);", "WriteConflict")
+	expectOk(t, session, "commit;")
+	expectOk(t, other, "insert into ci2 values (1, 1);")
+	expectError(t, other, "insert into ci2 values (2, 1);", "duplicate")
+	expectRows(t, session, "select * from ci2 where v = 1;", 1)
+	// 写入的事务先提交, 在它提交之前开始的事务创建索引冲突;
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "select * from ci2;")
+	expectOk(t, other, "insert into ci2 values (3, 3);")
+	expectError(t, session, "create index ci2_w on ci2 (v);", "WriteConflict")
+	expectOk(t, session, "rollback;")
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "create index ci2_w on ci2 (v);")
+	expectOk(t, session, "commit;")
+	expectRows(t, session, "select * from ci2 where v = 3;", 1)
+}
+
+// testCreateIndexWaitTimeout 登记索引之前开启的事务在 IndexWaitTimeout 内未结束时, 放弃创建索引;
+func testCreateIndexWaitTimeout(t *testing.T, session *Session) {
+	server := session.Server
+	timeout := server.IndexWaitTimeout
+	server.IndexWaitTimeout = 50 * time.Millisecond
+	defer func() { server.IndexWaitTimeout = timeout }()
+	other := server.Session()
+	session.Execute("create table iw1 (a int primary key, b int);")
+	session.Execute("insert into iw1 values (1, 10);")
+
+	other.Execute("begin;")
+	other.Execute("insert into iw1 values (2, 20);")
+	resultSet := session.Execute("create index iw1_b on iw1 (b);")
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); !ok {
+		t.Errorf("create index should time out while an older transaction is active, got: %s", resultSet.ToString())
+	}
+	// 超时后不会残留索引;
+	resultSet = session.Execute("explain select * from iw1 where b = 20;")
+	if strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("index iw1_b should be dropped after timeout, got: %s", resultSet.ToString())
+	}
+
+	other.Execute("commit;")
+	resultSet = session.Execute("create index iw1_b on iw1 (b);")
+	if _, ok := resultSet.(*types.ErrorResult); ok {
+		t.Errorf("create index after commit should succeed, got: %s", resultSet.ToString())
+	}
+	resultSet = session.Execute("select * from iw1 where b = 20;")
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 1 {
+		t.Errorf("index scan on iw1_b expect 1 row, got: %s", resultSet.ToString())
+	}
+}
+func testCompositeIndex(t *testing.T, session *Session) {
+	session.Execute("create table co1 (id int primary key, tenant int, created int, v text);")
+	session.Execute("insert into co1 values (1, 1, 100, 'a');")
+	session.Execute("insert into co1 values (2, 1, 200, 'b');")
+	session.Execute("insert into co1 values (3, 1, 300, 'c');")
+	session.Execute("insert into co1 values (4, 2, 150, 'd');")
+	session.Execute("insert into co1 values (5, 0, 250, 'e');")
+	session.Execute("create index co1_tc on co1 (tenant, created);")
+
+	// id |tenant |created |v
+	//---+-------+--------+--
+	//2  |1      |200     |b
+	//3  |1      |300     |c
+	resultSet := session.Execute("select * from co1 where tenant = 1 and created >= 200;")
+	fmt.Println(resultSet.ToString())
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 2 {
+		t.Errorf("range scan on co1_tc expect 2 rows, got: %s", resultSet.ToString())
+	}
+
+	//           SQL PLAN
+	//------------------------------
+	//Index Scan On co1 co1_tc (tenant = 1 AND created >= 200)
+	resultSet = session.Execute("explain select * from co1 where tenant = 1 and created >= 200;")
+	fmt.Println(resultSet.ToString())
+
+	// 只命中最左前缀, 剩余条件逐行过滤;
+	//           SQL PLAN
+	//------------------------------
+	//Filter (v = a)
+	//   ->  Index Scan On co1 co1_tc (tenant = 1)
+	resultSet = session.Execute("explain select * from co1 where v = 'a' and tenant = 1;")
+	fmt.Println(resultSet.ToString())
+
+	// 首列上的范围, 结果按索引顺序返回;
+	// id |tenant |created |v
+	//---+-------+--------+--
+	//5  |0      |250     |e
+	//1  |1      |100     |a
+	//2  |1      |200     |b
+	//3  |1      |300     |c
+	resultSet = session.Execute("select * from co1 where tenant < 2;")
+	fmt.Println(resultSet.ToString())
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 4 {
+		t.Errorf("range scan on co1_tc expect 4 rows, got: %s", resultSet.ToString())
+	}
+	// 范围的两端编码为索引 key 的边界, 开闭区间都只读取范围内的索引项;
+	expectRows(t, session, "select * from co1 where tenant > 1;", 1)
+	expectRows(t, session, "select * from co1 where tenant >= 1 and tenant <= 1;", 3)
+	expectRows(t, session, "select * from co1 where tenant = 1 and created > 100 and created < 300;", 1)
+	expectRows(t, session, "select * from co1 where tenant = 1 and created <= 300;", 3)
+	expectRows(t, session, "select * from co1 where tenant = 1 and created > 300;", 0)
+
+	// 不满足最左前缀, 全表扫描;
+	resultSet = session.Execute("explain select * from co1 where created = 200;")
+	fmt.Println(resultSet.ToString())
+}
+func testCompositePrimaryKey(t *testing.T, session *Session) {
+	session.Execute("create table cp1 (tenant int, id int, v text, primary key (tenant, id));")
+	session.Execute("insert into cp1 values (2, 1, 'c');")
+	session.Execute("insert into cp1 values (1, 2, 'b');")
+	session.Execute("insert into cp1 values (1, 1, 'a');")
+	// 列上的主键与表约束主键不能同时声明;
+	expectError(t, session, "create table cp2 (a int primary key, b int, primary key (a, b));", "multiple primary key")
+	resultSet := session.Execute("insert into cp1 values (1, 1, 'x');")
+	// 联合主键重复;
+	fmt.Println(resultSet.ToString())
+
+	// tenant |id |v
+	//-------+---+--
+	//1      |2  |b
+	resultSet = session.Execute("select * from cp1 where id = 2 and tenant = 1;")
+	fmt.Println(resultSet.ToString())
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 1 {
+		t.Errorf("full key lookup on cp1 expect 1 row, got: %s", resultSet.ToString())
+	}
+
+	//           SQL PLAN
+	//------------------------------
+	//Primary key Scan On cp1 (tenant = 1 AND id = 2)
+	resultSet = session.Execute("explain select * from cp1 where id = 2 and tenant = 1;")
+	fmt.Println(resultSet.ToString())
+
+	// 主键前缀扫描, 结果按主键顺序返回;
+	// tenant |id |v
+	//-------+---+--
+	//1      |1  |a
+	//1      |2  |b
+	resultSet = session.Execute("select * from cp1 where tenant = 1;")
+	fmt.Println(resultSet.ToString())
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 2 {
+		t.Errorf("prefix scan on cp1 expect 2 rows, got: %s", resultSet.ToString())
+	}
+
+	// 更新主键的一部分: 删除旧行, 写入新行;
+	session.Execute("update cp1 set id = 3 where tenant = 2 and id = 1;")
+	resultSet = session.Execute("select * from cp1 where tenant = 2 and id = 3;")
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 1 {
+		t.Errorf("update primary key on cp1 expect 1 row, got: %s", resultSet.ToString())
+	}
+
+	// 不满足最左前缀, 全表扫描;
+	resultSet = session.Execute("explain select * from cp1 where id = 1;")
+	fmt.Println(resultSet.ToString())
+}
+func testUnique(t *testing.T, session *Session) {
+	session.Execute("create table un1 (id int primary key, email text unique, a int, b int, unique (a, b));")
+	session.Execute("insert into un1 values (1, 'x', 1, 1);")
+	resultSet := session.Execute("insert into un1 values (2, 'x', 1, 2);")
+	// ERROR: [Unique] duplicate key value violates unique constraint "un1_email_key": (email)=(x)
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), `"un1_email_key": (email)=(x)`) {
+		t.Errorf("insert duplicate email expect unique violation, got: %s", resultSet.ToString())
+	}
+	resultSet = session.Execute("insert into un1 values (3, 'y', 1, 1);")
+	// ERROR: [Unique] duplicate key value violates unique constraint "un1_a_b_key": (a, b)=(1, 1)
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), `"un1_a_b_key": (a, b)=(1, 1)`) {
+		t.Errorf("insert duplicate (a, b) expect unique violation, got: %s", resultSet.ToString())
+	}
+	// NULL 之间互不冲突;
+	session.Execute("insert into un1 values (4, null, null, 1);")
+	resultSet = session.Execute("insert into un1 values (5, null, null, 1);")
+	if _, ok := resultSet.(*types.ErrorResult); ok {
+		t.Errorf("insert null into unique column should succeed, got: %s", resultSet.ToString())
+	}
+	resultSet = session.Execute("update un1 set email = 'x' where id = 4;")
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); !ok {
+		t.Errorf("update to duplicate email should fail")
+	}
+
+	// 两个并发事务写入相同的值: 后写入的一方在唯一索引的 key 上发生写冲突;
+	other := session.Server.Session()
+	session.Execute("begin;")
+	other.Execute("begin;")
+	session.Execute("insert into un1 values (10, 'z', 10, 10);")
+	resultSet = other.Execute("insert into un1 values (11, 'z', 11, 11);")
+	fmt.Println(resultSet.ToString())
+	if _, ok := resultSet.(*types.ErrorResult); !ok {
+		t.Errorf("concurrent insert of duplicate email should fail")
+	}
+	session.Execute("commit;")
+	other.Execute("rollback;")
+	resultSet = session.Execute("select * from un1 where email = 'z';")
+	if scan, ok := resultSet.(*types.ScanTableResult); !ok || len(scan.Rows) != 1 {
+		t.Errorf("expect 1 row with email z, got: %s", resultSet.ToString())
+	}
+
+	// INDEXES: {
+	//	un1_email_key (email) UNIQUE
+	//	un1_a_b_key (a, b) UNIQUE
+	//}
+	showTableInfo(t, session, "un1")
+}
+func testForeignKey(t *testing.T, session *Session) {
+	session.Execute("create table fu (id int primary key, name text);")
+	session.Execute("create table fo (id int primary key, uid int references fu(id) on delete cascade on update cascade);")
+	session.Execute("create table fl (id int primary key, oid int, foreign key (oid) references fo);")
+	session.Execute("create table fn (id int primary key, uid int references fu on delete set null on update set null);")
+	session.Execute("insert into fu values (1, 'a');")
+	session.Execute("insert into fu values (2, 'b');")
+	session.Execute("insert into fo values (10, 1);")
+	session.Execute("insert into fo values (20, 2);")
+	session.Execute("insert into fl values (100, 20);")
+	session.Execute("insert into fn values (1000, 1);")
+
+	// ERROR: [ForeignKey] insert or update on table "fo" violates foreign key constraint "fo_uid_fkey": (uid)=(9) is not present in table "fu"
+	expectError(t, session, "insert into fo values (30, 9);", "")
+	// 外键列为 NULL 时不校验;
+	session.Execute("insert into fo values (31, null);")
+	expectRows(t, session, "select * from fo;", 3)
+	expectError(t, session, "update fl set oid = 99 where id = 100;", "")
+	// ERROR: [ForeignKey] update or delete on table "fo" violates foreign key constraint "fl_oid_fkey" on table "fl": (id)=(20) is still referenced
+	expectError(t, session, "delete from fo where id = 20;", "")
+
+	// ON UPDATE CASCADE / SET NULL;
+	session.Execute("update fu set id = 5 where id = 1;")
+	expectRows(t, session, "select * from fo where uid = 5;", 1)
+	if scan := expectRows(t, session, "select * from fn where id = 1000;", 1); scan != nil && scan.Rows[0][1].DateType() != types.Null {
+		t.Errorf("fn.uid expect NULL after ON UPDATE SET NULL, got: %s", scan.ToString())
+	}
+	// ON DELETE CASCADE;
+	session.Execute("delete from fu where id = 5;")
+	expectRows(t, session, "select * from fo where id = 10;", 0)
+	// ERROR: [ForeignKey] can not drop table fu because constraint fn_uid_fkey on table fn depends on it
+	expectError(t, session, "drop table fu;", "")
+
+	// 自引用的级联删除;
+	session.Execute("create table ft (id int primary key, parent int references ft on delete cascade);")
+	session.Execute("insert into ft values (1, null);")
+	session.Execute("insert into ft values (2, 1);")
+	session.Execute("insert into ft values (3, 2);")
+	session.Execute("insert into ft values (4, 4);")
+	session.Execute("delete from ft where id = 1;")
+	expectRows(t, session, "select * from ft;", 1)
+
+	// FOREIGN KEYS: {
+	//	fo_uid_fkey (uid) REFERENCES fu (id) ON DELETE CASCADE ON UPDATE CASCADE
+	//}
+	showTableInfo(t, session, "fo")
+}
+
+// 插入子行与并发删除父行的事务之间必有一方写冲突, 不会留下引用不存在父行的子行;
+func testForeignKeyConcurrent(t *testing.T, session *Session) {
+	other := session.Server.Session()
+	session.Execute("create table fc1 (id int primary key, name text);")
+	session.Execute("create table fc2 (id int primary key, pid int references fc1);")
+	session.Execute("insert into fc1 values (1, 'a'), (2, 'b'), (3, 'c');")
+
+	// 子行先插入, 未提交时删除父行;
+	expectOk(t, other, "begin;")
+	expectOk(t, other, "insert into fc2 values (1, 1);")
+	expectError(t, session, "delete from fc1 where id = 1;", "WriteConflict")
+	expectOk(t, other, "commit;")
+	expectRows(t, session, "select * from fc2 where pid = 1;", 1)
+	expectRows(t, session, "select * from fc1 where id = 1;", 1)
+
+	// 父行先删除, 提交之前或之后插入子行;
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "delete from fc1 where id = 2;")
+	expectOk(t, other, "begin;")
+	expectError(t, other, "insert into fc2 values (2, 2);", "WriteConflict")
+	expectOk(t, other, "rollback;")
+	expectOk(t, other, "begin;")
+	expectOk(t, other, "select * from fc1;")
+	expectOk(t, session, "commit;")
+	expectError(t, other, "insert into fc2 values (2, 2);", "WriteConflict")
+	expectOk(t, other, "rollback;")
+	expectRows(t, session, "select * from fc2 where pid = 2;", 0)
+
+	// 修改被引用列同样冲突, 修改父行的其他列不冲突;
+	expectOk(t, other, "begin;")
+	expectOk(t, other, "insert into fc2 values (3, 3);")
+	expectError(t, session, "update fc1 set id = 4 where id = 3;", "WriteConflict")
+	expectOk(t, session, "update fc1 set name = 'd' where id = 3;")
+	expectOk(t, other, "commit;")
+	expectRows(t, session, "select * from fc2 where pid = 3;", 1)
+	expectRows(t, session, "select * from fc1 where name = 'd';", 1)
+
+	// 子行提交之后, 在它之前开始的事务删除父行仍然冲突; 引用同一父行的子行之间不冲突;
+	session.Execute("insert into fc1 values (5, 'e');")
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "select * from fc1;")
+	expectOk(t, other, "begin;")
+	expectOk(t, other, "insert into fc2 values (5, 5);")
+	expectOk(t, session.Server.Session(), "insert into fc2 values (6, 5);")
+	expectOk(t, other, "commit;")
+	expectError(t, session, "delete from fc1 where id = 5;", "WriteConflict")
+	expectOk(t, session, "rollback;")
+	expectRows(t, session, "select * from fc2 where pid = 5;", 2)
+	expectError(t, session, "delete from fc1 where id = 5;", "ForeignKey")
+}
+func testCheck(t *testing.T, session *Session) {
+	session.Execute("create table ck1 (id int primary key, price float check (price > 0), lo int, hi int, check (lo <= hi));")
+	session.Execute("insert into ck1 values (1, 1.5, 1, 2);")
+	// ERROR: [Check] new row for table "ck1" violates check constraint "ck1_price_check": (price > 0)
+	expectError(t, session, "insert into ck1 values (2, 0.0, 1, 2);", `"ck1_price_check"`)
+	expectError(t, session, "insert into ck1 values (3, 2.5, 5, 1);", `"ck1_lo_check"`)
+	expectError(t, session, "update ck1 set price = 0.0 where id = 1;", `"ck1_price_check"`)
+	// 表达式结果为 NULL 时通过;
+	resultSet := session.Execute("insert into ck1 values (4, null, null, 1);")
+	if _, ok := resultSet.(*types.ErrorResult); ok {
+		t.Errorf("insert null into check column should succeed, got: %s", resultSet.ToString())
+	}
+
+	// 建表时校验表达式中的列与类型;
+	expectError(t, session, "create table ck2 (id int primary key, name text check (name > 1));", "can not compare")
+	expectError(t, session, "create table ck3 (id int primary key, check (missing > 1));", "column missing not exists")
+
+	// CHECKS: {
+	//	ck1_price_check CHECK (price > 0)
+	//	ck1_lo_check CHECK (lo <= hi)
+	//}
+	showTableInfo(t, session, "ck1")
+}
+func testAlterTable(t *testing.T, session *Session) {
+	session.Execute("create table al1 (id int primary key, name text);")
+	session.Execute("insert into al1 values (1, 'a');")
+	session.Execute("insert into al1 values (2, 'b');")
+
+	// 已有的行读取新增列时取添加时的默认值, 不改写已有的行;
+	expectOk(t, session, "alter table al1 add column age int default 18;")
+	expectValue(t, session, "select age from al1 where id = 1;", "18")
+	expectOk(t, session, "insert into al1 values (3, 'c', 30);")
+	expectError(t, session, "alter table al1 add column score int not null;", "can not be null")
+	expectError(t, session, "alter table al1 add code int unique default 1;", "duplicate key value")
+	expectOk(t, session, "alter table al1 add email text unique;")
+
+	// 修改默认值只影响之后插入的行;
+	expectOk(t, session, "alter table al1 alter column age set default 20;")
+	expectOk(t, session, "insert into al1 (id, name, email) values (4, 'd', null);")
+	expectValue(t, session, "select age from al1 where id = 4;", "20")
+	expectValue(t, session, "select age from al1 where id = 1;", "18")
+	expectOk(t, session, "alter table al1 alter age drop default;")
+
+	// 删除的列不再读取, 同名的新列使用新的存储位置;
+	expectOk(t, session, "alter table al1 drop column name;")
+	if scan := expectRows(t, session, "select * from al1 where id = 1;", 1); scan != nil && len(scan.Rows[0]) != 3 {
+		t.Errorf("al1 expect 3 columns after drop column, got: %s", scan.ToString())
+	}
+	expectOk(t, session, "alter table al1 add column name int default 0;")
+	expectValue(t, session, "select name from al1 where id = 1;", "0")
+	expectError(t, session, "alter table al1 drop column id;", "primary key")
+
+	expectOk(t, session, "alter table al1 rename column age to years;")
+	expectValue(t, session, "select years from al1 where id = 1;", "18")
+
+	// NOT NULL;
+	expectError(t, session, "alter table al1 alter column email set not null;", "contains null values")
+	expectOk(t, session, "alter table al1 alter column years set not null;")
+	expectError(t, session, "update al1 set years = null where id = 1;", "can not be null")
+	expectOk(t, session, "alter table al1 alter column years drop not null;")
+	expectError(t, session, "alter table al1 alter column id drop not null;", "can not be nullable")
+
+	// 改名后数据仍在原来的存储名下, 与旧表同名的新表不会读到旧表的数据;
+	expectOk(t, session, "alter table al1 rename to al2;")
+	expectRows(t, session, "select * from al2;", 4)
+	expectError(t, session, "select * from al1;", "")
+	session.Execute("create table al1 (id int primary key);")
+	expectRows(t, session, "select * from al1;", 0)
+
+	// 外键随被引用表与列改名;
+	session.Execute("create table al3 (id int primary key, pid int references al2);")
+	expectOk(t, session, "alter table al2 rename to al4;")
+	expectOk(t, session, "alter table al4 rename column id to uid;")
+	expectOk(t, session, "insert into al3 values (1, 1);")
+	expectError(t, session, "insert into al3 values (2, 99);", "al3_pid_fkey")
+	expectError(t, session, "alter table al4 drop column uid;", "depends on it")
+	expectError(t, session, "alter table al3 add column ref int references al4 (years);", "no unique constraint")
+
+	// 新增列上的索引会回填, 列改名后隐式索引随之改名;
+	expectOk(t, session, "alter table al4 add column tag int index default 7;")
+	expectRows(t, session, "select * from al4 where tag = 7;", 4)
+	expectOk(t, session, "alter table al4 rename column tag to label;")
+	expectRows(t, session, "select * from al4 where label = 7;", 4)
+
+	//COLUMNS: {
+	//uid Integer PRIMARY KEY
+	//years Integer
+	//email String
+	//name Integer DEFAULT 0
+	//label Integer DEFAULT 7
+	//}
+	//INDEXES: {
+	//label (label)
+	//al1_email_key (email) UNIQUE
+	//}
+	showTableInfo(t, session, "al4")
+
+	// 校验已有行的结构变更与并发写入行的事务之间必有一方写冲突, 不会漏掉并发写入的行;
+	other := session.Server.Session()
+	session.Execute("create table al5 (id int primary key, v int);")
+	expectOk(t, other, "begin;")
+	expectOk(t, other, "insert into al5 values (2, null);")
+	expectError(t, session, "alter table al5 alter column v set not null;", "WriteConflict")
+	expectOk(t, other, "commit;")
+	expectError(t, session, "alter table al5 alter column v set not null;", "contains null values")
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "select * from al5;")
+	expectOk(t, other, "insert into al5 values (3, null);")
+	expectError(t, session, "alter table al5 add column w int not null;", "WriteConflict")
+	expectOk(t, session, "rollback;")
+	expectOk(t, session, "delete from al5;")
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "alter table al5 alter column v set not null;")
+	expectError(t, other, "insert into al5 values (4, null);", "WriteConflict")
+	expectOk(t, session, "commit;")
+	expectError(t, other, "insert into al5 values (4, null);", "can not be null")
+}
+func testSequence(t *testing.T, session *Session) {
+	// 省略 AUTO_INCREMENT / SERIAL 列时从列的序列取值;
+	expectOk(t, session, "create table sq1 (id int primary key auto_increment, name text);")
+	expectOk(t, session, "insert into sq1 (name) values ('a'), ('b');")
+	expectValue(t, session, "select id from sq1 where name = 'b';", "2")
+	expectOk(t, session, "insert into sq1 values (10, 'c');")
+	expectOk(t, session, "insert into sq1 (name) values ('d');")
+	expectValue(t, session, "select id from sq1 where name = 'd';", "3")
+	expectOk(t, session, "create table sq2 (id serial primary key, name text);")
+	expectOk(t, session, "insert into sq2 (name) values ('a');")
+	expectValue(t, session, "select id from sq2 where name = 'a';", "1")
+	expectError(t, session, "drop sequence sq1_id_seq;", "depends on it")
+
+	// 序列值不随事务回滚归还;
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "insert into sq1 (name) values ('e');")
+	expectOk(t, session, "rollback;")
+	expectOk(t, session, "insert into sq1 (name) values ('f');")
+	expectValue(t, session, "select id from sq1 where name = 'f';", "5")
+
+	expectOk(t, session, "create sequence sq_order start with 100 increment by 10;")
+	expectError(t, session, "create sequence sq_order;", "already exists")
+	expectError(t, session, "insert into sq1 values (currval('sq_order'), 'g');", "not yet defined")
+	expectOk(t, session, "insert into sq1 values (nextval('sq_order'), 'g');")
+	expectOk(t, session, "insert into sq1 values (nextval('sq_order'), 'h');")
+	expectValue(t, session, "select id from sq1 where name = 'h';", "110")
+	expectOk(t, session, "insert into sq2 values (currval('sq_order'), 'i');")
+	expectValue(t, session, "select id from sq2 where name = 'i';", "110")
+
+	// 并发的会话取到不同的值, currval 只属于各自的会话;
+	other := session.Server.Session()
+	other.Execute("begin;")
+	session.Execute("begin;")
+	other.Execute("insert into sq2 values (nextval('sq_order'), 'j');")
+	session.Execute("insert into sq2 values (nextval('sq_order'), 'k');")
+	other.Execute("commit;")
+	session.Execute("commit;")
+	expectValue(t, other, "select id from sq2 where name = 'j';", "120")
+	expectValue(t, session, "select id from sq2 where name = 'k';", "130")
+
+	// nextval / currval 也可以用在查询与 UPDATE 中, 每一行取一个新的值; 不能用在 CHECK 约束中;
+	if scan := expectRows(t, session, "select nextval('sq_order') as no from sq2;", 4); scan != nil {
+		for i, row := range scan.Rows {
+			if no := types.FormatValue(row[0]); no != fmt.Sprint(140+10*i) {
+				t.Errorf("expect nextval %d on row %d, got: %s", 140+10*i, i, no)
+			}
+		}
+	}
+	expectValue(t, session, "select currval('sq_order') from sq2 where name = 'a';", "170")
+	expectOk(t, session, "create table sq3 (id int primary key, no int);")
+	expectOk(t, session, "insert into sq3 values (1, null), (2, null);")
+	expectOk(t, session, "update sq3 set no = nextval('sq_order');")
+	expectValue(t, session, "select no from sq3 where id = 2;", "190")
+	expectOk(t, session, "update sq3 set no = currval('sq_order') + 1 where id = 1;")
+	expectValue(t, session, "select no from sq3 where id = 1;", "191")
+	expectError(t, session, "create table sq4 (id int primary key, check (id < nextval('sq_order')));", "not allowed")
+
+	expectOk(t, session, "drop sequence sq_order;")
+	expectError(t, session, "insert into sq1 values (nextval('sq_order'), 'l');", "not exists")
+	// 删除表时一并删除列的序列;
+	expectOk(t, session, "drop table sq2;")
+	expectOk(t, session, "create sequence sq2_id_seq;")
+	expectOk(t, session, "drop sequence sq2_id_seq;")
+}
+
+func testInsertSelect(t *testing.T, session *Session) {
+	session.Execute("create table is1 (id int primary key, name text, age int);")
+	session.Execute("insert into is1 values (1, 'a', 10), (2, 'b', 20), (3, 'c', 30);")
+	session.Execute("create table is2 (id int primary key, name text, age int default 0);")
+
+	expectOk(t, session, "insert into is2 select * from is1 where age > 10;")
+	expectRows(t, session, "select * from is2;", 2)
+	expectOk(t, session, "insert into is2 (id, name) select id, name from is1 where id = 1;")
+	expectRows(t, session, "select * from is2 where age = 0;", 1)
+	expectError(t, session, "insert into is2 select * from is1;", "already exists")
+
+	// 类型不匹配时一行都不写入;
+	session.Execute("create table is3 (id int primary key, name int);")
+	expectError(t, session, "insert into is3 select id, name from is1;", "expects Integer")
+	expectRows(t, session, "select * from is3;", 0)
+	expectError(t, session, "insert into is3 (id) select id, name from is1;", "expects 1")
+
+	// 查询不会读到本条语句新插入的行;
+	session.Execute("create table is4 (id serial primary key, v int);")
+	session.Execute("insert into is4 (v) values (1), (2);")
+	expectOk(t, session, "insert into is4 (v) select v from is4;")
+	expectRows(t, session, "select * from is4;", 4)
+	expectOk(t, session, "insert into is4 (v) select v from is4;")
+	expectRows(t, session, "select * from is4;", 8)
+}
+
+func testUpsert(t *testing.T, session *Session) {
+	expectCount := func(sql string, count int) {
+		resultSet := session.Execute(sql)
+		fmt.Println(resultSet.ToString())
+		if insert, ok := resultSet.(*types.InsertTableResult); !ok || insert.Count != count {
+			t.Errorf("%s expect %d rows, got: %s", sql, count, resultSet.ToString())
+		}
+	}
+	session.Execute("create table up1 (id int primary key, city text index, email text unique, visits int default 0);")
+	session.Execute("insert into up1 values (1, 'bj', 'a@x', 1), (2, 'sh', 'b@x', 1);")
+
+	expectCount("insert into up1 values (1, 'gz', 'c@x', 5) on conflict (id) do nothing;", 0)
+	expectValue(t, session, "select city from up1 where id = 1;", "bj")
+	expectCount("insert into up1 values (3, 'gz', 'b@x', 5) on conflict do nothing;", 0)
+	expectCount("insert into up1 values (3, 'gz', 'c@x', 5), (1, 'x', 'y', 0) on conflict do nothing;", 1)
+
+	// DO UPDATE 修改索引列时同步维护索引;
+	expectCount("insert into up1 values (1, 'sz', 'a@x', 1) on conflict (id) do update set city = excluded.city, visits = 2;", 1)
+	expectValue(t, session, "select visits from up1 where id = 1;", "2")
+	expectRows(t, session, "select * from up1 where city = 'bj';", 0)
+	expectValue(t, session, "select id from up1 where city = 'sz';", "1")
+	expectCount("insert into up1 (id, city, email) values (4, null, 'a@x') on conflict (email) do update set email = 'd@x';", 1)
+	expectValue(t, session, "select id from up1 where email = 'd@x';", "1")
+	expectRows(t, session, "select * from up1 where email = 'a@x';", 0)
+	expectCount("insert into up1 values (1, 'sz', 'e@x', 1) on conflict (id) do update set email = excluded.email, city = 'wh';", 1)
+	expectValue(t, session, "select id from up1 where city = 'wh';", "1")
+	expectError(t, session, "insert into up1 values (2, 'sh', 'c@x', 1) on conflict (id) do update set email = excluded.email;", "duplicate key value")
+
+	expectError(t, session, "insert into up1 values (1, 'a', 'b', 1) on conflict (city) do nothing;", "no unique or primary key constraint")
+	expectError(t, session, "insert into up1 values (1, 'a', 'b', 1) on conflict (id) do update set age = 1;", "not exists")
+	expectError(t, session, "insert into up1 values (5, 'a', 'g@x', 1), (5, 'b', 'h@x', 1) on conflict (id) do update set city = excluded.city;", "second time")
+}
+
+func testReturning(t *testing.T, session *Session) {
+	expectReturning := func(sql string, columns string, rows ...string) {
+		resultSet := session.Execute(sql)
+		fmt.Println(resultSet.ToString())
+		scan, ok := resultSet.(*types.ScanTableResult)
+		if !ok || strings.Join(scan.Columns, ",") != columns || len(scan.Rows) != len(rows) {
+			t.Errorf("%s expect columns %s and %d rows, got: %s", sql, columns, len(rows), resultSet.ToString())
+			return
+		}
+		for i, row := range scan.Rows {
+			if got := types.RowsToString(row); got != rows[i] {
+				t.Errorf("%s expect row %s, got: %s", sql, rows[i], got)
+			}
+		}
+	}
+	session.Execute("create table rt1 (id serial primary key, name text, age int default 18);")
+
+	// 返回生成的值;
+	expectReturning("insert into rt1 (name) values ('a'), ('b') returning id, age;", "id,age", "1 |18 |", "2 |18 |")
+	expectReturning("insert into rt1 values (3, 'c', 30) returning *;", "id,name,age", "3 |c |30 |")
+	expectReturning("insert into rt1 values (3, 'x', 0) on conflict (id) do update set age = 31 returning name as n, age;", "n,age", "c |31 |")
+	expectReturning("insert into rt1 values (3, 'x', 0) on conflict do nothing returning id;", "id")
+
+	expectReturning("update rt1 set age = 20 where id = 1 returning *;", "id,name,age", "1 |a |20 |")
+	expectReturning("update rt1 set age = 21 where id = 100 returning id;", "id")
+	expectReturning("delete from rt1 where id = 2 returning name;", "name", "b |")
+	expectReturning("select id from rt1 where id = 2;", "id")
+
+	// RETURNING 引用的列不存在时不写入;
+	expectError(t, session, "delete from rt1 where id = 1 returning email;", "not exists")
+	expectReturning("select id from rt1 where id = 1;", "id", "1 |")
+	expectError(t, session, "insert into rt1 (name) values ('d') returning count(id);", "not allowed")
+	expectReturning("select id from rt1 where name = 'd';", "id")
+
+	// 表达式对写入之后(DELETE 为删除之前)的行逐行计算, 别名作为列名, 没有别名时为表达式本身;
+	expectReturning("update rt1 set age = age + 1 where id = 1 returning id * 2 as double_id, upper(name) as up, age - 1;",
+		"double_id,up,age - 1", "2 |A |20 |")
+	expectReturning("insert into rt1 values (5, 'Ee', 1) returning id * 10 as x, lower(name) as l, length(name);",
+		"x,l,length(name)", "50 |ee |2 |")
+	expectReturning("delete from rt1 where id = 5 returning substring(upper(name), 2) as tail, age;", "tail,age", "E |1 |")
+	expectError(t, session, "update rt1 set age = 1 where id = 1 returning age + email;", "not exists")
+	expectReturning("select age from rt1 where id = 1;", "age", "21 |")
+}
+
+func testTruncate(t *testing.T, session *Session) {
+	// 旧存储名下的数据在提交后被清理;
+	expectCollected := func(keySpace string) {
+		service := session.Server.Begin().(*KVService)
+		defer service.Commit()
+		if garbage := service.GarbageKeySpaces(); len(garbage) != 0 {
+			t.Errorf("expect garbage collected, got: %v", garbage)
+		}
+		rows := service.txn.ScanPrefix(GetPrefixRowKey(keySpace), true)
+		indexes := service.txn.ScanPrefix(GetIndexSpacePrefixKey(keySpace), true)
+		if len(rows) != 0 || len(indexes) != 0 {
+			t.Errorf("expect no data in %s, got %d rows and %d index entries", keySpace, len(rows), len(indexes))
+		}
+	}
+	session.Execute("create table tr1 (id serial primary key, name text unique, v int index);")
+	session.Execute("insert into tr1 (name, v) values ('a', 1), ('b', 2), ('c', 2);")
+
+	// 事务中清空, 提交前其他会话仍能读到旧数据, 回滚后数据恢复;
+	other := session.Server.Session()
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "truncate table tr1;")
+	expectRows(t, session, "select * from tr1;", 0)
+	expectRows(t, other, "select * from tr1;", 3)
+	expectOk(t, session, "rollback;")
+	expectRows(t, session, "select * from tr1 where v = 2;", 2)
+
+	expectOk(t, session, "truncate tr1;")
+	expectRows(t, session, "select * from tr1;", 0)
+	expectRows(t, session, "select * from tr1 where v = 2;", 0)
+	expectCollected("tr1")
+	// 唯一索引随之清空, 自增序列继续增长;
+	expectOk(t, session, "insert into tr1 (name, v) values ('a', 2);")
+	expectRows(t, session, "select * from tr1 where v = 2;", 1)
+	expectRows(t, session, "select * from tr1 where id = 4;", 1)
+
+	// 开启较早的事务与清空并发: 两者之一写冲突, 行不会写进已经废弃的存储名;
+	other.Execute("begin;")
+	other.Execute("insert into tr1 (name, v) values ('d', 4);")
+	expectError(t, session, "truncate table tr1;", "WriteConflict")
+	other.Execute("commit;")
+	expectRows(t, session, "select * from tr1;", 2)
+	other.Execute("begin;")
+	expectRows(t, other, "select * from tr1;", 2)
+	expectOk(t, session, "truncate table tr1;")
+	expectError(t, other, "insert into tr1 (name, v) values ('e', 5);", "WriteConflict")
+	expectError(t, other, "delete from tr1 where v = 4;", "WriteConflict")
+	other.Execute("rollback;")
+	expectRows(t, session, "select * from tr1;", 0)
+	expectOk(t, session, "insert into tr1 (name, v) values ('a', 2);")
+
+	session.Execute("create table tr2 (id int primary key, pid int references tr1);")
+	expectError(t, session, "truncate table tr2x;", "not exists")
+	expectError(t, session, "truncate table tr1;", "depends on it")
+	expectOk(t, session, "drop table tr2;")
+
+	// 删除表同样只登记旧数据, 重建的同名表是空表;
+	expectOk(t, session, "drop table tr1;")
+	expectCollected("tr1")
+	expectOk(t, session, "create table tr1 (id int primary key, v int index);")
+	expectRows(t, session, "select * from tr1;", 0)
+	expectRows(t, session, "select * from tr1 where v = 2;", 0)
+}
+
+func testView(t *testing.T, session *Session) {
+	session.Execute("create table vw1 (id int primary key, name text, dept int);")
+	session.Execute("create table vw2 (did int primary key, title text);")
+	session.Execute("insert into vw1 values (1, 'a', 10), (2, 'b', 20), (3, 'c', 10);")
+	session.Execute("insert into vw2 values (10, 'dev'), (20, 'ops');")
+
+	// 视图中的查询在使用时展开, 外层的条件与排序作用在视图的结果上;
+	expectOk(t, session, "create view vv1 as select id, name from vw1 where dept = 10;")
+	expectRows(t, session, "select * from vv1;", 2)
+	expectRows(t, session, "select name from vv1 where id > 1;", 1)
+	expectOk(t, session, "insert into vw1 values (4, 'd', 10);")
+	expectRows(t, session, "select * from vv1;", 3)
+	expectRows(t, session, "select * from vv1 order by id desc limit 2;", 2)
+
+	// 视图可以包含连接与聚合, 也可以引用其他视图;
+	expectOk(t, session, "create view vv2 as select * from vw1 join vw2 on dept = did;")
+	expectRows(t, session, "select name, title from vv2 where title = 'ops';", 1)
+	expectOk(t, session, "create view vv3 as select dept, count(id) from vw1 group by dept;")
+	expectRows(t, session, "select * from vv3;", 2)
+	expectOk(t, session, "create view vv4 as select id from vv1;")
+	expectRows(t, session, "select * from vv4 where id = 3;", 1)
+
+	// 名字与表共用, 同名视图只能用 OR REPLACE 覆盖, 且不能引用自己;
+	expectError(t, session, "create view vv1 as select * from vw1;", "already exists")
+	expectError(t, session, "create view vw1 as select * from vw2;", "already exists")
+	expectError(t, session, "create table vv1 (id int primary key);", "already exists")
+	expectError(t, session, "create view vv5 as select * from vw0;", "not exists")
+	expectError(t, session, "create or replace view vv1 as select * from vv4;", "reference itself")
+	expectOk(t, session, "create or replace view vv1 as select id, name from vw1 where dept = 20;")
+	expectRows(t, session, "select * from vv4;", 1)
+
+	// SHOW TABLES 中视图带有标记;
+	names := session.Execute("show tables;").ToString()
+	if !strings.Contains(names, "vv1(view)") || strings.Contains(names, "vw1(view)") {
+		t.Errorf("show tables expect view marker, got: %s", names)
+	}
+
+	// 被视图引用的表不能删除或改名, CASCADE 连同依赖的视图一起删除;
+	expectError(t, session, "drop table vw2;", "depends on it")
+	expectError(t, session, "alter table vw1 rename to vw9;", "depends on it")
+	expectError(t, session, "alter table vw1 drop column name;", "depends on it")
+	expectError(t, session, "alter table vw1 rename column name to title;", "depends on it")
+	expectOk(t, session, "select * from vv1;")
+	expectError(t, session, "drop view vv1;", "depends on it")
+	expectOk(t, session, "drop view vv4;")
+	expectOk(t, session, "drop view vv1;")
+	expectError(t, session, "drop view vv1;", "not exists")
+	expectOk(t, session, "drop table vw2 cascade;")
+	expectError(t, session, "select * from vv2;", "not exists")
+	expectOk(t, session, "drop table vw1 cascade;")
+	if names = session.Execute("show tables;").ToString(); strings.Contains(names, "(view)") {
+		t.Errorf("show tables expect no views, got: %s", names)
+	}
+}
+
+func testMaterializedView(t *testing.T, session *Session) {
+	session.Execute("create table mv1 (id int primary key, dept int, amount int);")
+	session.Execute("insert into mv1 values (1, 10, 5), (2, 20, 7), (3, 10, 1);")
+
+	// 创建时计算一次结果, 之后基表的修改在 REFRESH 之前不可见;
+	expectOk(t, session, "create materialized view mvv1 as select dept, count(id), sum(amount) from mv1 group by dept;")
+	expectRows(t, session, "select * from mvv1;", 2)
+	expectRows(t, session, "select dept from mvv1 where sum_amount = 6;", 1)
+	expectOk(t, session, "insert into mv1 values (4, 30, 2);")
+	expectRows(t, session, "select * from mvv1;", 2)
+	expectOk(t, session, "refresh materialized view mvv1;")
+	expectRows(t, session, "select * from mvv1;", 3)
+
+	// 刷新在事务中进行, 提交之前其他会话仍读到旧的结果; 已开启的事务提交后仍读到自己的快照;
+	other := session.Server.Session()
+	reader := session.Server.Session()
+	reader.Execute("begin;")
+	expectRows(t, reader, "select * from mvv1;", 3)
+	expectOk(t, session, "insert into mv1 values (5, 40, 3);")
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "refresh materialized view mvv1;")
+	expectRows(t, session, "select * from mvv1;", 4)
+	expectRows(t, other, "select * from mvv1;", 3)
+	expectOk(t, session, "commit;")
+	expectRows(t, other, "select * from mvv1;", 4)
+	expectRows(t, reader, "select * from mvv1;", 3)
+	reader.Execute("commit;")
+
+	// 隐藏表不出现在 SHOW TABLES 中, 旧结果在没有更早的事务之后被清理;
+	names := session.Execute("show tables;").ToString()
+	if !strings.Contains(names, "mvv1(materialized view)") || strings.Contains(names, "#") {
+		t.Errorf("show tables expect materialized view marker, got: %s", names)
+	}
+	session.Server.CollectGarbage()
+	service := session.Server.Begin().(*KVService)
+	if garbage := service.GarbageKeySpaces(); len(garbage) != 0 {
+		t.Errorf("expect garbage collected, got: %v", garbage)
+	}
+	service.Commit()
+
+	// 隐藏表的列类型与排序规则由查询推断, 与结果中的值无关: 全为 NULL 的列仍是整数, nocase 的列仍不区分大小写;
+	session.Execute("create table mv2 (id int primary key, name text collate nocase, score decimal(5, 2), note int);")
+	session.Execute("insert into mv2 values (1, 'Alice', null, null), (2, 'bob', 1.5, null);")
+	expectOk(t, session, "create materialized view mvv3 as select id, name, score, note, upper(name) as up, id * 2 as twice, now() as at from mv2;")
+	expectColumns := func() {
+		service := session.Server.Begin().(*KVService)
+		defer service.Commit()
+		table, err := service.MustGetTable(types.MaterializedTableName("mvv3"))
+		if err != nil {
+			t.Errorf("expect hidden table of mvv3, got: %s", err)
+			return
+		}
+		expected := []struct {
+			name      string
+			dataType  types.DataType
+			collation string
+		}{
+			{"name", types.String, "nocase"}, {"score", types.Decimal, ""}, {"note", types.Integer, ""},
+			{"up", types.String, "nocase"}, {"twice", types.Integer, ""}, {"at", types.Timestamp, ""},
+		}
+		for _, e := range expected {
+			column := table.Columns[table.GetColumnIndex(e.name)]
+			if column.DataType != e.dataType || column.Collation != e.collation {
+				t.Errorf("mvv3.%s expect %s %s, got: %s %s", e.name, types.GetDataTypeInfo(e.dataType), e.collation,
+					types.GetDataTypeInfo(column.DataType), column.Collation)
+			}
+		}
+	}
+	expectColumns()
+	expectRows(t, session, "select id from mvv3 where name = 'ALICE';", 1)
+	expectRows(t, session, "select id from mvv3 where up = 'Bob';", 1)
+	expectOk(t, session, "update mv2 set note = 3 where id = 1;")
+	expectOk(t, session, "refresh materialized view mvv3;")
+	expectColumns()
+	expectRows(t, session, "select id from mvv3 where note = 3;", 1)
+	expectOk(t, session, "drop materialized view mvv3;")
+
+	expectError(t, session, "refresh materialized view mv1;", "not exists")
+	expectError(t, session, "create or replace view mvv1 as select * from mv1;", "materialized view")
+	expectError(t, session, "create or replace materialized view mvv2 as select * from mv1;", "OR REPLACE")
+	expectError(t, session, "create materialized view mvv2 as select * from mv1 join mv1 on id = id;", "duplicated")
+	expectError(t, session, "drop table mv1;", "depends on it")
+	expectOk(t, session, "drop table mv1 cascade;")
+	expectError(t, session, "select * from mvv1;", "not exists")
+	service = session.Server.Begin().(*KVService)
+	if table, _ := service.GetTable(types.MaterializedTableName("mvv1")); table != nil {
+		t.Errorf("expect hidden table dropped with the view")
+	}
+	service.Commit()
+}
+
+func testTemporal(t *testing.T, session *Session) {
+	// 日期作为主键, 时间戳建立索引;
+	expectOk(t, session, "create table tm1 (day date primary key, opened timestamp index, duration interval, alarm time);")
+	expectOk(t, session, `insert into tm1 values
+		(date '2024-01-31', timestamp '2024-01-31 08:30:00', interval '1 hour 30 minutes', time '07:00'),
+		(date '2024-02-29', timestamp '2024-02-29 09:15:30.5', interval '2 days', time '06:45:10'),
+		(date '2023-12-25', timestamp '2023-12-25 23:59:59', interval '1 year 2 months', time '23:30');`)
+	expectError(t, session, "insert into tm1 values (date '2024-02-30', null, null, null);", "date")
+	// 字符串按日期的格式隐式转换, 格式不对时报错;
+	expectError(t, session, "insert into tm1 values ('2024-03-32', null, null, null);", "date")
+
+	// 主键与索引按时间顺序扫描;
+	expectRows(t, session, "select * from tm1 where day >= date '2024-01-01';", 2)
+	expectRows(t, session, "select * from tm1 where opened < timestamp '2024-02-01';", 2)
+	expectValue(t, session, "select day from tm1 where opened = timestamp '2024-02-29T09:15:30.500';", "2024-02-29")
+	// DATE 与 TIMESTAMP 可以比较, DATE 视为当天零点;
+	expectRows(t, session, "select * from tm1 where opened >= date '2024-01-31';", 2)
+	expectRows(t, session, "select * from tm1 where opened < date '2024-02-01';", 2)
+	resultSet := session.Execute("explain select * from tm1 where opened < date '2024-02-01';")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("expect index scan on tm1.opened, got: %s", resultSet.ToString())
+	}
+
+	// 与 INTERVAL 的运算: 加一个月超出月末时取月末;
+	expectValue(t, session, "select day + interval '1 month' from tm1 where day = date '2024-01-31';", "2024-02-29 00:00:00")
+	expectValue(t, session, "select opened - interval '1 day 30 minutes' as t from tm1 where day = date '2024-02-29';", "2024-02-28 08:45:30.5")
+	expectValue(t, session, "select day - 1 from tm1 where day = date '2024-01-31';", "2024-01-30")
+	expectValue(t, session, "select date '2024-03-01' - day from tm1 where day = date '2024-02-29';", "1")
+	expectValue(t, session, "select opened - timestamp '2024-01-30 06:00:00' from tm1 where day = date '2024-01-31';", "1 day 02:30:00")
+	expectValue(t, session, "select duration * 2 from tm1 where day = date '2024-01-31';", "03:00:00")
+	expectValue(t, session, "select alarm + interval '2 hours' from tm1 where day = date '2023-12-25';", "01:30:00")
+	expectRows(t, session, "select * from tm1 where opened + duration > timestamp '2024-03-01';", 2)
+	expectError(t, session, "select day + opened from tm1;", "does not support")
+
+	// DATE_TRUNC 与 EXTRACT;
+	expectValue(t, session, "select date_trunc('month', opened) from tm1 where day = date '2024-02-29';", "2024-02-01 00:00:00")
+	expectValue(t, session, "select extract(year from day) from tm1 where day = date '2023-12-25';", "2023")
+	expectValue(t, session, "select extract(second from opened) from tm1 where day = date '2024-02-29';", "30.5")
+	expectValue(t, session, "select day from tm1 where extract(dow from day) = 1;", "2023-12-25")
+	expectValue(t, session, "select extract(month from duration) from tm1 where day = date '2023-12-25';", "2")
+	expectRows(t, session, "select * from tm1 where date_trunc('year', day) = date '2024-01-01';", 2)
+
+	// NOW() 在执行时取当前时间;
+	expectOk(t, session, "insert into tm1 values (date '2030-01-01', now(), null, null);")
+	expectRows(t, session, "select * from tm1 where opened <= now();", 4)
+	expectRows(t, session, "select * from tm1 where opened > now() - interval '1 hour';", 1)
+	// NOW() 取事务开始的时间: 同一语句的每一行、同一事务的每条语句都相同;
+	if scan := expectRows(t, session, "select now() from tm1;", 4); scan != nil {
+		for _, row := range scan.Rows {
+			if types.FormatValue(row[0]) != types.FormatValue(scan.Rows[0][0]) {
+				t.Errorf("expect the same now() on every row, got: %s", scan.ToString())
+			}
+		}
+	}
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "insert into tm1 values (date '2030-01-02', now(), null, null);")
+	time.Sleep(2 * time.Millisecond)
+	expectRows(t, session, "select * from tm1 where opened = now();", 1)
+	expectOk(t, session, "commit;")
+	expectError(t, session, "create table tm2 (id int primary key, created timestamp default now());", "constant")
+
+	// 更新时可以引用原来的值;
+	expectOk(t, session, "update tm1 set day = day + 365, duration = duration + interval '1 day' where day = date '2023-12-25';")
+	expectValue(t, session, "select duration from tm1 where day = date '2024-12-24';", "1 year 2 mons 1 day")
+
+	// 按月分组: 通过视图先计算分组列;
+	expectOk(t, session, "create view tm1_month as select date_trunc('month', day) as month, day from tm1;")
+	expectRows(t, session, "select month, count(day) from tm1_month group by month;", 4)
+	expectOk(t, session, "drop view tm1_month;")
+}
+
+func testDecimal(t *testing.T, session *Session) {
+	expectOk(t, session, "create table dm1 (id int primary key, price decimal(8, 2) index, rate numeric(5, 4) default 0.05, note decimal);")
+	// 写入时按列的小数位数四舍五入, 整数与浮点数常量按十进制转换;
+	expectOk(t, session, "insert into dm1 values (1, 0.1, 0.12345, decimal '123456789.123456789'), (2, 0.2, 1, 3), (3, 19.995, 0.5, null);")
+	expectOk(t, session, "insert into dm1 (id, price, note) values (4, 100, null);")
+	expectValue(t, session, "select price from dm1 where id = 3;", "20.00")
+	expectValue(t, session, "select rate from dm1 where id = 1;", "0.1235")
+	expectValue(t, session, "select rate from dm1 where id = 4;", "0.0500")
+	expectValue(t, session, "select note from dm1 where id = 1;", "123456789.123456789")
+	// 整数部分超过 p - s 位时报错, 一行都不写入;
+	expectError(t, session, "insert into dm1 values (5, 1000000, 0, null);", "numeric field overflow")
+	expectError(t, session, "insert into dm1 values (5, 999999.995, 0, null);", "numeric field overflow")
+	expectRows(t, session, "select * from dm1 where id >= 5;", 0)
+	expectError(t, session, "update dm1 set rate = rate * 10 where id = 2;", "numeric field overflow")
+
+	// 精确运算;
+	expectValue(t, session, "select price + price * 2 from dm1 where id = 1;", "0.30")
+	expectValue(t, session, "select price * rate from dm1 where id = 1;", "0.012350")
+	expectValue(t, session, "select price + 0.2 from dm1 where id = 1;", "0.30")
+	expectRows(t, session, "select * from dm1 where price + 0.2 = 0.3;", 1)
+	expectValue(t, session, "select price / 3 from dm1 where id = 3;", "6.666667")
+	expectValue(t, session, "select decimal '0.1' + decimal '0.2' from dm1 where id = 1;", "0.3")
+	expectError(t, session, "select price / 0 from dm1;", "division by zero")
+
+	// 与整数、浮点数比较, 索引按数值顺序扫描;
+	expectRows(t, session, "select * from dm1 where price = 0.1;", 1)
+	expectRows(t, session, "select * from dm1 where price >= 0.2;", 3)
+	expectRows(t, session, "select * from dm1 where price < 20;", 2)
+	resultSet := session.Execute("explain select * from dm1 where price >= 0.2;")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("expect index scan on dm1.price, got: %s", resultSet.ToString())
+	}
+	expectOk(t, session, "update dm1 set price = price + 0.005 where id = 1;")
+	expectValue(t, session, "select price from dm1 where id = 1;", "0.11")
+
+	// SUM/AVG 精确计算;
+	expectValue(t, session, "select sum(price) from dm1;", "120.31")
+	expectValue(t, session, "select avg(price) from dm1;", "30.077500")
+	expectValue(t, session, "select max(price) from dm1;", "100.00")
+
+	// DECIMAL 主键: 0.5 与 0.50 是同一个值;
+	expectOk(t, session, "create table dm2 (k decimal(4, 2) primary key, v int);")
+	expectOk(t, session, "insert into dm2 values (0.5, 1), (1.25, 2);")
+	expectError(t, session, "insert into dm2 values (decimal '0.50', 3);", "already exists")
+	expectValue(t, session, "select v from dm2 where k = 1.25;", "2")
+	expectError(t, session, "create table dm3 (id int primary key, v decimal(2, 3));", "DECIMAL")
+}
+
+func testBlob(t *testing.T, session *Session) {
+	expectOk(t, session, "create table bl1 (id int primary key, hash blob index, data bytea);")
+	expectOk(t, session, "insert into bl1 values (1, X'DEADBEEF', x'00ff00'), (2, X'00', blob 'a\\x00b\\n'), (3, X'', null);")
+	// 查询结果按 \x 加十六进制显示;
+	expectValue(t, session, "select hash from bl1 where id = 1;", `\xdeadbeef`)
+	expectValue(t, session, "select data from bl1 where id = 2;", `\x6100620a`)
+	resultSet := session.Execute("select * from bl1 where id = 1;")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), `\x00ff00`) {
+		t.Errorf("expect hex display, got: %s", resultSet.ToString())
+	}
+
+	// 按字节序比较, 索引可以范围扫描;
+	expectRows(t, session, "select * from bl1 where hash = X'deadbeef';", 1)
+	expectRows(t, session, "select * from bl1 where hash < X'DE';", 2)
+	expectRows(t, session, "select * from bl1 where hash >= X'00';", 2)
+	resultSet = session.Execute("explain select * from bl1 where hash < X'DE';")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("expect index scan on bl1.hash, got: %s", resultSet.ToString())
+	}
+	expectOk(t, session, "update bl1 set data = X'0102' where id = 3;")
+	expectValue(t, session, "select data from bl1 where id = 3;", `\x0102`)
+
+	// length 与 substring;
+	expectValue(t, session, "select length(data) from bl1 where id = 2;", "4")
+	expectValue(t, session, "select length(hash) from bl1 where id = 3;", "0")
+	expectValue(t, session, "select substring(hash, 2, 2) from bl1 where id = 1;", `\xadbe`)
+	expectValue(t, session, "select substring(hash, 3) from bl1 where id = 1;", `\xbeef`)
+	expectValue(t, session, "select length('héllo') from bl1 where id = 1;", "5")
+	expectValue(t, session, "select substring('héllo', 0, 3) from bl1 where id = 1;", "hé")
+	expectValue(t, session, "select 'it\\'s' from bl1 where id = 1;", "it's")
+	expectError(t, session, "select substring(hash, 1, 0 - 1) from bl1;", "negative substring length")
+
+	// 二进制主键: 含 0x00 的值可以正确区分;
+	expectOk(t, session, "create table bl2 (k bytea primary key, v int);")
+	expectOk(t, session, "insert into bl2 values (X'00', 1), (X'0000', 2), (X'0001', 3);")
+	expectError(t, session, "insert into bl2 values (X'0000', 4);", "already exists")
+	expectValue(t, session, "select v from bl2 where k = X'0000';", "2")
+	expectRows(t, session, "select * from bl2 where k > X'00';", 2)
+	expectError(t, session, "select * from bl2 where k = X'ABC';", "odd number of digits")
+}
+
+func testJson(t *testing.T, session *Session) {
+	expectOk(t, session, "create table js1 (id int primary key, attrs json);")
+	expectOk(t, session, `insert into js1 values (1, '{"size": 12, "color": "red", "tags": ["a", "b"]}'), (2, '{"color":"blue","size":8}'), (3, '{"size": 1.5e1, "dims": {"w": 3}}'), (4, null);`)
+	// 写入时校验, 非法的文本报错;
+	expectError(t, session, "insert into js1 values (5, '{\"size\": }');", "invalid input syntax for type json")
+	// 规范化: 键按字典序排列, 去掉多余的空白;
+	expectValue(t, session, "select attrs from js1 where id = 2;", `{"color":"blue","size":8}`)
+
+	// -> 取出子文档, ->> 取出文本;
+	expectValue(t, session, "select attrs -> 'color' from js1 where id = 1;", `"red"`)
+	expectValue(t, session, "select attrs ->> 'color' from js1 where id = 1;", "red")
+	expectValue(t, session, "select attrs -> 'tags' -> 1 from js1 where id = 1;", `"b"`)
+	expectValue(t, session, "select json_extract(attrs, '$.dims.w') from js1 where id = 3;", "3")
+	expectValue(t, session, "select attrs ->> '$.tags[0]' from js1 where id = 1;", "a")
+	expectRows(t, session, "select * from js1 where attrs -> 'missing' = 1;", 0)
+	expectValue(t, session, "select json_array_length(attrs, 'tags') from js1 where id = 1;", "2")
+	expectValue(t, session, "select json_array_length(attrs -> 'tags') from js1 where id = 1;", "2")
+	expectValue(t, session, "select json_keys(attrs) from js1 where id = 3;", `["dims","size"]`)
+	expectError(t, session, "select json_array_length(attrs) from js1 where id = 1;", "non-array")
+
+	// WHERE 与 ORDER BY 中使用路径;
+	expectRows(t, session, "select * from js1 where attrs ->> 'color' = 'red';", 1)
+	expectRows(t, session, "select * from js1 where attrs -> 'size' > 10;", 2)
+	expectRows(t, session, "select * from js1 where attrs -> 'color' = 'blue';", 1)
+	expectValue(t, session, "select id from js1 where attrs -> 'size' > 0 order by attrs -> 'size' limit 1;", "2")
+	expectValue(t, session, "select id from js1 where attrs -> 'size' > 0 order by attrs -> 'size' desc limit 1;", "3")
+
+	// 表达式索引: 条件中的表达式与索引表达式相同时走索引;
+	expectOk(t, session, "create index js1_color on js1 ((attrs ->> 'color'));")
+	resultSet := session.Execute("explain select * from js1 where attrs ->> 'color' = 'red';")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("expect index scan on js1_color, got: %s", resultSet.ToString())
+	}
+	expectRows(t, session, "select * from js1 where attrs ->> 'color' = 'red';", 1)
+	expectOk(t, session, `insert into js1 values (5, '{"color": "red"}');`)
+	expectRows(t, session, "select * from js1 where attrs ->> 'color' = 'red';", 2)
+	expectOk(t, session, `update js1 set attrs = '{"color": "green"}' where id = 5;`)
+	expectRows(t, session, "select * from js1 where attrs ->> 'color' = 'red';", 1)
+	expectRows(t, session, "select * from js1 where attrs ->> 'color' = 'green';", 1)
+	expectOk(t, session, "delete from js1 where id = 5;")
+	expectRows(t, session, "select * from js1 where attrs ->> 'color' = 'green';", 0)
+
+	// JSON 值上的索引只用于等值匹配;
+	expectOk(t, session, "create index js1_size on js1 ((attrs -> 'size'));")
+	expectRows(t, session, "select * from js1 where attrs -> 'size' = 15;", 1)
+	resultSet = session.Execute("explain select * from js1 where attrs -> 'size' = 15;")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("expect index scan on js1_size, got: %s", resultSet.ToString())
+	}
+	expectRows(t, session, "select * from js1 where attrs -> 'size' >= 8;", 3)
+
+	// 唯一表达式索引;
+	expectOk(t, session, "create table js2 (id int primary key, doc json);")
+	expectOk(t, session, `insert into js2 values (1, '{"email": "a@x.com"}'), (2, '{"email": "b@x.com"}');`)
+	expectOk(t, session, "create unique index js2_email on js2 ((doc ->> 'email'));")
+	expectError(t, session, `insert into js2 values (3, '{"email": "a@x.com"}');`, "duplicate key value")
+	expectOk(t, session, `insert into js2 values (3, '{"email": "a@x.com"}') on conflict do nothing;`)
+	expectRows(t, session, "select * from js2;", 2)
+	// 重命名列时索引表达式随之改变, 删除列时一并删除索引;
+	expectOk(t, session, "alter table js2 rename column doc to body;")
+	expectRows(t, session, "select * from js2 where body ->> 'email' = 'b@x.com';", 1)
+	expectError(t, session, `insert into js2 values (3, '{"email": "b@x.com"}');`, "duplicate key value")
+	expectOk(t, session, "alter table js2 drop column body;")
+	expectOk(t, session, "insert into js2 values (3);")
+}
+
+func testCollation(t *testing.T, session *Session) {
+	expectOk(t, session, "create table vc1 (id int primary key, code char(3), name varchar(5) collate nocase index, note text);")
+	resultSet := session.Execute("show table vc1;")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "name String(5) COLLATE nocase") {
+		t.Errorf("expect column length and collation, got: %s", resultSet.ToString())
+	}
+	// 长度按字符计算, 超出时报错;
+	expectOk(t, session, "insert into vc1 values (1, 'abc', 'Alice', 'x'), (2, 'déf', 'alice', 'y'), (3, 'g', 'Bob', 'z');")
+	expectError(t, session, "insert into vc1 values (4, 'abc', 'Alicia', 'x');", "value too long for type String(5)")
+	expectError(t, session, "insert into vc1 values (4, 'abcd', 'Al', 'x');", "value too long for type String(3)")
+	expectError(t, session, "update vc1 set name = 'Roberto' where id = 3;", "value too long")
+	expectOk(t, session, "update vc1 set name = 'BOB' where id = 3;")
+	expectValue(t, session, "select name from vc1 where id = 3;", "BOB")
+
+	// nocase 列比较、排序、分组都不区分大小写, 索引按折叠后的值编码;
+	expectRows(t, session, "select * from vc1 where name = 'ALICE';", 2)
+	resultSet = session.Execute("explain select * from vc1 where name = 'ALICE';")
+	fmt.Println(resultSet.ToString())
+	if !strings.Contains(resultSet.ToString(), "Index Scan") {
+		t.Errorf("expect index scan on vc1.name, got: %s", resultSet.ToString())
+	}
+	expectRows(t, session, "select * from vc1 where name > 'AZ';", 1)
+	expectRows(t, session, "select name, count(id) from vc1 group by name;", 2)
+	expectValue(t, session, "select id from vc1 order by name desc limit 1;", "3")
+	// 普通列仍区分大小写;
+	expectRows(t, session, "select * from vc1 where note = 'X';", 0)
+
+	// 不区分大小写的主键: 大小写不同的值视为重复, 保留写入时的大小写;
+	expectOk(t, session, "create table vc2 (k varchar(10) collate NOCASE primary key, v int);")
+	expectOk(t, session, "insert into vc2 values ('Key', 1);")
+	expectError(t, session, "insert into vc2 values ('KEY', 2);", "already exists")
+	expectValue(t, session, "select v from vc2 where k = 'key';", "1")
+	expectValue(t, session, "select k from vc2 where k = 'kEY';", "Key")
+
+	expectError(t, session, "create table vc3 (id int primary key collate nocase);", "only apply to string columns")
+	expectError(t, session, "create table vc3 (id int primary key, name text collate french);", "not supported")
+	expectError(t, session, "create table vc3 (id int primary key, name varchar(0));", "at least 1")
+	expectError(t, session, "create table vc3 (id int primary key, name varchar(2) default 'abc');", "value too long")
+}
+
+func testCoercion(t *testing.T, session *Session) {
+	// 写入时整数提升为浮点数与定点数, 字符串按列的类型解析;
+	expectOk(t, session, "create table cv1 (id int primary key, f float default null, amount decimal(6, 2) default 0, d date default null, ts timestamp default null, ok bool default null);")
+	expectOk(t, session, "insert into cv1(id, f) values (1, 1);")
+	expectOk(t, session, "insert into cv1 values (2, 2.5, 3, '2024-01-31', '2024-01-31 08:30:00', true);")
+	expectOk(t, session, "insert into cv1 values (3, 3, 1.25, date '2024-02-29', date '2024-02-29', false);")
+	expectValue(t, session, "select f from cv1 where id = 1;", "1")
+	expectValue(t, session, "select ts from cv1 where id = 3;", "2024-02-29 00:00:00")
+	expectError(t, session, "insert into cv1(id, d) values (4, '2024-02-30');", "date")
+	expectError(t, session, "insert into cv1(id, f) values (4, 'abc');", "column f expects Float, got String")
+	// UPDATE 与插入相同: 先隐式转换, 类型不符时报错;
+	expectError(t, session, "update cv1 set f = 'abc' where id = 1;", "column f expects Float, got String")
+	expectError(t, session, "update cv1 set id = 'abc' where id = 1;", "column id expects Integer, got String")
+	expectRows(t, session, "select * from cv1 where id = 1;", 1)
+	expectOk(t, session, "update cv1 set f = 2, d = '2024-03-01' where id = 1;")
+	expectValue(t, session, "select f from cv1 where id = 1;", "2")
+	expectValue(t, session, "select d from cv1 where id = 1;", "2024-03-01")
+	expectOk(t, session, "update cv1 set f = 1, d = null where id = 1;")
+
+	// 比较时字符串常量按列的类型转换, 数值按公共类型比较;
+	expectRows(t, session, "select * from cv1 where d = '2024-01-31';", 1)
+	expectRows(t, session, "select * from cv1 where ts >= '2024-02-01';", 1)
+	expectRows(t, session, "select * from cv1 where f > 2;", 2)
+	expectRows(t, session, "select * from cv1 where amount = 3;", 1)
+	expectError(t, session, "select * from cv1 where d = 'yesterday';", "date")
+	expectValue(t, session, "select f + id from cv1 where id = 2;", "4.5")
+	expectValue(t, session, "select amount + id from cv1 where id = 3;", "4.25")
+
+	// 显式转换: 会丢失信息时报错而不是截断;
+	expectValue(t, session, "select cast('12' as int) from cv1 where id = 1;", "12")
+	expectValue(t, session, "select cast(2.0 as integer) from cv1 where id = 1;", "2")
+	expectValue(t, session, "select cast(id as varchar(3)) from cv1 where id = 1;", "1")
+	expectValue(t, session, "select cast(amount as decimal(5, 1)) from cv1 where id = 2;", "3.0")
+	expectValue(t, session, "select cast(f as int) from cv1 where id = 3;", "3")
+	expectValue(t, session, "select cast('2024-01-31' as date) + 1 from cv1 where id = 1;", "2024-02-01")
+	expectError(t, session, "select cast(1.5 as integer) from cv1;", "without losing information")
+	expectError(t, session, "select cast('abc' as int) from cv1;", "invalid input syntax for type integer")
+	expectError(t, session, "select cast(amount as decimal(5, 1)) from cv1 where id = 3;", "without losing information")
+	expectError(t, session, "select cast(f as int) from cv1 where id = 2;", "without losing information")
+	expectError(t, session, "select cast(12345 as varchar(3)) from cv1;", "value too long")
+	expectError(t, session, "select cast(d as int) from cv1 where id = 2;", "cannot cast type Date to Integer")
+
+	// CHECK 约束中的字符串同样按列的类型转换;
+	expectOk(t, session, "create table cv2 (id int primary key, d date check (d >= '2024-01-01'));")
+	expectOk(t, session, "insert into cv2 values (1, '2024-06-01');")
+	expectError(t, session, "insert into cv2 values (2, '2023-06-01');", "check")
+}
+
+func testStatementAtomicity(t *testing.T, session *Session) {
+	expectOk(t, session, "create table sa1 (id int primary key, v int check (v >= 0));")
+	// 自动提交的语句失败时整体回滚, 不会留下前面已经写入的行;
+	expectError(t, session, "insert into sa1 values (1, 1), (2, 2), (1, 3);", "already exists")
+	expectRows(t, session, "select * from sa1;", 0)
+	expectOk(t, session, "insert into sa1 values (1, 1), (2, 2);")
+	expectError(t, session, "update sa1 set v = v - 2;", "check")
+	expectValue(t, session, "select v from sa1 where id = 2;", "2")
+
+	// 显式事务中失败的语句只撤销自己的写入, 事务继续;
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "insert into sa1 values (3, 3);")
+	expectOk(t, session, "update sa1 set v = 10 where id = 1;")
+	expectError(t, session, "insert into sa1 values (4, 4), (3, 3);", "already exists")
+	expectError(t, session, "update sa1 set v = v - 3;", "check")
+	expectOk(t, session, "show table sa1;")
+	expectOk(t, session, "insert into sa1 values (5, 5);")
+	expectOk(t, session, "commit;")
+	expectRows(t, session, "select * from sa1;", 4)
+	expectValue(t, session, "select v from sa1 where id = 1;", "10")
+	expectValue(t, session, "select v from sa1 where id = 3;", "3")
+	expectRows(t, session, "select * from sa1 where id = 4;", 0)
+
+	// 回滚整个事务; SHOW 不会提前结束事务;
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "delete from sa1 where id = 5;")
+	expectOk(t, session, "show tables;")
+	expectOk(t, session, "rollback;")
+	expectRows(t, session, "select * from sa1 where id = 5;", 1)
+
+	// 没有事务时 COMMIT / ROLLBACK 返回警告;
+	for _, sql := range []string{"commit;", "rollback;"} {
+		resultSet := session.Execute(sql)
+		fmt.Println(resultSet.ToString())
+		if _, ok := resultSet.(*types.WarningResult); !ok || !strings.Contains(resultSet.ToString(), "no transaction in progress") {
+			t.Errorf("%s expect warning, got: %s", sql, resultSet.ToString())
+		}
+	}
+}
+
+func testSavepoint(t *testing.T, session *Session) {
+	expectOk(t, session, "create table sp1 (id int primary key, v int);")
+	expectError(t, session, "savepoint a;", "transaction blocks")
+	expectError(t, session, "rollback to savepoint a;", "transaction blocks")
+
+	expectOk(t, session, "begin;")
+	expectOk(t, session, "insert into sp1 values (1, 1);")
+	expectOk(t, session, "savepoint a;")
+	expectOk(t, session, "insert into sp1 values (2, 2);")
+	expectOk(t, session, "update sp1 set v = 10 where id = 1;")
+	expectOk(t, session, "savepoint b;")
+	expectOk(t, session, "delete from sp1 where id = 2;")
+	expectRows(t, session, "select * from sp1;", 1)
+	// 回滚到 b: 删除撤销, b 之前的写入保留;
+	expectOk(t, session, "rollback to savepoint b;")
+	expectRows(t, session, "select * from sp1;", 2)
+	expectValue(t, session, "select v from sp1 where id = 1;", "10")
+	// 回滚到 a 之后 b 不再存在, a 可以再次使用;
+	expectOk(t, session, "rollback to a;")
+	expectRows(t, session, "select * from sp1;", 1)
+	expectValue(t, session, "select v from sp1 where id = 1;", "1")
+	expectError(t, session, "rollback to savepoint b;", "does not exist")
+	expectOk(t, session, "insert into sp1 values (3, 3);")
+	expectOk(t, session, "release savepoint a;")
+	expectError(t, session, "release a;", "does not exist")
+	// 失败的语句不影响已有的保存点;
+	expectOk(t, session, "savepoint c;")
+	expectOk(t, session, "insert into sp1 values (4, 4);")
+	expectError(t, session, "insert into sp1 values (5, 5), (1, 1);", "already exists")
+	expectOk(t, session, "rollback to c;")
+	expectOk(t, session, "commit;")
+	expectRows(t, session, "select * from sp1;", 2)
+	expectRows(t, session, "select * from sp1 where id = 3;", 1)
+}
+
+func testSerializable(t *testing.T, session *Session) {
+	other := session.Server.Session()
+	expectOk(t, session, "create table sr1 (id int primary key, room int, day int);")
+	// 检查没有预订再写入: 快照隔离下两个并发事务都能提交, 出现重复预订;
+	book := func(level string) (types.ResultSet, types.ResultSet) {
+		expectOk(t, session, "begin"+level+";")
+		other.Execute("begin" + level + ";")
+		expectRows(t, session, "select * from sr1 where room = 1 and day = 5;", 0)
+		expectRows(t, other, "select * from sr1 where room = 1 and day = 5;", 0)
+		expectOk(t, session, "insert into sr1 values (1, 1, 5);")
+		expectOk(t, other, "insert into sr1 values (2, 1, 5);")
+		return session.Execute("commit;"), other.Execute("commit;")
+	}
+	first, second := book(" isolation level repeatable read")
+	if failed(first) || failed(second) {
+		t.Errorf("expect both commits under snapshot isolation, got: %s, %s", first.ToString(), second.ToString())
+	}
+	expectRows(t, session, "select * from sr1 where room = 1 and day = 5;", 2)
+	expectOk(t, session, "delete from sr1;")
+
+	// 可串行化: 先提交的事务读到的数据被对方改写, 对方读到的数据也被它改写, 提交失败;
+	first, second = book(" isolation level serializable")
+	if !strings.Contains(first.ToString(), "could not serialize access") || failed(second) {
+		t.Errorf("expect a serialization failure, got: %s, %s", first.ToString(), second.ToString())
+	}
+	expectRows(t, session, "select * from sr1 where room = 1 and day = 5;", 1)
+	expectValue(t, session, "select id from sr1 where room = 1 and day = 5;", "2")
+	expectOk(t, session, "delete from sr1;")
+
+	// 会话默认的隔离级别同样适用于没有指定隔离级别的 BEGIN;
+	expectOk(t, session, "set session characteristics as transaction isolation level serializable;")
+	expectOk(t, other, "set session characteristics as transaction isolation level serializable;")
+	first, second = book("")
+	expectOk(t, session, "set session characteristics as transaction isolation level repeatable read;")
+	if session.Isolation != storage.SnapshotIsolation || other.Isolation != storage.Serializable {
+		t.Errorf("expect the session default isolation to change, got: %d, %d", session.Isolation, other.Isolation)
+	}
+	if !failed(first) || failed(second) {
+		t.Errorf("expect a serialization failure, got: %s, %s", first.ToString(), second.ToString())
+	}
+	expectRows(t, session, "select * from sr1;", 1)
+	expectError(t, session, "begin isolation level chaos;", "unsupported isolation level")
+}
+
+func testReadCommitted(t *testing.T, session *Session) {
+	other := session.Server.Session()
+	expectOk(t, session, "create table rc1 (id int primary key, v int);")
+	expectOk(t, session, "insert into rc1 values (1, 1), (2, 2);")
+
+	// 每条语句读到之前已经提交的数据;
+	expectOk(t, session, "begin isolation level read committed;")
+	expectRows(t, session, "select * from rc1;", 2)
+	other.Execute("insert into rc1 values (3, 3);")
+	expectRows(t, session, "select * from rc1;", 3)
+	// 其他事务未提交的数据不可见;
+	other.Execute("begin;")
+	other.Execute("update rc1 set v = 20 where id = 2;")
+	expectValue(t, session, "select v from rc1 where id = 2;", "2")
+	expectError(t, session, "update rc1 set v = 21 where id = 2;", "WriteConflict")
+	other.Execute("commit;")
+	expectValue(t, session, "select v from rc1 where id = 2;", "20")
+	// 之后开启的事务提交的数据可见, 也可以覆盖;
+	expectOk(t, session, "update rc1 set v = 22 where id = 2;")
+	expectValue(t, session, "select v from rc1 where id = 2;", "22")
+	expectOk(t, session, "update rc1 set v = 10 where id = 1;")
+	expectOk(t, session, "commit;")
+	expectValue(t, session, "select v from rc1 where id = 1;", "10")
+	expectValue(t, session, "select v from rc1 where id = 2;", "22")
+
+	// 快照隔离的事务读不到开始之后提交的数据;
+	expectOk(t, session, "begin;")
+	expectRows(t, session, "select * from rc1;", 3)
+	other.Execute("insert into rc1 values (4, 4);")
+	expectRows(t, session, "select * from rc1;", 3)
+	expectOk(t, session, "commit;")
+	expectRows(t, session, "select * from rc1;", 4)
+}
+
 func testCrossJoin(t *testing.T, session *Session) {
 	session.Execute("create table ac1 (a int primary key);")
 	session.Execute("create table ac2 (b int primary key);")
@@ -449,8 +1821,8 @@ func showTableInfo(t *testing.T, session *Session, tableName string) {
 	//	a Integer PRIMARY KEY
 	//	b Integer DEFAULT 100
 	//	c Float DEFAULT 1.1
-	//	d UNKNOWN DEFAULT false
-	//	e UNKNOWN DEFAULT true
+	//	d Boolean DEFAULT false
+	//	e Boolean DEFAULT true
 	//	f String DEFAULT v1
 	//	g String DEFAULT v2
 	//	h String DEFAULT v3
@@ -473,7 +1845,10 @@ func testShowAllTableRows(t *testing.T, session *Session) {
 
 func TestMemoryStorage(t *testing.T) {
 	memoryStorage := storage.NewMemoryStorage()
-	server := NewServer(memoryStorage)
+	server, err := NewServer(memoryStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
 	session := server.Session()
 	// 第一组测试
 	testCreateTable(t, session)
@@ -488,6 +1863,32 @@ func TestMemoryStorage(t *testing.T) {
 	testFilter(t, session)
 	testIndexScan(t, session)
 	testPrimaryKeyScan(t, session)
+	testCreateIndex(t, session)
+	testCreateIndexWaitTimeout(t, session)
+	testCompositeIndex(t, session)
+	testCompositePrimaryKey(t, session)
+	testUnique(t, session)
+	testForeignKey(t, session)
+	testForeignKeyConcurrent(t, session)
+	testCheck(t, session)
+	testAlterTable(t, session)
+	testSequence(t, session)
+	testInsertSelect(t, session)
+	testUpsert(t, session)
+	testReturning(t, session)
+	testTruncate(t, session)
+	testView(t, session)
+	testMaterializedView(t, session)
+	testTemporal(t, session)
+	testDecimal(t, session)
+	testBlob(t, session)
+	testJson(t, session)
+	testCollation(t, session)
+	testCoercion(t, session)
+	testStatementAtomicity(t, session)
+	testSavepoint(t, session)
+	testSerializable(t, session)
+	testReadCommitted(t, session)
 
 	//第三组测试
 	testCrossJoin(t, session)
@@ -511,7 +1912,10 @@ func TestMemoryStorage(t *testing.T) {
 func TestDiskStorage(t *testing.T) {
 	util.ClearPath(dirPath)
 	diskStorage := storage.NewDiskStorage(dirPath)
-	server := NewServer(diskStorage)
+	server, err := NewServer(diskStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
 	session := server.Session()
 
 	// 第一组测试
@@ -526,6 +1930,32 @@ func TestDiskStorage(t *testing.T) {
 	testFilter(t, session)
 	testIndexScan(t, session)
 	testPrimaryKeyScan(t, session)
+	testCreateIndex(t, session)
+	testCreateIndexWaitTimeout(t, session)
+	testCompositeIndex(t, session)
+	testCompositePrimaryKey(t, session)
+	testUnique(t, session)
+	testForeignKey(t, session)
+	testForeignKeyConcurrent(t, session)
+	testCheck(t, session)
+	testAlterTable(t, session)
+	testSequence(t, session)
+	testInsertSelect(t, session)
+	testUpsert(t, session)
+	testReturning(t, session)
+	testTruncate(t, session)
+	testView(t, session)
+	testMaterializedView(t, session)
+	testTemporal(t, session)
+	testDecimal(t, session)
+	testBlob(t, session)
+	testJson(t, session)
+	testCollation(t, session)
+	testCoercion(t, session)
+	testStatementAtomicity(t, session)
+	testSavepoint(t, session)
+	testSerializable(t, session)
+	testReadCommitted(t, session)
 
 	// 第三组测试
 	testCrossJoin(t, session)
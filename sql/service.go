@@ -2,22 +2,55 @@ package sql
 
 import (
 	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/storage"
 )
 
 type Service interface {
-	Commit()
+	Commit() error
 	Rollback()
 	Version() uint64
+	Refresh()
+	Savepoint() storage.Savepoint
+	RollbackTo(savepoint storage.Savepoint)
+	SetSavepoint(name string)
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
 	CreateRow(tableName string, row types.Row) error
-	UpdateRow(table *types.Table, value types.Value, row []types.Value) error
-	DeleteRow(table *types.Table, value types.Value) error
+	UpdateRow(table *types.Table, pk []types.Value, row []types.Value) error
+	DeleteRow(table *types.Table, pk []types.Value) error
+	HoldReference(table *types.Table, values []types.Value) error
+	ChangeReference(table *types.Table, values []types.Value) error
 	ScanTable(tableName string, filter *types.Expression) ([]types.Row, error)
-	LoadIndex(name string, filed string, value types.Value) ([]types.Value, error)
-	SaveIndex(tableName string, colName string, value types.Value, indexSet []types.Value) error
-	ReadById(name string, index types.Value) (types.Row, error)
+	ScanIndex(tableName string, indexName string, prefix []types.Value, indexRange *types.IndexRange) ([][]types.Value, error)
+	ReadById(name string, pk []types.Value) (types.Row, error)
+	ScanPrimaryKey(tableName string, prefix []types.Value) ([]types.Row, error)
+	CreateIndex(tableName string, index *types.Index) error
+	BackfillIndex(tableName string, indexName string, from []byte, limit int) ([]byte, error)
+	SetIndexState(tableName string, indexName string, state types.IndexState) error
+	DropIndex(indexName string) error
 	CreateTable(table *types.Table) error
-	DropTable(tableName string) error
+	DropTable(tableName string, cascade bool) error
+	TruncateTable(tableName string) error
+	LockTable(tableName string) error
+	GarbageKeySpaces() []string
+	CollectGarbage(keySpace string, limit int) (bool, error)
+	AddColumn(tableName string, definition *types.Table) error
+	DropColumn(tableName string, colName string) error
+	RenameColumn(tableName string, oldName string, newName string) error
+	RenameTable(tableName string, newName string) error
+	SetColumnDefault(tableName string, colName string, value types.Value) error
+	SetColumnNotNull(tableName string, colName string, notNull bool) error
+	CreateSequence(sequence *types.Sequence) error
+	DropSequence(sequenceName string) error
+	NextVal(sequenceName string) (int64, error)
+	CurrVal(sequenceName string) (int64, error)
+	Now() *types.ConstTimestamp
 	GetTable(tableName string) (*types.Table, error)
 	MustGetTable(tableName string) (*types.Table, error)
 	GetTableNames() []string
+	CreateView(view *types.View, replace bool) error
+	DropView(viewName string, cascade bool) error
+	GetView(viewName string) (*types.View, error)
+	GetViewNames() []string
+	MaterializeView(viewName string, columns []types.ColumnV, rows []types.Row) error
 }
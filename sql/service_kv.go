@@ -3,13 +3,18 @@ package sql
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"github.com/kebukeYi/TrainSQL/sql/types"
 	"github.com/kebukeYi/TrainSQL/sql/util"
 	"github.com/kebukeYi/TrainSQL/storage"
 )
 
 type KVService struct {
-	txn *storage.Transaction
+	txn        *storage.Transaction
+	sequences  *sequenceCache        // 序列值的预分配缓存, 为空时每次直接从计数器分配;
+	currValues map[string]int64      // 会话中各序列最近一次 nextval 的值;
+	now        *types.ConstTimestamp // 事务开始的时间, 事务中的 now() 都返回这个值;
 }
 
 func NewKVService(t *storage.Transaction) *KVService {
@@ -18,62 +23,77 @@ func NewKVService(t *storage.Transaction) *KVService {
 	gob.Register(&types.ConstBool{})
 	gob.Register(&types.ConstFloat{})
 	gob.Register(&types.ConstString{})
+	gob.Register(&types.ConstDate{})
+	gob.Register(&types.ConstTime{})
+	gob.Register(&types.ConstTimestamp{})
+	gob.Register(&types.ConstInterval{})
+	gob.Register(&types.ConstDecimal{})
+	gob.Register(&types.ConstBlob{})
+	gob.Register(&types.ConstJson{})
+	// CHECK 约束的表达式随表结构一起编码;
+	gob.Register(&types.OperationEqual{})
+	gob.Register(&types.OperationGreaterThan{})
+	gob.Register(&types.OperationLessThan{})
+	gob.Register(&types.OperationGreaterEqual{})
+	gob.Register(&types.OperationLessEqual{})
+	gob.Register(&types.OperationAnd{})
+	gob.Register(&types.OperationOr{})
+	gob.Register(&types.OperationArith{})
 	return &KVService{
 		txn: t,
+		now: types.NowTimestamp(),
 	}
 }
+
+// Now 事务开始的时间;
+func (s *KVService) Now() *types.ConstTimestamp {
+	return s.now
+}
 func (s *KVService) CreateRow(tableName string, row types.Row) error {
 	table, err := s.MustGetTable(tableName)
 	if err != nil {
 		return util.Error("[CreateRow] table not exists")
 	}
+	if err = s.holdKeySpace(table); err != nil {
+		return err
+	}
+	// DECIMAL 列按列的精度取整, JSON 列校验字符串;
+	if err = table.CastValues(row); err != nil {
+		return err
+	}
 	// 校验 row 行每一列的的有效性;
-	for i, column := range table.Columns {
-		dateType := row[i].DateType()
-		if dateType == types.Null {
-			if column.Nullable == true {
-				continue
-			} else {
-				return util.Error("[CreateRow] column %s can not be null", column.Name)
-			}
-		}
-		if dateType != column.DataType {
-			return util.Error("[CreateRow] column type not match")
-		}
+	if err = checkColumns(table, row, "CreateRow"); err != nil {
+		return err
+	}
+	if err = checkConstraints(table, row); err != nil {
+		return err
 	}
 	// 找到 此行的主键, 作为该行数据的唯一标识;
 	pk := table.GetPrimaryKeyOfValue(row)
 	// 查看主键对应的数据是否已经存在了;
-	rowKey := GetRowKey(tableName, pk)
+	rowKey := GetRowKey(table.KeySpace, pk)
 	// key: tableName_primaryKey 是否已经存在; Row_test1
 	if get := s.txn.Get(rowKey); get != nil {
 		return util.Error("[CreateRow] row already exists")
 	}
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(row); err != nil {
+	// 唯一索引先行校验, 避免写入一半的数据;
+	indexes := table.GetIndexes()
+	for i := range indexes {
+		if err = s.checkUnique(table, &indexes[i], row, pk); err != nil {
+			return err
+		}
+	}
+	value, err := encodeRow(table, row)
+	if err != nil {
 		return util.Error("#CreateRow encode row error:%s", err)
 	}
-	err = s.txn.Set(rowKey, buffer.Bytes())
+	err = s.txn.Set(rowKey, value)
 	if err != nil {
 		return util.Error("#CreateRow set row error:%s", err)
 	}
-	// 维护索引, 主键索引需要维护吗?
-	indexCol := make(map[int]types.ColumnV)
-	for i, column := range table.Columns {
-		if column.IsIndex {
-			indexCol[i] = column
-		}
-	}
-	// 多个索引;
-	for i, column := range indexCol {
-		loadIndex, err := s.LoadIndex(tableName, column.Name, row[i])
-		if err != nil {
-			return err
-		}
-		loadIndex = append(loadIndex, pk)
-		err = s.SaveIndex(tableName, column.Name, row[i], loadIndex)
-		if err != nil {
+	// 维护索引(包括回填中的索引), 主键不需要额外维护;
+	for i := range indexes {
+		if err = s.insertIndexEntry(table, &indexes[i], row, pk); err != nil {
 			return err
 		}
 	}
@@ -82,21 +102,20 @@ func (s *KVService) CreateRow(tableName string, row types.Row) error {
 func (s *KVService) ScanTable(tableName string, filter *types.Expression) ([]types.Row, error) {
 	// prefixRowKey: Row_user
 	// 扫描数据时, 需要过滤一些数据;
-	prefixRowKey := GetPrefixRowKey(tableName)
 	table, err := s.MustGetTable(tableName)
 	if err != nil {
 		return nil, err
 	}
+	prefixRowKey := GetPrefixRowKey(table.KeySpace)
 	resultPairs := s.txn.ScanPrefix(prefixRowKey, true)
 	rows := make([]types.Row, 0)
 	for _, resultPair := range resultPairs {
-		row := types.Row{}
 		value := resultPair.Value
 		if len(value) == 0 || value == nil {
 			continue
 		}
-		decoder := gob.NewDecoder(bytes.NewReader(value))
-		if err := decoder.Decode(&row); err != nil {
+		row, err := decodeRow(table, value)
+		if err != nil {
 			return nil, util.Error("#ScanTable decode row error")
 		}
 		if filter != nil {
@@ -132,35 +151,135 @@ func (s *KVService) CreateTable(table *types.Table) error {
 	if getTable != nil {
 		return util.Error("#CreateTable table already exists")
 	}
+	if view, err := s.GetView(table.Name); err != nil {
+		return err
+	} else if view != nil {
+		return util.Error("#CreateTable view %s already exists", table.Name)
+	}
+	for i := range table.ForeignKeys {
+		if err = s.resolveRefColumns(table, &table.ForeignKeys[i]); err != nil {
+			return err
+		}
+	}
 	if err = table.Validate(); err != nil {
 		return err
 	}
+	if err = s.initLayout(table); err != nil {
+		return err
+	}
+	for i := range table.Columns {
+		if table.Columns[i].Sequence == "" {
+			continue
+		}
+		if err = s.createColumnSequence(table, &table.Columns[i]); err != nil {
+			return err
+		}
+	}
+	for i := range table.ForeignKeys {
+		if err = s.validateReference(table, &table.ForeignKeys[i]); err != nil {
+			return err
+		}
+	}
+	for _, index := range table.Indexes {
+		if owner, err := s.findIndexTable(index.Name); err != nil {
+			return err
+		} else if owner != nil {
+			return util.Error("#CreateTable index %s already exists on table %s", index.Name, owner.Name)
+		}
+	}
+	return s.saveTable(table)
+}
+
+// resolveRefColumns 省略被引用列的外键引用父表的主键;
+func (s *KVService) resolveRefColumns(table *types.Table, foreignKey *types.ForeignKey) error {
+	if len(foreignKey.RefColumns) > 0 {
+		return nil
+	}
+	if foreignKey.RefTable == table.Name {
+		foreignKey.RefColumns = table.GetPrimaryKeys()
+	} else if parent, err := s.MustGetTable(foreignKey.RefTable); err != nil {
+		return util.Error("#CreateTable foreign key %s referenced table %s not exists", foreignKey.Name, foreignKey.RefTable)
+	} else {
+		foreignKey.RefColumns = parent.GetPrimaryKeys()
+	}
+	return nil
+}
+
+// validateReference 被引用的列必须是父表的主键或唯一约束, 且类型与外键列一致;
+func (s *KVService) validateReference(table *types.Table, foreignKey *types.ForeignKey) error {
+	parent := table
+	if foreignKey.RefTable != table.Name {
+		var err error
+		if parent, err = s.MustGetTable(foreignKey.RefTable); err != nil {
+			return util.Error("#CreateTable foreign key %s referenced table %s not exists", foreignKey.Name, foreignKey.RefTable)
+		}
+	}
+	for i, colName := range foreignKey.RefColumns {
+		pos := parent.GetColumnIndex(colName)
+		if pos == -1 {
+			return util.Error("#CreateTable foreign key %s referenced column %s.%s not exists", foreignKey.Name, parent.Name, colName)
+		}
+		column := table.Columns[table.GetColumnIndex(foreignKey.Columns[i])]
+		if column.DataType != parent.Columns[pos].DataType {
+			return util.Error("#CreateTable foreign key %s column %s type not match %s.%s", foreignKey.Name, column.Name, parent.Name, colName)
+		}
+	}
+	if !parent.IsPrimaryKey(foreignKey.RefColumns) && parent.GetUniqueIndex(foreignKey.RefColumns) == nil {
+		return util.Error("#CreateTable foreign key %s: there is no unique constraint matching given keys for referenced table %s", foreignKey.Name, parent.Name)
+	}
+	return nil
+}
 
+// initLayout 为新建的表分配列的 slot 与存储名;
+// 改名后的表仍使用原来的存储名, 与之同名的新表追加序号, 避免两张表的数据混在一起;
+func (s *KVService) initLayout(table *types.Table) error {
+	var err error
+	if table.KeySpace, err = s.newKeySpace(table.Name); err != nil {
+		return err
+	}
+	table.NextSlot = 0
+	table.InitLayout()
+	return nil
+}
+
+// saveTable 写入表的元数据;
+func (s *KVService) saveTable(table *types.Table) error {
 	var buffer bytes.Buffer
 	encoder := gob.NewEncoder(&buffer)
 	if err := encoder.Encode(table); err != nil {
-		return util.Error("#CreateTable encode table error")
+		return util.Error("#saveTable encode table error")
 	}
 	// tableNameKey: Table_test
 	tableNameKey := GetTableNameKey(table.Name)
 	return s.txn.Set(tableNameKey, buffer.Bytes())
 }
-func (s *KVService) DropTable(tableName string) error {
+
+// DropTable 删除表; 被视图引用时, cascade 为 true 才连同这些视图一起删除;
+func (s *KVService) DropTable(tableName string, cascade bool) error {
 	table, err := s.MustGetTable(tableName)
 	if err != nil {
 		return err
 	}
-	rows, err := s.ScanTable(tableName, nil)
+	// 被其他表的外键引用时不允许删除;
+	references, err := referencingForeignKeys(s, tableName)
 	if err != nil {
 		return err
 	}
-	for _, row := range rows {
-		primaryKeyOfValue := table.GetPrimaryKeyOfValue(row)
-		err = s.DeleteRow(table, primaryKeyOfValue)
-		if err != nil {
-			return err
+	for _, ref := range references {
+		if ref.child.Name != tableName {
+			return util.Error("[ForeignKey] can not drop table %s because constraint %s on table %s depends on it", tableName, ref.foreignKey.Name, ref.child.Name)
 		}
 	}
+	if err = s.dropDependentViews(tableName, cascade); err != nil {
+		return err
+	}
+	// 行与索引数据随元数据一起不可见, 提交后再清理;
+	if err = s.txn.Set(GetGarbageKey(table.KeySpace), []byte{1}); err != nil {
+		return err
+	}
+	if err = s.dropColumnSequences(table.Columns); err != nil {
+		return err
+	}
 	tableNameKey := GetTableNameKey(tableName)
 	return s.txn.Delete(tableNameKey)
 }
@@ -178,6 +297,8 @@ func (s *KVService) GetTable(tableName string) (*types.Table, error) {
 	if err := decoder.Decode(&table); err != nil {
 		return nil, err
 	}
+	// 旧版本的表没有 slot 信息;
+	table.InitLayout()
 	return &table, nil
 }
 func (s *KVService) MustGetTable(tableName string) (*types.Table, error) {
@@ -192,140 +313,459 @@ func (s *KVService) MustGetTable(tableName string) (*types.Table, error) {
 	}
 	return table, nil
 }
-func (s *KVService) UpdateRow(table *types.Table, primaryId types.Value, row []types.Value) error {
+
+// checkColumns 写入前校验每一列: 非空约束, 以及转换之后的值与列的类型一致; tag 为报错的前缀;
+func checkColumns(table *types.Table, row types.Row, tag string) error {
+	for i, column := range table.Columns {
+		dateType := row[i].DateType()
+		if dateType == types.Null {
+			if column.Nullable {
+				continue
+			}
+			return util.Error("[%s] column %s can not be null", tag, column.Name)
+		}
+		if dateType != column.DataType {
+			return util.Error("[%s] column %s expects %s, got %s", tag, column.Name,
+				types.GetDataTypeInfo(column.DataType), types.GetDataTypeInfo(dateType))
+		}
+	}
+	return nil
+}
+
+func (s *KVService) UpdateRow(table *types.Table, primaryId []types.Value, row []types.Value) error {
+	if err := s.holdKeySpace(table); err != nil {
+		return err
+	}
+	// 与插入相同: 先按列的类型转换, 再校验每一列;
+	if err := table.CastValues(row); err != nil {
+		return err
+	}
+	if err := checkColumns(table, row, "UpdateRow"); err != nil {
+		return err
+	}
+	if err := checkConstraints(table, row); err != nil {
+		return err
+	}
 	newPk := table.GetPrimaryKeyOfValue(row)
-	if primaryId != newPk {
+	// 更新了主键(任意一列): 删除原来的数据, 新增一条新的数据;
+	if !valuesEqual(primaryId, newPk) {
 		err := s.DeleteRow(table, primaryId)
 		if err != nil {
 			return err
 		}
-		err = s.CreateRow(table.Name, row)
-		if err != nil {
-			return err
-		}
+		return s.CreateRow(table.Name, row)
 	}
-	// 没有更新主键的情况:
-	// 查询当前表的所有索引列; 判断是否更新了索引列;
-	indexCol := make(map[int]types.ColumnV)
-	for i, column := range table.Columns {
-		if column.IsIndex {
-			indexCol[i] = column
-		}
+	oldRow, err := s.ReadById(table.Name, primaryId)
+	if err != nil {
+		return err
 	}
+	if oldRow == nil {
+		return util.Error("#UpdateRow row not exists")
+	}
+	// 没有更新主键的情况:
+	// 查询当前表的所有索引; 只有索引列的值发生变化时, 才需要维护索引;
 	// update user set name="kk" where index=30;
 	// update user set index="kk" where id=10;
-
-	for i, v := range indexCol {
-		oldRow, err := s.ReadById(table.Name, primaryId)
+	changed := make([]*types.Index, 0)
+	indexes := table.GetIndexes()
+	for i := range indexes {
+		oldValues, err := table.GetIndexValues(&indexes[i], oldRow)
 		if err != nil {
 			return err
 		}
-		if oldRow != nil {
-			if oldRow[i] == row[i] {
-				continue
-			}
-		}
-		oldIndex, err := s.LoadIndex(table.Name, v.Name, oldRow[i])
+		newValues, err := table.GetIndexValues(&indexes[i], row)
 		if err != nil {
 			return err
 		}
-		oldIndex = types.Remove(oldIndex, oldRow[i])
-		err = s.SaveIndex(table.Name, v.Name, oldRow[i], oldIndex)
-		if err != nil {
+		if valuesEqual(oldValues, newValues) {
+			continue
+		}
+		if err = s.checkUnique(table, &indexes[i], row, newPk); err != nil {
 			return err
 		}
-		newIndex, err := s.LoadIndex(table.Name, v.Name, row[i])
-		if err != nil {
+		changed = append(changed, &indexes[i])
+	}
+	for _, index := range changed {
+		if err = s.deleteIndexEntry(table, index, oldRow, primaryId); err != nil {
 			return err
 		}
-		newIndex = append(newIndex, newPk)
-		err = s.SaveIndex(table.Name, v.Name, row[i], newIndex)
-		if err != nil {
+		if err = s.insertIndexEntry(table, index, row, newPk); err != nil {
 			return err
 		}
 	}
-	rowKey := GetRowKey(table.Name, newPk)
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(row); err != nil {
+	rowKey := GetRowKey(table.KeySpace, newPk)
+	value, err := encodeRow(table, row)
+	if err != nil {
 		return util.Error("#UpdateRow encode row error")
 	}
-	return s.txn.Set(rowKey, buffer.Bytes())
+	return s.txn.Set(rowKey, value)
 }
-func (s *KVService) DeleteRow(table *types.Table, primaryIdDelete types.Value) error {
-	indexCols := make(map[int]types.ColumnV)
-	for i, column := range table.Columns {
-		if column.IsIndex {
-			indexCols[i] = column
-		}
+func (s *KVService) DeleteRow(table *types.Table, primaryIdDelete []types.Value) error {
+	if err := s.holdKeySpace(table); err != nil {
+		return err
+	}
+	row, err := s.ReadById(table.Name, primaryIdDelete)
+	if err != nil {
+		return err
 	}
 	// 每一个索引都关联着 主键; 所以当删除主键时,也需要将索引关系剔除;
-	for i, indexCol := range indexCols {
-		row, err := s.ReadById(table.Name, primaryIdDelete)
-		if err != nil {
-			return err
-		}
-		if row != nil {
-			index, err := s.LoadIndex(table.Name, indexCol.Name, row[i])
-			if err != nil {
+	if row != nil {
+		indexes := table.GetIndexes()
+		for i := range indexes {
+			if err = s.deleteIndexEntry(table, &indexes[i], row, primaryIdDelete); err != nil {
 				return err
 			}
-			index = types.Remove(index, primaryIdDelete)
-			err = s.SaveIndex(table.Name, indexCol.Name, row[i], index)
+		}
+	}
+	rowKey := GetRowKey(table.KeySpace, primaryIdDelete)
+	return s.txn.Delete(rowKey)
+}
+
+// HoldReference 子行引用父行之后, 登记依赖父行被引用的值; 登记在提交之后保留, 直到所有活跃事务都在提交之后开始;
+// 并发的事务(包括在子行提交之前开始的事务)删除父行或修改被引用列时, 与 ChangeReference 之间必有一方写冲突;
+func (s *KVService) HoldReference(table *types.Table, values []types.Value) error {
+	return s.txn.Hold(GetReferenceKey(table.KeySpace, values))
+}
+
+// ChangeReference 删除父行或修改被引用列之前, 改写被引用值的 key;
+func (s *KVService) ChangeReference(table *types.Table, values []types.Value) error {
+	return s.txn.Set(GetReferenceKey(table.KeySpace, values), []byte{1})
+}
+
+// ScanIndex 在索引上做最左前缀扫描: 前缀列等值匹配, 紧随其后的一列按 indexRange 过滤;
+// 结果按索引顺序返回主键;
+func (s *KVService) ScanIndex(tableName string, indexName string, prefix []types.Value, indexRange *types.IndexRange) ([][]types.Value, error) {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	index := table.GetIndex(indexName)
+	if index == nil {
+		return nil, util.Error("#ScanIndex index %s not exists", indexName)
+	}
+	indexPrefix := GetIndexPrefixKey(table.KeySpace, indexName)
+	keyPrefix := GetIndexKey(table.KeySpace, indexName, prefix)
+	var resultPairs []storage.ResultPair
+	if indexRange == nil {
+		resultPairs = s.txn.ScanPrefix(keyPrefix, true)
+	} else {
+		// 范围的两端编码为 key 的边界, 只读取范围内的索引项;
+		start, end := indexRange.KeyBounds(keyPrefix)
+		resultPairs = s.txn.ScanRange(keyPrefix, start, end)
+	}
+	pks := make([][]types.Value, 0)
+	for _, resultPair := range resultPairs {
+		// 索引项: <enc(vals)><enc(pk)>, 索引列之后的值是主键;
+		// 唯一索引项: <enc(vals)> => enc(pk), 主键在 value 中;
+		values, err := types.DecodeKey(resultPair.Key[len(indexPrefix):])
+		if err != nil {
+			return nil, util.Error("#ScanIndex decode index entry error: %s", err)
+		}
+		if len(values) == len(index.Columns) && index.Unique {
+			pk, err := types.DecodeKey(resultPair.Value)
 			if err != nil {
-				return err
+				return nil, util.Error("#ScanIndex decode unique index entry error: %s", err)
 			}
+			values = append(values, pk...)
+		}
+		if len(values) <= len(index.Columns) {
+			return nil, util.Error("#ScanIndex index %s entry is broken", indexName)
 		}
+		pks = append(pks, values[len(index.Columns):])
 	}
-	rowKey := GetRowKey(table.Name, primaryIdDelete)
-	return s.txn.Delete(rowKey)
+	return pks, nil
 }
-func (s *KVService) LoadIndex(tableName string, colName string, colVal types.Value) ([]types.Value, error) {
-	indexKey := GetIndexKey(tableName, colName, colVal)
-	values := s.txn.Get(indexKey)
-	var index []types.Value
-	if values != nil {
-		var buffer bytes.Buffer
-		buffer.Write(values)
-		decoder := gob.NewDecoder(&buffer)
-		if err := decoder.Decode(&index); err != nil {
-			return nil, util.Error("#LoadIndex decode index error")
+
+// ScanPrimaryKey 按主键的前若干列做前缀扫描, 结果按主键顺序返回;
+func (s *KVService) ScanPrimaryKey(tableName string, prefix []types.Value) ([]types.Row, error) {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	resultPairs := s.txn.ScanPrefix(GetRowKey(table.KeySpace, prefix), true)
+	rows := make([]types.Row, 0, len(resultPairs))
+	for _, resultPair := range resultPairs {
+		row, err := decodeRow(table, resultPair.Value)
+		if err != nil {
+			return nil, util.Error("#ScanPrimaryKey decode row error")
 		}
-		return index, nil
+		rows = append(rows, row)
 	}
-	return nil, nil
+	return rows, nil
 }
-func (s *KVService) ReadById(tableName string, primaryId types.Value) (types.Row, error) {
-	rowKey := GetRowKey(tableName, primaryId)
+func (s *KVService) ReadById(tableName string, primaryId []types.Value) (types.Row, error) {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	rowKey := GetRowKey(table.KeySpace, primaryId)
 	values := s.txn.Get(rowKey)
 	if values != nil {
-		var buffer bytes.Buffer
-		buffer.Write(values)
-		decoder := gob.NewDecoder(&buffer)
-		var row types.Row
-		if err := decoder.Decode(&row); err != nil {
+		row, err := decodeRow(table, values)
+		if err != nil {
 			return nil, util.Error("#ReadById decode row error")
 		}
 		return row, nil
 	}
 	return nil, nil
 }
-func (s *KVService) SaveIndex(tableName string, colName string, colVal types.Value, indexSet []types.Value) error {
-	indexKey := GetIndexKey(tableName, colName, colVal)
-	if len(indexSet) == 0 {
-		return s.txn.Delete(indexKey)
-	} else {
-		var buffer bytes.Buffer
-		encoder := gob.NewEncoder(&buffer)
-		if err := encoder.Encode(indexSet); err != nil {
-			return util.Error("#SaveIndex encode index error")
+
+// encodeRow 按表的存储布局编码一行数据;
+func encodeRow(table *types.Table, row types.Row) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(table.ToStorageRow(row)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decodeRow 解码存储的行, 按表当前的列定义取值;
+func decodeRow(table *types.Table, value []byte) (types.Row, error) {
+	stored := types.Row{}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&stored); err != nil {
+		return nil, err
+	}
+	return table.FromStorageRow(stored), nil
+}
+
+// checkConstraints 校验行是否满足表上的全部 CHECK 约束, 结果为 false 时拒绝;
+func checkConstraints(table *types.Table, row types.Row) error {
+	if len(table.Checks) == 0 {
+		return nil
+	}
+	colNames := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		colNames = append(colNames, column.Name)
+	}
+	for _, check := range table.Checks {
+		value, err := types.EvaluateCheck(check.Expr, colNames, row)
+		if err != nil {
+			return err
+		}
+		if b, ok := value.(*types.ConstBool); ok && !b.Value {
+			return util.Error("[Check] new row for table \"%s\" violates check constraint \"%s\": (%s)",
+				table.Name, check.Name, check.Expr.ToString())
+		}
+	}
+	return nil
+}
+
+// uniqueEntry 唯一索引的值不含 NULL 时, 索引项的 key 中不带主键:
+// 同一个值只对应一个 key, 并发事务写入相同的值时会在这个 key 上发生写冲突, 保证唯一性;
+// 含有 NULL 的值之间互不冲突, 仍按普通索引项存储;
+func uniqueEntry(index *types.Index, values []types.Value) bool {
+	return index.Unique && !hasNull(values)
+}
+
+// indexEntryKey 一行数据在索引中对应的 key 与 value;
+func indexEntryKey(table *types.Table, index *types.Index, row types.Row, pk []types.Value) ([]byte, []byte, error) {
+	values, err := table.GetIndexValues(index, row)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uniqueEntry(index, values) {
+		return GetIndexKey(table.KeySpace, index.Name, values), types.EncodeKey(pk...), nil
+	}
+	return GetIndexEntryKey(table.KeySpace, index.Name, values, pk), indexEntryMarker, nil
+}
+
+// checkUnique 校验唯一索引上是否已存在其他主键;
+func (s *KVService) checkUnique(table *types.Table, index *types.Index, row types.Row, pk []types.Value) error {
+	values, err := table.GetIndexValues(index, row)
+	if err != nil {
+		return err
+	}
+	if !uniqueEntry(index, values) {
+		return nil
+	}
+	value := s.txn.Get(GetIndexKey(table.KeySpace, index.Name, values))
+	if value == nil {
+		return nil
+	}
+	if !bytes.Equal(value, types.EncodeKey(pk...)) {
+		return duplicateKeyError(index, values)
+	}
+	return nil
+}
+
+func duplicateKeyError(index *types.Index, values []types.Value) error {
+	return util.Error("[Unique] duplicate key value violates unique constraint \"%s\": (%s)=(%s)",
+		index.Name, util.Join(index.Columns, ", "), formatValues(values))
+}
+
+// indexEntryMarker 普通索引项的信息全部在 key 中; 空 value 在 MVCC 中表示删除, 因此写入 1 字节占位;
+var indexEntryMarker = []byte{1}
+
+// insertIndexEntry 写入一条索引项;
+// 回填与并发写入可能先后处理同一行, 已存在时不再重复写入, 避免无谓的写冲突;
+func (s *KVService) insertIndexEntry(table *types.Table, index *types.Index, row types.Row, pk []types.Value) error {
+	if err := s.checkUnique(table, index, row, pk); err != nil {
+		return err
+	}
+	entryKey, entryValue, err := indexEntryKey(table, index, row, pk)
+	if err != nil {
+		return err
+	}
+	if s.txn.Get(entryKey) != nil {
+		return nil
+	}
+	err = s.txn.Set(entryKey, entryValue)
+	if values, _ := table.GetIndexValues(index, row); errors.Is(err, util.WriteConflict) && uniqueEntry(index, values) {
+		// 并发事务正在写入相同的唯一值; 保留 WriteConflict 以便调用方重试;
+		return fmt.Errorf("[Unique] concurrent write on unique constraint \"%s\": (%s)=(%s): %w",
+			index.Name, util.Join(index.Columns, ", "), formatValues(values), err)
+	}
+	return err
+}
+
+// deleteIndexEntry 删除一条索引项;
+func (s *KVService) deleteIndexEntry(table *types.Table, index *types.Index, row types.Row, pk []types.Value) error {
+	entryKey, _, err := indexEntryKey(table, index, row, pk)
+	if err != nil {
+		return err
+	}
+	return s.txn.Delete(entryKey)
+}
+
+// CreateIndex 在表的元数据中登记索引; 索引数据由 BackfillIndex 回填;
+func (s *KVService) CreateIndex(tableName string, index *types.Index) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	if owner, err := s.findIndexTable(index.Name); err != nil {
+		return err
+	} else if owner != nil {
+		return util.Error("#CreateIndex index %s already exists on table %s", index.Name, owner.Name)
+	}
+	if err = table.AddIndex(*index); err != nil {
+		return err
+	}
+	return s.saveTable(table)
+}
+
+// BackfillIndex 从 from(不包含) 之后的行开始, 最多为 limit 行补写索引;
+// 返回本批最后一行的 key 作为下一批的起点, 全部处理完毕时返回 nil;
+func (s *KVService) BackfillIndex(tableName string, indexName string, from []byte, limit int) ([]byte, error) {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	index := table.GetIndex(indexName)
+	if index == nil {
+		return nil, util.Error("#BackfillIndex index %s not exists", indexName)
+	}
+	count := 0
+	resultPairs := s.txn.ScanPrefix(GetPrefixRowKey(table.KeySpace), true)
+	for _, resultPair := range resultPairs {
+		if from != nil && bytes.Compare(resultPair.Key, from) <= 0 {
+			continue
+		}
+		row, err := decodeRow(table, resultPair.Value)
+		if err != nil {
+			return nil, util.Error("#BackfillIndex decode row error")
+		}
+		if err = s.insertIndexEntry(table, index, row, table.GetPrimaryKeyOfValue(row)); err != nil {
+			return nil, err
+		}
+		count++
+		if count == limit {
+			return resultPair.Key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *KVService) SetIndexState(tableName string, indexName string, state types.IndexState) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	if !table.SetIndexState(indexName, state) {
+		return util.Error("#SetIndexState index %s not exists", indexName)
+	}
+	return s.saveTable(table)
+}
+
+// DropIndex 删除索引数据以及元数据; 索引名在库内唯一, 因此无需指定表名;
+func (s *KVService) DropIndex(indexName string) error {
+	table, err := s.findIndexTable(indexName)
+	if err != nil {
+		return err
+	}
+	if table == nil {
+		return util.Error("#DropIndex index %s not exists", indexName)
+	}
+	// 唯一索引被外键引用时不允许删除;
+	references, err := referencingForeignKeys(s, table.Name)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references {
+		if index := table.GetUniqueIndex(ref.foreignKey.RefColumns); !table.IsPrimaryKey(ref.foreignKey.RefColumns) && index != nil && index.Name == indexName {
+			return util.Error("[ForeignKey] can not drop index %s because constraint %s on table %s depends on it", indexName, ref.foreignKey.Name, ref.child.Name)
 		}
-		return s.txn.Set(indexKey, buffer.Bytes())
 	}
+	if err = s.deletePrefix(GetIndexPrefixKey(table.KeySpace, indexName)); err != nil {
+		return err
+	}
+	table.RemoveIndex(indexName)
+	return s.saveTable(table)
+}
+
+// deletePrefix 删除当前事务可见的、以 prefix 开头的全部 key;
+func (s *KVService) deletePrefix(prefix []byte) error {
+	for _, resultPair := range s.txn.ScanPrefix(prefix, true) {
+		if err := s.txn.Delete(resultPair.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findIndexTable 查找索引所属的表, 不存在时返回 nil;
+func (s *KVService) findIndexTable(indexName string) (*types.Table, error) {
+	for _, tableName := range s.GetTableNames() {
+		table, err := s.GetTable(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if table != nil && table.GetIndex(indexName) != nil {
+			return table, nil
+		}
+	}
+	return nil, nil
+}
+
+func valueEqual(a, b types.Value) bool {
+	if allow, cmp := a.PartialCmp(b); allow {
+		return cmp == 0
+	}
+	return false
 }
+func valuesEqual(a, b []types.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !valueEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+func formatValues(values []types.Value) string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, string(value.Bytes()))
+	}
+	return util.Join(strs, ", ")
+}
+
 func (s *KVService) GetTableNames() []string {
 	tablePrefixKey := GetTableNamePrefixKey()
-	pairs := s.txn.ScanPrefix(tablePrefixKey, false)
+	// 需要取出 value 才能过滤掉已删除(以及改名前)的表;
+	pairs := s.txn.ScanPrefix(tablePrefixKey, true)
 	names := make([]string, 0)
 	for _, pair := range pairs {
 		//var buffer bytes.Buffer
@@ -343,9 +783,36 @@ func (s *KVService) GetTableNames() []string {
 func (s *KVService) Version() uint64 {
 	return uint64(s.txn.Version())
 }
-func (s *KVService) Commit() {
-	s.txn.Commit()
+func (s *KVService) Commit() error {
+	return s.txn.Commit()
 }
 func (s *KVService) Rollback() {
 	s.txn.Rollback()
 }
+
+// Refresh 读已提交的事务在语句开始时刷新快照;
+func (s *KVService) Refresh() {
+	s.txn.Refresh()
+}
+
+// Savepoint 记录事务当前的写入位置;
+func (s *KVService) Savepoint() storage.Savepoint {
+	return s.txn.Savepoint()
+}
+
+// RollbackTo 撤销保存点之后的写入, 事务继续;
+func (s *KVService) RollbackTo(savepoint storage.Savepoint) {
+	s.txn.RollbackTo(savepoint)
+}
+
+func (s *KVService) SetSavepoint(name string) {
+	s.txn.SetSavepoint(name)
+}
+
+func (s *KVService) RollbackToSavepoint(name string) error {
+	return s.txn.RollbackToSavepoint(name)
+}
+
+func (s *KVService) ReleaseSavepoint(name string) error {
+	return s.txn.ReleaseSavepoint(name)
+}
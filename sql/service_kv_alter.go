@@ -0,0 +1,309 @@
+package sql
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"slices"
+)
+
+// ALTER TABLE 只修改表的元数据, 不改写已有的行, 行的存储布局见 types.Table.InitLayout;
+// 需要校验已有数据的操作(新增约束、SET NOT NULL)先扫描全部行, 校验通过后才写入元数据;
+
+// AddColumn 新增一列; definition 是只包含新增列的表结构, 带有列上的 UNIQUE、REFERENCES 与 CHECK 约束;
+func (s *KVService) AddColumn(tableName string, definition *types.Table) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	// 新增列的约束要对已有的行校验, 先与并发写入行的事务互斥;
+	if err = s.lockKeySpace(table); err != nil {
+		return err
+	}
+	rows, err := s.ScanTable(tableName, nil)
+	if err != nil {
+		return err
+	}
+	if err = table.AddColumn(definition.Columns[0]); err != nil {
+		return err
+	}
+	column := table.Columns[len(table.Columns)-1]
+	// 已有的行读取新增列时取添加时的默认值;
+	var missing types.Value = &types.ConstNull{}
+	if column.DefaultValue != nil {
+		missing = column.DefaultValue
+	}
+	if !column.Nullable && missing.DateType() == types.Null && len(rows) > 0 {
+		return util.Error("[Table] %s column %s can not be null, add a default value for existing rows", table.Name, column.Name)
+	}
+	for i := range rows {
+		rows[i] = append(rows[i], missing)
+	}
+	if column.Sequence != "" {
+		if len(rows) > 0 {
+			return util.Error("[Table] %s can not add auto increment column %s to a table with rows", table.Name, column.Name)
+		}
+		if err = s.createColumnSequence(table, &table.Columns[len(table.Columns)-1]); err != nil {
+			return err
+		}
+	}
+	for _, index := range definition.Indexes {
+		if owner, err := s.findIndexTable(index.Name); err != nil {
+			return err
+		} else if owner != nil {
+			return util.Error("#AddColumn index %s already exists on table %s", index.Name, owner.Name)
+		}
+		if err = table.AddIndex(index); err != nil {
+			return err
+		}
+	}
+	for _, foreignKey := range definition.ForeignKeys {
+		if err = s.resolveRefColumns(table, &foreignKey); err != nil {
+			return err
+		}
+		table.ForeignKeys = append(table.ForeignKeys, foreignKey)
+	}
+	for _, check := range definition.Checks {
+		name := check.Name
+		for i := 1; slices.ContainsFunc(table.Checks, func(c types.Check) bool { return c.Name == name }); i++ {
+			name = fmt.Sprintf("%s%d", check.Name, i)
+		}
+		table.Checks = append(table.Checks, types.Check{Name: name, Expr: check.Expr})
+	}
+	if err = table.Validate(); err != nil {
+		return err
+	}
+	added := table.ForeignKeys[len(table.ForeignKeys)-len(definition.ForeignKeys):]
+	for i := range added {
+		if err = s.validateReference(table, &added[i]); err != nil {
+			return err
+		}
+	}
+	// 已有的行必须满足新增的约束;
+	for _, row := range rows {
+		if err = checkConstraints(table, row); err != nil {
+			return err
+		}
+		if err = checkForeignKeys(s, table, nil, row); err != nil {
+			return err
+		}
+	}
+	for i := range definition.Indexes {
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			values, err := table.GetIndexValues(&definition.Indexes[i], row)
+			if err != nil {
+				return err
+			}
+			if hasNull(values) {
+				continue
+			}
+			key := string(types.EncodeKey(values...))
+			if seen[key] {
+				return duplicateKeyError(&definition.Indexes[i], values)
+			}
+			seen[key] = true
+		}
+	}
+	if err = s.saveTable(table); err != nil {
+		return err
+	}
+	// 回填新增列上的索引(包括列上的 INDEX 关键字声明的隐式索引);
+	for _, index := range table.GetIndexes() {
+		if !slices.Contains(index.Columns, column.Name) {
+			continue
+		}
+		if _, err = s.BackfillIndex(tableName, index.Name, nil, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropColumn 删除列, 以及依赖它的索引(连同索引数据)、外键与 CHECK 约束; 行中这一列的数据不再读取;
+// 被其他表的外键引用的列不允许删除;
+func (s *KVService) DropColumn(tableName string, colName string) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	// 视图按列名引用表中的列, 删除后将无法展开;
+	if views, err := s.dependentViews(tableName); err != nil {
+		return err
+	} else if len(views) > 0 {
+		return util.Error("[View] can not drop column %s.%s because view %s depends on it", tableName, colName, views[0].Name)
+	}
+	references, err := referencingForeignKeys(s, tableName)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references {
+		// 自引用的外键列同时被删除时, 外键随之删除;
+		if ref.child.Name == tableName && slices.Contains(ref.foreignKey.Columns, colName) {
+			continue
+		}
+		if slices.Contains(ref.foreignKey.RefColumns, colName) {
+			return util.Error("[ForeignKey] can not drop column %s.%s because constraint %s on table %s depends on it",
+				tableName, colName, ref.foreignKey.Name, ref.child.Name)
+		}
+	}
+	var column types.ColumnV
+	if pos := table.GetColumnIndex(colName); pos != -1 {
+		column = table.Columns[pos]
+	}
+	dropped, err := table.DropColumn(colName)
+	if err != nil {
+		return err
+	}
+	if err = s.dropColumnSequences([]types.ColumnV{column}); err != nil {
+		return err
+	}
+	for _, index := range dropped {
+		if err = s.deletePrefix(GetIndexPrefixKey(table.KeySpace, index.Name)); err != nil {
+			return err
+		}
+	}
+	return s.saveTable(table)
+}
+
+// RenameColumn 修改列名; 列上的隐式索引以列名命名, 需要在新的索引名下重建;
+func (s *KVService) RenameColumn(tableName string, oldName string, newName string) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	// 与 RenameTable 相同, 视图按列名引用, 改名后将无法展开;
+	if views, err := s.dependentViews(tableName); err != nil {
+		return err
+	} else if len(views) > 0 {
+		return util.Error("[View] can not rename column %s.%s because view %s depends on it", tableName, oldName, views[0].Name)
+	}
+	pos := table.GetColumnIndex(oldName)
+	implicitIndex := pos != -1 && table.Columns[pos].IsIndex
+	if implicitIndex {
+		if owner, err := s.findIndexTable(newName); err != nil {
+			return err
+		} else if owner != nil {
+			return util.Error("#RenameColumn index %s already exists on table %s", newName, owner.Name)
+		}
+	}
+	references, err := referencingForeignKeys(s, tableName)
+	if err != nil {
+		return err
+	}
+	if err = table.RenameColumn(oldName, newName); err != nil {
+		return err
+	}
+	if err = s.saveTable(table); err != nil {
+		return err
+	}
+	if implicitIndex {
+		if err = s.deletePrefix(GetIndexPrefixKey(table.KeySpace, oldName)); err != nil {
+			return err
+		}
+		if _, err = s.BackfillIndex(tableName, newName, nil, 0); err != nil {
+			return err
+		}
+	}
+	// 其他表引用这一列的外键;
+	for _, ref := range references {
+		if ref.child.Name == tableName || !slices.Contains(ref.foreignKey.RefColumns, oldName) {
+			continue
+		}
+		types.RenameColumnOf(ref.foreignKey.RefColumns, oldName, newName)
+		if err = s.saveTable(ref.child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenameTable 修改表名; 数据仍存放在原来的存储名下, 只需移动元数据并修改引用这张表的外键;
+func (s *KVService) RenameTable(tableName string, newName string) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	if other, err := s.GetTable(newName); err != nil {
+		return err
+	} else if other != nil {
+		return util.Error("#RenameTable table %s already exists", newName)
+	}
+	if view, err := s.GetView(newName); err != nil {
+		return err
+	} else if view != nil {
+		return util.Error("#RenameTable view %s already exists", newName)
+	}
+	// 视图按名字引用表, 改名后将无法展开;
+	if views, err := s.dependentViews(tableName); err != nil {
+		return err
+	} else if len(views) > 0 {
+		return util.Error("[View] can not rename table %s because view %s depends on it", tableName, views[0].Name)
+	}
+	references, err := referencingForeignKeys(s, tableName)
+	if err != nil {
+		return err
+	}
+	table.Name = newName
+	for i := range table.ForeignKeys {
+		if table.ForeignKeys[i].RefTable == tableName {
+			table.ForeignKeys[i].RefTable = newName
+		}
+	}
+	if err = s.txn.Delete(GetTableNameKey(tableName)); err != nil {
+		return err
+	}
+	if err = s.saveTable(table); err != nil {
+		return err
+	}
+	for _, ref := range references {
+		if ref.child.Name == tableName {
+			continue
+		}
+		ref.foreignKey.RefTable = newName
+		if err = s.saveTable(ref.child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetColumnDefault 修改列的默认值, value 为空时删除默认值; 已有的行不受影响;
+func (s *KVService) SetColumnDefault(tableName string, colName string, value types.Value) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	if err = table.SetColumnDefault(colName, value); err != nil {
+		return err
+	}
+	return s.saveTable(table)
+}
+
+// SetColumnNotNull 修改列是否允许为 NULL; 设置 NOT NULL 时已有的行中不能有 NULL;
+func (s *KVService) SetColumnNotNull(tableName string, colName string, notNull bool) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	pos := table.GetColumnIndex(colName)
+	if notNull && pos != -1 {
+		// 并发写入的行不在当前快照中, 先与写入行的事务互斥;
+		if err = s.lockKeySpace(table); err != nil {
+			return err
+		}
+		rows, err := s.ScanTable(tableName, nil)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if row[pos].DateType() == types.Null {
+				return util.Error("[Table] %s column %s contains null values", tableName, colName)
+			}
+		}
+	}
+	if err = table.SetColumnNotNull(colName, notNull); err != nil {
+		return err
+	}
+	return s.saveTable(table)
+}
@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+)
+
+// TRUNCATE TABLE 与 DROP TABLE 不逐行删除数据:
+// TRUNCATE 为表分配新的存储名(KeySpace), DROP 直接删除表的元数据, 旧存储名下的行与索引随之不可见;
+// 旧存储名登记在 Garbage_<keySpace> 中, 与元数据的修改在同一个事务中提交或回滚;
+// 提交之后由 ServerManager.CollectGarbage 分批删除旧数据, 清理完成后删除登记;
+// 写入行的事务以 Hold 登记依赖 Garbage_<keySpace> 的当前版本, 与并发的 TRUNCATE / DROP 之间必有一方写冲突,
+// 无论哪一方先提交, 行都不会写进已经废弃的存储名;
+
+// holdKeySpace 写入表的行与索引之前, 登记依赖表当前的存储名;
+func (s *KVService) holdKeySpace(table *types.Table) error {
+	return s.txn.Hold(GetGarbageKey(table.KeySpace))
+}
+
+// LockTable 需要校验表中已有行的结构变更在校验之前调用, 与并发写入行的事务之间必有一方写冲突;
+func (s *KVService) LockTable(tableName string) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	return s.lockKeySpace(table)
+}
+
+// lockKeySpace 以删除的方式改写 Garbage_<keySpace>: 存储名仍在使用, 登记保持为空;
+// 登记依赖它的写入事务尚未结束或在当前事务之后提交时写冲突, 校验不会漏掉并发写入的行;
+func (s *KVService) lockKeySpace(table *types.Table) error {
+	return s.txn.Delete(GetGarbageKey(table.KeySpace))
+}
+
+// TruncateTable 清空表中的行与索引数据; 表结构、约束与自增序列保持不变;
+// 被其他表的外键引用时不允许清空;
+func (s *KVService) TruncateTable(tableName string) error {
+	table, err := s.MustGetTable(tableName)
+	if err != nil {
+		return err
+	}
+	references, err := referencingForeignKeys(s, tableName)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references {
+		if ref.child.Name != tableName {
+			return util.Error("[ForeignKey] can not truncate table %s because constraint %s on table %s depends on it", tableName, ref.foreignKey.Name, ref.child.Name)
+		}
+	}
+	if err = s.txn.Set(GetGarbageKey(table.KeySpace), []byte{1}); err != nil {
+		return err
+	}
+	if table.KeySpace, err = s.newKeySpace(table.Name); err != nil {
+		return err
+	}
+	return s.saveTable(table)
+}
+
+// newKeySpace 为表分配存储名: 优先使用表名, 已被其他表或待清理的旧数据占用时追加 #n;
+func (s *KVService) newKeySpace(tableName string) (string, error) {
+	used := make(map[string]bool)
+	for _, name := range s.GetTableNames() {
+		other, err := s.GetTable(name)
+		if err != nil {
+			return "", err
+		}
+		if other != nil {
+			used[other.KeySpace] = true
+		}
+	}
+	for _, keySpace := range s.GarbageKeySpaces() {
+		used[keySpace] = true
+	}
+	keySpace := tableName
+	for i := 1; used[keySpace]; i++ {
+		keySpace = fmt.Sprintf("%s#%d", tableName, i)
+	}
+	return keySpace, nil
+}
+
+// GarbageKeySpaces 返回待清理的旧存储名;
+func (s *KVService) GarbageKeySpaces() []string {
+	keySpaces := make([]string, 0)
+	for _, resultPair := range s.txn.ScanPrefix(GetGarbagePrefixKey(), true) {
+		keySpaces = append(keySpaces, string(resultPair.Key[len(Garbage_):]))
+	}
+	return keySpaces
+}
+
+// CollectGarbage 删除旧存储名下至多 limit 个行与索引 key; 全部删除后删除登记并返回 true;
+func (s *KVService) CollectGarbage(keySpace string, limit int) (bool, error) {
+	count := 0
+	for _, prefix := range [][]byte{GetPrefixRowKey(keySpace), GetIndexSpacePrefixKey(keySpace), GetReferencePrefixKey(keySpace)} {
+		for _, resultPair := range s.txn.ScanPrefix(prefix, true) {
+			if count == limit {
+				return false, nil
+			}
+			if err := s.txn.Delete(resultPair.Key); err != nil {
+				return false, err
+			}
+			count++
+		}
+	}
+	return true, s.txn.Delete(GetGarbageKey(keySpace))
+}
@@ -1,11 +1,19 @@
 package sql
 
-import "github.com/kebukeYi/TrainSQL/sql/types"
+import (
+	"encoding/binary"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+)
 
 var (
-	Table_ = "Table_"
-	Row_   = "Row_"
-	Index_ = "Index_"
+	Table_     = "Table_"
+	Row_       = "Row_"
+	Index_     = "Index_"
+	Meta_      = "Meta_"
+	Sequence_  = "Sequence_"
+	Garbage_   = "Garbage_"
+	View_      = "View_"
+	Reference_ = "Reference_"
 )
 
 func GetTableNameKey(tableName string) []byte {
@@ -17,22 +25,85 @@ func GetTableName(tableNameKey []byte) []byte {
 func GetTableNamePrefixKey() []byte {
 	return []byte(Table_)
 }
-func GetRowKey(tableName string, value types.Value) []byte {
-	buf := []byte(Row_)
-	buf = append(buf, tableName...)
-	buf = append(buf, value.Bytes()...) // Row_test1
-	return buf
+
+// GetRowKey Row_<table>\x00<enc(pk1)><enc(pk2)>...; 主键使用保序编码, 行数据按主键顺序存放;
+// 行与索引 key 中的 <table> 是表的存储名 Table.KeySpace, 表改名后保持不变;
+func GetRowKey(keySpace string, pk []types.Value) []byte {
+	return append(GetPrefixRowKey(keySpace), types.EncodeKey(pk...)...)
+}
+func GetPrefixRowKey(keySpace string) []byte {
+	// Row_user\x00 enc(id1) +version
+	// Row_user\x00 enc(id2) +version
+	// 表名之后的 \x00 避免 user 与 user1 的前缀互相包含;
+	return []byte(Row_ + keySpace + "\x00")
 }
-func GetPrefixRowKey(tableName string) []byte {
-	// Row+user+ id1 +version
-	// Row+user+ id2 +version
-	// Row+user+ id3 +version
-	return []byte(Row_ + tableName)
+
+// GetIndexKey Index_<table>\x00<index>\x00<索引列的保序编码>;
+// 表名与索引名不会包含 \x00, 因此不同索引之间的前缀互不重叠;
+func GetIndexKey(keySpace string, indexName string, values []types.Value) []byte {
+	buf := GetIndexPrefixKey(keySpace, indexName)
+	return append(buf, types.EncodeKey(values...)...)
+}
+
+// GetIndexEntryKey 每条索引项一个 key: Index_<table>\x00<index>\x00<enc(vals)><enc(pk)>;
+// 同一索引值下的主键按顺序排列, 用 GetIndexKey 作为前缀扫描即可取出;
+func GetIndexEntryKey(keySpace string, indexName string, values []types.Value, pk []types.Value) []byte {
+	return append(GetIndexKey(keySpace, indexName, values), types.EncodeKey(pk...)...)
 }
-func GetIndexKey(tableName string, colName string, value types.Value) []byte {
+func GetIndexPrefixKey(keySpace string, indexName string) []byte {
 	buf := []byte(Index_)
-	buf = append(buf, tableName...)
-	buf = append(buf, colName...)
-	buf = append(buf, value.Bytes()...)
+	buf = append(buf, keySpace...)
+	buf = append(buf, 0x00)
+	buf = append(buf, indexName...)
+	buf = append(buf, 0x00)
 	return buf
 }
+
+// GetIndexSpacePrefixKey 表的全部索引项: Index_<table>\x00;
+func GetIndexSpacePrefixKey(keySpace string) []byte {
+	return []byte(Index_ + keySpace + "\x00")
+}
+
+// GetReferenceKey 父行被引用的值: Reference_<table>\x00<enc(vals)>; 只用于外键检查与父行修改之间的写冲突, 不保存数据;
+// 不区分被引用的列, 不同外键的值编码相同时共用一个 key, 只会多出冲突, 不会漏掉;
+func GetReferenceKey(keySpace string, values []types.Value) []byte {
+	return append(GetReferencePrefixKey(keySpace), types.EncodeKey(values...)...)
+}
+func GetReferencePrefixKey(keySpace string) []byte {
+	return []byte(Reference_ + keySpace + "\x00")
+}
+
+// GetGarbageKey 待清理的旧存储名: Garbage_<keySpace>;
+func GetGarbageKey(keySpace string) []byte {
+	return []byte(Garbage_ + keySpace)
+}
+func GetGarbagePrefixKey() []byte {
+	return []byte(Garbage_)
+}
+
+// GetViewNameKey 视图定义: View_<name>, 与表共用同一个名字空间;
+func GetViewNameKey(viewName string) []byte {
+	return []byte(View_ + viewName)
+}
+func GetViewName(viewNameKey []byte) []byte {
+	return viewNameKey[len(View_):]
+}
+func GetViewNamePrefixKey() []byte {
+	return []byte(View_)
+}
+func GetMetaKey(name string) []byte {
+	return []byte(Meta_ + name)
+}
+
+// GetSequenceNameKey 序列定义: Sequence_<name>;
+func GetSequenceNameKey(sequenceName string) []byte {
+	return []byte(Sequence_ + sequenceName)
+}
+
+// GetSequenceCounterKey 序列计数器的 key, 由存储层在 MVCC 之外维护, 见 Transaction.NextSequence;
+func GetSequenceCounterKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte("seq_"), id)
+}
+
+// sequenceIdKey 分配序列计数器标识的计数器;
+var sequenceIdKey = []byte("seq_id")
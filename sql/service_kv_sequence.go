@@ -0,0 +1,166 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"github.com/kebukeYi/TrainSQL/storage"
+	"sync"
+)
+
+// 序列的定义保存在 Sequence_<name> 中, 随事务提交或回滚;
+// 已分配的值由存储层的计数器记录, 不经过 MVCC, 并发事务取值不会在同一个 key 上产生写冲突;
+// 事务回滚时已分配的值不会归还, 序列的值可能出现空洞;
+
+const sequenceCacheSize = 32 // 每次从计数器预分配的个数;
+
+// sequenceCache 按序列缓存预分配的一段值, 由同一个 ServerManager 开启的事务共享;
+// 重启后缓存中未使用的值被丢弃;
+type sequenceCache struct {
+	lock   sync.Mutex
+	ranges map[uint64]*sequenceRange
+}
+
+// sequenceRange 预分配的 [next, end);
+type sequenceRange struct {
+	next uint64
+	end  uint64
+}
+
+func newSequenceCache() *sequenceCache {
+	return &sequenceCache{ranges: make(map[uint64]*sequenceRange)}
+}
+
+// allocate 取出序列 id 的下一个计数值, 缓存用完时从计数器再预分配一段;
+func (c *sequenceCache) allocate(txn *storage.Transaction, id uint64) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	r := c.ranges[id]
+	if r == nil || r.next == r.end {
+		start := txn.NextSequence(GetSequenceCounterKey(id), sequenceCacheSize)
+		r = &sequenceRange{next: start, end: start + sequenceCacheSize}
+		c.ranges[id] = r
+	}
+	n := r.next
+	r.next++
+	return n
+}
+
+func (s *KVService) CreateSequence(sequence *types.Sequence) error {
+	if err := sequence.Validate(); err != nil {
+		return err
+	}
+	if exists, err := s.GetSequence(sequence.Name); err != nil {
+		return err
+	} else if exists != nil {
+		return util.Error("#CreateSequence sequence %s already exists", sequence.Name)
+	}
+	sequence.Id = s.txn.NextSequence(sequenceIdKey, 1)
+	return s.saveSequence(sequence)
+}
+
+// DropSequence 删除序列; AUTO_INCREMENT 列使用的序列随表或列一起删除;
+func (s *KVService) DropSequence(sequenceName string) error {
+	if sequence, err := s.GetSequence(sequenceName); err != nil {
+		return err
+	} else if sequence == nil {
+		return util.Error("#DropSequence sequence %s not exists", sequenceName)
+	}
+	for _, tableName := range s.GetTableNames() {
+		table, err := s.GetTable(tableName)
+		if err != nil {
+			return err
+		}
+		if table == nil {
+			continue
+		}
+		for _, column := range table.Columns {
+			if column.Sequence == sequenceName {
+				return util.Error("#DropSequence can not drop sequence %s because column %s.%s depends on it", sequenceName, table.Name, column.Name)
+			}
+		}
+	}
+	return s.txn.Delete(GetSequenceNameKey(sequenceName))
+}
+
+func (s *KVService) GetSequence(sequenceName string) (*types.Sequence, error) {
+	value := s.txn.Get(GetSequenceNameKey(sequenceName))
+	if value == nil {
+		return nil, nil
+	}
+	var sequence types.Sequence
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&sequence); err != nil {
+		return nil, util.Error("#GetSequence decode sequence error")
+	}
+	return &sequence, nil
+}
+
+func (s *KVService) saveSequence(sequence *types.Sequence) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(sequence); err != nil {
+		return util.Error("#saveSequence encode sequence error")
+	}
+	return s.txn.Set(GetSequenceNameKey(sequence.Name), buffer.Bytes())
+}
+
+// NextVal 取序列的下一个值, 并记为会话的 currval;
+func (s *KVService) NextVal(sequenceName string) (int64, error) {
+	sequence, err := s.GetSequence(sequenceName)
+	if err != nil {
+		return 0, err
+	}
+	if sequence == nil {
+		return 0, util.Error("#NextVal sequence %s not exists", sequenceName)
+	}
+	var n uint64
+	if s.sequences != nil {
+		n = s.sequences.allocate(s.txn, sequence.Id)
+	} else {
+		n = s.txn.NextSequence(GetSequenceCounterKey(sequence.Id), 1)
+	}
+	value := sequence.Value(n)
+	if s.currValues == nil {
+		s.currValues = make(map[string]int64)
+	}
+	s.currValues[sequenceName] = value
+	return value, nil
+}
+
+// CurrVal 返回当前会话中最近一次 nextval 取到的值;
+func (s *KVService) CurrVal(sequenceName string) (int64, error) {
+	value, ok := s.currValues[sequenceName]
+	if !ok {
+		return 0, util.Error("#CurrVal currval of sequence %s is not yet defined in this session", sequenceName)
+	}
+	return value, nil
+}
+
+// createColumnSequence 为 AUTO_INCREMENT 列创建序列; 序列名已被占用时追加序号;
+func (s *KVService) createColumnSequence(table *types.Table, column *types.ColumnV) error {
+	name := types.SequenceName(table.Name, column.Name)
+	column.Sequence = name
+	for i := 1; ; i++ {
+		if exists, err := s.GetSequence(column.Sequence); err != nil {
+			return err
+		} else if exists == nil {
+			break
+		}
+		column.Sequence = fmt.Sprintf("%s%d", name, i)
+	}
+	return s.CreateSequence(&types.Sequence{Name: column.Sequence, Start: 1, Increment: 1})
+}
+
+// dropColumnSequences 删除列使用的序列;
+func (s *KVService) dropColumnSequences(columns []types.ColumnV) error {
+	for _, column := range columns {
+		if column.Sequence == "" {
+			continue
+		}
+		if err := s.txn.Delete(GetSequenceNameKey(column.Sequence)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/kebukeYi/TrainSQL/sql/types"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+)
+
+// 视图的定义保存在 View_<name> 中, 与表共用同一个名字空间, 随事务提交或回滚;
+// 视图不保存数据, 查询时重新解析保存的语句, 在 FROM 中原地展开, 见 Plan.BuildFromItem;
+// 视图记录了 FROM 中引用的表或视图, 删除或改名被引用的表时据此检查依赖;
+// 物化视图的查询结果保存在隐藏表中, REFRESH 在一个事务中删除旧的隐藏表并写入新的结果;
+// 旧表的数据与 DROP TABLE 一样登记后清理, 提交之前其他事务按 MVCC 可见性仍读到旧的结果;
+
+// CreateView 创建视图; replace 为 true 时覆盖同名的视图;
+func (s *KVService) CreateView(view *types.View, replace bool) error {
+	if table, err := s.GetTable(view.Name); err != nil {
+		return err
+	} else if table != nil {
+		return util.Error("#CreateView table %s already exists", view.Name)
+	}
+	if exists, err := s.GetView(view.Name); err != nil {
+		return err
+	} else if exists != nil && !replace {
+		return util.Error("#CreateView view %s already exists", view.Name)
+	} else if exists != nil && exists.Materialized {
+		return util.Error("#CreateView %s is a materialized view", view.Name)
+	}
+	for _, name := range view.Tables {
+		// 替换视图时, 新的定义不能直接或间接地引用自己;
+		if name == view.Name {
+			return util.Error("#CreateView view %s can not reference itself", view.Name)
+		}
+		if table, err := s.GetTable(name); err != nil {
+			return err
+		} else if table != nil {
+			continue
+		}
+		dependency, err := s.GetView(name)
+		if err != nil {
+			return err
+		}
+		if dependency == nil {
+			return util.Error("#CreateView table %s not exists", name)
+		}
+		if referenced, err := s.viewReferences(dependency, view.Name); err != nil {
+			return err
+		} else if referenced {
+			return util.Error("#CreateView view %s can not reference itself", view.Name)
+		}
+	}
+	return s.saveView(view)
+}
+
+// viewReferences view 是否直接或间接地引用了 name;
+func (s *KVService) viewReferences(view *types.View, name string) (bool, error) {
+	if view.DependsOn(name) {
+		return true, nil
+	}
+	for _, dependency := range view.Tables {
+		other, err := s.GetView(dependency)
+		if err != nil {
+			return false, err
+		}
+		if other == nil {
+			continue
+		}
+		if referenced, err := s.viewReferences(other, name); err != nil || referenced {
+			return referenced, err
+		}
+	}
+	return false, nil
+}
+
+// DropView 删除视图; 被其他视图引用时, cascade 为 true 才一起删除;
+func (s *KVService) DropView(viewName string, cascade bool) error {
+	view, err := s.GetView(viewName)
+	if err != nil {
+		return err
+	} else if view == nil {
+		return util.Error("#DropView view %s not exists", viewName)
+	}
+	if err = s.dropDependentViews(viewName, cascade); err != nil {
+		return err
+	}
+	return s.deleteView(view)
+}
+
+// deleteView 删除视图的定义, 物化视图连同隐藏表一起删除;
+func (s *KVService) deleteView(view *types.View) error {
+	if view.Materialized {
+		if err := s.dropMaterializedTable(view.Name); err != nil {
+			return err
+		}
+	}
+	return s.txn.Delete(GetViewNameKey(view.Name))
+}
+
+// dropMaterializedTable 删除物化视图的隐藏表, 数据与 DROP TABLE 一样登记后清理;
+func (s *KVService) dropMaterializedTable(viewName string) error {
+	tableName := types.MaterializedTableName(viewName)
+	if table, err := s.GetTable(tableName); err != nil || table == nil {
+		return err
+	}
+	return s.DropTable(tableName, false)
+}
+
+// dropDependentViews 删除引用了 name 的视图, 以及引用这些视图的视图; cascade 为 false 时存在引用即报错;
+func (s *KVService) dropDependentViews(name string, cascade bool) error {
+	views, err := s.dependentViews(name)
+	if err != nil {
+		return err
+	}
+	for _, view := range views {
+		if !cascade {
+			return util.Error("[View] can not drop %s because view %s depends on it", name, view.Name)
+		}
+		if err = s.dropDependentViews(view.Name, true); err != nil {
+			return err
+		}
+		if err = s.deleteView(view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependentViews 直接引用了 name 的视图;
+func (s *KVService) dependentViews(name string) ([]*types.View, error) {
+	views := make([]*types.View, 0)
+	for _, viewName := range s.GetViewNames() {
+		view, err := s.GetView(viewName)
+		if err != nil {
+			return nil, err
+		}
+		if view != nil && view.DependsOn(name) {
+			views = append(views, view)
+		}
+	}
+	return views, nil
+}
+
+// MaterializeView 用查询结果重建物化视图的隐藏表; columns 为查询计划推断出的输出列, 见 Plan.outputColumns;
+func (s *KVService) MaterializeView(viewName string, columns []types.ColumnV, rows []types.Row) error {
+	tableName := types.MaterializedTableName(viewName)
+	table, err := materializedTable(tableName, columns)
+	if err != nil {
+		return err
+	}
+	if err = s.dropMaterializedTable(viewName); err != nil {
+		return err
+	}
+	if err = s.CreateTable(table); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		// 第一列为行号, 作为隐藏表的主键;
+		values := append(types.Row{&types.ConstInt{Value: int64(i)}}, row...)
+		if err = s.CreateRow(tableName, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializedTable 隐藏表的结构: 行号列作为主键, 之后是查询的输出列, 类型与排序规则与查询结果一致, 都可以为 NULL;
+func materializedTable(tableName string, columns []types.ColumnV) (*types.Table, error) {
+	table := &types.Table{Name: tableName}
+	table.Columns = append(table.Columns, types.ColumnV{Name: types.MaterializedRowColumn, DataType: types.Integer, PrimaryKey: true})
+	for _, column := range columns {
+		if table.GetColumnIndex(column.Name) != -1 {
+			return nil, util.Error("#MaterializeView column %s is duplicated, use an alias", column.Name)
+		}
+		column.Nullable = true
+		table.Columns = append(table.Columns, column)
+	}
+	return table, nil
+}
+
+func (s *KVService) GetView(viewName string) (*types.View, error) {
+	value := s.txn.Get(GetViewNameKey(viewName))
+	if value == nil {
+		return nil, nil
+	}
+	var view types.View
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&view); err != nil {
+		return nil, util.Error("#GetView decode view error")
+	}
+	return &view, nil
+}
+
+func (s *KVService) saveView(view *types.View) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(view); err != nil {
+		return util.Error("#saveView encode view error")
+	}
+	return s.txn.Set(GetViewNameKey(view.Name), buffer.Bytes())
+}
+
+func (s *KVService) GetViewNames() []string {
+	// 需要取出 value 才能过滤掉已删除的视图;
+	pairs := s.txn.ScanPrefix(GetViewNamePrefixKey(), true)
+	names := make([]string, 0)
+	for _, pair := range pairs {
+		names = append(names, string(GetViewName(pair.Key)))
+	}
+	return names
+}
@@ -3,12 +3,23 @@ package sql
 import (
 	"github.com/kebukeYi/TrainSQL/sql/types"
 	"github.com/kebukeYi/TrainSQL/sql/util"
+	"github.com/kebukeYi/TrainSQL/storage"
 	"strings"
 )
 
 type Session struct {
-	Server  *ServerManager
-	Service Service
+	Server     *ServerManager
+	Service    Service
+	Isolation  storage.IsolationLevel // 会话默认的隔离级别, 用于自动提交的语句与没有指定隔离级别的 BEGIN; 见 SetIsolationData;
+	currValues map[string]int64       // 会话中各序列最近一次 nextval 的值;
+}
+
+// begin 以指定的隔离级别开启事务, 事务中取到的序列值记录在会话中;
+func (s *Session) begin(level storage.IsolationLevel) Service {
+	if s.currValues == nil {
+		s.currValues = make(map[string]int64)
+	}
+	return s.Server.begin(s.currValues, level)
 }
 
 func (s *Session) Execute(sqlStr string) types.ResultSet {
@@ -26,23 +37,55 @@ func (s *Session) Execute(sqlStr string) types.ResultSet {
 					ErrorMessage: "Transaction already exists;",
 				}
 			} else {
-				txn := s.Server.Begin()
+				level := s.Isolation
+				if isolation := statement.(*BeginData).Isolation; isolation != nil {
+					level = *isolation
+				}
+				txn := s.begin(level)
 				s.Service = txn
 				version := txn.Version()
 				return &types.BeginResult{
 					Version: int(version),
 				}
 			}
+		case *SetIsolationData:
+			// 只影响之后开启的事务, 进行中的事务不变;
+			s.Isolation = statement.(*SetIsolationData).Isolation
+			return &types.SetResult{}
 		case *CommitData:
+			if s.Service == nil {
+				return &types.WarningResult{Message: "there is no transaction in progress;"}
+			}
 			version := s.Service.Version()
-			s.Service.Commit()
+			err := s.Service.Commit()
 			s.Service = nil
+			if err != nil {
+				return &types.ErrorResult{ErrorMessage: err.Error()}
+			}
+			s.collectGarbage()
 			return &types.CommitResult{Version: int(version)}
 		case *RollbackData:
+			if name := statement.(*RollbackData).Savepoint; name != "" {
+				return s.savepoint(name, "ROLLBACK TO SAVEPOINT", func(name string) error {
+					return s.Service.RollbackToSavepoint(name)
+				})
+			}
+			if s.Service == nil {
+				return &types.WarningResult{Message: "there is no transaction in progress;"}
+			}
 			version := s.Service.Version()
 			s.Service.Rollback()
 			s.Service = nil
 			return &types.RollbackResult{Version: int(version)}
+		case *SavepointData:
+			return s.savepoint(statement.(*SavepointData).Name, "SAVEPOINT", func(name string) error {
+				s.Service.SetSavepoint(name)
+				return nil
+			})
+		case *ReleaseSavepointData:
+			return s.savepoint(statement.(*ReleaseSavepointData).Name, "RELEASE SAVEPOINT", func(name string) error {
+				return s.Service.ReleaseSavepoint(name)
+			})
 		case *ExplainData:
 			sourceStatement := statement.(*ExplainData).Statements
 			if s.Service != nil {
@@ -73,6 +116,18 @@ func (s *Session) Execute(sqlStr string) types.ResultSet {
 					Plan: explain,
 				}
 			}
+		case *CreateIndexData:
+			// 显式事务中随事务一起提交, 否则在线分批构建;
+			if s.Service != nil {
+				return s.executeInTransaction(statement)
+			}
+			createIndexData := statement.(*CreateIndexData)
+			return s.Server.CreateIndex(createIndexData.TableName, &types.Index{
+				Name:        createIndexData.IndexName,
+				Columns:     createIndexData.Columns,
+				Expressions: createIndexData.Expressions,
+				Unique:      createIndexData.Unique,
+			})
 		case *ShowTableData:
 			showStatement := statement.(*ShowTableData)
 			table := s.GetTable(showStatement.TableName)
@@ -87,27 +142,31 @@ func (s *Session) Execute(sqlStr string) types.ResultSet {
 		default:
 			// 前面手动 begin 起来的事务;随后的sql会进入到这分支;
 			if s.Service != nil {
-				plan := NewPlan(statement, s.Service)
-				return plan.Execute()
-			} else {
-				// 没有手动启动 begin, 那么随后的每一条sql 都将会进入到这分支;
-				// 自动创建事务, 自动提交;
-				s.Service = s.Server.Begin()
-				plan := NewPlan(statement, s.Service)
-				resultSet := plan.Execute()
-				if resultSet != nil {
-					plan.Service.Commit()
-					s.Service = nil
-					return resultSet
-				} else {
-					s.Service = nil
-					plan.Service.Rollback()
-					s.Service = nil
+				return s.executeInTransaction(statement)
+			}
+			// 没有手动启动 begin, 那么随后的每一条sql 都将会进入到这分支;
+			// 自动创建事务, 语句成功时自动提交, 失败时回滚, 不留下执行了一半的写入;
+			s.Service = s.begin(s.Isolation)
+			plan := NewPlan(statement, s.Service)
+			resultSet := plan.Execute()
+			s.Service = nil
+			if failed(resultSet) {
+				plan.Service.Rollback()
+				if resultSet == nil {
 					return &types.ErrorResult{
 						ErrorMessage: "Execute sql error will rollback;",
 					}
 				}
+				return resultSet
+			}
+			if err := plan.Service.Commit(); err != nil {
+				return &types.ErrorResult{ErrorMessage: err.Error()}
 			}
+			switch statement.(type) {
+			case *TruncateTableData, *DropTableData, *DropViewData, *RefreshViewData:
+				s.collectGarbage()
+			}
+			return resultSet
 		}
 	} else {
 		return &types.ErrorResult{
@@ -116,14 +175,50 @@ func (s *Session) Execute(sqlStr string) types.ResultSet {
 	}
 }
 
+// savepoint 保存点只能在显式事务中使用; 失败时事务不受影响;
+func (s *Session) savepoint(name string, command string, action func(name string) error) types.ResultSet {
+	if s.Service == nil {
+		return &types.ErrorResult{ErrorMessage: command + " can only be used in transaction blocks;"}
+	}
+	if err := action(name); err != nil {
+		return &types.ErrorResult{ErrorMessage: err.Error()}
+	}
+	return &types.SavepointResult{Command: command, Name: name}
+}
+
+// executeInTransaction 在显式事务中执行一条语句; 读已提交的事务先刷新快照; 语句开始前记录保存点,
+// 失败时只撤销这条语句的写入, 事务中之前的写入保留, 事务可以继续;
+func (s *Session) executeInTransaction(statement Statement) types.ResultSet {
+	s.Service.Refresh()
+	savepoint := s.Service.Savepoint()
+	resultSet := NewPlan(statement, s.Service).Execute()
+	if failed(resultSet) {
+		s.Service.RollbackTo(savepoint)
+	}
+	return resultSet
+}
+
+// failed 语句执行失败: 返回错误, 或者没有返回结果;
+func failed(resultSet types.ResultSet) bool {
+	if resultSet == nil {
+		return true
+	}
+	_, ok := resultSet.(*types.ErrorResult)
+	return ok
+}
+
+// collectGarbage 提交之后清理 TRUNCATE / DROP TABLE 以及刷新物化视图留下的旧数据; 清理失败不影响语句的结果, 留到下一次;
+func (s *Session) collectGarbage() {
+	_ = s.Server.CollectGarbage()
+}
+
+// GetTable 显式事务中直接读取, 不结束事务; 否则开启只读事务读取后提交;
 func (s *Session) GetTable(tableName string) string {
 	if s.Service != nil {
 		table, err := s.Service.MustGetTable(tableName)
 		if err != nil {
-			s.Service.Rollback()
 			return err.Error()
 		}
-		s.Service.Commit()
 		return table.ToString()
 	} else {
 		service := s.Server.Begin()
@@ -140,16 +235,33 @@ func (s *Session) GetTable(tableName string) string {
 func (s *Session) ShowTableNames() string {
 	var names []string
 	if s.Service != nil {
-		names = s.Service.GetTableNames()
-		s.Service.Commit()
+		names = showTableNames(s.Service)
 	} else {
 		service := s.Server.Begin()
-		names = service.GetTableNames()
+		names = showTableNames(service)
 		service.Commit()
 	}
 	return util.Join(names, ",")
 }
 
+// showTableNames 表名之后列出视图, 视图名带有 (view) 或 (materialized view) 标记; 物化视图的隐藏表不列出;
+func showTableNames(service Service) []string {
+	names := make([]string, 0)
+	for _, tableName := range service.GetTableNames() {
+		if !types.IsHiddenTable(tableName) {
+			names = append(names, tableName)
+		}
+	}
+	for _, viewName := range service.GetViewNames() {
+		if view, _ := service.GetView(viewName); view != nil && view.Materialized {
+			names = append(names, viewName+"(materialized view)")
+		} else {
+			names = append(names, viewName+"(view)")
+		}
+	}
+	return names
+}
+
 func (s *Session) Explain(node Node) string {
 	var builder strings.Builder
 	node.FormatNode(&builder, "", true)
@@ -0,0 +1,176 @@
+package types
+
+import (
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"slices"
+)
+
+// 表结构变更不改写已有的行:
+// 每一列在建表或 ADD COLUMN 时分配一个 slot, 行按 slot 存储, slot 只增不减;
+// DROP COLUMN 只从表结构中删除列, 行中对应的 slot 作废, 不会再分配给新的列;
+// 读取时按当前的列定义从存储的行中取值, ADD COLUMN 之前写入的行没有新列的 slot, 取该列的 MissingValue;
+
+// InitLayout 为新建的表, 或者旧版本没有 slot 信息的表, 按列的位置分配 slot;
+func (t *Table) InitLayout() {
+	if t.KeySpace == "" {
+		t.KeySpace = t.Name
+	}
+	if t.NextSlot > 0 {
+		return
+	}
+	for i := range t.Columns {
+		t.Columns[i].Slot = i
+	}
+	t.NextSlot = len(t.Columns)
+}
+
+// ToStorageRow 将按列顺序排列的行转换为按 slot 排列的存储格式, 作废的 slot 写入 NULL;
+func (t *Table) ToStorageRow(row Row) Row {
+	stored := make(Row, t.NextSlot)
+	for i := range stored {
+		stored[i] = &ConstNull{}
+	}
+	for i, column := range t.Columns {
+		stored[column.Slot] = row[i]
+	}
+	return stored
+}
+
+// FromStorageRow 按当前的列定义从存储的行中取值;
+func (t *Table) FromStorageRow(stored Row) Row {
+	row := make(Row, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		if column.Slot < len(stored) {
+			row = append(row, stored[column.Slot])
+		} else if column.MissingValue != nil {
+			row = append(row, column.MissingValue)
+		} else {
+			row = append(row, &ConstNull{})
+		}
+	}
+	return row
+}
+
+// AddColumn 追加一列并分配新的 slot; 已有的行读取这一列时取添加时的默认值;
+func (t *Table) AddColumn(column ColumnV) error {
+	if t.GetColumnIndex(column.Name) != -1 {
+		return util.Error("[Table] %s column %s already exists", t.Name, column.Name)
+	}
+	// 隐式索引以列名命名, 新列不能与已有的索引重名;
+	if t.GetIndex(column.Name) != nil {
+		return util.Error("[Table] %s column name %s conflicts with index", t.Name, column.Name)
+	}
+	if column.PrimaryKey {
+		return util.Error("[Table] %s can not add primary key column %s", t.Name, column.Name)
+	}
+	if column.DefaultValue != nil {
+		value, err := column.CastValue(column.DefaultValue)
+		if err != nil {
+			return err
+		}
+		column.DefaultValue = value
+	}
+	column.Slot = t.NextSlot
+	column.MissingValue = column.DefaultValue
+	t.NextSlot++
+	t.Columns = append(t.Columns, column)
+	return nil
+}
+
+// DropColumn 删除列, 以及引用这一列的索引、外键与 CHECK 约束; 返回被删除的索引, 由调用方删除索引数据;
+func (t *Table) DropColumn(colName string) ([]Index, error) {
+	pos := t.GetColumnIndex(colName)
+	if pos == -1 {
+		return nil, util.Error("[Table] %s column %s not exists", t.Name, colName)
+	}
+	if t.Columns[pos].PrimaryKey {
+		return nil, util.Error("[Table] %s can not drop primary key column %s", t.Name, colName)
+	}
+	dropped := make([]Index, 0)
+	for _, index := range t.GetIndexes() {
+		if index.References(colName) {
+			dropped = append(dropped, index)
+			t.RemoveIndex(index.Name)
+		}
+	}
+	t.ForeignKeys = slices.DeleteFunc(t.ForeignKeys, func(foreignKey ForeignKey) bool {
+		return slices.Contains(foreignKey.Columns, colName)
+	})
+	t.Checks = slices.DeleteFunc(t.Checks, func(check Check) bool {
+		return slices.Contains(ExpressionFields(check.Expr), colName)
+	})
+	t.Columns = slices.Delete(t.Columns, pos, pos+1)
+	return dropped, nil
+}
+
+// RenameColumn 修改列名, 并同步索引、主键、外键与 CHECK 约束中的列名; 约束名保持不变;
+// 其他表引用这一列的外键由调用方修改;
+func (t *Table) RenameColumn(oldName string, newName string) error {
+	pos := t.GetColumnIndex(oldName)
+	if pos == -1 {
+		return util.Error("[Table] %s column %s not exists", t.Name, oldName)
+	}
+	if t.GetColumnIndex(newName) != -1 {
+		return util.Error("[Table] %s column %s already exists", t.Name, newName)
+	}
+	if t.GetIndex(newName) != nil {
+		return util.Error("[Table] %s column name %s conflicts with index", t.Name, newName)
+	}
+	t.Columns[pos].Name = newName
+	for i := range t.Indexes {
+		index := &t.Indexes[i]
+		for pos := range index.Columns {
+			// 表达式索引项修改表达式中的列名, 并重新生成表达式的文本;
+			if expr := index.Expression(pos); expr != nil {
+				renameExpressionField(expr, oldName, newName)
+				index.Columns[pos] = expr.ToString()
+			} else if index.Columns[pos] == oldName {
+				index.Columns[pos] = newName
+			}
+		}
+	}
+	RenameColumnOf(t.PrimaryKeys, oldName, newName)
+	for i := range t.ForeignKeys {
+		RenameColumnOf(t.ForeignKeys[i].Columns, oldName, newName)
+		if t.ForeignKeys[i].RefTable == t.Name {
+			RenameColumnOf(t.ForeignKeys[i].RefColumns, oldName, newName)
+		}
+	}
+	for i := range t.Checks {
+		renameExpressionField(t.Checks[i].Expr, oldName, newName)
+	}
+	return nil
+}
+
+// RenameColumnOf 将列名列表中的 oldName 替换为 newName;
+func RenameColumnOf(columns []string, oldName string, newName string) {
+	for i := range columns {
+		if columns[i] == oldName {
+			columns[i] = newName
+		}
+	}
+}
+
+// SetColumnDefault 修改列的默认值, value 为空时删除默认值; 只影响之后插入的行;
+func (t *Table) SetColumnDefault(colName string, value Value) error {
+	pos := t.GetColumnIndex(colName)
+	if pos == -1 {
+		return util.Error("[Table] %s column %s not exists", t.Name, colName)
+	}
+	t.Columns[pos].DefaultValue = value
+	return t.Validate()
+}
+
+// SetColumnNotNull 修改列是否允许为 NULL; 主键列始终不允许为 NULL;
+// 设置 NOT NULL 时由调用方先检查已有的行;
+func (t *Table) SetColumnNotNull(colName string, notNull bool) error {
+	pos := t.GetColumnIndex(colName)
+	if pos == -1 {
+		return util.Error("[Table] %s column %s not exists", t.Name, colName)
+	}
+	if t.Columns[pos].PrimaryKey && !notNull {
+		return util.Error("[Table] %s primary key column %s can not be nullable", t.Name, colName)
+	}
+	t.Columns[pos].Nullable = !notNull
+	return t.Validate()
+}
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"strings"
+	"unicode/utf8"
+)
+
+// 二进制类型 BLOB / BYTEA: 保存任意字节, 按字节序比较;
+// 字面量写作 X'DEADBEEF', 也可以写作 BLOB '...', 后者按字符串的原始字节保存, 字符串中可以使用 \xHH 等转义;
+// 查询结果中按 \x 加十六进制显示, 见 FormatValue;
+
+type ConstBlob struct {
+	Value []byte
+}
+
+func NewConstBlob(value []byte) *ConstBlob {
+	return &ConstBlob{Value: value}
+}
+
+// ParseHex 解析 X'...' 中的十六进制串, 字符个数必须为偶数;
+func ParseHex(s string) (*ConstBlob, error) {
+	value, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, util.Error("invalid hexadecimal data: X'%s'", s)
+	}
+	return &ConstBlob{Value: value}, nil
+}
+
+func (b *ConstBlob) Hash() uint32 {
+	return util.Hash(b.Value)
+}
+
+func (b *ConstBlob) Into() interface{} {
+	return b.Value
+}
+
+// Bytes 返回原始字节; 显示时使用 FormatValue;
+func (b *ConstBlob) Bytes() []byte {
+	return b.Value
+}
+
+func (b *ConstBlob) DateType() DataType {
+	return Blob
+}
+
+func (b *ConstBlob) PartialCmp(c Const) (bool, int) {
+	switch c.(type) {
+	case *ConstBlob:
+		return true, bytes.Compare(b.Value, c.(*ConstBlob).Value)
+	case *ConstNull:
+		return true, 1
+	default:
+		return false, 0
+	}
+}
+
+// String 以 \x 开头的十六进制形式;
+func (b *ConstBlob) String() string {
+	return `\x` + hex.EncodeToString(b.Value)
+}
+
+// FormatValue 查询结果中值的显示形式, 二进制按十六进制显示, 其余类型即 Bytes();
+func FormatValue(value Value) string {
+	if blob, ok := value.(*ConstBlob); ok {
+		return blob.String()
+	}
+	return string(value.Bytes())
+}
+
+// Length length(v): 字符串的字符个数, 二进制的字节数;
+func Length(value Value) (Value, error) {
+	switch v := value.(type) {
+	case *ConstNull:
+		return v, nil
+	case *ConstString:
+		return &ConstInt{Value: int64(utf8.RuneCountInString(v.Value))}, nil
+	case *ConstBlob:
+		return &ConstInt{Value: int64(len(v.Value))}, nil
+	}
+	return nil, util.Error("function length does not support %s", GetDataTypeInfo(value.DateType()))
+}
+
+// changeCase upper(s) / lower(s): 只接受字符串, 保留排序规则;
+func changeCase(value Value, convert func(string) string) (Value, error) {
+	switch v := value.(type) {
+	case *ConstNull:
+		return v, nil
+	case *ConstString:
+		return &ConstString{Value: convert(v.Value), Collation: v.Collation}, nil
+	}
+	return nil, util.Error("function upper/lower does not support %s", GetDataTypeInfo(value.DateType()))
+}
+
+// Substring substring(v, start[, count]): start 从 1 开始, 字符串按字符截取, 二进制按字节截取; count 为 nil 时截取到末尾;
+// 与 PostgreSQL 一致, start 小于 1 时范围仍从 start 算起, 只是不会越过开头;
+func Substring(value Value, start Value, count Value) (Value, error) {
+	if value.DateType() == Null || start.DateType() == Null || count != nil && count.DateType() == Null {
+		return &ConstNull{}, nil
+	}
+	from, ok := start.(*ConstInt)
+	if !ok {
+		return nil, util.Error("function substring start must be an integer")
+	}
+	var runes []rune
+	var units int64
+	switch v := value.(type) {
+	case *ConstString:
+		runes = []rune(v.Value)
+		units = int64(len(runes))
+	case *ConstBlob:
+		units = int64(len(v.Value))
+	default:
+		return nil, util.Error("function substring does not support %s", GetDataTypeInfo(value.DateType()))
+	}
+	lower, upper := from.Value-1, units
+	if count != nil {
+		n, ok := count.(*ConstInt)
+		if !ok {
+			return nil, util.Error("function substring count must be an integer")
+		}
+		if n.Value < 0 {
+			return nil, util.Error("negative substring length not allowed")
+		}
+		upper = min(upper, lower+n.Value)
+	}
+	lower = max(lower, 0)
+	if lower >= upper {
+		lower, upper = 0, 0
+	}
+	if value.DateType() == String {
+		return &ConstString{Value: string(runes[lower:upper])}, nil
+	}
+	return &ConstBlob{Value: bytes.Clone(value.(*ConstBlob).Value[lower:upper])}, nil
+}
+
+// blobLiteral X'...' 形式, 用于表达式的显示;
+func blobLiteral(b *ConstBlob) string {
+	return "X'" + strings.ToUpper(hex.EncodeToString(b.Value)) + "'"
+}
@@ -0,0 +1,309 @@
+package types
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math/big"
+)
+
+// Check CHECK 约束: 写入的行使 Expr 为 false 时拒绝, 为 true 或 NULL 时通过;
+type Check struct {
+	Name string
+	Expr *Expression
+}
+
+func (c *Check) ToString() string {
+	return fmt.Sprintf("%s CHECK (%s)", c.Name, c.Expr.ToString())
+}
+
+// CheckConstraintName CHECK 约束名: <table>_<首个引用列>_check, 不引用列时为 <table>_check;
+func CheckConstraintName(tableName string, expr *Expression) string {
+	if fields := ExpressionFields(expr); len(fields) > 0 {
+		return tableName + "_" + fields[0] + "_check"
+	}
+	return tableName + "_check"
+}
+
+// EvaluateCheck 对一行数据计算 CHECK 表达式; 比较运算的任一侧为 NULL 时结果为 NULL(未知),
+// 与 WHERE 中 NULL 最小的比较规则不同, 保证含 NULL 的行能够通过约束;
+func EvaluateCheck(expr *Expression, cols []string, row Row) (Value, error) {
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		return evaluateCheckLogic(operation.Left, operation.Right, operation, cols, row)
+	case *OperationOr:
+		return evaluateCheckLogic(operation.Left, operation.Right, operation, cols, row)
+	case nil:
+		return EvaluateExpr(expr, cols, row, cols, row)
+	}
+	left, right := compareOperands(expr.OperationVal)
+	if left == nil {
+		return EvaluateExpr(expr, cols, row, cols, row)
+	}
+	for _, side := range []*Expression{left, right} {
+		value, err := EvaluateCheck(side, cols, row)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil || value.DateType() == Null {
+			return &ConstNull{}, nil
+		}
+	}
+	return EvaluateExpr(expr, cols, row, cols, row)
+}
+
+func evaluateCheckLogic(left, right *Expression, operation Operation, cols []string, row Row) (Value, error) {
+	lv, err := EvaluateCheck(left, cols, row)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := EvaluateCheck(right, cols, row)
+	if err != nil {
+		return nil, err
+	}
+	return OperationLogicValue(lv, rv, operation)
+}
+
+// validateCheck 建表时校验 CHECK 表达式: 引用的列必须存在, 比较两侧的类型必须兼容, 结果必须是布尔值;
+func (t *Table) validateCheck(check *Check) error {
+	// d > '2024-01-31': 与列比较的常量先转换为列的类型;
+	if err := t.CoerceComparisons(check.Expr); err != nil {
+		return util.Error("[Table] %s check constraint %s: %s", t.Name, check.Name, err)
+	}
+	dataType, err := t.checkExprType(check.Expr)
+	if err != nil {
+		return util.Error("[Table] %s check constraint %s: %s", t.Name, check.Name, err)
+	}
+	if dataType != Boolean && dataType != Null {
+		return util.Error("[Table] %s check constraint %s must be a boolean expression", t.Name, check.Name)
+	}
+	return nil
+}
+
+func (t *Table) checkExprType(expr *Expression) (DataType, error) {
+	return t.exprType(expr, true)
+}
+
+// ExprType 推算表达式在表上的结果类型, 与 CHECK 约束的类型检查相同, 但允许 now() 等结果不稳定的函数;
+// 物化视图据此由查询的投影推断隐藏表的列类型;
+func (t *Table) ExprType(expr *Expression) (DataType, error) {
+	return t.exprType(expr, false)
+}
+
+// exprType stable 为 true 时只允许结果稳定的函数;
+func (t *Table) exprType(expr *Expression, stable bool) (DataType, error) {
+	if expr.Field != "" {
+		pos := t.GetColumnIndex(expr.Field)
+		if pos == -1 {
+			return 0, util.Error("column %s not exists", expr.Field)
+		}
+		return t.Columns[pos].DataType, nil
+	}
+	if expr.ConstVal != nil {
+		return expr.ConstVal.DateType(), nil
+	}
+	if expr.Function != nil {
+		return t.checkFunctionType(expr.Function, stable)
+	}
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		return t.checkLogicType(operation.Left, operation.Right, stable)
+	case *OperationOr:
+		return t.checkLogicType(operation.Left, operation.Right, stable)
+	case *OperationArith:
+		return t.checkArithType(operation, stable)
+	case nil:
+		return 0, util.Error("empty expression")
+	}
+	left, right := compareOperands(expr.OperationVal)
+	if left == nil {
+		return 0, util.Error("unsupported operation in (%s)", expr.ToString())
+	}
+	lt, err := t.exprType(left, stable)
+	if err != nil {
+		return 0, err
+	}
+	rt, err := t.exprType(right, stable)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := CommonType(lt, rt); !ok {
+		return 0, util.Error("can not compare %s with %s in (%s)", GetDataTypeInfo(lt), GetDataTypeInfo(rt), expr.ToString())
+	}
+	return Boolean, nil
+}
+
+// checkArithType 用两侧类型的样本值试算一次, 得到运算结果的类型;
+func (t *Table) checkArithType(arith *OperationArith, stable bool) (DataType, error) {
+	lt, err := t.exprType(arith.Left, stable)
+	if err != nil {
+		return 0, err
+	}
+	rt, err := t.exprType(arith.Right, stable)
+	if err != nil {
+		return 0, err
+	}
+	value, err := ArithValue(arith.Operator, sampleValue(lt), sampleValue(rt))
+	if err != nil {
+		return 0, err
+	}
+	return value.DateType(), nil
+}
+
+// checkFunctionType 只允许标量函数, stable 为 true 时还要求结果稳定; 常量参数按原值、列参数按样本值推算结果类型;
+func (t *Table) checkFunctionType(function *Function, stable bool) (DataType, error) {
+	if !IsScalarFunction(function.FuncName) || stable && IsVolatileFunction(function.FuncName) {
+		return 0, util.Error("function %s is not allowed", function.FuncName)
+	}
+	args := make([]Value, 0, len(function.Args))
+	for _, arg := range function.Args {
+		if arg.ConstVal != nil {
+			args = append(args, arg.ConstVal)
+			continue
+		}
+		dataType, err := t.exprType(arg, stable)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, sampleValue(dataType))
+	}
+	return ScalarResultType(function.FuncName, args)
+}
+
+// sampleValue 类型检查时代表该类型的值, 数值取 1 避免除零;
+func sampleValue(dataType DataType) Value {
+	switch dataType {
+	case Boolean:
+		return &ConstBool{}
+	case Integer:
+		return &ConstInt{Value: 1}
+	case Float:
+		return &ConstFloat{Value: 1}
+	case Decimal:
+		return &ConstDecimal{Unscaled: big.NewInt(1)}
+	case String:
+		return &ConstString{}
+	case Date:
+		return &ConstDate{}
+	case Time:
+		return &ConstTime{}
+	case Timestamp:
+		return &ConstTimestamp{}
+	case Interval:
+		return &ConstInterval{}
+	case Blob:
+		return &ConstBlob{}
+	case Json:
+		return &ConstJson{Value: "null"}
+	default:
+		return &ConstNull{}
+	}
+}
+
+func (t *Table) checkLogicType(left, right *Expression, stable bool) (DataType, error) {
+	for _, side := range []*Expression{left, right} {
+		dataType, err := t.exprType(side, stable)
+		if err != nil {
+			return 0, err
+		}
+		if dataType != Boolean && dataType != Null {
+			return 0, util.Error("(%s) is not a boolean expression", side.ToString())
+		}
+	}
+	return Boolean, nil
+}
+
+// compareOperands 取出比较运算的左右两侧; 算术运算同样返回两侧, 供遍历表达式使用;
+func compareOperands(operation Operation) (*Expression, *Expression) {
+	switch o := operation.(type) {
+	case *OperationArith:
+		return o.Left, o.Right
+	case *OperationEqual:
+		return o.Left, o.Right
+	case *OperationGreaterThan:
+		return o.Left, o.Right
+	case *OperationLessThan:
+		return o.Left, o.Right
+	case *OperationGreaterEqual:
+		return o.Left, o.Right
+	case *OperationLessEqual:
+		return o.Left, o.Right
+	}
+	return nil, nil
+}
+
+// ExpressionFields 按出现顺序返回表达式引用的列;
+func ExpressionFields(expr *Expression) []string {
+	if expr == nil {
+		return nil
+	}
+	if expr.Field != "" {
+		return []string{expr.Field}
+	}
+	if expr.Function != nil {
+		fields := make([]string, 0)
+		for _, arg := range expr.Function.Args {
+			fields = append(fields, ExpressionFields(arg)...)
+		}
+		return fields
+	}
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		return append(ExpressionFields(operation.Left), ExpressionFields(operation.Right)...)
+	case *OperationOr:
+		return append(ExpressionFields(operation.Left), ExpressionFields(operation.Right)...)
+	}
+	left, right := compareOperands(expr.OperationVal)
+	return append(ExpressionFields(left), ExpressionFields(right)...)
+}
+
+// ExpressionFunctions 按出现顺序返回表达式调用的函数名, 包括参数中嵌套的调用;
+func ExpressionFunctions(expr *Expression) []string {
+	if expr == nil {
+		return nil
+	}
+	if expr.Function != nil {
+		names := []string{expr.Function.FuncName}
+		for _, arg := range expr.Function.Args {
+			names = append(names, ExpressionFunctions(arg)...)
+		}
+		return names
+	}
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		return append(ExpressionFunctions(operation.Left), ExpressionFunctions(operation.Right)...)
+	case *OperationOr:
+		return append(ExpressionFunctions(operation.Left), ExpressionFunctions(operation.Right)...)
+	}
+	left, right := compareOperands(expr.OperationVal)
+	return append(ExpressionFunctions(left), ExpressionFunctions(right)...)
+}
+
+// renameExpressionField 将表达式中引用的列 oldName 改为 newName;
+func renameExpressionField(expr *Expression, oldName string, newName string) {
+	if expr == nil {
+		return
+	}
+	if expr.Field == oldName {
+		expr.Field = newName
+		return
+	}
+	if expr.Function != nil {
+		for _, arg := range expr.Function.Args {
+			renameExpressionField(arg, oldName, newName)
+		}
+		return
+	}
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		renameExpressionField(operation.Left, oldName, newName)
+		renameExpressionField(operation.Right, oldName, newName)
+		return
+	case *OperationOr:
+		renameExpressionField(operation.Left, oldName, newName)
+		renameExpressionField(operation.Right, oldName, newName)
+		return
+	}
+	left, right := compareOperands(expr.OperationVal)
+	renameExpressionField(left, oldName, newName)
+	renameExpressionField(right, oldName, newName)
+}
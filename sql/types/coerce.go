@@ -0,0 +1,340 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 类型转换规则:
+// 隐式转换: 写入列、比较与算术运算时自动进行, 不会丢失信息;
+//   数值沿 Integer → Float → Decimal 提升; 字符串常量可以转换为 DATE、TIME、TIMESTAMP、INTERVAL 与 JSON; DATE 可以提升为 TIMESTAMP;
+// 显式转换: CAST(expr AS type), 支持更多的组合; 会丢失信息的转换报错, 例如 CAST(1.5 AS INTEGER), 而不是悄悄截断;
+
+// numericRank 数值类型在提升链中的位置, 不是数值类型时为 0;
+func numericRank(dataType DataType) int {
+	switch dataType {
+	case Integer:
+		return 1
+	case Float:
+		return 2
+	case Decimal:
+		return 3
+	}
+	return 0
+}
+
+// CommonType 比较与算术运算时两侧提升后的公共类型; 不能隐式转换时返回 false;
+func CommonType(l, r DataType) (DataType, bool) {
+	switch {
+	case l == r || r == Null:
+		return l, true
+	case l == Null:
+		return r, true
+	case numericRank(l) > 0 && numericRank(r) > 0:
+		if numericRank(l) > numericRank(r) {
+			return l, true
+		}
+		return r, true
+	case l == Date && r == Timestamp || l == Timestamp && r == Date:
+		return Timestamp, true
+	}
+	return 0, false
+}
+
+// CoerceValue 按隐式转换规则把值转换为 target 类型; 不能隐式转换时原样返回, 由调用方校验类型;
+// 字符串的内容不符合目标类型的格式时报错;
+func CoerceValue(value Value, target DataType) (Value, error) {
+	if value.DateType() == target || value.DateType() == Null {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case *ConstInt:
+		switch target {
+		case Float:
+			return &ConstFloat{Value: float64(v.Value)}, nil
+		case Decimal:
+			decimal, _ := ToDecimal(v)
+			return decimal, nil
+		}
+	case *ConstFloat:
+		if decimal, ok := ToDecimal(v); ok && target == Decimal {
+			return decimal, nil
+		}
+	case *ConstDate:
+		if target == Timestamp {
+			return v.Timestamp(), nil
+		}
+	case *ConstString:
+		if target == Date || target == Time || target == Timestamp || target == Interval || target == Json {
+			return parseString(v.Value, target)
+		}
+	}
+	return value, nil
+}
+
+// parseString 按目标类型的字面量格式解析字符串;
+func parseString(s string, target DataType) (Value, error) {
+	switch target {
+	case Integer:
+		value, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, util.Error("invalid input syntax for type integer: '%s'", s)
+		}
+		return &ConstInt{Value: value}, nil
+	case Float:
+		value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, util.Error("invalid input syntax for type float: '%s'", s)
+		}
+		return &ConstFloat{Value: value}, nil
+	case Decimal:
+		return ParseDecimal(s)
+	case Boolean:
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true", "t", "yes", "1":
+			return &ConstBool{Value: true}, nil
+		case "false", "f", "no", "0":
+			return &ConstBool{Value: false}, nil
+		}
+		return nil, util.Error("invalid input syntax for type boolean: '%s'", s)
+	case Date:
+		return ParseDate(s)
+	case Time:
+		return ParseTime(s)
+	case Timestamp:
+		return ParseTimestamp(s)
+	case Interval:
+		return ParseInterval(s)
+	case Blob:
+		return &ConstBlob{Value: []byte(s)}, nil
+	case Json:
+		return ParseJson(s)
+	}
+	return nil, util.Error("cannot cast type String to %s", GetDataTypeInfo(target))
+}
+
+// CoerceComparisons 比较条件中与列比较的常量按列的类型隐式转换, 生成执行计划与校验 CHECK 约束时调用;
+// 例如 d > '2024-01-31' 中的字符串转换为日期, 格式不对时报错; JSON 列上的字符串按 JSON 标量比较, 不做转换;
+func (t *Table) CoerceComparisons(expr *Expression) error {
+	if expr == nil {
+		return nil
+	}
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		if err := t.CoerceComparisons(operation.Left); err != nil {
+			return err
+		}
+		return t.CoerceComparisons(operation.Right)
+	case *OperationOr:
+		if err := t.CoerceComparisons(operation.Left); err != nil {
+			return err
+		}
+		return t.CoerceComparisons(operation.Right)
+	case *OperationArith:
+		return nil
+	}
+	left, right := compareOperands(expr.OperationVal)
+	if left == nil {
+		return nil
+	}
+	if err := t.coerceOperand(left, right); err != nil {
+		return err
+	}
+	return t.coerceOperand(right, left)
+}
+
+// coerceOperand field 为本表的列、constant 为常量时, 常量转换为列的类型;
+func (t *Table) coerceOperand(field, constant *Expression) error {
+	if field.Field == "" || constant.ConstVal == nil {
+		return nil
+	}
+	pos := t.GetColumnIndex(field.Field)
+	if pos == -1 || t.Columns[pos].DataType == Json {
+		return nil
+	}
+	value, err := CoerceValue(constant.ConstVal, t.Columns[pos].DataType)
+	if err != nil {
+		return err
+	}
+	constant.ConstVal = value
+	return nil
+}
+
+// CastFunction CAST(v AS type) 对应的标量函数名;
+const CastFunction = "cast"
+
+// castTarget cast 函数的第二个参数是目标类型的名字;
+func castTarget(arg Value) (ColumnV, error) {
+	name, ok := arg.(*ConstString)
+	if !ok {
+		return ColumnV{}, util.Error("cast target type must be a type name")
+	}
+	return ParseCastType(name.Value)
+}
+
+// CastTypeName CAST 目标类型的名字, 与列类型的显示一致: Integer, Decimal(10, 2), String(5);
+func CastTypeName(target ColumnV) string {
+	switch target.DataType {
+	case Decimal:
+		return DecimalTypeName(target.Precision, target.Scale)
+	case String:
+		return StringTypeName(target.Length)
+	}
+	return GetDataTypeInfo(target.DataType)
+}
+
+// ParseCastType 还原 CastTypeName 的结果;
+func ParseCastType(name string) (ColumnV, error) {
+	base, params, _ := strings.Cut(name, "(")
+	for dataType := Boolean; dataType <= Json; dataType++ {
+		if dataType == Null || GetDataTypeInfo(dataType) != base {
+			continue
+		}
+		target := ColumnV{DataType: dataType}
+		if params == "" {
+			return target, nil
+		}
+		switch dataType {
+		case Decimal:
+			if _, err := fmt.Sscanf(params, "%d, %d)", &target.Precision, &target.Scale); err == nil {
+				return target, nil
+			}
+		case String:
+			if _, err := fmt.Sscanf(params, "%d)", &target.Length); err == nil {
+				return target, nil
+			}
+		}
+	}
+	return ColumnV{}, util.Error("cast target type %s is not supported", name)
+}
+
+// CastTo 显式转换 CAST(value AS type); 会丢失信息的转换报错, 包括超出 DECIMAL(p, s) 的小数位数与 VARCHAR(n) 的长度;
+func CastTo(value Value, target ColumnV) (Value, error) {
+	if value.DateType() == Null {
+		return value, nil
+	}
+	result, err := castTo(value, target.DataType)
+	if err != nil {
+		return nil, err
+	}
+	switch target.DataType {
+	case Decimal:
+		decimal := result.(*ConstDecimal)
+		fitted, err := decimal.Fit(target.Precision, target.Scale)
+		if err != nil {
+			return nil, err
+		}
+		if _, cmp := fitted.PartialCmp(decimal); cmp != 0 {
+			return nil, lossyCast(value, CastTypeName(target))
+		}
+		return fitted, nil
+	case String:
+		if target.Length > 0 && int32(utf8.RuneCountInString(result.(*ConstString).Value)) > target.Length {
+			return nil, util.Error("value too long for type %s", StringTypeName(target.Length))
+		}
+	}
+	return result, nil
+}
+
+func lossyCast(value Value, typeName string) error {
+	return util.Error("cannot cast %s to %s without losing information", FormatValue(value), typeName)
+}
+
+// castTo 转换为 target 类型, 不考虑精度与长度;
+func castTo(value Value, target DataType) (Value, error) {
+	if value.DateType() == target {
+		return value, nil
+	}
+	if text, ok := value.(*ConstString); ok {
+		return parseString(text.Value, target)
+	}
+	// JSON 标量按对应的 SQL 值转换: 数字按定点数, 字符串去掉引号;
+	if doc, ok := value.(*ConstJson); ok && target != String {
+		switch document := doc.document().(type) {
+		case string:
+			return parseString(document, target)
+		case bool:
+			return castTo(&ConstBool{Value: document}, target)
+		case json.Number:
+			return castTo(jsonNumber(document), target)
+		}
+	}
+	switch target {
+	case String:
+		if blob, ok := value.(*ConstBlob); ok {
+			if !utf8.Valid(blob.Value) {
+				return nil, util.Error("cannot cast %s to String: invalid UTF-8", blob.String())
+			}
+			return &ConstString{Value: string(blob.Value)}, nil
+		}
+		return &ConstString{Value: string(value.Bytes())}, nil
+	case Integer:
+		switch v := value.(type) {
+		case *ConstBool:
+			if v.Value {
+				return &ConstInt{Value: 1}, nil
+			}
+			return &ConstInt{Value: 0}, nil
+		case *ConstFloat:
+			if v.Value != math.Trunc(v.Value) || v.Value < math.MinInt64 || v.Value >= math.MaxInt64 {
+				return nil, lossyCast(value, GetDataTypeInfo(target))
+			}
+			return &ConstInt{Value: int64(v.Value)}, nil
+		case *ConstDecimal:
+			integer := v.Rescale(0)
+			if _, cmp := integer.PartialCmp(v); cmp != 0 || !integer.Unscaled.IsInt64() {
+				return nil, lossyCast(value, GetDataTypeInfo(target))
+			}
+			return &ConstInt{Value: integer.Unscaled.Int64()}, nil
+		}
+	case Float:
+		switch v := value.(type) {
+		case *ConstInt:
+			if f := float64(v.Value); f >= math.MaxInt64 || int64(f) != v.Value {
+				return nil, lossyCast(value, GetDataTypeInfo(target))
+			}
+			return &ConstFloat{Value: float64(v.Value)}, nil
+		case *ConstDecimal:
+			// 按最短的十进制形式往返一次, 不相等说明浮点数表示不了这个值;
+			f, _ := strconv.ParseFloat(v.String(), 64)
+			if back, ok := ToDecimal(&ConstFloat{Value: f}); !ok || !decimalEqual(back, v) {
+				return nil, lossyCast(value, GetDataTypeInfo(target))
+			}
+			return &ConstFloat{Value: f}, nil
+		}
+	case Decimal:
+		if decimal, ok := ToDecimal(value); ok {
+			return decimal, nil
+		}
+	case Boolean:
+		if v, ok := value.(*ConstInt); ok && (v.Value == 0 || v.Value == 1) {
+			return &ConstBool{Value: v.Value == 1}, nil
+		}
+	case Date:
+		if v, ok := value.(*ConstTimestamp); ok {
+			if v.Value%microsPerDay != 0 {
+				return nil, lossyCast(value, GetDataTypeInfo(target))
+			}
+			return &ConstDate{Value: floorDiv(v.Value, microsPerDay)}, nil
+		}
+	case Timestamp:
+		if v, ok := value.(*ConstDate); ok {
+			return v.Timestamp(), nil
+		}
+	case Json:
+		if doc, ok := ToJson(value); ok {
+			return doc, nil
+		}
+	}
+	return nil, util.Error("cannot cast type %s to %s", GetDataTypeInfo(value.DateType()), GetDataTypeInfo(target))
+}
+
+func decimalEqual(l, r *ConstDecimal) bool {
+	_, cmp := l.PartialCmp(r)
+	return cmp == 0
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"strings"
+	"unicode/utf8"
+)
+
+// 字符串列的长度限制与排序规则:
+// VARCHAR(n) / CHAR(n) 限制最多 n 个字符, 超出时报错, CHAR(n) 不补齐空格;
+// COLLATE binary 按字节比较 (默认), COLLATE nocase 比较、分组与索引编码时不区分大小写;
+// nocase 列写入的值带有排序规则, 与其他字符串比较时只要一侧不区分大小写, 就按不区分大小写比较;
+
+const (
+	CollateBinary = "binary"
+	CollateNocase = "nocase"
+)
+
+// ParseCollation 排序规则的名字不区分大小写, 返回规范的名字;
+func ParseCollation(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case CollateBinary:
+		return CollateBinary, nil
+	case CollateNocase:
+		return CollateNocase, nil
+	}
+	return "", util.Error("collation \"%s\" is not supported, use %s or %s", name, CollateBinary, CollateNocase)
+}
+
+// StringTypeName 列类型的显示名: String, String(n);
+func StringTypeName(length int32) string {
+	if length == 0 {
+		return "String"
+	}
+	return fmt.Sprintf("String(%d)", length)
+}
+
+// foldCase 不区分大小写时比较、哈希与编码使用的形式;
+func foldCase(s string) string {
+	return strings.ToLower(s)
+}
+
+func (s *ConstString) caseInsensitive() bool {
+	return s.Collation == CollateNocase
+}
+
+// collationKey 按排序规则参与比较、哈希与编码的字节;
+func (s *ConstString) collationKey() string {
+	if s.caseInsensitive() {
+		return foldCase(s.Value)
+	}
+	return s.Value
+}
+
+// castString 校验字符串的长度, 并带上列的排序规则;
+func (c *ColumnV) castString(value Value) (Value, error) {
+	text, ok := value.(*ConstString)
+	if !ok {
+		return value, nil
+	}
+	if c.Length > 0 && int32(utf8.RuneCountInString(text.Value)) > c.Length {
+		return nil, util.Error("[Table] column %s value too long for type %s", c.Name, StringTypeName(c.Length))
+	}
+	if c.Collation == CollateNocase && !text.caseInsensitive() {
+		return &ConstString{Value: text.Value, Collation: c.Collation}, nil
+	}
+	return value, nil
+}
+
+// WithCollation 查询条件中的常量按列的排序规则编码, 以便与索引中的编码一致;
+func (c *ColumnV) WithCollation(value Value) Value {
+	if text, ok := value.(*ConstString); ok && c.Collation == CollateNocase && !text.caseInsensitive() {
+		return &ConstString{Value: text.Value, Collation: c.Collation}
+	}
+	return value
+}
@@ -87,3 +87,71 @@ func TestConstHash(t *testing.T) {
 	assert.Equal(t, NewConstString("string").Hash(), NewConstString("string").Hash())
 	assert.Equal(t, NewConstNull().Hash(), NewConstNull().Hash())
 }
+
+func TestCommonType(t *testing.T) {
+	for _, c := range []struct {
+		l, r   DataType
+		common DataType
+		ok     bool
+	}{
+		{Integer, Float, Float, true},
+		{Decimal, Integer, Decimal, true},
+		{Float, Decimal, Decimal, true},
+		{Date, Timestamp, Timestamp, true},
+		{Null, String, String, true},
+		{String, Integer, 0, false},
+		{Boolean, Integer, 0, false},
+	} {
+		common, ok := CommonType(c.l, c.r)
+		assert.Equal(t, c.ok, ok, "%v %v", c.l, c.r)
+		assert.Equal(t, c.common, common, "%v %v", c.l, c.r)
+	}
+}
+
+func TestCastTo(t *testing.T) {
+	cast := func(value Value, typeName string) (string, error) {
+		target, err := ParseCastType(typeName)
+		if err != nil {
+			return "", err
+		}
+		result, err := CastTo(value, target)
+		if err != nil {
+			return "", err
+		}
+		return FormatValue(result), nil
+	}
+	for _, c := range []struct {
+		value    Value
+		typeName string
+		expect   string
+	}{
+		{NewConstString(" 12 "), "Integer", "12"},
+		{NewConstFloat(2), "Integer", "2"},
+		{NewConstInt(3), "Decimal(5, 2)", "3.00"},
+		{NewConstFloat(1.5), "Decimal(3, 1)", "1.5"},
+		{NewConstInt(42), "String(2)", "42"},
+		{NewConstString("2024-01-31"), "Timestamp", "2024-01-31 00:00:00"},
+		{NewConstBool(true), "Integer", "1"},
+		{NewConstNull(), "Integer", "null"},
+	} {
+		result, err := cast(c.value, c.typeName)
+		assert.NoError(t, err, "%s as %s", FormatValue(c.value), c.typeName)
+		assert.Equal(t, c.expect, result)
+	}
+	for _, c := range []struct {
+		value    Value
+		typeName string
+		message  string
+	}{
+		{NewConstFloat(1.5), "Integer", "without losing information"},
+		{NewConstFloat(1.25), "Decimal(3, 1)", "without losing information"},
+		{NewConstString("abc"), "Integer", "invalid input syntax"},
+		{NewConstInt(123), "String(2)", "value too long"},
+		{NewConstInt(2), "Boolean", "cannot cast type Integer to Boolean"},
+	} {
+		_, err := cast(c.value, c.typeName)
+		if assert.Error(t, err, "%s as %s", FormatValue(c.value), c.typeName) {
+			assert.Contains(t, err.Error(), c.message)
+		}
+	}
+}
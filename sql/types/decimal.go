@@ -0,0 +1,231 @@
+package types
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 定点数 DECIMAL(p, s): 值为 Unscaled * 10^-Scale, 用任意精度整数保存, 运算没有浮点误差;
+// 写入列时按列的小数位数 s 四舍五入(远离零), 整数部分超过 p - s 位时报错;
+// 与整数运算时整数视为小数位数为 0 的定点数; 与浮点数运算或比较时, 浮点数按最短的十进制表示转换为定点数;
+// 加减的结果取两侧较大的小数位数, 乘法为两侧之和, 除法至少保留 DecimalDivScale 位;
+
+const (
+	DecimalMaxPrecision = 1000 // DECIMAL(p, s) 中 p 的上限;
+	DecimalDivScale     = 6    // 除法结果至少保留的小数位数;
+)
+
+type ConstDecimal struct {
+	Unscaled *big.Int
+	Scale    int32
+}
+
+func NewConstDecimal(unscaled *big.Int, scale int32) *ConstDecimal {
+	return &ConstDecimal{Unscaled: unscaled, Scale: scale}
+}
+
+// ParseDecimal 解析十进制数: [+-]digits[.digits];
+func ParseDecimal(s string) (*ConstDecimal, error) {
+	text := strings.TrimSpace(s)
+	digits := strings.TrimLeft(text, "+-")
+	if len(text)-len(digits) > 1 || digits == "" || strings.HasPrefix(digits, ".") && len(digits) == 1 {
+		return nil, util.Error("invalid input syntax for type decimal: '%s'", s)
+	}
+	scale := int32(0)
+	if dot := strings.IndexByte(digits, '.'); dot != -1 {
+		scale = int32(len(digits) - dot - 1)
+		digits = digits[:dot] + digits[dot+1:]
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return nil, util.Error("invalid input syntax for type decimal: '%s'", s)
+		}
+	}
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(text, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return &ConstDecimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+// ToDecimal 整数、浮点数与定点数转换为定点数, 其他类型返回 false;
+func ToDecimal(value Value) (*ConstDecimal, bool) {
+	switch v := value.(type) {
+	case *ConstDecimal:
+		return v, true
+	case *ConstInt:
+		return &ConstDecimal{Unscaled: big.NewInt(v.Value), Scale: 0}, true
+	case *ConstFloat:
+		decimal, err := ParseDecimal(strconv.FormatFloat(v.Value, 'f', -1, 64))
+		return decimal, err == nil
+	}
+	return nil, false
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (d *ConstDecimal) Hash() uint32 {
+	return util.Hash([]byte(d.normalize().String()))
+}
+
+// Into 返回对应的有理数;
+func (d *ConstDecimal) Into() interface{} {
+	return new(big.Rat).SetFrac(d.Unscaled, pow10(d.Scale))
+}
+
+// Bytes 按小数位数输出, 保留末尾的 0: DECIMAL(5, 2) 的 1.5 输出为 1.50;
+func (d *ConstDecimal) Bytes() []byte {
+	return []byte(d.String())
+}
+
+func (d *ConstDecimal) String() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	sign := ""
+	if d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-d.Scale))
+	}
+	if len(digits) <= int(d.Scale) {
+		digits = strings.Repeat("0", int(d.Scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(d.Scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func (d *ConstDecimal) DateType() DataType {
+	return Decimal
+}
+
+func (d *ConstDecimal) PartialCmp(c Const) (bool, int) {
+	switch c.(type) {
+	case *ConstNull:
+		return true, 1
+	}
+	other, ok := ToDecimal(c)
+	if !ok {
+		return false, 0
+	}
+	l, r := alignDecimal(d, other)
+	return true, l.Cmp(r)
+}
+
+// alignDecimal 把两个定点数调整到相同的小数位数, 返回调整后的整数部分;
+func alignDecimal(l, r *ConstDecimal) (*big.Int, *big.Int) {
+	switch {
+	case l.Scale > r.Scale:
+		return l.Unscaled, new(big.Int).Mul(r.Unscaled, pow10(l.Scale-r.Scale))
+	case l.Scale < r.Scale:
+		return new(big.Int).Mul(l.Unscaled, pow10(r.Scale-l.Scale)), r.Unscaled
+	}
+	return l.Unscaled, r.Unscaled
+}
+
+// Rescale 调整到指定的小数位数, 舍去的部分四舍五入(远离零);
+func (d *ConstDecimal) Rescale(scale int32) *ConstDecimal {
+	if scale >= d.Scale {
+		return &ConstDecimal{Unscaled: new(big.Int).Mul(d.Unscaled, pow10(scale-d.Scale)), Scale: scale}
+	}
+	return &ConstDecimal{Unscaled: roundDiv(d.Unscaled, pow10(d.Scale-scale)), Scale: scale}
+}
+
+// roundDiv 整数除法, 余数四舍五入(远离零);
+func roundDiv(x, y *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(x, y, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(new(big.Int).Abs(y)) >= 0 {
+		if (x.Sign() < 0) != (y.Sign() < 0) {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
+
+// normalize 去掉末尾的 0, 相等的值得到相同的结果: 1.50 与 1.5 都为 (15, 1);
+func (d *ConstDecimal) normalize() *ConstDecimal {
+	unscaled, scale := new(big.Int).Set(d.Unscaled), d.Scale
+	if unscaled.Sign() == 0 {
+		return &ConstDecimal{Unscaled: unscaled, Scale: 0}
+	}
+	ten, remainder := big.NewInt(10), new(big.Int)
+	for {
+		quotient, _ := new(big.Int).QuoRem(unscaled, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		unscaled, scale = quotient, scale-1
+	}
+	return &ConstDecimal{Unscaled: unscaled, Scale: scale}
+}
+
+// Fit 按列的精度调整: 小数部分按 scale 取整, 整数部分最多 precision - scale 位; precision 为 0 表示不限制;
+func (d *ConstDecimal) Fit(precision int32, scale int32) (*ConstDecimal, error) {
+	if precision == 0 {
+		return d, nil
+	}
+	fitted := d.Rescale(scale)
+	if int32(len(new(big.Int).Abs(fitted.Unscaled).String())) > precision && fitted.Unscaled.Sign() != 0 {
+		return nil, util.Error("numeric field overflow: %s exceeds DECIMAL(%d, %d)", d.String(), precision, scale)
+	}
+	return fitted, nil
+}
+
+// decimalArith 定点数参与的运算, 另一侧可以是整数或浮点数; 不支持的组合返回 false;
+func decimalArith(operator ArithOperator, lv, rv Value) (Value, bool, error) {
+	_, lok := lv.(*ConstDecimal)
+	_, rok := rv.(*ConstDecimal)
+	if !lok && !rok {
+		return nil, false, nil
+	}
+	l, lok := ToDecimal(lv)
+	r, rok := ToDecimal(rv)
+	if !lok || !rok {
+		return nil, false, nil
+	}
+	switch operator {
+	case ArithAdd:
+		lu, ru := alignDecimal(l, r)
+		return &ConstDecimal{Unscaled: new(big.Int).Add(lu, ru), Scale: max(l.Scale, r.Scale)}, true, nil
+	case ArithSub:
+		lu, ru := alignDecimal(l, r)
+		return &ConstDecimal{Unscaled: new(big.Int).Sub(lu, ru), Scale: max(l.Scale, r.Scale)}, true, nil
+	case ArithMul:
+		return &ConstDecimal{Unscaled: new(big.Int).Mul(l.Unscaled, r.Unscaled), Scale: l.Scale + r.Scale}, true, nil
+	default:
+		if r.Unscaled.Sign() == 0 {
+			return nil, true, util.Error("division by zero")
+		}
+		// l / r = (lu * 10^(scale + rs - ls)) / ru, 结果的小数位数为 scale;
+		scale := max(DecimalDivScale, l.Scale, r.Scale)
+		numerator := new(big.Int).Mul(l.Unscaled, pow10(scale+r.Scale-l.Scale))
+		return &ConstDecimal{Unscaled: roundDiv(numerator, r.Unscaled), Scale: scale}, true, nil
+	}
+}
+
+// castDecimal DECIMAL 列写入整数、浮点数或定点数时, 转换为列的精度;
+func (c *ColumnV) castDecimal(value Value) (Value, error) {
+	decimal, ok := ToDecimal(value)
+	if !ok {
+		return value, nil
+	}
+	fitted, err := decimal.Fit(c.Precision, c.Scale)
+	if err != nil {
+		return nil, util.Error("[Table] column %s %s", c.Name, err)
+	}
+	return fitted, nil
+}
+
+// DecimalTypeName DECIMAL(p, s), 不限制精度时为 DECIMAL;
+func DecimalTypeName(precision int32, scale int32) string {
+	if precision == 0 {
+		return "Decimal"
+	}
+	return fmt.Sprintf("Decimal(%d, %d)", precision, scale)
+}
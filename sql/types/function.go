@@ -0,0 +1,201 @@
+package types
+
+import (
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"strings"
+)
+
+// 标量函数: 对每一行计算一次, 参数可以是任意表达式; 聚合函数见 sql.BuildCal;
+// 参数都是常量且结果稳定的调用在解析时直接算出结果, 见 Parser.parseFunction;
+// now() 等结果取决于当前事务的函数在执行语句前由 BindFunctions 绑定到事务;
+
+type scalarFunction struct {
+	args     int      // 参数个数;
+	optional int      // 末尾可以省略的参数个数, 省略的参数以 nil 传入;
+	result   DataType // 结果类型, 类型检查使用; 为 Null 时用参数的样本值试算;
+	volatile bool     // 每次调用的结果可能不同, 不能在解析时提前计算;
+	call     func(args []Value) (Value, error)
+	typeOf   func(args []Value) (DataType, error)                       // 结果类型由常量参数决定时使用, 优先于 result;
+	bound    func(context FunctionContext, args []Value) (Value, error) // 绑定到事务之后使用, 优先于 call;
+}
+
+// FunctionContext 结果取决于当前事务的函数的实现, 由 sql.Service 提供;
+type FunctionContext interface {
+	Now() *ConstTimestamp // 事务开始的时间, 同一事务中的 now() 都返回这个值;
+	NextVal(sequenceName string) (int64, error)
+	CurrVal(sequenceName string) (int64, error)
+}
+
+var scalarFunctions = map[string]*scalarFunction{
+	// now(): 当前事务开始的时间; 没有绑定到事务时取当前时间;
+	"now": {args: 0, volatile: true, result: Timestamp, call: func(args []Value) (Value, error) {
+		return NowTimestamp(), nil
+	}, bound: func(context FunctionContext, args []Value) (Value, error) {
+		return context.Now(), nil
+	}},
+	// nextval('seq') / currval('seq'): 序列的下一个值 / 当前会话最近一次 nextval 取到的值, 只能在绑定到事务的语句中使用;
+	"nextval": {args: 1, volatile: true, result: Integer, bound: func(context FunctionContext, args []Value) (Value, error) {
+		return sequenceValue(args[0], context.NextVal)
+	}},
+	"currval": {args: 1, volatile: true, result: Integer, bound: func(context FunctionContext, args []Value) (Value, error) {
+		return sequenceValue(args[0], context.CurrVal)
+	}},
+	// date_trunc('month', ts): 截断到指定精度;
+	"date_trunc": {args: 2, result: Null, call: func(args []Value) (Value, error) {
+		unit, ok := args[0].(*ConstString)
+		if !ok {
+			return nil, util.Error("date_trunc unit must be a string")
+		}
+		return DateTrunc(unit.Value, args[1])
+	}},
+	// date_part('year', ts): 取出时间的某个部分, EXTRACT(year FROM ts) 解析为该函数;
+	"date_part": {args: 2, result: Null, call: func(args []Value) (Value, error) {
+		field, ok := args[0].(*ConstString)
+		if !ok {
+			return nil, util.Error("date_part field must be a string")
+		}
+		return Extract(field.Value, args[1])
+	}},
+	// length(s): 字符串的字符个数或二进制的字节数;
+	"length": {args: 1, result: Integer, call: func(args []Value) (Value, error) {
+		return Length(args[0])
+	}},
+	// upper(s) / lower(s): 转换为大写 / 小写;
+	"upper": {args: 1, result: String, call: func(args []Value) (Value, error) {
+		return changeCase(args[0], strings.ToUpper)
+	}},
+	"lower": {args: 1, result: String, call: func(args []Value) (Value, error) {
+		return changeCase(args[0], strings.ToLower)
+	}},
+	// substring(s, start[, count]): 截取子串, start 从 1 开始;
+	"substring": {args: 3, optional: 1, result: Null, call: func(args []Value) (Value, error) {
+		return Substring(args[0], args[1], args[2])
+	}},
+	// doc -> 'key', json_extract(doc, '$.a.b'): 取出子文档;
+	JsonArrow: {args: 2, result: Json, call: func(args []Value) (Value, error) {
+		return JsonExtract(args[0], args[1])
+	}},
+	"json_extract": {args: 2, result: Json, call: func(args []Value) (Value, error) {
+		return JsonExtract(args[0], args[1])
+	}},
+	// doc ->> 'key': 取出子文档的文本;
+	JsonLongArrow: {args: 2, result: String, call: func(args []Value) (Value, error) {
+		return JsonExtractText(args[0], args[1])
+	}},
+	// json_array_length(doc[, path]): 数组的元素个数;
+	"json_array_length": {args: 2, optional: 1, result: Integer, call: func(args []Value) (Value, error) {
+		return JsonArrayLength(args[0], args[1])
+	}},
+	// CAST(v AS type) 解析为 cast(v, 'type'), 类型名见 CastTypeName;
+	CastFunction: {args: 2, call: func(args []Value) (Value, error) {
+		target, err := castTarget(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return CastTo(args[0], target)
+	}, typeOf: func(args []Value) (DataType, error) {
+		target, err := castTarget(args[1])
+		return target.DataType, err
+	}},
+	// json_keys(doc[, path]): 对象的键;
+	"json_keys": {args: 2, optional: 1, result: Json, call: func(args []Value) (Value, error) {
+		return JsonKeys(args[0], args[1])
+	}},
+}
+
+// IsScalarFunction 函数名不区分大小写;
+func IsScalarFunction(funcName string) bool {
+	_, ok := scalarFunctions[strings.ToLower(funcName)]
+	return ok
+}
+
+func IsVolatileFunction(funcName string) bool {
+	function, ok := scalarFunctions[strings.ToLower(funcName)]
+	return ok && function.volatile
+}
+
+func CallScalarFunction(funcName string, args []Value) (Value, error) {
+	return callScalarFunction(funcName, args, nil)
+}
+
+// callScalarFunction context 不为空时按绑定的事务计算;
+func callScalarFunction(funcName string, args []Value, context FunctionContext) (Value, error) {
+	function, ok := scalarFunctions[strings.ToLower(funcName)]
+	if !ok {
+		return nil, util.Error("function %s is not supported", funcName)
+	}
+	if len(args) > function.args || len(args) < function.args-function.optional {
+		return nil, util.Error("function %s expects %d arguments, got %d", funcName, function.args, len(args))
+	}
+	for len(args) < function.args {
+		args = append(args, nil)
+	}
+	if context != nil && function.bound != nil {
+		return function.bound(context, args)
+	}
+	if function.call == nil {
+		return nil, util.Error("function %s can not be used here", funcName)
+	}
+	return function.call(args)
+}
+
+func sequenceValue(arg Value, value func(sequenceName string) (int64, error)) (Value, error) {
+	sequenceName, ok := arg.(*ConstString)
+	if !ok {
+		return nil, util.Error("sequence name must be a string")
+	}
+	v, err := value(sequenceName.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &ConstInt{Value: v}, nil
+}
+
+// BindFunctions 将表达式中结果取决于当前事务的函数绑定到 context, 语句执行前调用;
+// 表达式来自每次执行时重新解析的语句, 绑定不会影响其他语句;
+func BindFunctions(expr *Expression, context FunctionContext) {
+	if expr == nil {
+		return
+	}
+	if expr.Function != nil {
+		if function, ok := scalarFunctions[strings.ToLower(expr.Function.FuncName)]; ok && function.bound != nil {
+			expr.Function.context = context
+		}
+		for _, arg := range expr.Function.Args {
+			BindFunctions(arg, context)
+		}
+		return
+	}
+	switch operation := expr.OperationVal.(type) {
+	case *OperationAnd:
+		BindFunctions(operation.Left, context)
+		BindFunctions(operation.Right, context)
+		return
+	case *OperationOr:
+		BindFunctions(operation.Left, context)
+		BindFunctions(operation.Right, context)
+		return
+	}
+	left, right := compareOperands(expr.OperationVal)
+	BindFunctions(left, context)
+	BindFunctions(right, context)
+}
+
+// ScalarResultType 标量函数的结果类型; 没有声明时用参数试算一次;
+func ScalarResultType(funcName string, args []Value) (DataType, error) {
+	function, ok := scalarFunctions[strings.ToLower(funcName)]
+	if !ok {
+		return 0, util.Error("function %s is not supported", funcName)
+	}
+	if function.typeOf != nil {
+		return function.typeOf(args)
+	}
+	if function.result != Null {
+		return function.result, nil
+	}
+	value, err := CallScalarFunction(funcName, args)
+	if err != nil {
+		return 0, err
+	}
+	return value.DateType(), nil
+}
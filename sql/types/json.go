@@ -0,0 +1,402 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSON 类型: 写入时校验并规范化为紧凑的文本, 对象的键按字典序排列, 因此相同内容的文档文本相同;
+// 比较时先按类型排序 null < bool < number < string < array < object, 数字按数值比较, 数组与对象按规范化后的文本比较;
+// JSON 标量也可以直接与 SQL 的数值、字符串、布尔值比较: attrs -> 'size' > 10;
+
+const (
+	JsonArrow     = "->"  // doc -> 'key': 取出子文档, 结果仍是 JSON;
+	JsonLongArrow = "->>" // doc ->> 'key': 取出子文档的文本, JSON 字符串去掉引号, JSON null 为 NULL;
+)
+
+type ConstJson struct {
+	Value string
+}
+
+// ParseJson 校验并规范化 JSON 文本;
+func ParseJson(s string) (*ConstJson, error) {
+	document, err := decodeJson(s)
+	if err != nil {
+		return nil, util.Error("invalid input syntax for type json: '%s'", s)
+	}
+	return newConstJson(document), nil
+}
+
+// decodeJson 只允许一个完整的 JSON 值, 数字解码为 json.Number, 保留原文;
+func decodeJson(s string) (interface{}, error) {
+	if !json.Valid([]byte(s)) {
+		return nil, util.Error("invalid json")
+	}
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// newConstJson 编码为规范化的文本: map 的键按字典序输出, 数字保留原文, 不转义 HTML 字符;
+func newConstJson(document interface{}) *ConstJson {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(document)
+	return &ConstJson{Value: strings.TrimSuffix(buf.String(), "\n")}
+}
+
+// document 规范化的文本一定可以解码;
+func (j *ConstJson) document() interface{} {
+	document, _ := decodeJson(j.Value)
+	return document
+}
+
+func (j *ConstJson) Hash() uint32 {
+	return util.Hash([]byte(j.Value))
+}
+
+func (j *ConstJson) Into() interface{} {
+	return j.Value
+}
+
+func (j *ConstJson) Bytes() []byte {
+	return []byte(j.Value)
+}
+
+func (j *ConstJson) DateType() DataType {
+	return Json
+}
+
+func (j *ConstJson) PartialCmp(c Const) (bool, int) {
+	switch other := c.(type) {
+	case *ConstNull:
+		return true, 1
+	case *ConstJson:
+		return true, compareJson(j.document(), other.document())
+	}
+	// 与 SQL 标量比较: 只有类型对应时才可以比较;
+	switch document := j.document().(type) {
+	case json.Number:
+		if _, ok := ToDecimal(c); ok {
+			ok, cmp := c.PartialCmp(jsonNumber(document))
+			return ok, -cmp
+		}
+	case string:
+		if other, ok := c.(*ConstString); ok {
+			return true, strings.Compare(document, other.Value)
+		}
+	case bool:
+		if other, ok := c.(*ConstBool); ok {
+			return (&ConstBool{Value: document}).PartialCmp(other)
+		}
+	}
+	return false, 0
+}
+
+// ToJson SQL 标量转换为内容相同的 JSON 标量: 数值为 JSON 数字, 字符串为 JSON 字符串, 布尔值为 JSON 布尔值;
+func ToJson(value Value) (*ConstJson, bool) {
+	switch v := value.(type) {
+	case *ConstJson:
+		return v, true
+	case *ConstString:
+		return newConstJson(v.Value), true
+	case *ConstBool:
+		return newConstJson(v.Value), true
+	}
+	if decimal, ok := ToDecimal(value); ok {
+		return &ConstJson{Value: decimal.String()}, true
+	}
+	return nil, false
+}
+
+// jsonRank 不同类型之间的顺序;
+func jsonRank(document interface{}) byte {
+	switch document.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case json.Number:
+		return 2
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func compareJson(l, r interface{}) int {
+	if lr, rr := jsonRank(l), jsonRank(r); lr != rr {
+		if lr < rr {
+			return -1
+		}
+		return 1
+	}
+	switch lv := l.(type) {
+	case nil:
+		return 0
+	case bool:
+		_, cmp := (&ConstBool{Value: lv}).PartialCmp(&ConstBool{Value: r.(bool)})
+		return cmp
+	case json.Number:
+		_, cmp := jsonNumber(lv).PartialCmp(jsonNumber(r.(json.Number)))
+		return cmp
+	case string:
+		return strings.Compare(lv, r.(string))
+	default:
+		return strings.Compare(newConstJson(l).Value, newConstJson(r).Value)
+	}
+}
+
+// jsonNumber JSON 数字转换为定点数, 支持指数形式: 1.5e3;
+func jsonNumber(number json.Number) *ConstDecimal {
+	text := strings.ToLower(number.String())
+	exponent := int64(0)
+	if pos := strings.IndexByte(text, 'e'); pos != -1 {
+		exponent, _ = strconv.ParseInt(text[pos+1:], 10, 32)
+		text = text[:pos]
+	}
+	decimal, _ := ParseDecimal(text)
+	decimal.Scale -= int32(exponent)
+	return decimal
+}
+
+// jsonPath 解析路径: 不以 $ 开头的字符串是对象的一个键, 整数是数组下标;
+// 以 $ 开头时为多级路径: $.a.b[0], 含有特殊字符的键用双引号: $."first name";
+func jsonPath(path Value) ([]interface{}, error) {
+	switch p := path.(type) {
+	case *ConstInt:
+		return []interface{}{int(p.Value)}, nil
+	case *ConstString:
+		if !strings.HasPrefix(p.Value, "$") {
+			return []interface{}{p.Value}, nil
+		}
+		return parseJsonPath(p.Value)
+	}
+	return nil, util.Error("json path must be a string or an integer")
+}
+
+func parseJsonPath(path string) ([]interface{}, error) {
+	steps := make([]interface{}, 0)
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.IndexByte(rest[1:], '"')
+				if end == -1 {
+					return nil, util.Error("invalid json path: %s", path)
+				}
+				steps, rest = append(steps, rest[1:end+1]), rest[end+2:]
+				continue
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, util.Error("invalid json path: %s", path)
+			}
+			steps, rest = append(steps, rest[:end]), rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, util.Error("invalid json path: %s", path)
+			}
+			index, err := strconv.Atoi(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, util.Error("invalid json path: %s", path)
+			}
+			steps, rest = append(steps, index), rest[end+1:]
+		default:
+			return nil, util.Error("invalid json path: %s", path)
+		}
+	}
+	return steps, nil
+}
+
+// lookupJson 按路径取出子文档, 路径不存在时返回 false;
+func lookupJson(value Value, path Value) (interface{}, bool, error) {
+	doc, ok := value.(*ConstJson)
+	if !ok {
+		return nil, false, util.Error("json path access does not support %s", GetDataTypeInfo(value.DateType()))
+	}
+	steps, err := jsonPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	document := doc.document()
+	for _, step := range steps {
+		switch key := step.(type) {
+		case string:
+			object, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if document, ok = object[key]; !ok {
+				return nil, false, nil
+			}
+		case int:
+			array, ok := document.([]interface{})
+			if !ok || key < 0 || key >= len(array) {
+				return nil, false, nil
+			}
+			document = array[key]
+		}
+	}
+	return document, true, nil
+}
+
+// JsonExtract doc -> path 与 json_extract(doc, path): 取出子文档, 路径不存在时为 NULL;
+func JsonExtract(value Value, path Value) (Value, error) {
+	if value.DateType() == Null || path.DateType() == Null {
+		return &ConstNull{}, nil
+	}
+	document, ok, err := lookupJson(value, path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &ConstNull{}, nil
+	}
+	return newConstJson(document), nil
+}
+
+// JsonExtractText doc ->> path: 取出子文档的文本; JSON 字符串去掉引号, JSON null 与不存在的路径为 NULL;
+func JsonExtractText(value Value, path Value) (Value, error) {
+	if value.DateType() == Null || path.DateType() == Null {
+		return &ConstNull{}, nil
+	}
+	document, ok, err := lookupJson(value, path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || document == nil {
+		return &ConstNull{}, nil
+	}
+	if text, ok := document.(string); ok {
+		return &ConstString{Value: text}, nil
+	}
+	return &ConstString{Value: newConstJson(document).Value}, nil
+}
+
+// JsonArrayLength json_array_length(doc[, path]): 数组的元素个数, 不是数组时报错;
+func JsonArrayLength(value Value, path Value) (Value, error) {
+	if path != nil {
+		var err error
+		if value, err = JsonExtract(value, path); err != nil {
+			return nil, err
+		}
+	}
+	if value.DateType() == Null {
+		return &ConstNull{}, nil
+	}
+	doc, ok := value.(*ConstJson)
+	if !ok {
+		return nil, util.Error("function json_array_length does not support %s", GetDataTypeInfo(value.DateType()))
+	}
+	array, ok := doc.document().([]interface{})
+	if !ok {
+		return nil, util.Error("cannot get array length of a non-array: %s", doc.Value)
+	}
+	return &ConstInt{Value: int64(len(array))}, nil
+}
+
+// JsonKeys json_keys(doc[, path]): 对象的键组成的 JSON 数组, 按字典序排列; 不是对象时为 NULL;
+func JsonKeys(value Value, path Value) (Value, error) {
+	if path != nil {
+		var err error
+		if value, err = JsonExtract(value, path); err != nil {
+			return nil, err
+		}
+	}
+	if value.DateType() == Null {
+		return &ConstNull{}, nil
+	}
+	doc, ok := value.(*ConstJson)
+	if !ok {
+		return nil, util.Error("function json_keys does not support %s", GetDataTypeInfo(value.DateType()))
+	}
+	object, ok := doc.document().(map[string]interface{})
+	if !ok {
+		return &ConstNull{}, nil
+	}
+	keys := make([]interface{}, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b interface{}) int { return strings.Compare(a.(string), b.(string)) })
+	return newConstJson(keys), nil
+}
+
+// jsonPathLiteral 表达式显示时, 路径中的字符串加上引号: attrs ->> 'color';
+func jsonPathLiteral(path *Expression) string {
+	if text, ok := path.ConstVal.(*ConstString); ok {
+		return "'" + text.Value + "'"
+	}
+	return path.arithOperand()
+}
+
+// appendKeyJson 先写入类型的顺序, 再写入值: 数字按定点数编码, 字符串按字节编码, 数组与对象按规范化后的文本编码;
+func appendKeyJson(buf []byte, doc *ConstJson) []byte {
+	document := doc.document()
+	buf = append(buf, jsonRank(document))
+	switch v := document.(type) {
+	case nil:
+		return buf
+	case bool:
+		if v {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case json.Number:
+		return appendKeyDecimal(buf, jsonNumber(v))
+	case string:
+		return appendKeyBytes(buf, []byte(v))
+	default:
+		return appendKeyBytes(buf, []byte(doc.Value))
+	}
+}
+
+// decodeKeyJson 还原 appendKeyJson 的结果, 数字还原为定点数的文本;
+func decodeKeyJson(buf []byte) (*ConstJson, int, error) {
+	if len(buf) < 1 {
+		return nil, 0, util.Error("#DecodeKey unexpected end of json")
+	}
+	switch buf[0] {
+	case 0:
+		return &ConstJson{Value: "null"}, 1, nil
+	case 1:
+		if len(buf) < 2 {
+			return nil, 0, util.Error("#DecodeKey unexpected end of json")
+		}
+		return &ConstJson{Value: strconv.FormatBool(buf[1] == 1)}, 2, nil
+	case 2:
+		decimal, n, err := decodeKeyDecimal(buf[1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		return &ConstJson{Value: decimal.String()}, n + 1, nil
+	default:
+		value, n, err := decodeKeyBytes(buf[1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		if buf[0] == 3 {
+			return newConstJson(string(value)), n + 1, nil
+		}
+		return &ConstJson{Value: string(value)}, n + 1, nil
+	}
+}
@@ -0,0 +1,326 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// 保序编码: 编码后的字节序与 PartialCmp 的大小关系一致, 多个值依次拼接后仍然保序,
+// 因此可以直接把多列的值拼进存储 key 中, 用前缀扫描实现最左前缀匹配;
+// 每个值以 1 字节类型标记开头, NULL 的标记最小, 与 PartialCmp 中 NULL 最小保持一致;
+const (
+	keyTagNull   byte = 0x00
+	keyTagBool   byte = 0x01
+	keyTagInt    byte = 0x02
+	keyTagFloat  byte = 0x03
+	keyTagString byte = 0x04
+
+	keyTagDate      byte = 0x05
+	keyTagTime      byte = 0x06
+	keyTagTimestamp byte = 0x07
+	keyTagInterval  byte = 0x08
+	keyTagDecimal   byte = 0x09
+	keyTagBlob      byte = 0x0A
+	keyTagJson      byte = 0x0B
+)
+
+// EncodeKey 将多个值编码为保序的字节串;
+func EncodeKey(values ...Value) []byte {
+	buf := make([]byte, 0)
+	for _, value := range values {
+		buf = appendKey(buf, value)
+	}
+	return buf
+}
+
+func appendKey(buf []byte, value Value) []byte {
+	switch v := value.(type) {
+	case *ConstBool:
+		if v.Value {
+			return append(buf, keyTagBool, 1)
+		}
+		return append(buf, keyTagBool, 0)
+	case *ConstInt:
+		// 翻转符号位, 负数排在正数之前;
+		buf = append(buf, keyTagInt)
+		return appendKeyInt64(buf, v.Value)
+	case *ConstFloat:
+		// 正数翻转符号位, 负数翻转全部位;
+		bits := math.Float64bits(v.Value)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits ^= 1 << 63
+		}
+		buf = append(buf, keyTagFloat)
+		return binary.BigEndian.AppendUint64(buf, bits)
+	case *ConstString:
+		// 不区分大小写的字符串按折叠后的形式编码, 大小写不同的值编码相同;
+		return appendKeyBytes(append(buf, keyTagString), []byte(v.collationKey()))
+	case *ConstBlob:
+		return appendKeyBytes(append(buf, keyTagBlob), v.Value)
+	case *ConstJson:
+		return appendKeyJson(append(buf, keyTagJson), v)
+	case *ConstDate:
+		return appendKeyInt64(append(buf, keyTagDate), v.Value)
+	case *ConstTime:
+		return appendKeyInt64(append(buf, keyTagTime), v.Value)
+	case *ConstTimestamp:
+		return appendKeyInt64(append(buf, keyTagTimestamp), v.Value)
+	case *ConstInterval:
+		// 先写入比较时使用的总长度, 再写入月与天, 解码时据此还原三个字段;
+		buf = appendKeyInt64(append(buf, keyTagInterval), v.approxMicros())
+		return appendKeyInt64(appendKeyInt64(buf, v.Months), v.Days)
+	case *ConstDecimal:
+		return appendKeyDecimal(append(buf, keyTagDecimal), v)
+	default:
+		return append(buf, keyTagNull)
+	}
+}
+
+// appendKeyBytes 0x00 转义为 0x00 0xFF, 以 0x00 0x01 结尾; 短串是长串的前缀时排在前面; 任意字节都可以还原;
+func appendKeyBytes(buf []byte, value []byte) []byte {
+	for i := 0; i < len(value); i++ {
+		if value[i] == 0x00 {
+			buf = append(buf, 0x00, 0xFF)
+		} else {
+			buf = append(buf, value[i])
+		}
+	}
+	return append(buf, 0x00, 0x01)
+}
+
+// decodeKeyBytes 还原 appendKeyBytes 的结果, 返回还原后的字节与消耗的长度;
+func decodeKeyBytes(buf []byte) ([]byte, int, error) {
+	value := make([]byte, 0)
+	for i := 0; ; {
+		if len(buf)-i < 2 {
+			return nil, 0, util.Error("#DecodeKey unexpected end of bytes")
+		}
+		if buf[i] != 0x00 {
+			value = append(value, buf[i])
+			i++
+			continue
+		}
+		if buf[i+1] == 0xFF {
+			value = append(value, 0x00)
+			i += 2
+			continue
+		}
+		return value, i + 2, nil
+	}
+}
+
+// appendKeyDecimal 定点数写成 0.d1d2...dn * 10^e 的形式(d1 不为 0, 去掉末尾的 0), 与小数位数无关:
+// 符号(负数 0x00, 零 0x01, 正数 0x02), 指数 e, 数字字符, 结束符 0x00; 负数的指数、数字与结束符按位取反;
+// 正数指数越大越大, 指数相同时按数字逐位比较, 较短的数字是较长数字的前缀时较小;
+func appendKeyDecimal(buf []byte, decimal *ConstDecimal) []byte {
+	normalized := decimal.normalize()
+	if normalized.Unscaled.Sign() == 0 {
+		return append(buf, 0x01)
+	}
+	digits := []byte(new(big.Int).Abs(normalized.Unscaled).String())
+	exponent := int64(len(digits)) - int64(normalized.Scale)
+	body := appendKeyInt64(make([]byte, 0, len(digits)+9), exponent)
+	body = append(append(body, digits...), 0x00)
+	if normalized.Unscaled.Sign() > 0 {
+		return append(append(buf, 0x02), body...)
+	}
+	for i := range body {
+		body[i] = ^body[i]
+	}
+	return append(append(buf, 0x00), body...)
+}
+
+// decodeKeyDecimal 解码 appendKeyDecimal 的结果, 返回定点数与消耗的字节数;
+func decodeKeyDecimal(buf []byte) (*ConstDecimal, int, error) {
+	if len(buf) < 1 {
+		return nil, 0, util.Error("#DecodeKey unexpected end of decimal")
+	}
+	sign := buf[0]
+	if sign == 0x01 {
+		return &ConstDecimal{Unscaled: new(big.Int), Scale: 0}, 1, nil
+	}
+	terminator := byte(0x00)
+	if sign == 0x00 {
+		terminator = 0xFF
+	}
+	if len(buf) < 10 {
+		return nil, 0, util.Error("#DecodeKey unexpected end of decimal")
+	}
+	end := bytes.IndexByte(buf[9:], terminator)
+	if end == -1 {
+		return nil, 0, util.Error("#DecodeKey unexpected end of decimal")
+	}
+	body := append([]byte{}, buf[1:9+end]...)
+	if sign == 0x00 {
+		for i := range body {
+			body[i] = ^body[i]
+		}
+	}
+	exponent := decodeKeyInt64(body)
+	digits := string(body[8:])
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, 0, util.Error("#DecodeKey invalid decimal digits")
+	}
+	if sign == 0x00 {
+		unscaled.Neg(unscaled)
+	}
+	scale := int64(len(digits)) - exponent
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(int32(-scale)))
+		scale = 0
+	}
+	return &ConstDecimal{Unscaled: unscaled, Scale: int32(scale)}, 10 + end, nil
+}
+
+func appendKeyInt64(buf []byte, value int64) []byte {
+	return binary.BigEndian.AppendUint64(buf, uint64(value)^(1<<63))
+}
+
+func decodeKeyInt64(buf []byte) int64 {
+	return int64(binary.BigEndian.Uint64(buf) ^ (1 << 63))
+}
+
+// DecodeKey 解码 EncodeKey 生成的字节串;
+func DecodeKey(buf []byte) ([]Value, error) {
+	values := make([]Value, 0)
+	for len(buf) > 0 {
+		tag := buf[0]
+		buf = buf[1:]
+		switch tag {
+		case keyTagNull:
+			values = append(values, &ConstNull{})
+		case keyTagBool:
+			if len(buf) < 1 {
+				return nil, util.Error("#DecodeKey unexpected end of bool")
+			}
+			values = append(values, &ConstBool{Value: buf[0] == 1})
+			buf = buf[1:]
+		case keyTagInt:
+			if len(buf) < 8 {
+				return nil, util.Error("#DecodeKey unexpected end of int")
+			}
+			values = append(values, &ConstInt{Value: decodeKeyInt64(buf)})
+			buf = buf[8:]
+		case keyTagFloat:
+			if len(buf) < 8 {
+				return nil, util.Error("#DecodeKey unexpected end of float")
+			}
+			bits := binary.BigEndian.Uint64(buf)
+			if bits&(1<<63) != 0 {
+				bits ^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			values = append(values, &ConstFloat{Value: math.Float64frombits(bits)})
+			buf = buf[8:]
+		case keyTagString, keyTagBlob:
+			value, n, err := decodeKeyBytes(buf)
+			if err != nil {
+				return nil, err
+			}
+			if tag == keyTagString {
+				values = append(values, &ConstString{Value: string(value)})
+			} else {
+				values = append(values, &ConstBlob{Value: value})
+			}
+			buf = buf[n:]
+		case keyTagDate, keyTagTime, keyTagTimestamp:
+			if len(buf) < 8 {
+				return nil, util.Error("#DecodeKey unexpected end of datetime")
+			}
+			value := decodeKeyInt64(buf)
+			switch tag {
+			case keyTagDate:
+				values = append(values, &ConstDate{Value: value})
+			case keyTagTime:
+				values = append(values, &ConstTime{Value: value})
+			default:
+				values = append(values, &ConstTimestamp{Value: value})
+			}
+			buf = buf[8:]
+		case keyTagDecimal:
+			decimal, n, err := decodeKeyDecimal(buf)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, decimal)
+			buf = buf[n:]
+		case keyTagJson:
+			doc, n, err := decodeKeyJson(buf)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, doc)
+			buf = buf[n:]
+		case keyTagInterval:
+			if len(buf) < 24 {
+				return nil, util.Error("#DecodeKey unexpected end of interval")
+			}
+			total, months, days := decodeKeyInt64(buf), decodeKeyInt64(buf[8:]), decodeKeyInt64(buf[16:])
+			values = append(values, &ConstInterval{Months: months, Days: days, Micros: total - (months*daysPerMonth+days)*microsPerDay})
+			buf = buf[24:]
+		default:
+			return nil, util.Error("#DecodeKey unknown value tag %d", tag)
+		}
+	}
+	return values, nil
+}
+
+// IndexBound 索引范围扫描的一端;
+type IndexBound struct {
+	Value     Value
+	Inclusive bool
+}
+
+// IndexRange 索引列上的范围条件, Lower/Upper 为空表示该端不设限;
+type IndexRange struct {
+	Lower *IndexBound
+	Upper *IndexBound
+}
+
+// KeyBounds 把范围转换为索引 key 的区间 [start, end), prefix 为范围所在列之前的索引 key 前缀; end 为空表示不设右边界;
+// 保序编码自定界: 值 v 的索引项都以 prefix+enc(v) 开头, 大于 v 的值的索引项都不小于 PrefixEnd(prefix+enc(v));
+// 两端的值已经转换为列的类型, 见 sql.coerceScanValue;
+func (r *IndexRange) KeyBounds(prefix []byte) ([]byte, []byte) {
+	start := prefix
+	if r.Lower != nil {
+		start = append(bytes.Clone(prefix), EncodeKey(r.Lower.Value)...)
+		if !r.Lower.Inclusive {
+			start = util.PrefixEnd(start)
+		}
+	}
+	var end []byte
+	if r.Upper != nil {
+		end = append(bytes.Clone(prefix), EncodeKey(r.Upper.Value)...)
+		if r.Upper.Inclusive {
+			end = util.PrefixEnd(end)
+		}
+	}
+	return start, end
+}
+
+func (r *IndexRange) ToString(field string) string {
+	conditions := make([]string, 0)
+	if r.Lower != nil {
+		op := ">"
+		if r.Lower.Inclusive {
+			op = ">="
+		}
+		conditions = append(conditions, field+" "+op+" "+string(r.Lower.Value.Bytes()))
+	}
+	if r.Upper != nil {
+		op := "<"
+		if r.Upper.Inclusive {
+			op = "<="
+		}
+		conditions = append(conditions, field+" "+op+" "+string(r.Upper.Value.Bytes()))
+	}
+	return strings.Join(conditions, " AND ")
+}
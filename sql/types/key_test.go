@@ -0,0 +1,221 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeKeyOrder(t *testing.T) {
+	// 按 PartialCmp 升序排列, 编码后的字节序必须一致;
+	groups := [][]Value{
+		{&ConstNull{}, &ConstInt{Value: -100}, &ConstInt{Value: -1}, &ConstInt{Value: 0}, &ConstInt{Value: 7}, &ConstInt{Value: 1 << 40}},
+		{&ConstNull{}, &ConstFloat{Value: -2.5}, &ConstFloat{Value: -0.1}, &ConstFloat{Value: 0}, &ConstFloat{Value: 0.1}, &ConstFloat{Value: 3e10}},
+		{&ConstNull{}, &ConstString{Value: ""}, &ConstString{Value: "a"}, &ConstString{Value: "a\x00"}, &ConstString{Value: "ab"}, &ConstString{Value: "b"}},
+		{&ConstNull{}, &ConstBool{Value: false}, &ConstBool{Value: true}},
+	}
+	for _, group := range groups {
+		for i := 1; i < len(group); i++ {
+			prev, curr := EncodeKey(group[i-1]), EncodeKey(group[i])
+			if bytes.Compare(prev, curr) >= 0 {
+				t.Errorf("EncodeKey(%s) should be less than EncodeKey(%s)", group[i-1].Bytes(), group[i].Bytes())
+			}
+		}
+	}
+	// 多列拼接后仍然保序: ("a", 2) < ("ab", 1);
+	if bytes.Compare(EncodeKey(&ConstString{Value: "a"}, &ConstInt{Value: 2}), EncodeKey(&ConstString{Value: "ab"}, &ConstInt{Value: 1})) >= 0 {
+		t.Errorf("composite key order mismatch")
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	values := []Value{&ConstInt{Value: -3}, &ConstString{Value: "x\x00y"}, &ConstNull{}, &ConstFloat{Value: -1.5}, &ConstBool{Value: true}}
+	decoded, err := DecodeKey(EncodeKey(values...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(values) {
+		t.Fatalf("expect %d values, got %d", len(values), len(decoded))
+	}
+	for i := range values {
+		if ok, cmp := values[i].PartialCmp(decoded[i]); !ok || cmp != 0 || values[i].DateType() != decoded[i].DateType() {
+			t.Errorf("value %d: expect %s, got %s", i, values[i].Bytes(), decoded[i].Bytes())
+		}
+	}
+}
+
+func TestEncodeTemporalKey(t *testing.T) {
+	date := func(s string) Value { v, _ := ParseDate(s); return v }
+	timestamp := func(s string) Value { v, _ := ParseTimestamp(s); return v }
+	interval := func(s string) Value { v, _ := ParseInterval(s); return v }
+	groups := [][]Value{
+		{&ConstNull{}, date("1969-12-31"), date("1970-01-01"), date("2024-02-29"), date("2024-03-01")},
+		{&ConstNull{}, timestamp("1969-12-31 23:59:59.999999"), timestamp("2024-01-01"), timestamp("2024-01-01 00:00:00.000001")},
+		{&ConstNull{}, interval("-1 day"), interval("0 seconds"), interval("29 days 23:59:59"), interval("1 month 1 second"), interval("1 year")},
+	}
+	for _, group := range groups {
+		for i := 1; i < len(group); i++ {
+			if ok, cmp := group[i-1].PartialCmp(group[i]); !ok || cmp != -1 {
+				t.Errorf("%s should be less than %s", group[i-1].Bytes(), group[i].Bytes())
+			}
+			if bytes.Compare(EncodeKey(group[i-1]), EncodeKey(group[i])) >= 0 {
+				t.Errorf("EncodeKey(%s) should be less than EncodeKey(%s)", group[i-1].Bytes(), group[i].Bytes())
+			}
+		}
+	}
+	values := []Value{date("2024-02-29"), &ConstTime{Value: 3723000001}, timestamp("2024-02-29 12:00:00.5"), interval("1 year 2 mons -3 days 04:05:06.5")}
+	decoded, err := DecodeKey(EncodeKey(values...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range values {
+		if string(values[i].Bytes()) != string(decoded[i].Bytes()) || values[i].DateType() != decoded[i].DateType() {
+			t.Errorf("value %d: expect %s, got %s", i, values[i].Bytes(), decoded[i].Bytes())
+		}
+	}
+}
+
+func TestEncodeDecimalKey(t *testing.T) {
+	decimal := func(s string) *ConstDecimal { v, _ := ParseDecimal(s); return v }
+	// 按数值升序, 与小数位数无关;
+	group := []Value{&ConstNull{}, decimal("-1000"), decimal("-99.5"), decimal("-1.25"), decimal("-1.2"), decimal("-0.001"),
+		decimal("0.00"), decimal("0.0012"), decimal("1.2"), decimal("1.25"), decimal("9.99"), decimal("10"), decimal("123456789012345678901234567890.5")}
+	for i := 1; i < len(group); i++ {
+		if ok, cmp := group[i-1].PartialCmp(group[i]); !ok || cmp != -1 {
+			t.Errorf("%s should be less than %s", group[i-1].Bytes(), group[i].Bytes())
+		}
+		if bytes.Compare(EncodeKey(group[i-1]), EncodeKey(group[i])) >= 0 {
+			t.Errorf("EncodeKey(%s) should be less than EncodeKey(%s)", group[i-1].Bytes(), group[i].Bytes())
+		}
+	}
+	// 相等的值编码相同;
+	if !bytes.Equal(EncodeKey(decimal("1.50")), EncodeKey(decimal("1.5"))) || !bytes.Equal(EncodeKey(decimal("-0.0")), EncodeKey(decimal("0"))) {
+		t.Errorf("equal decimals should have the same key")
+	}
+	values := []Value{decimal("-12.340"), &ConstInt{Value: 7}, decimal("1200"), decimal("0.000001")}
+	decoded, err := DecodeKey(EncodeKey(values...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range values {
+		if ok, cmp := values[i].PartialCmp(decoded[i]); !ok || cmp != 0 || values[i].DateType() != decoded[i].DateType() {
+			t.Errorf("value %d: expect %s, got %s", i, values[i].Bytes(), decoded[i].Bytes())
+		}
+	}
+}
+
+func TestEncodeBlobKey(t *testing.T) {
+	group := []Value{&ConstNull{}, &ConstBlob{Value: []byte{}}, &ConstBlob{Value: []byte{0x00}}, &ConstBlob{Value: []byte{0x00, 0x00}},
+		&ConstBlob{Value: []byte{0x00, 0x01}}, &ConstBlob{Value: []byte{0x01}}, &ConstBlob{Value: []byte{0xFF, 0xFF}}}
+	for i := 1; i < len(group); i++ {
+		if ok, cmp := group[i-1].PartialCmp(group[i]); !ok || cmp != -1 {
+			t.Errorf("%s should be less than %s", FormatValue(group[i-1]), FormatValue(group[i]))
+		}
+		if bytes.Compare(EncodeKey(group[i-1]), EncodeKey(group[i])) >= 0 {
+			t.Errorf("EncodeKey(%s) should be less than EncodeKey(%s)", FormatValue(group[i-1]), FormatValue(group[i]))
+		}
+	}
+	values := []Value{&ConstBlob{Value: []byte{0x00, 0xFF, 0x00, 0x01}}, &ConstString{Value: "\x00"}, &ConstBlob{Value: []byte{}}}
+	decoded, err := DecodeKey(EncodeKey(values...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range values {
+		if ok, cmp := values[i].PartialCmp(decoded[i]); !ok || cmp != 0 || values[i].DateType() != decoded[i].DateType() {
+			t.Errorf("value %d: expect %s, got %s", i, FormatValue(values[i]), FormatValue(decoded[i]))
+		}
+	}
+}
+func TestEncodeJsonKey(t *testing.T) {
+	// 先按类型排序 null < bool < number < string < array < object, 同类型按值排序;
+	texts := []string{`null`, `false`, `true`, `-2.5`, `1`, `1.5e1`, `100`, `""`, `"a"`, `"b\u0000"`, `[1,2]`, `[2]`, `{"a":1}`, `{"b":0}`}
+	group := []Value{&ConstNull{}}
+	for _, text := range texts {
+		doc, err := ParseJson(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group = append(group, doc)
+	}
+	for i := 1; i < len(group); i++ {
+		if ok, cmp := group[i-1].PartialCmp(group[i]); !ok || cmp != -1 {
+			t.Errorf("%s should be less than %s", FormatValue(group[i-1]), FormatValue(group[i]))
+		}
+		if bytes.Compare(EncodeKey(group[i-1]), EncodeKey(group[i])) >= 0 {
+			t.Errorf("EncodeKey(%s) should be less than EncodeKey(%s)", FormatValue(group[i-1]), FormatValue(group[i]))
+		}
+	}
+	decoded, err := DecodeKey(EncodeKey(group[1:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range group[1:] {
+		if ok, cmp := value.PartialCmp(decoded[i]); !ok || cmp != 0 || decoded[i].DateType() != Json {
+			t.Errorf("value %d: expect %s, got %s", i, FormatValue(value), FormatValue(decoded[i]))
+		}
+	}
+}
+func TestEncodeCollationKey(t *testing.T) {
+	// nocase 的值按折叠后的形式比较与编码, 大小写不同的值相等;
+	upper, lower := &ConstString{Value: "ABC", Collation: CollateNocase}, &ConstString{Value: "abc", Collation: CollateNocase}
+	if ok, cmp := upper.PartialCmp(lower); !ok || cmp != 0 || upper.Hash() != lower.Hash() {
+		t.Errorf("%s should equal %s", upper.Value, lower.Value)
+	}
+	if !bytes.Equal(EncodeKey(upper), EncodeKey(lower)) {
+		t.Errorf("EncodeKey(%s) should equal EncodeKey(%s)", upper.Value, lower.Value)
+	}
+	// 只要一侧不区分大小写, 就按不区分大小写比较;
+	if ok, cmp := (&ConstString{Value: "abc"}).PartialCmp(upper); !ok || cmp != 0 {
+		t.Errorf("abc should equal %s", upper.Value)
+	}
+	if ok, cmp := (&ConstString{Value: "abc"}).PartialCmp(&ConstString{Value: "ABC"}); !ok || cmp != 1 {
+		t.Errorf("binary abc should be greater than ABC")
+	}
+	group := []Value{&ConstString{Value: "a", Collation: CollateNocase}, &ConstString{Value: "B", Collation: CollateNocase}, &ConstString{Value: "c", Collation: CollateNocase}}
+	for i := 1; i < len(group); i++ {
+		if ok, cmp := group[i-1].PartialCmp(group[i]); !ok || cmp != -1 {
+			t.Errorf("%s should be less than %s", FormatValue(group[i-1]), FormatValue(group[i]))
+		}
+		if bytes.Compare(EncodeKey(group[i-1]), EncodeKey(group[i])) >= 0 {
+			t.Errorf("EncodeKey(%s) should be less than EncodeKey(%s)", FormatValue(group[i-1]), FormatValue(group[i]))
+		}
+	}
+}
+
+func TestIndexRangeKeyBounds(t *testing.T) {
+	// 索引项: prefix + enc(v) + enc(pk); 255 的编码以 0xff 结尾, 检查边界的进位;
+	prefix := append([]byte("idx\x00"), EncodeKey(&ConstInt{Value: 1})...)
+	values := []Value{&ConstNull{}, &ConstInt{Value: -1}, &ConstInt{Value: 0}, &ConstInt{Value: 255}, &ConstInt{Value: 256}, &ConstInt{Value: 511}}
+	bound := func(v int64, inclusive bool) *IndexBound {
+		return &IndexBound{Value: &ConstInt{Value: v}, Inclusive: inclusive}
+	}
+	ranges := []*IndexRange{
+		{Lower: bound(0, false)},
+		{Lower: bound(255, true)},
+		{Upper: bound(256, false)},
+		{Upper: bound(255, true)},
+		{Lower: bound(0, true), Upper: bound(255, true)},
+		{Lower: bound(255, false), Upper: bound(511, false)},
+	}
+	for _, r := range ranges {
+		start, end := r.KeyBounds(prefix)
+		for _, value := range values {
+			// 比较规则与 WHERE 一致, NULL 最小;
+			expect := true
+			if r.Lower != nil {
+				_, cmp := value.PartialCmp(r.Lower.Value)
+				expect = cmp > 0 || cmp == 0 && r.Lower.Inclusive
+			}
+			if r.Upper != nil {
+				_, cmp := value.PartialCmp(r.Upper.Value)
+				expect = expect && (cmp < 0 || cmp == 0 && r.Upper.Inclusive)
+			}
+			for _, pk := range []int64{1, 2} {
+				key := append(append([]byte{}, prefix...), EncodeKey(value, &ConstInt{Value: pk})...)
+				got := bytes.Compare(key, start) >= 0 && (end == nil || bytes.Compare(key, end) < 0)
+				if got != expect {
+					t.Errorf("%s: value %s expect %v, got %v", r.ToString("v"), value.Bytes(), expect, got)
+				}
+			}
+		}
+	}
+}
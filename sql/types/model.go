@@ -3,6 +3,7 @@ package types
 import (
 	"fmt"
 	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -15,10 +16,20 @@ const (
 	Float
 	String
 	Null
+	// 日期时间类型追加在后面, 不改变已持久化的类型编号;
+	Date
+	Time
+	Timestamp
+	Interval
+	Decimal
+	Blob
+	Json
 )
 
 func GetDataTypeInfo(dataType DataType) string {
 	switch dataType {
+	case Boolean:
+		return "Boolean"
 	case Integer:
 		return "Integer"
 	case Float:
@@ -27,6 +38,20 @@ func GetDataTypeInfo(dataType DataType) string {
 		return "String"
 	case Null:
 		return "Null"
+	case Date:
+		return "Date"
+	case Time:
+		return "Time"
+	case Timestamp:
+		return "Timestamp"
+	case Interval:
+		return "Interval"
+	case Decimal:
+		return "Decimal"
+	case Blob:
+		return "Blob"
+	case Json:
+		return "Json"
 	default:
 		return "UNKNOWN"
 	}
@@ -39,6 +64,14 @@ type Column struct {
 	DefaultValue *Expression
 	PrimaryKey   bool
 	IsIndex      bool
+	Unique       bool
+	References   *ForeignKey // REFERENCES parent(col) 列约束;
+	Check        *Expression // CHECK (expr) 列约束;
+	Serial       bool        // AUTO_INCREMENT 列约束或 SERIAL 类型;
+	Precision    int32       // DECIMAL(p, s) 的 p, 0 表示不限制;
+	Scale        int32       // DECIMAL(p, s) 的 s;
+	Length       int32       // VARCHAR(n) / CHAR(n) 的 n, 0 表示不限制;
+	Collation    string      // COLLATE 子句指定的排序规则;
 }
 
 type Expression struct {
@@ -52,6 +85,21 @@ func (e *Expression) ToString() string {
 	if e.Field != "" {
 		return fmt.Sprintf("%s", e.Field)
 	} else if e.Function != nil {
+		if e.Function.Args != nil {
+			args := make([]string, 0, len(e.Function.Args))
+			for _, arg := range e.Function.Args {
+				args = append(args, arg.ToString())
+			}
+			// doc -> 'key' 与 doc ->> 'key' 按运算符的形式显示;
+			if name := e.Function.FuncName; (name == JsonArrow || name == JsonLongArrow) && len(e.Function.Args) == 2 {
+				return fmt.Sprintf("%s %s %s", e.Function.Args[0].arithOperand(), name, jsonPathLiteral(e.Function.Args[1]))
+			}
+			// cast(v, 'Integer') 按 CAST(v AS Integer) 显示;
+			if e.Function.FuncName == CastFunction && len(e.Function.Args) == 2 {
+				return fmt.Sprintf("CAST(%s AS %s)", args[0], e.Function.Args[1].ConstVal.Bytes())
+			}
+			return fmt.Sprintf("%s(%s)", e.Function.FuncName, strings.Join(args, ", "))
+		}
 		return fmt.Sprintf("%s(%s)", e.Function.FuncName, e.Function.ColName)
 	} else if e.OperationVal != nil {
 		switch e.OperationVal.(type) {
@@ -61,13 +109,35 @@ func (e *Expression) ToString() string {
 			return fmt.Sprintf("%s > %s", e.OperationVal.(*OperationGreaterThan).Left.ToString(), e.OperationVal.(*OperationGreaterThan).Right.ToString())
 		case *OperationLessThan:
 			return fmt.Sprintf("%s < %s", e.OperationVal.(*OperationLessThan).Left.ToString(), e.OperationVal.(*OperationLessThan).Right.ToString())
+		case *OperationGreaterEqual:
+			return fmt.Sprintf("%s >= %s", e.OperationVal.(*OperationGreaterEqual).Left.ToString(), e.OperationVal.(*OperationGreaterEqual).Right.ToString())
+		case *OperationLessEqual:
+			return fmt.Sprintf("%s <= %s", e.OperationVal.(*OperationLessEqual).Left.ToString(), e.OperationVal.(*OperationLessEqual).Right.ToString())
+		case *OperationAnd:
+			return fmt.Sprintf("%s AND %s", e.OperationVal.(*OperationAnd).Left.ToString(), e.OperationVal.(*OperationAnd).Right.ToString())
+		case *OperationOr:
+			return fmt.Sprintf("(%s OR %s)", e.OperationVal.(*OperationOr).Left.ToString(), e.OperationVal.(*OperationOr).Right.ToString())
+		case *OperationArith:
+			arith := e.OperationVal.(*OperationArith)
+			return fmt.Sprintf("%s %s %s", arith.Left.arithOperand(), arith.Operator.ToString(), arith.Right.arithOperand())
 		}
 	} else if e.ConstVal != nil {
+		if blob, ok := e.ConstVal.(*ConstBlob); ok {
+			return blobLiteral(blob)
+		}
 		return fmt.Sprintf("%s", e.ConstVal.Bytes())
 	}
 	return ""
 }
 
+// arithOperand 作为运算数时, 嵌套的运算加上括号;
+func (e *Expression) arithOperand() string {
+	if _, ok := e.OperationVal.(*OperationArith); ok {
+		return "(" + e.ToString() + ")"
+	}
+	return e.ToString()
+}
+
 func EvaluateExpr(expr *Expression, lcols []string, lrows []Value, rcols []string, rrows []Value) (Value, error) {
 	// 假如字段类型不为空, 那就默认获取左表字段值;
 	// note:仅仅解析第一对参数值, 所以以后传参只传第一对即;
@@ -90,6 +160,19 @@ func EvaluateExpr(expr *Expression, lcols []string, lrows []Value, rcols []strin
 		return expr.ConstVal, nil
 	}
 
+	// 标量函数: 先计算参数, 再调用函数; 聚合函数由 AggregateExecutor 计算;
+	if expr.Function != nil && IsScalarFunction(expr.Function.FuncName) {
+		args := make([]Value, 0, len(expr.Function.Args))
+		for _, arg := range expr.Function.Args {
+			value, err := EvaluateExpr(arg, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, value)
+		}
+		return callScalarFunction(expr.Function.FuncName, args, expr.Function.context)
+	}
+
 	// 左列值 和 右列值进行比较;
 	if expr.OperationVal != nil {
 		switch expr.OperationVal.(type) {
@@ -129,6 +212,63 @@ func EvaluateExpr(expr *Expression, lcols []string, lrows []Value, rcols []strin
 				return nil, err
 			}
 			return OperationCompareValue(lv, rv, expr.OperationVal)
+		case *OperationGreaterEqual:
+			greaterEqual := expr.OperationVal.(*OperationGreaterEqual)
+			lv, err := EvaluateExpr(greaterEqual.Left, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			rv, err := EvaluateExpr(greaterEqual.Right, rcols, rrows, lcols, lrows)
+			if err != nil {
+				return nil, err
+			}
+			return OperationCompareValue(lv, rv, expr.OperationVal)
+		case *OperationLessEqual:
+			lessEqual := expr.OperationVal.(*OperationLessEqual)
+			lv, err := EvaluateExpr(lessEqual.Left, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			rv, err := EvaluateExpr(lessEqual.Right, rcols, rrows, lcols, lrows)
+			if err != nil {
+				return nil, err
+			}
+			return OperationCompareValue(lv, rv, expr.OperationVal)
+		case *OperationAnd:
+			// AND/OR 的两侧都是完整的条件, 使用相同的参数求值;
+			and := expr.OperationVal.(*OperationAnd)
+			lv, err := EvaluateExpr(and.Left, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			rv, err := EvaluateExpr(and.Right, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			return OperationLogicValue(lv, rv, expr.OperationVal)
+		case *OperationOr:
+			or := expr.OperationVal.(*OperationOr)
+			lv, err := EvaluateExpr(or.Left, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			rv, err := EvaluateExpr(or.Right, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			return OperationLogicValue(lv, rv, expr.OperationVal)
+		case *OperationArith:
+			// 算术运算的两侧属于同一行, 使用相同的参数求值;
+			arith := expr.OperationVal.(*OperationArith)
+			lv, err := EvaluateExpr(arith.Left, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			rv, err := EvaluateExpr(arith.Right, lcols, lrows, rcols, rrows)
+			if err != nil {
+				return nil, err
+			}
+			return ArithValue(arith.Operator, lv, rv)
 		}
 		return nil, util.Error("#EvaluateExpr: not support operation")
 	}
@@ -165,10 +305,132 @@ func OperationCompareValue(lv, rv Value, operation Operation) (Value, error) {
 			return nil, util.Error("#OperationCompareValue OperationLessThan can not compare value")
 		}
 		return &ConstBool{Value: false}, nil
+	case *OperationGreaterEqual:
+		if allow, compare := lv.PartialCmp(rv); allow {
+			if compare >= 0 {
+				return &ConstBool{Value: true}, nil
+			}
+		} else {
+			return nil, util.Error("#OperationCompareValue OperationGreaterEqual can not compare value")
+		}
+		return &ConstBool{Value: false}, nil
+	case *OperationLessEqual:
+		if allow, compare := lv.PartialCmp(rv); allow {
+			if compare <= 0 {
+				return &ConstBool{Value: true}, nil
+			}
+		} else {
+			return nil, util.Error("#OperationCompareValue OperationLessEqual can not compare value")
+		}
+		return &ConstBool{Value: false}, nil
 	}
 	return nil, nil
 }
 
+// OperationLogicValue 三值逻辑: NULL 表示未知;
+// AND: 任一侧为 false 即为 false; OR: 任一侧为 true 即为 true;
+func OperationLogicValue(lv, rv Value, operation Operation) (Value, error) {
+	var lb, rb *ConstBool
+	switch lv.(type) {
+	case *ConstBool:
+		lb = lv.(*ConstBool)
+	case *ConstNull:
+	default:
+		return nil, util.Error("#OperationLogicValue left value is not bool")
+	}
+	switch rv.(type) {
+	case *ConstBool:
+		rb = rv.(*ConstBool)
+	case *ConstNull:
+	default:
+		return nil, util.Error("#OperationLogicValue right value is not bool")
+	}
+	switch operation.(type) {
+	case *OperationAnd:
+		if (lb != nil && !lb.Value) || (rb != nil && !rb.Value) {
+			return &ConstBool{Value: false}, nil
+		}
+		if lb != nil && rb != nil {
+			return &ConstBool{Value: true}, nil
+		}
+		return &ConstNull{}, nil
+	case *OperationOr:
+		if (lb != nil && lb.Value) || (rb != nil && rb.Value) {
+			return &ConstBool{Value: true}, nil
+		}
+		if lb != nil && rb != nil {
+			return &ConstBool{Value: false}, nil
+		}
+		return &ConstNull{}, nil
+	}
+	return nil, util.Error("#OperationLogicValue not support operation")
+}
+
+// ArithValue 计算 lv operator rv; 任一侧为 NULL 时结果为 NULL;
+// 整数之间的运算结果为整数, 整数与浮点数混合时为浮点数; 定点数的运算见 decimalArith, 日期时间的运算见 temporalArith;
+func ArithValue(operator ArithOperator, lv, rv Value) (Value, error) {
+	if lv.DateType() == Null || rv.DateType() == Null {
+		return &ConstNull{}, nil
+	}
+	if value, ok, err := decimalArith(operator, lv, rv); ok {
+		return value, err
+	}
+	if value, ok, err := temporalArith(operator, lv, rv); ok {
+		return value, err
+	}
+	li, lInt := lv.(*ConstInt)
+	ri, rInt := rv.(*ConstInt)
+	if lInt && rInt {
+		switch operator {
+		case ArithAdd:
+			return &ConstInt{Value: li.Value + ri.Value}, nil
+		case ArithSub:
+			return &ConstInt{Value: li.Value - ri.Value}, nil
+		case ArithMul:
+			return &ConstInt{Value: li.Value * ri.Value}, nil
+		default:
+			if ri.Value == 0 {
+				return nil, util.Error("division by zero")
+			}
+			return &ConstInt{Value: li.Value / ri.Value}, nil
+		}
+	}
+	if isNumeric(lv) && isNumeric(rv) {
+		l, r := toFloat(lv), toFloat(rv)
+		switch operator {
+		case ArithAdd:
+			return &ConstFloat{Value: l + r}, nil
+		case ArithSub:
+			return &ConstFloat{Value: l - r}, nil
+		case ArithMul:
+			return &ConstFloat{Value: l * r}, nil
+		default:
+			if r == 0 {
+				return nil, util.Error("division by zero")
+			}
+			return &ConstFloat{Value: l / r}, nil
+		}
+	}
+	return nil, util.Error("operator %s does not support %s and %s", operator.ToString(), GetDataTypeInfo(lv.DateType()), GetDataTypeInfo(rv.DateType()))
+}
+
+func isNumeric(value Value) bool {
+	return value.DateType() == Integer || value.DateType() == Float
+}
+
+func toFloat(value Value) float64 {
+	switch v := value.(type) {
+	case *ConstInt:
+		return float64(v.Value)
+	case *ConstFloat:
+		return v.Value
+	case *ConstDecimal:
+		f, _ := v.Into().(*big.Rat).Float64()
+		return f
+	}
+	return 0
+}
+
 func NewExpression(con Const) *Expression {
 	return &Expression{ConstVal: con}
 }
@@ -204,9 +466,85 @@ func (o *OperationLessThan) operation() {
 
 }
 
+type OperationGreaterEqual struct {
+	Left  *Expression
+	Right *Expression
+}
+
+func (o *OperationGreaterEqual) operation() {
+
+}
+
+type OperationLessEqual struct {
+	Left  *Expression
+	Right *Expression
+}
+
+func (o *OperationLessEqual) operation() {
+
+}
+
+type OperationAnd struct {
+	Left  *Expression
+	Right *Expression
+}
+
+func (o *OperationAnd) operation() {
+
+}
+
+type OperationOr struct {
+	Left  *Expression
+	Right *Expression
+}
+
+func (o *OperationOr) operation() {
+
+}
+
+type ArithOperator int32
+
+const (
+	ArithAdd ArithOperator = iota
+	ArithSub
+	ArithMul
+	ArithDiv
+)
+
+func (a ArithOperator) ToString() string {
+	switch a {
+	case ArithAdd:
+		return "+"
+	case ArithSub:
+		return "-"
+	case ArithMul:
+		return "*"
+	default:
+		return "/"
+	}
+}
+
+// OperationArith 含有列或日期时间的算术运算, 执行时逐行计算; 纯数值常量的运算在解析时已经算出结果;
+type OperationArith struct {
+	Operator ArithOperator
+	Left     *Expression
+	Right    *Expression
+}
+
+func (o *OperationArith) operation() {
+
+}
+
 type Function struct {
 	FuncName string
 	ColName  string
+	Args     []*Expression   // 标量函数的参数, 见 IsScalarFunction; 聚合函数只使用 ColName;
+	context  FunctionContext // 绑定的事务, 见 BindFunctions;
+}
+
+// ExcludedField ON CONFLICT DO UPDATE 中 EXCLUDED.col 对应的字段名;
+func ExcludedField(colName string) string {
+	return "excluded." + colName
 }
 
 type Const interface {
@@ -255,6 +593,9 @@ func (i *ConstInt) PartialCmp(c Const) (bool, int) {
 			return true, -1
 		}
 		return true, 1
+	case *ConstDecimal:
+		ok, cmp := c.PartialCmp(i)
+		return ok, -cmp
 	case *ConstNull:
 		// 任何数据类型 vs null 类型, 结果都比null值大;
 		return true, 1
@@ -302,6 +643,9 @@ func (f *ConstFloat) PartialCmp(c Const) (bool, int) {
 			return true, -1
 		}
 		return true, 1
+	case *ConstDecimal:
+		ok, cmp := c.PartialCmp(f)
+		return ok, -cmp
 	case *ConstNull:
 		return true, 1
 	default:
@@ -310,7 +654,8 @@ func (f *ConstFloat) PartialCmp(c Const) (bool, int) {
 }
 
 type ConstString struct {
-	Value string
+	Value     string
+	Collation string // 排序规则, 来自 COLLATE nocase 的列; 为空时按字节比较, 见 collation.go;
 }
 
 func NewConstString(value string) *ConstString {
@@ -318,7 +663,7 @@ func NewConstString(value string) *ConstString {
 }
 
 func (s *ConstString) Hash() uint32 {
-	return util.Hash(s.Bytes())
+	return util.Hash([]byte(s.collationKey()))
 }
 func (s *ConstString) Bytes() []byte {
 	return []byte(s.Value)
@@ -335,7 +680,12 @@ func (s *ConstString) DateType() DataType {
 func (s *ConstString) PartialCmp(c Const) (bool, int) {
 	switch c.(type) {
 	case *ConstString:
-		return true, strings.Compare(s.Value, c.(*ConstString).Value)
+		// 任意一侧不区分大小写时, 按不区分大小写比较;
+		other := c.(*ConstString)
+		if s.caseInsensitive() || other.caseInsensitive() {
+			return true, strings.Compare(foldCase(s.Value), foldCase(other.Value))
+		}
+		return true, strings.Compare(s.Value, other.Value)
 	case *ConstNull:
 		return true, 1
 	default:
@@ -435,6 +785,78 @@ func (d *DropTableResult) ToString() string {
 	return fmt.Sprintf("DROP TABLE: %s", d.TableName)
 }
 
+type TruncateTableResult struct {
+	TableName string
+}
+
+func (t *TruncateTableResult) ToString() string {
+	return fmt.Sprintf("TRUNCATE TABLE: %s", t.TableName)
+}
+
+type CreateIndexResult struct {
+	IndexName string
+}
+
+func (c *CreateIndexResult) ToString() string {
+	return fmt.Sprintf("CREATE INDEX: %s", c.IndexName)
+}
+
+type DropIndexResult struct {
+	IndexName string
+}
+
+func (d *DropIndexResult) ToString() string {
+	return fmt.Sprintf("DROP INDEX: %s", d.IndexName)
+}
+
+type AlterTableResult struct {
+	TableName string
+}
+
+func (a *AlterTableResult) ToString() string {
+	return fmt.Sprintf("ALTER TABLE: %s", a.TableName)
+}
+
+type CreateSequenceResult struct {
+	SequenceName string
+}
+
+func (c *CreateSequenceResult) ToString() string {
+	return fmt.Sprintf("CREATE SEQUENCE: %s", c.SequenceName)
+}
+
+type DropSequenceResult struct {
+	SequenceName string
+}
+
+func (d *DropSequenceResult) ToString() string {
+	return fmt.Sprintf("DROP SEQUENCE: %s", d.SequenceName)
+}
+
+type CreateViewResult struct {
+	ViewName string
+}
+
+func (c *CreateViewResult) ToString() string {
+	return fmt.Sprintf("CREATE VIEW: %s", c.ViewName)
+}
+
+type DropViewResult struct {
+	ViewName string
+}
+
+func (d *DropViewResult) ToString() string {
+	return fmt.Sprintf("DROP VIEW: %s", d.ViewName)
+}
+
+type RefreshViewResult struct {
+	ViewName string
+}
+
+func (r *RefreshViewResult) ToString() string {
+	return fmt.Sprintf("REFRESH MATERIALIZED VIEW: %s", r.ViewName)
+}
+
 type InsertTableResult struct {
 	Count int
 }
@@ -458,8 +880,8 @@ func FormatScanResult(columns []string, rows []Row) string {
 	// 遍历所有行，更新最大长度
 	for _, row := range rows {
 		for i, value := range row {
-			if len(value.Bytes()) > maxLen[i] {
-				maxLen[i] = len(value.Bytes())
+			if len(FormatValue(value)) > maxLen[i] {
+				maxLen[i] = len(FormatValue(value))
 			}
 		}
 	}
@@ -504,7 +926,7 @@ func formatRows(rows []Row, maxLen []int) string {
 	for rowIdx, row := range rows {
 		cells := make([]string, len(row))
 		for i, value := range row {
-			strValue := string(value.Bytes())
+			strValue := FormatValue(value)
 			// 使用左对齐，宽度为 maxLen[i]
 			cells[i] = fmt.Sprintf("%-*s", maxLen[i], strValue)
 		}
@@ -522,7 +944,7 @@ type ScanTableResult struct {
 func RowsToString(row Row) string {
 	var buf strings.Builder
 	for _, value := range row {
-		buf.WriteString(string(value.Bytes()))
+		buf.WriteString(FormatValue(value))
 		buf.WriteString(" |")
 	}
 	return buf.String()
@@ -598,6 +1020,33 @@ func (b *RollbackResult) ToString() string {
 
 }
 
+// SavepointResult SAVEPOINT、ROLLBACK TO SAVEPOINT、RELEASE SAVEPOINT 的结果;
+type SavepointResult struct {
+	Command string
+	Name    string
+}
+
+func (s *SavepointResult) ToString() string {
+	return fmt.Sprintf("%s %s", s.Command, s.Name)
+}
+
+// SetResult SET SESSION CHARACTERISTICS 的结果;
+type SetResult struct {
+}
+
+func (s *SetResult) ToString() string {
+	return "SET"
+}
+
+// WarningResult 语句没有执行任何操作, 但不是错误, 例如没有事务时的 COMMIT;
+type WarningResult struct {
+	Message string
+}
+
+func (w *WarningResult) ToString() string {
+	return fmt.Sprintf("WARNING: %s", w.Message)
+}
+
 type ErrorResult struct {
 	ErrorMessage string
 }
@@ -605,13 +1054,3 @@ type ErrorResult struct {
 func (e *ErrorResult) ToString() string {
 	return fmt.Sprintf("ERROR: %s", e.ErrorMessage)
 }
-func Remove(source []Value, value Value) []Value {
-	for i, v := range source {
-		if allow, compare := v.PartialCmp(value); allow {
-			if compare == 0 {
-				return append(source[:i], source[i+1:]...)
-			}
-		}
-	}
-	return source
-}
@@ -3,11 +3,19 @@ package types
 import (
 	"fmt"
 	"github.com/kebukeYi/TrainSQL/sql/util"
+	"slices"
+	"strings"
 )
 
 type Table struct {
-	Name    string
-	Columns []ColumnV
+	Name        string
+	Columns     []ColumnV
+	Indexes     []Index  // CREATE INDEX 创建的具名索引; 列上的 INDEX 关键字仍记录在 ColumnV.IsIndex;
+	PrimaryKeys []string // PRIMARY KEY (a, b) 表约束声明的联合主键, 按声明顺序; 单列主键时为空;
+	ForeignKeys []ForeignKey
+	Checks      []Check
+	KeySpace    string // 行与索引数据的 key 使用的表名; RENAME TO 只修改 Name, 数据不需要搬迁;
+	NextSlot    int    // 下一个新增列分配的存储位置, 见 ColumnV.Slot;
 }
 
 func (t *Table) Validate() error {
@@ -16,38 +24,303 @@ func (t *Table) Validate() error {
 	}
 	// 校验是否有主键
 	count := 0
-	for _, column := range t.Columns {
+	for i, column := range t.Columns {
 		if column.PrimaryKey {
 			count++
 		}
+		if column.DataType == Decimal && (column.Precision < 0 || column.Scale < 0 || column.Scale > column.Precision && column.Precision != 0) {
+			return util.Error("[Table] %s column %s has invalid %s", t.Name, column.Name, DecimalTypeName(column.Precision, column.Scale))
+		}
+		if (column.Length != 0 || column.Collation != "") && column.DataType != String {
+			return util.Error("[Table] %s column %s: length and COLLATE only apply to string columns", t.Name, column.Name)
+		}
+		if column.Length < 0 {
+			return util.Error("[Table] %s column %s has invalid %s", t.Name, column.Name, StringTypeName(column.Length))
+		}
 		if column.PrimaryKey && column.Nullable {
 			return util.Error("[Table] %s column %s can not be nullable", t.Name, column.Name)
 		}
+		if column.Sequence != "" && column.DataType != Integer {
+			return util.Error("[Table] %s auto increment column %s must be integer", t.Name, column.Name)
+		}
 		if column.DefaultValue != nil {
 			// 尽管列的定义是 int string bool, float, 但是仍允许列为 默认值为  NULL;
 			if column.DefaultValue.DateType() == Null {
 				continue
 			}
+			// DECIMAL 列的默认值按列的精度保存, JSON 列的默认值规范化后保存, 字符串列的默认值校验长度;
+			value, err := column.CastValue(column.DefaultValue)
+			if err != nil {
+				return err
+			}
+			t.Columns[i].DefaultValue, column.DefaultValue = value, value
 			if column.DefaultValue.DateType() != column.DataType {
 				return util.Error("[Table] %s column %s default value type %d not match %d", t.Name, column.Name, column.DefaultValue.DateType(), column.DataType)
 			}
 		}
 	}
-	if count != 1 {
+	if count == 0 {
 		return util.Error("[Table] %s has no primary key", t.Name)
 	}
+	// 多个主键列只能通过 PRIMARY KEY (a, b) 表约束声明;
+	if len(t.PrimaryKeys) == 0 && count > 1 {
+		return util.Error("[Table] %s has multiple primary keys, use PRIMARY KEY (...) instead", t.Name)
+	}
+	if len(t.PrimaryKeys) > 0 && len(t.PrimaryKeys) != count {
+		return util.Error("[Table] %s primary key columns not match", t.Name)
+	}
+	for _, colName := range t.PrimaryKeys {
+		pos := t.GetColumnIndex(colName)
+		if pos == -1 || !t.Columns[pos].PrimaryKey {
+			return util.Error("[Table] %s primary key column %s not exists", t.Name, colName)
+		}
+	}
+	names := make(map[string]bool)
+	for _, index := range t.GetIndexes() {
+		if names[index.Name] {
+			return util.Error("[Table] %s index %s already exists", t.Name, index.Name)
+		}
+		names[index.Name] = true
+		if err := t.validateIndex(&index); err != nil {
+			return err
+		}
+	}
+	for _, foreignKey := range t.ForeignKeys {
+		if names[foreignKey.Name] {
+			return util.Error("[Table] %s constraint %s already exists", t.Name, foreignKey.Name)
+		}
+		names[foreignKey.Name] = true
+		if err := t.validateForeignKey(&foreignKey); err != nil {
+			return err
+		}
+	}
+	for _, check := range t.Checks {
+		if names[check.Name] {
+			return util.Error("[Table] %s constraint %s already exists", t.Name, check.Name)
+		}
+		names[check.Name] = true
+		if err := t.validateCheck(&check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateForeignKey 只校验本表内的部分, 被引用表由 KVService.CreateTable 校验;
+func (t *Table) validateForeignKey(foreignKey *ForeignKey) error {
+	if len(foreignKey.Columns) == 0 || len(foreignKey.Columns) != len(foreignKey.RefColumns) {
+		return util.Error("[Table] %s foreign key %s columns not match referenced columns", t.Name, foreignKey.Name)
+	}
+	seen := make(map[string]bool)
+	for _, colName := range foreignKey.Columns {
+		pos := t.GetColumnIndex(colName)
+		if pos == -1 {
+			return util.Error("[Table] %s foreign key %s column %s not exists", t.Name, foreignKey.Name, colName)
+		}
+		if seen[colName] {
+			return util.Error("[Table] %s foreign key %s column %s is duplicated", t.Name, foreignKey.Name, colName)
+		}
+		seen[colName] = true
+		if (foreignKey.OnDelete == ActionSetNull || foreignKey.OnUpdate == ActionSetNull) && !t.Columns[pos].Nullable {
+			return util.Error("[Table] %s foreign key %s column %s is not nullable, can not SET NULL", t.Name, foreignKey.Name, colName)
+		}
+	}
+	return nil
+}
+
+func (t *Table) validateIndex(index *Index) error {
+	if len(index.Columns) == 0 {
+		return util.Error("[Table] %s index %s has no columns", t.Name, index.Name)
+	}
+	seen := make(map[string]bool)
+	for pos, colName := range index.Columns {
+		if expr := index.Expression(pos); expr != nil {
+			// 表达式只能引用本表的列, 只能使用结果稳定的函数;
+			if _, err := t.checkExprType(expr); err != nil {
+				return util.Error("[Table] %s index %s expression (%s): %s", t.Name, index.Name, colName, err)
+			}
+		} else if t.GetColumnIndex(colName) == -1 {
+			return util.Error("[Table] %s index %s column %s not exists", t.Name, index.Name, colName)
+		}
+		if seen[colName] {
+			return util.Error("[Table] %s index %s column %s is duplicated", t.Name, index.Name, colName)
+		}
+		seen[colName] = true
+	}
+	return nil
+}
+
+// GetIndexValues 按索引列的顺序取出行中对应的值, 表达式索引项按这一行计算;
+func (t *Table) GetIndexValues(index *Index, row Row) ([]Value, error) {
+	if index.Expressions == nil {
+		return t.GetColumnValues(index.Columns, row), nil
+	}
+	values := make([]Value, 0, len(index.Columns))
+	for pos, colName := range index.Columns {
+		expr := index.Expression(pos)
+		if expr == nil {
+			values = append(values, row[t.GetColumnIndex(colName)])
+			continue
+		}
+		cols := t.GetColumnNames()
+		value, err := EvaluateExpr(expr, cols, row, cols, row)
+		if err != nil {
+			return nil, util.Error("[Table] %s index %s expression (%s): %s", t.Name, index.Name, colName, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// IndexColumn 第 pos 个索引项对应的列; 表达式索引项返回以表达式文本命名、类型为表达式结果类型的列;
+func (t *Table) IndexColumn(index *Index, pos int) ColumnV {
+	expr := index.Expression(pos)
+	if expr == nil {
+		return t.Columns[t.GetColumnIndex(index.Columns[pos])]
+	}
+	dataType, err := t.checkExprType(expr)
+	if err != nil {
+		dataType = Null
+	}
+	return ColumnV{Name: index.Columns[pos], DataType: dataType, Nullable: true}
+}
+
+// GetColumnValues 按给定列的顺序取出行中对应的值;
+func (t *Table) GetColumnValues(columns []string, row Row) []Value {
+	values := make([]Value, 0, len(columns))
+	for _, colName := range columns {
+		values = append(values, row[t.GetColumnIndex(colName)])
+	}
+	return values
+}
+
+// AddIndex 校验并追加一个具名索引;
+// 隐式索引以列名命名, 因此具名索引不能与任何列重名;
+func (t *Table) AddIndex(index Index) error {
+	if t.GetColumnIndex(index.Name) != -1 {
+		return util.Error("[Table] %s index name %s conflicts with column", t.Name, index.Name)
+	}
+	if t.GetIndex(index.Name) != nil {
+		return util.Error("[Table] %s index %s already exists", t.Name, index.Name)
+	}
+	if err := t.validateIndex(&index); err != nil {
+		return err
+	}
+	t.Indexes = append(t.Indexes, index)
+	return nil
+}
+
+// RemoveIndex 删除索引定义; 列上声明的隐式索引通过清除 IsIndex 标记删除;
+func (t *Table) RemoveIndex(indexName string) bool {
+	for i, index := range t.Indexes {
+		if index.Name == indexName {
+			t.Indexes = append(t.Indexes[:i], t.Indexes[i+1:]...)
+			return true
+		}
+	}
+	for i, column := range t.Columns {
+		if column.IsIndex && column.Name == indexName {
+			t.Columns[i].IsIndex = false
+			return true
+		}
+	}
+	return false
+}
+
+// GetIndexes 返回表上的全部索引;
+// 列上 INDEX 关键字声明的索引, 以列名作为索引名;
+func (t *Table) GetIndexes() []Index {
+	indexes := make([]Index, 0, len(t.Indexes))
+	for _, column := range t.Columns {
+		if column.IsIndex {
+			indexes = append(indexes, Index{Name: column.Name, Columns: []string{column.Name}})
+		}
+	}
+	return append(indexes, t.Indexes...)
+}
+
+func (t *Table) GetIndex(indexName string) *Index {
+	for _, index := range t.GetIndexes() {
+		if index.Name == indexName {
+			return &index
+		}
+	}
 	return nil
 }
 
-func (t *Table) GetPrimaryKeyOfValue(row Row) Value {
+func (t *Table) SetIndexState(indexName string, state IndexState) bool {
+	for i, index := range t.Indexes {
+		if index.Name == indexName {
+			t.Indexes[i].State = state
+			return true
+		}
+	}
+	return false
+}
+
+// GetColumnIndex 返回列在表中的位置, 不存在返回 -1;
+func (t *Table) GetColumnIndex(colName string) int {
 	for i, column := range t.Columns {
+		if column.Name == colName {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetColumnNames 按列的顺序返回列名;
+func (t *Table) GetColumnNames() []string {
+	names := make([]string, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// UniqueConstraintName UNIQUE 约束对应的唯一索引名: <table>_<col1>_<col2>_key;
+func UniqueConstraintName(tableName string, columns []string) string {
+	return tableName + "_" + strings.Join(columns, "_") + "_key"
+}
+
+// GetPrimaryKeys 返回主键列名, 联合主键按声明顺序返回;
+func (t *Table) GetPrimaryKeys() []string {
+	if len(t.PrimaryKeys) > 0 {
+		return t.PrimaryKeys
+	}
+	for _, column := range t.Columns {
 		if column.PrimaryKey {
-			return row[i]
+			return []string{column.Name}
+		}
+	}
+	return nil
+}
+
+// IsPrimaryKey 判断给定的列(按顺序)是否恰好是主键;
+func (t *Table) IsPrimaryKey(columns []string) bool {
+	return slices.Equal(t.GetPrimaryKeys(), columns)
+}
+
+// GetUniqueIndex 查找列(按顺序)恰好为 columns 的可用唯一索引;
+func (t *Table) GetUniqueIndex(columns []string) *Index {
+	indexes := t.GetIndexes()
+	for i := range indexes {
+		if indexes[i].Unique && indexes[i].State == IndexPublic && slices.Equal(indexes[i].Columns, columns) {
+			return &indexes[i]
 		}
 	}
 	return nil
 }
 
+// GetPrimaryKeyOfValue 按主键列的顺序取出行中的主键值;
+func (t *Table) GetPrimaryKeyOfValue(row Row) []Value {
+	primaryKeys := t.GetPrimaryKeys()
+	values := make([]Value, 0, len(primaryKeys))
+	for _, colName := range primaryKeys {
+		values = append(values, row[t.GetColumnIndex(colName)])
+	}
+	return values
+}
+
 func (t *Table) ToString() string {
 	str := fmt.Sprintf("TABLE_NAME: %s\n", t.Name)
 	str += "COLUMNS: { \n"
@@ -55,7 +328,130 @@ func (t *Table) ToString() string {
 		str += column.ToString()
 		str += "\n"
 	}
-	return str + "}"
+	if len(t.PrimaryKeys) > 1 {
+		str += fmt.Sprintf("PRIMARY KEY (%s)\n", strings.Join(t.PrimaryKeys, ", "))
+	}
+	str += "}"
+	if indexes := t.GetIndexes(); len(indexes) > 0 {
+		str += "\nINDEXES: { \n"
+		for _, index := range indexes {
+			str += index.ToString()
+			str += "\n"
+		}
+		str += "}"
+	}
+	if len(t.ForeignKeys) > 0 {
+		str += "\nFOREIGN KEYS: { \n"
+		for _, foreignKey := range t.ForeignKeys {
+			str += foreignKey.ToString()
+			str += "\n"
+		}
+		str += "}"
+	}
+	if len(t.Checks) > 0 {
+		str += "\nCHECKS: { \n"
+		for _, check := range t.Checks {
+			str += check.ToString()
+			str += "\n"
+		}
+		str += "}"
+	}
+	return str
+}
+
+type IndexState int32
+
+const (
+	IndexPublic    IndexState = iota // 可用于查询;
+	IndexWriteOnly                   // 回填中: 写入时维护, 查询时不可用;
+)
+
+type Index struct {
+	Name        string
+	Columns     []string
+	Expressions []*Expression // 表达式索引: 与 Columns 一一对应, 不为空的项按表达式计算, Columns 中为表达式的文本; 普通索引为空;
+	Unique      bool
+	State       IndexState
+}
+
+// Expression 第 pos 个索引项的表达式, 普通列返回 nil;
+func (i *Index) Expression(pos int) *Expression {
+	if pos < len(i.Expressions) {
+		return i.Expressions[pos]
+	}
+	return nil
+}
+
+// References 索引是否引用了列 colName;
+func (i *Index) References(colName string) bool {
+	for pos, column := range i.Columns {
+		if expr := i.Expression(pos); expr != nil {
+			if slices.Contains(ExpressionFields(expr), colName) {
+				return true
+			}
+		} else if column == colName {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Index) ToString() string {
+	columns := make([]string, 0, len(i.Columns))
+	for pos, column := range i.Columns {
+		if i.Expression(pos) != nil {
+			column = "(" + column + ")"
+		}
+		columns = append(columns, column)
+	}
+	desc := fmt.Sprintf("%s (%s)", i.Name, strings.Join(columns, ", "))
+	if i.Unique {
+		desc += " UNIQUE"
+	}
+	if i.State == IndexWriteOnly {
+		desc += " BUILDING"
+	}
+	return desc
+}
+
+// ReferentialAction 被引用的行删除或更新时, 对引用它的子行采取的动作;
+type ReferentialAction int32
+
+const (
+	ActionRestrict ReferentialAction = iota // 存在子行时拒绝, 默认动作;
+	ActionCascade                           // 级联删除或更新子行;
+	ActionSetNull                           // 子行的外键列置为 NULL;
+)
+
+func (a ReferentialAction) ToString() string {
+	switch a {
+	case ActionCascade:
+		return "CASCADE"
+	case ActionSetNull:
+		return "SET NULL"
+	default:
+		return "RESTRICT"
+	}
+}
+
+// ForeignKey 外键: Columns 引用 RefTable 的 RefColumns; RefColumns 必须是被引用表的主键或唯一约束;
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string // 建表时为空表示引用被引用表的主键;
+	OnDelete   ReferentialAction
+	OnUpdate   ReferentialAction
+}
+
+func (f *ForeignKey) ToString() string {
+	return fmt.Sprintf("%s (%s) REFERENCES %s (%s) ON DELETE %s ON UPDATE %s", f.Name, strings.Join(f.Columns, ", "),
+		f.RefTable, strings.Join(f.RefColumns, ", "), f.OnDelete.ToString(), f.OnUpdate.ToString())
+}
+
+// ForeignKeyConstraintName 外键约束名: <table>_<col1>_<col2>_fkey;
+func ForeignKeyConstraintName(tableName string, columns []string) string {
+	return tableName + "_" + strings.Join(columns, "_") + "_fkey"
 }
 
 type ColumnV struct {
@@ -65,11 +461,58 @@ type ColumnV struct {
 	DefaultValue Value
 	PrimaryKey   bool
 	IsIndex      bool
+	Slot         int    // 列在存储的行中的位置, 分配后不再改变;
+	MissingValue Value  // ADD COLUMN 之前写入的行没有这一列, 读取时取这个值;
+	Sequence     string // AUTO_INCREMENT 列使用的序列, 插入时未指定这一列则取序列的下一个值;
+	Precision    int32  // DECIMAL(p, s) 的总位数 p, 0 表示不限制;
+	Scale        int32  // DECIMAL(p, s) 的小数位数 s;
+	Length       int32  // VARCHAR(n) / CHAR(n) 的最大字符数 n, 0 表示不限制;
+	Collation    string // 字符串列的排序规则, 为空即 binary;
+}
+
+// CastValue 写入列之前按列的类型转换值: 先按隐式转换规则转换类型 (见 CoerceValue), 再按列的定义调整:
+// DECIMAL 列按精度取整, 字符串列校验长度并带上排序规则; 不能转换时原样返回, 由调用方校验类型;
+func (c *ColumnV) CastValue(value Value) (Value, error) {
+	value, err := CoerceValue(value, c.DataType)
+	if err != nil {
+		return nil, util.Error("[Table] column %s %s", c.Name, err)
+	}
+	switch c.DataType {
+	case Decimal:
+		return c.castDecimal(value)
+	case String:
+		return c.castString(value)
+	}
+	return value, nil
+}
+
+// CastValues 写入一行之前转换各列的值, 见 ColumnV.CastValue;
+func (t *Table) CastValues(row Row) error {
+	for i := range t.Columns {
+		if i >= len(row) {
+			break
+		}
+		value, err := t.Columns[i].CastValue(row[i])
+		if err != nil {
+			return err
+		}
+		row[i] = value
+	}
+	return nil
 }
 
 func (c *ColumnV) ToString() string {
 	dataTypeInfo := GetDataTypeInfo(c.DataType)
+	if c.DataType == Decimal {
+		dataTypeInfo = DecimalTypeName(c.Precision, c.Scale)
+	}
+	if c.DataType == String {
+		dataTypeInfo = StringTypeName(c.Length)
+	}
 	col_desc := fmt.Sprintf("%s %s", c.Name, dataTypeInfo)
+	if c.Collation != "" {
+		col_desc += " COLLATE " + c.Collation
+	}
 	if c.PrimaryKey {
 		col_desc += " PRIMARY KEY"
 	}
@@ -79,5 +522,8 @@ func (c *ColumnV) ToString() string {
 	if c.DefaultValue != nil {
 		col_desc += " DEFAULT " + string(c.DefaultValue.Bytes())
 	}
+	if c.Sequence != "" {
+		col_desc += " AUTO_INCREMENT"
+	}
 	return col_desc
 }
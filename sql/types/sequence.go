@@ -0,0 +1,35 @@
+package types
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+)
+
+// Sequence 序列: 定义随事务提交, 已分配的值由存储层在 MVCC 之外计数, 第 n 个值为 Start + n*Increment;
+type Sequence struct {
+	Name      string
+	Id        uint64 // 计数器标识; 删除后重建的同名序列使用新的计数器, 从 Start 重新开始;
+	Start     int64
+	Increment int64
+}
+
+func (s *Sequence) Validate() error {
+	if s.Increment == 0 {
+		return util.Error("[Sequence] %s increment must not be zero", s.Name)
+	}
+	return nil
+}
+
+// Value 第 n 个(从 0 开始)分配的值;
+func (s *Sequence) Value(n uint64) int64 {
+	return s.Start + int64(n)*s.Increment
+}
+
+func (s *Sequence) ToString() string {
+	return fmt.Sprintf("%s START %d INCREMENT %d", s.Name, s.Start, s.Increment)
+}
+
+// SequenceName AUTO_INCREMENT 列使用的序列名: <table>_<column>_seq;
+func SequenceName(tableName string, colName string) string {
+	return tableName + "_" + colName + "_seq"
+}
@@ -0,0 +1,585 @@
+package types
+
+import (
+	"fmt"
+	"github.com/kebukeYi/TrainSQL/sql/util"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日期与时间类型, 一律按 UTC 处理, 不带时区:
+// DATE 保存距 1970-01-01 的天数, TIME 保存距零点的微秒数, TIMESTAMP 保存距 1970-01-01 00:00:00 的微秒数;
+// INTERVAL 分别保存月、天与微秒: 一个月的天数不固定, 与 PostgreSQL 一样不在三者之间换算, 只在比较大小时按 1 月 = 30 天折算;
+
+const (
+	microsPerSecond = int64(1000000)
+	microsPerMinute = 60 * microsPerSecond
+	microsPerHour   = 60 * microsPerMinute
+	microsPerDay    = 24 * microsPerHour
+	daysPerMonth    = 30
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timeLayout      = "15:04:05.999999"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+type ConstDate struct {
+	Value int64
+}
+
+func (d *ConstDate) Hash() uint32 {
+	return util.Hash(d.Bytes())
+}
+func (d *ConstDate) Into() interface{} {
+	return d.Time()
+}
+func (d *ConstDate) Bytes() []byte {
+	return []byte(d.Time().Format(dateLayout))
+}
+func (d *ConstDate) DateType() DataType {
+	return Date
+}
+
+// Time 当天零点;
+func (d *ConstDate) Time() time.Time {
+	return time.UnixMicro(d.Value * microsPerDay).UTC()
+}
+
+// Timestamp 当天零点的时间戳;
+func (d *ConstDate) Timestamp() *ConstTimestamp {
+	return &ConstTimestamp{Value: d.Value * microsPerDay}
+}
+func (d *ConstDate) PartialCmp(c Const) (bool, int) {
+	switch c.(type) {
+	case *ConstDate:
+		return true, compareInt64(d.Value, c.(*ConstDate).Value)
+	case *ConstTimestamp:
+		return true, compareInt64(d.Value*microsPerDay, c.(*ConstTimestamp).Value)
+	case *ConstNull:
+		return true, 1
+	default:
+		return false, 0
+	}
+}
+
+type ConstTime struct {
+	Value int64
+}
+
+func (t *ConstTime) Hash() uint32 {
+	return util.Hash(t.Bytes())
+}
+func (t *ConstTime) Into() interface{} {
+	return time.Duration(t.Value) * time.Microsecond
+}
+func (t *ConstTime) Bytes() []byte {
+	return []byte(time.UnixMicro(t.Value).UTC().Format(timeLayout))
+}
+func (t *ConstTime) DateType() DataType {
+	return Time
+}
+func (t *ConstTime) PartialCmp(c Const) (bool, int) {
+	switch c.(type) {
+	case *ConstTime:
+		return true, compareInt64(t.Value, c.(*ConstTime).Value)
+	case *ConstNull:
+		return true, 1
+	default:
+		return false, 0
+	}
+}
+
+type ConstTimestamp struct {
+	Value int64
+}
+
+// NowTimestamp 当前时间, 精确到微秒;
+func NowTimestamp() *ConstTimestamp {
+	return &ConstTimestamp{Value: time.Now().UnixMicro()}
+}
+
+func (t *ConstTimestamp) Hash() uint32 {
+	return util.Hash(t.Bytes())
+}
+func (t *ConstTimestamp) Into() interface{} {
+	return t.Time()
+}
+func (t *ConstTimestamp) Bytes() []byte {
+	return []byte(t.Time().Format(timestampLayout))
+}
+func (t *ConstTimestamp) DateType() DataType {
+	return Timestamp
+}
+func (t *ConstTimestamp) Time() time.Time {
+	return time.UnixMicro(t.Value).UTC()
+}
+func (t *ConstTimestamp) PartialCmp(c Const) (bool, int) {
+	switch c.(type) {
+	case *ConstTimestamp:
+		return true, compareInt64(t.Value, c.(*ConstTimestamp).Value)
+	case *ConstDate:
+		return true, compareInt64(t.Value, c.(*ConstDate).Value*microsPerDay)
+	case *ConstNull:
+		return true, 1
+	default:
+		return false, 0
+	}
+}
+
+type ConstInterval struct {
+	Months int64
+	Days   int64
+	Micros int64
+}
+
+func (i *ConstInterval) Hash() uint32 {
+	return util.Hash(i.Bytes())
+}
+func (i *ConstInterval) Into() interface{} {
+	return i.Bytes()
+}
+
+// Bytes 与 PostgreSQL 的输出格式一致: 1 year 2 mons 3 days 04:05:06;
+func (i *ConstInterval) Bytes() []byte {
+	parts := make([]string, 0)
+	plural := func(n int64, unit string) string {
+		if n == 1 || n == -1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	if years := i.Months / 12; years != 0 {
+		parts = append(parts, plural(years, "year"))
+	}
+	if months := i.Months % 12; months != 0 {
+		parts = append(parts, plural(months, "mon"))
+	}
+	if i.Days != 0 {
+		parts = append(parts, plural(i.Days, "day"))
+	}
+	if i.Micros != 0 || len(parts) == 0 {
+		micros, sign := i.Micros, ""
+		if micros < 0 {
+			micros, sign = -micros, "-"
+		}
+		parts = append(parts, sign+formatClock(micros))
+	}
+	return []byte(strings.Join(parts, " "))
+}
+func (i *ConstInterval) DateType() DataType {
+	return Interval
+}
+
+// approxMicros 比较大小时使用的长度: 1 月按 30 天, 1 天按 24 小时折算;
+func (i *ConstInterval) approxMicros() int64 {
+	return (i.Months*daysPerMonth+i.Days)*microsPerDay + i.Micros
+}
+func (i *ConstInterval) PartialCmp(c Const) (bool, int) {
+	switch c.(type) {
+	case *ConstInterval:
+		return true, compareInt64(i.approxMicros(), c.(*ConstInterval).approxMicros())
+	case *ConstNull:
+		return true, 1
+	default:
+		return false, 0
+	}
+}
+
+// negate 取反, 用于减法;
+func (i *ConstInterval) negate() *ConstInterval {
+	return &ConstInterval{Months: -i.Months, Days: -i.Days, Micros: -i.Micros}
+}
+
+func compareInt64(l, r int64) int {
+	if l == r {
+		return 0
+	}
+	if l < r {
+		return -1
+	}
+	return 1
+}
+
+// formatClock 非负的微秒数格式化为 hh:mm:ss[.ffffff], 小时数可以超过 24;
+func formatClock(micros int64) string {
+	hours := micros / microsPerHour
+	minutes := micros % microsPerHour / microsPerMinute
+	seconds := micros % microsPerMinute / microsPerSecond
+	clock := fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+	if fraction := micros % microsPerSecond; fraction != 0 {
+		clock += strings.TrimRight(fmt.Sprintf(".%06d", fraction), "0")
+	}
+	return clock
+}
+
+// ParseDate 解析 DATE 字面量: YYYY-MM-DD;
+func ParseDate(s string) (*ConstDate, error) {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return nil, util.Error("invalid input syntax for type date: '%s'", s)
+	}
+	return &ConstDate{Value: t.Unix() / (microsPerDay / microsPerSecond)}, nil
+}
+
+// ParseTime 解析 TIME 字面量: HH:MM[:SS[.ffffff]];
+func ParseTime(s string) (*ConstTime, error) {
+	for _, layout := range []string{"15:04:05.999999999", "15:04"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			clock := int64(t.Hour())*microsPerHour + int64(t.Minute())*microsPerMinute + int64(t.Second())*microsPerSecond
+			return &ConstTime{Value: clock + int64(t.Nanosecond())/1000}, nil
+		}
+	}
+	return nil, util.Error("invalid input syntax for type time: '%s'", s)
+}
+
+// ParseTimestamp 解析 TIMESTAMP 字面量: YYYY-MM-DD[( |T)HH:MM[:SS[.ffffff]]];
+func ParseTimestamp(s string) (*ConstTimestamp, error) {
+	layouts := []string{"2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "2006-01-02 15:04", "2006-01-02T15:04", dateLayout}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return &ConstTimestamp{Value: t.UnixMicro()}, nil
+		}
+	}
+	return nil, util.Error("invalid input syntax for type timestamp: '%s'", s)
+}
+
+// intervalUnits INTERVAL 字面量中的单位: 月、天或微秒, 以及每单位的数量;
+var intervalUnits = map[string]struct {
+	field  byte // 'M' 月, 'D' 天, 'U' 微秒;
+	amount int64
+}{
+	"year": {'M', 12}, "years": {'M', 12}, "y": {'M', 12},
+	"month": {'M', 1}, "months": {'M', 1}, "mon": {'M', 1}, "mons": {'M', 1},
+	"week": {'D', 7}, "weeks": {'D', 7}, "w": {'D', 7},
+	"day": {'D', 1}, "days": {'D', 1}, "d": {'D', 1},
+	"hour": {'U', microsPerHour}, "hours": {'U', microsPerHour}, "h": {'U', microsPerHour},
+	"minute": {'U', microsPerMinute}, "minutes": {'U', microsPerMinute}, "min": {'U', microsPerMinute}, "mins": {'U', microsPerMinute}, "m": {'U', microsPerMinute},
+	"second": {'U', microsPerSecond}, "seconds": {'U', microsPerSecond}, "sec": {'U', microsPerSecond}, "secs": {'U', microsPerSecond}, "s": {'U', microsPerSecond},
+	"millisecond": {'U', 1000}, "milliseconds": {'U', 1000}, "ms": {'U', 1000},
+	"microsecond": {'U', 1}, "microseconds": {'U', 1}, "us": {'U', 1},
+}
+
+// ParseInterval 解析 INTERVAL 字面量: '1 year 2 months', '3 days 04:05:06', '-90 minutes';
+// 年、月、周、天的数量必须是整数, 小时及以下的单位可以带小数;
+func ParseInterval(s string) (*ConstInterval, error) {
+	interval := &ConstInterval{}
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return nil, util.Error("invalid input syntax for type interval: '%s'", s)
+	}
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		// hh:mm[:ss[.ffffff]], 可以带负号;
+		if strings.Contains(field, ":") {
+			sign := int64(1)
+			if strings.HasPrefix(field, "-") {
+				sign, field = -1, field[1:]
+			}
+			parts := strings.Split(field, ":")
+			if len(parts) > 3 {
+				return nil, util.Error("invalid input syntax for type interval: '%s'", s)
+			}
+			units := []int64{microsPerHour, microsPerMinute, microsPerSecond}
+			for j, part := range parts {
+				value, err := strconv.ParseFloat(part, 64)
+				if err != nil || value < 0 || (j < 2 && value != math.Trunc(value)) {
+					return nil, util.Error("invalid input syntax for type interval: '%s'", s)
+				}
+				interval.Micros += sign * int64(math.Round(value*float64(units[j])))
+			}
+			continue
+		}
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil || i+1 == len(fields) {
+			return nil, util.Error("invalid input syntax for type interval: '%s'", s)
+		}
+		i++
+		unit, ok := intervalUnits[fields[i]]
+		if !ok {
+			return nil, util.Error("interval unit '%s' is not supported", fields[i])
+		}
+		switch unit.field {
+		case 'M', 'D':
+			if value != math.Trunc(value) {
+				return nil, util.Error("interval %s must be an integer: '%s'", fields[i], s)
+			}
+			if unit.field == 'M' {
+				interval.Months += int64(value) * unit.amount
+			} else {
+				interval.Days += int64(value) * unit.amount
+			}
+		default:
+			interval.Micros += int64(math.Round(value * float64(unit.amount)))
+		}
+	}
+	return interval, nil
+}
+
+// addInterval 时间戳加上时间间隔: 先加月(超出当月天数时取月末), 再加天, 最后加微秒;
+func addInterval(micros int64, interval *ConstInterval) int64 {
+	t := time.UnixMicro(micros).UTC()
+	if interval.Months != 0 {
+		year, month, day := t.Date()
+		total := int64(month-1) + interval.Months
+		year += int(floorDiv(total, 12))
+		month = time.Month(total-floorDiv(total, 12)*12) + 1
+		if last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+			day = last
+		}
+		t = time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+	t = t.AddDate(0, 0, int(interval.Days))
+	return t.UnixMicro() + interval.Micros
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// scaleInterval 时间间隔乘以系数, 月与天的小数部分依次折算到下一级;
+func scaleInterval(interval *ConstInterval, factor float64) *ConstInterval {
+	months := float64(interval.Months) * factor
+	days := float64(interval.Days)*factor + (months-math.Trunc(months))*daysPerMonth
+	micros := float64(interval.Micros)*factor + (days-math.Trunc(days))*float64(microsPerDay)
+	return &ConstInterval{Months: int64(months), Days: int64(days), Micros: int64(math.Round(micros))}
+}
+
+// temporalArith 日期时间参与的加减乘除, 不支持的组合返回 false;
+// DATE ± INTEGER = DATE, DATE - DATE = INTEGER(天数), DATE/TIMESTAMP ± INTERVAL = TIMESTAMP,
+// TIMESTAMP - TIMESTAMP = INTERVAL, TIME ± INTERVAL = TIME, TIME - TIME = INTERVAL,
+// INTERVAL ± INTERVAL = INTERVAL, INTERVAL * / 数值 = INTERVAL;
+func temporalArith(operator ArithOperator, l, r Value) (Value, bool, error) {
+	// 加法交换律: 把时间间隔与整数放到右侧;
+	if operator == ArithAdd {
+		switch l.(type) {
+		case *ConstInterval:
+			switch r.(type) {
+			case *ConstDate, *ConstTimestamp, *ConstTime:
+				l, r = r, l
+			}
+		case *ConstInt:
+			if _, ok := r.(*ConstDate); ok {
+				l, r = r, l
+			}
+		}
+	}
+	if operator == ArithMul {
+		if _, ok := r.(*ConstInterval); ok {
+			l, r = r, l
+		}
+	}
+	sign := int64(1)
+	if operator == ArithSub {
+		sign = -1
+	}
+	additive := operator == ArithAdd || operator == ArithSub
+	switch lv := l.(type) {
+	case *ConstDate:
+		switch rv := r.(type) {
+		case *ConstInt:
+			if additive {
+				return &ConstDate{Value: lv.Value + sign*rv.Value}, true, nil
+			}
+		case *ConstInterval:
+			if additive {
+				return &ConstTimestamp{Value: addInterval(lv.Value*microsPerDay, signed(rv, sign))}, true, nil
+			}
+		case *ConstDate:
+			if operator == ArithSub {
+				return &ConstInt{Value: lv.Value - rv.Value}, true, nil
+			}
+		case *ConstTimestamp:
+			if operator == ArithSub {
+				return timestampDiff(lv.Value*microsPerDay, rv.Value), true, nil
+			}
+		}
+	case *ConstTimestamp:
+		switch rv := r.(type) {
+		case *ConstInterval:
+			if additive {
+				return &ConstTimestamp{Value: addInterval(lv.Value, signed(rv, sign))}, true, nil
+			}
+		case *ConstTimestamp:
+			if operator == ArithSub {
+				return timestampDiff(lv.Value, rv.Value), true, nil
+			}
+		case *ConstDate:
+			if operator == ArithSub {
+				return timestampDiff(lv.Value, rv.Value*microsPerDay), true, nil
+			}
+		}
+	case *ConstTime:
+		switch rv := r.(type) {
+		case *ConstInterval:
+			// 只取时间间隔中不足一天的部分, 结果在一天之内循环;
+			if additive {
+				clock := (lv.Value + sign*rv.Micros) % microsPerDay
+				if clock < 0 {
+					clock += microsPerDay
+				}
+				return &ConstTime{Value: clock}, true, nil
+			}
+		case *ConstTime:
+			if operator == ArithSub {
+				return &ConstInterval{Micros: lv.Value - rv.Value}, true, nil
+			}
+		}
+	case *ConstInterval:
+		switch rv := r.(type) {
+		case *ConstInterval:
+			if additive {
+				rv = signed(rv, sign)
+				return &ConstInterval{Months: lv.Months + rv.Months, Days: lv.Days + rv.Days, Micros: lv.Micros + rv.Micros}, true, nil
+			}
+		case *ConstInt, *ConstFloat, *ConstDecimal:
+			factor := toFloat(rv)
+			if operator == ArithMul {
+				return scaleInterval(lv, factor), true, nil
+			}
+			if operator == ArithDiv {
+				if factor == 0 {
+					return nil, true, util.Error("division by zero")
+				}
+				return scaleInterval(lv, 1/factor), true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+func signed(interval *ConstInterval, sign int64) *ConstInterval {
+	if sign < 0 {
+		return interval.negate()
+	}
+	return interval
+}
+
+// timestampDiff 两个时间戳之差, 整天的部分记为天数;
+func timestampDiff(l, r int64) *ConstInterval {
+	diff := l - r
+	return &ConstInterval{Days: diff / microsPerDay, Micros: diff % microsPerDay}
+}
+
+// DateTrunc DATE_TRUNC(unit, value): 把时间截断到指定精度, 结果与参数的类型相同;
+// 周从星期一开始; 对 DATE 截断到天以下的精度时结果不变;
+func DateTrunc(unit string, value Value) (Value, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case *ConstNull:
+		return v, nil
+	case *ConstTimestamp:
+		t = v.Time()
+	case *ConstDate:
+		t = v.Time()
+	default:
+		return nil, util.Error("date_trunc does not support %s", GetDataTypeInfo(value.DateType()))
+	}
+	year, month, day := t.Date()
+	switch strings.ToLower(unit) {
+	case "microsecond", "microseconds":
+	case "millisecond", "milliseconds":
+		t = t.Truncate(time.Millisecond)
+	case "second", "seconds":
+		t = t.Truncate(time.Second)
+	case "minute", "minutes":
+		t = t.Truncate(time.Minute)
+	case "hour", "hours":
+		t = t.Truncate(time.Hour)
+	case "day", "days":
+		t = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	case "week", "weeks":
+		offset := (int(t.Weekday()) + 6) % 7
+		t = time.Date(year, month, day-offset, 0, 0, 0, 0, time.UTC)
+	case "month", "months":
+		t = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		t = time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case "year", "years":
+		t = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return nil, util.Error("date_trunc unit '%s' is not supported", unit)
+	}
+	if _, ok := value.(*ConstDate); ok {
+		return &ConstDate{Value: floorDiv(t.UnixMicro(), microsPerDay)}, nil
+	}
+	return &ConstTimestamp{Value: t.UnixMicro()}, nil
+}
+
+// Extract EXTRACT(field FROM value): 取出时间的某个部分; second 与 epoch 为浮点数(带小数秒), 其余为整数;
+func Extract(field string, value Value) (Value, error) {
+	field = strings.ToLower(field)
+	switch v := value.(type) {
+	case *ConstNull:
+		return v, nil
+	case *ConstDate:
+		return extractTime(field, v.Time())
+	case *ConstTimestamp:
+		return extractTime(field, v.Time())
+	case *ConstTime:
+		return extractClock(field, v.Value)
+	case *ConstInterval:
+		switch field {
+		case "year", "years":
+			return &ConstInt{Value: v.Months / 12}, nil
+		case "month", "months":
+			return &ConstInt{Value: v.Months % 12}, nil
+		case "day", "days":
+			return &ConstInt{Value: v.Days}, nil
+		case "epoch":
+			seconds := float64(v.Months*daysPerMonth+v.Days)*float64(microsPerDay/microsPerSecond) + float64(v.Micros)/float64(microsPerSecond)
+			return &ConstFloat{Value: seconds}, nil
+		}
+		return extractClock(field, v.Micros)
+	default:
+		return nil, util.Error("extract does not support %s", GetDataTypeInfo(value.DateType()))
+	}
+}
+
+func extractTime(field string, t time.Time) (Value, error) {
+	switch field {
+	case "year", "years":
+		return &ConstInt{Value: int64(t.Year())}, nil
+	case "quarter":
+		return &ConstInt{Value: int64(t.Month()-1)/3 + 1}, nil
+	case "month", "months":
+		return &ConstInt{Value: int64(t.Month())}, nil
+	case "week", "weeks":
+		_, week := t.ISOWeek()
+		return &ConstInt{Value: int64(week)}, nil
+	case "day", "days":
+		return &ConstInt{Value: int64(t.Day())}, nil
+	case "dow":
+		return &ConstInt{Value: int64(t.Weekday())}, nil
+	case "isodow":
+		return &ConstInt{Value: int64((int(t.Weekday())+6)%7 + 1)}, nil
+	case "doy":
+		return &ConstInt{Value: int64(t.YearDay())}, nil
+	case "epoch":
+		return &ConstFloat{Value: float64(t.UnixMicro()) / float64(microsPerSecond)}, nil
+	}
+	clock := t.UnixMicro() - floorDiv(t.UnixMicro(), microsPerDay)*microsPerDay
+	return extractClock(field, clock)
+}
+
+// extractClock 从一天之内(或时间间隔中不足一天)的微秒数中取出时、分、秒;
+func extractClock(field string, micros int64) (Value, error) {
+	switch field {
+	case "hour", "hours":
+		return &ConstInt{Value: micros / microsPerHour}, nil
+	case "minute", "minutes":
+		return &ConstInt{Value: micros % microsPerHour / microsPerMinute}, nil
+	case "second", "seconds":
+		return &ConstFloat{Value: float64(micros%microsPerMinute) / float64(microsPerSecond)}, nil
+	case "epoch":
+		return &ConstFloat{Value: float64(micros) / float64(microsPerSecond)}, nil
+	}
+	return nil, util.Error("extract field '%s' is not supported", field)
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// View 视图: 只保存查询语句的原文, 查询时在 FROM 中原地展开;
+// 物化视图另外把查询结果保存在隐藏表 MaterializedTableName(Name) 中, REFRESH 时重新计算;
+type View struct {
+	Name         string
+	Query        string   // SELECT 语句原文, 不含结尾的分号;
+	Tables       []string // FROM 中引用的表或视图, 删除它们时据此检查依赖;
+	Materialized bool
+}
+
+// MaterializedRowColumn 隐藏表的主键列, 保存结果中的行号; 标识符不能包含 #, 不会与查询结果的列重名;
+const MaterializedRowColumn = "#row"
+
+// MaterializedTableName 物化视图的隐藏表: #<view>; 语句中无法直接访问这张表;
+func MaterializedTableName(viewName string) string {
+	return "#" + viewName
+}
+
+// IsHiddenTable SHOW TABLES 不列出的表;
+func IsHiddenTable(tableName string) bool {
+	return strings.HasPrefix(tableName, "#")
+}
+
+// DependsOn 视图是否直接引用了 name;
+func (v *View) DependsOn(name string) bool {
+	for _, table := range v.Tables {
+		if table == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *View) ToString() string {
+	if v.Materialized {
+		return fmt.Sprintf("MATERIALIZED VIEW %s AS %s", v.Name, v.Query)
+	}
+	return fmt.Sprintf("VIEW %s AS %s", v.Name, v.Query)
+}
@@ -25,8 +25,22 @@ func Error(format string, args ...interface{}) error {
 var (
 	Mismatch      = errors.New("mismatch")
 	WriteConflict = errors.New("WriteConflict")
+	// SerializationFailure 可串行化事务之间的读写依赖可能构成环, 提交的事务被回滚, 可以重试;
+	SerializationFailure = errors.New("could not serialize access due to read/write dependencies among transactions")
 )
 
+// PrefixEnd 大于所有以 prefix 开头的 key 的最小 key, 用作范围扫描的右边界; prefix 全为 0xff 时返回 nil, 表示不设右边界;
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
 func Join(names []string, s string) string {
 	return strings.Join(names, s)
 }
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"sync"
+)
+
+// IsolationLevel 事务的隔离级别;
+type IsolationLevel int
+
+const (
+	// SnapshotIsolation 快照隔离 (默认): 只检测写写冲突, 允许写偏斜;
+	SnapshotIsolation IsolationLevel = iota
+	// Serializable 可串行化: 在快照隔离之上记录读集合, 检测并发事务之间的读写反依赖;
+	Serializable
+	// ReadCommitted 读已提交: 每条语句开始时刷新快照, 能读到之前的语句执行期间其他事务提交的数据;
+	ReadCommitted
+)
+
+// ssiTracker 可串行化快照隔离 (SSI) 的读写集合与反依赖, 只在内存中, 所有可串行化事务共享;
+// 读写反依赖 r -> w: 事务 r 读到的版本被与它并发的事务 w 改写, 即 w 对 r 不可见;
+// 一个事务同时存在指向它与从它出发的反依赖时, 是危险结构的中心, 可能构成环, 提交时回滚;
+type ssiTracker struct {
+	lock sync.Mutex
+	txns map[Version]*ssiTxn
+}
+
+type ssiTxn struct {
+	state     *TransactionState
+	committed bool
+	reads     map[string]struct{} // Get 读过的 key;
+	prefixes  [][]byte            // ScanPrefix 扫描过的前缀, 相当于谓词锁, 之后插入的 key 同样算作读过;
+	writes    map[string]struct{}
+	in        map[*ssiTxn]struct{} // 并发事务 r -> 当前事务;
+	out       map[*ssiTxn]struct{} // 当前事务 -> 并发事务 w;
+	doomed    bool                 // 已提交的事务因为当前事务成为危险结构的中心, 当前事务只能回滚;
+}
+
+func newSsiTracker() *ssiTracker {
+	return &ssiTracker{txns: make(map[Version]*ssiTxn)}
+}
+
+// register 登记新开启的事务; 调用方持有 lock, 在同一把锁内分配版本号;
+func (s *ssiTracker) register(state *TransactionState) *ssiTxn {
+	txn := &ssiTxn{
+		state:  state,
+		reads:  make(map[string]struct{}),
+		writes: make(map[string]struct{}),
+		in:     make(map[*ssiTxn]struct{}),
+		out:    make(map[*ssiTxn]struct{}),
+	}
+	s.txns[state.Version] = txn
+	return txn
+}
+
+// concurrent r 与 w 的执行时间有重叠: 双方的快照都看不到对方, 即 w 在 r 开始之后才提交, r 也在 w 开始之后才提交;
+// 一方开始之前另一方已经提交时, 两者的先后顺序已经确定, 不构成反依赖;
+func concurrent(r, w *ssiTxn) bool {
+	return r != w && !r.state.isVisible(w.state.Version) && !w.state.isVisible(r.state.Version)
+}
+
+// pivot 同时存在指向它与从它出发的反依赖;
+func (t *ssiTxn) pivot() bool {
+	return len(t.in) > 0 && len(t.out) > 0
+}
+
+func (r *ssiTxn) hasRead(key []byte) bool {
+	if _, ok := r.reads[string(key)]; ok {
+		return true
+	}
+	for _, prefix := range r.prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addConflict 记录 r -> w; 已提交的一方因此成为危险结构的中心时, 未提交的一方只能回滚;
+func addConflict(r, w *ssiTxn) {
+	r.out[w] = struct{}{}
+	w.in[r] = struct{}{}
+	if r.committed && r.pivot() && !w.committed {
+		w.doomed = true
+	}
+	if w.committed && w.pivot() && !r.committed {
+		r.doomed = true
+	}
+}
+
+func (s *ssiTracker) onRead(r *ssiTxn, key []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r.reads[string(key)] = struct{}{}
+	for _, w := range s.txns {
+		if _, ok := w.writes[string(key)]; ok && concurrent(r, w) {
+			addConflict(r, w)
+		}
+	}
+}
+
+func (s *ssiTracker) onScan(r *ssiTxn, prefix []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r.prefixes = append(r.prefixes, prefix)
+	for _, w := range s.txns {
+		if !concurrent(r, w) {
+			continue
+		}
+		for key := range w.writes {
+			if bytes.HasPrefix([]byte(key), prefix) {
+				addConflict(r, w)
+				break
+			}
+		}
+	}
+}
+
+func (s *ssiTracker) onWrite(w *ssiTxn, key []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w.writes[string(key)] = struct{}{}
+	for _, r := range s.txns {
+		if concurrent(r, w) && r.hasRead(key) {
+			addConflict(r, w)
+		}
+	}
+}
+
+// commit 事务是危险结构的中心时返回 false, 由调用方回滚; 否则在锁内执行存储层的提交,
+// 提交成功的事务保留到与它并发的事务全部结束;
+func (s *ssiTracker) commit(txn *ssiTxn, commit func()) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if txn.doomed || txn.pivot() {
+		return false
+	}
+	commit()
+	txn.committed = true
+	s.prune()
+	return true
+}
+
+// rollback 回滚的事务不再参与任何依赖, 去掉与它相关的反依赖;
+func (s *ssiTracker) rollback(txn *ssiTxn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for r := range txn.in {
+		delete(r.out, txn)
+	}
+	for w := range txn.out {
+		delete(w.in, txn)
+	}
+	delete(s.txns, txn.state.Version)
+	s.prune()
+}
+
+// prune 已提交的事务对所有未提交的事务都可见时, 不会再产生新的反依赖, 可以删除;
+func (s *ssiTracker) prune() {
+	for version, txn := range s.txns {
+		if !txn.committed {
+			continue
+		}
+		visible := true
+		for _, active := range s.txns {
+			if !active.committed && !active.state.isVisible(version) {
+				visible = false
+				break
+			}
+		}
+		if visible {
+			delete(s.txns, version)
+		}
+	}
+}
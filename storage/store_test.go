@@ -77,14 +77,16 @@ func TestAscendKeysOfPattern(t *testing.T) {
 	validate := func(targetKey [][]byte, targetValue [][]byte, pattern []byte) {
 		var keys [][]byte
 		var values [][]byte
-		db.AscendKeys(pattern, true, func(key []byte, value []byte) (bool, error) {
-			//if strings.HasPrefix(string(key), string(pattern)) {
-			//	return true, nil
-			//}
+		err := db.AscendKeys(pattern, true, func(key []byte) (bool, error) {
+			value, err := db.Get(key)
+			if err != nil {
+				return false, err
+			}
 			keys = append(keys, key)
 			values = append(values, value)
 			return true, nil
 		})
+		assert.Nil(t, err)
 		assert.Equal(t, targetKey, keys)
 		assert.Equal(t, targetValue, values)
 	}
@@ -1,27 +1,98 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/binary"
 	"github.com/google/btree"
 	"github.com/kebukeYi/TrainSQL/sql/util"
 	"math"
+	"time"
 )
 
 type TransactionManager struct {
 	storage Storage
+	ssi     *ssiTracker
 }
 
 func NewTransactionManager(storage Storage) *TransactionManager {
-	return &TransactionManager{
+	m := &TransactionManager{
 		storage: storage,
+		ssi:     newSsiTracker(),
+	}
+	m.recover()
+	return m
+}
+
+// recover 启动时回滚上次异常退出前仍然活跃的事务, 并删除全部登记;
+// 此时没有活跃的事务, 已经提交的登记对之后开始的事务都可见, 不会再引起冲突;
+func (m *TransactionManager) recover() {
+	m.storage.Lock()
+	defer m.storage.UnLock()
+	t := NewTransaction(m.storage)
+	for _, version := range t.ScanActive() {
+		t.undo(version)
+	}
+	for _, pair := range m.storage.ScanPrefix([]byte(TxnHold), false) {
+		m.storage.Delete(pair.Key)
 	}
 }
 func (m *TransactionManager) Begin() *Transaction {
+	return m.BeginWith(SnapshotIsolation)
+}
+
+// BeginWith 以指定的隔离级别开启事务;
+func (m *TransactionManager) BeginWith(level IsolationLevel) *Transaction {
 	t := NewTransaction(m.storage)
+	t.level = level
+	if level != Serializable {
+		t.begin()
+		return t
+	}
+	// 分配版本号与登记在同一把锁内, 期间提交的事务不会在登记之前被清理;
+	m.ssi.lock.Lock()
+	defer m.ssi.lock.Unlock()
 	t.begin()
+	t.tracker = m.ssi
+	t.ssi = m.ssi.register(t.transactionState)
 	return t
 }
 
+// NextVersion 返回下一个事务将要分配的版本号, 不会占用该版本号;
+func (m *TransactionManager) NextVersion() Version {
+	m.storage.Lock()
+	defer m.storage.UnLock()
+	nextVersion := m.storage.Get(GetNextVersionKey())
+	if nextVersion == nil {
+		return Version(1)
+	}
+	return Version(binary.LittleEndian.Uint64(nextVersion))
+}
+
+// WaitActive 等待版本号小于 version 的活跃事务全部结束(提交或回滚);
+// 超过 timeout 仍未结束时返回错误;
+func (m *TransactionManager) WaitActive(version Version, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		m.storage.Lock()
+		activeVersions := NewTransaction(m.storage).ScanActive()
+		m.storage.UnLock()
+		waiting := false
+		for _, activeVersion := range activeVersions {
+			if activeVersion < version {
+				waiting = true
+				break
+			}
+		}
+		if !waiting {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return util.Error("#WaitActive transactions before version %d still active", version)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func (m *TransactionManager) Close() error {
 	return m.storage.Close()
 }
@@ -30,16 +101,39 @@ type Version uint64
 
 type TransactionState struct {
 	Version        Version
+	snapshot       Version // 可见的最大版本号; 开始时等于 Version, 读已提交的事务在每条语句开始时刷新;
 	activeVersions []Version
+	ownVersions    []Version // 读已提交的事务换用新版本号之前使用过的版本号, 写入的数据仍然属于这个事务;
 }
 
 func (s *TransactionState) isVisible(version Version) bool {
+	if s.isOwn(version) {
+		return true
+	}
 	for _, v := range s.activeVersions {
 		if v == (version) {
 			return false
 		}
 	}
-	return (version) <= (s.Version)
+	return (version) <= (s.snapshot)
+}
+
+// isOwn 版本号属于当前事务;
+func (s *TransactionState) isOwn(version Version) bool {
+	if version == s.Version {
+		return true
+	}
+	for _, v := range s.ownVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// versions 当前事务使用过的全部版本号;
+func (s *TransactionState) versions() []Version {
+	return append(append([]Version{}, s.ownVersions...), s.Version)
 }
 
 func (s *TransactionState) getMinVersion() Version {
@@ -55,9 +149,35 @@ func (s *TransactionState) getMinVersion() Version {
 type Transaction struct {
 	storage          Storage
 	transactionState *TransactionState
+	undoLog          []undoEntry      // 事务内每次写入之前 key 的状态, 回滚到保存点时逆序撤销;
+	savepoints       []namedSavepoint // SAVEPOINT 建立的保存点, 按建立的顺序排列;
+	holds            [][]byte         // Hold 登记的 TxnHold key, 回滚时删除, 提交时记下提交点;
+	level            IsolationLevel   // 隔离级别;
+	tracker          *ssiTracker      // 可串行化事务的读写集合, 其他隔离级别时为空;
+	ssi              *ssiTxn
 }
 
+// undoEntry 写入之前 key 在当前事务中的版本; written 为 false 表示当前事务还没有写过这个 key;
+type undoEntry struct {
+	key     []byte
+	version Version // 写入时事务的版本号;
+	prior   []byte
+	written bool
+}
+
+// Savepoint 保存点, 记录事务写入集合的水位, 即当时 undoLog 的长度;
+type Savepoint int
+
+type namedSavepoint struct {
+	name      string
+	savepoint Savepoint
+}
+
+// Version 事务开始时分配的版本号; 读已提交的事务之后可能换用新的版本号, 对外仍然以开始时的为准;
 func (t *Transaction) Version() Version {
+	if len(t.transactionState.ownVersions) > 0 {
+		return t.transactionState.ownVersions[0]
+	}
 	return t.transactionState.Version
 }
 
@@ -70,6 +190,18 @@ func NewTransaction(storage Storage) *Transaction {
 func (t *Transaction) begin() *Transaction {
 	t.storage.Lock()
 	defer t.storage.UnLock()
+	activeVersions := t.ScanActive() // 不会扫描到当前事务;
+	version := t.allocateVersion()
+	t.transactionState = &TransactionState{
+		Version:        version,
+		snapshot:       version,
+		activeVersions: activeVersions,
+	}
+	return t
+}
+
+// allocateVersion 分配新的版本号并登记为活跃事务; 调用方持有存储锁;
+func (t *Transaction) allocateVersion() Version {
 	// nextVersionKey : NextVersion_
 	nextVersionKey := GetNextVersionKey()
 	nextVersion := t.storage.Get(nextVersionKey)
@@ -81,15 +213,60 @@ func (t *Transaction) begin() *Transaction {
 		version = Version(v)
 	}
 	t.storage.Set(nextVersionKey, binary.LittleEndian.AppendUint64(nil, uint64(version+1)))
-	activeVersions := t.ScanActive() // 不会扫描到当前事务;
 	// key: TenActive_version(8字节)
 	key := GetTenActiveKey(version)
 	t.storage.Set(key, []byte{})
+	return version
+}
+
+// renew 读已提交的事务要覆盖版本号更大的事务已经提交的数据时, 换用新的版本号, 使写入的版本排在已有版本之后;
+// 快照不变, 之前的版本号直到提交或回滚仍然是活跃的, 对其他事务不可见; 调用方持有存储锁;
+func (t *Transaction) renew() {
+	state := t.transactionState
 	t.transactionState = &TransactionState{
-		Version:        version,
+		Version:        t.allocateVersion(),
+		snapshot:       state.snapshot,
+		activeVersions: state.activeVersions,
+		ownVersions:    state.versions(),
+	}
+}
+
+// Refresh 读已提交的事务在每条语句开始时取新的快照: 之前已经提交的事务全部可见, 仍然活跃的事务不可见;
+// 事务自己的版本号不变, 已经写入的数据仍然属于这个事务; 其他隔离级别的快照在整个事务内不变;
+func (t *Transaction) Refresh() {
+	if t.level != ReadCommitted {
+		return
+	}
+	t.storage.Lock()
+	defer t.storage.UnLock()
+	nextVersion := binary.LittleEndian.Uint64(t.storage.Get(GetNextVersionKey()))
+	activeVersions := make([]Version, 0)
+	for _, version := range t.ScanActive() {
+		if !t.transactionState.isOwn(version) {
+			activeVersions = append(activeVersions, version)
+		}
+	}
+	t.transactionState = &TransactionState{
+		Version:        t.transactionState.Version,
+		snapshot:       Version(nextVersion - 1),
 		activeVersions: activeVersions,
+		ownVersions:    t.transactionState.ownVersions,
 	}
-	return t
+}
+
+// NextSequence 为计数器 key 分配 count 个连续的值, 返回其中的第一个; 计数器从 0 开始;
+// 与 begin 分配版本号相同, 在存储锁内直接读写, 不经过 MVCC: 不会产生写冲突, 事务回滚也不会归还已分配的值;
+func (t *Transaction) NextSequence(key []byte, count uint64) uint64 {
+	t.storage.Lock()
+	defer t.storage.UnLock()
+	// sequenceKey: Sequence_key
+	sequenceKey := GetSequenceKey(key)
+	value := uint64(0)
+	if current := t.storage.Get(sequenceKey); current != nil {
+		value = binary.LittleEndian.Uint64(current)
+	}
+	t.storage.Set(sequenceKey, binary.LittleEndian.AppendUint64(nil, value+count))
+	return value
 }
 
 func (t *Transaction) ScanActive() []Version {
@@ -107,34 +284,66 @@ func (t *Transaction) ScanActive() []Version {
 	return versions
 }
 
-func (t *Transaction) Commit() {
+// Commit 提交事务; 可串行化事务是危险结构的中心时回滚, 返回 util.SerializationFailure;
+func (t *Transaction) Commit() error {
+	if t.ssi == nil {
+		t.commit()
+		return nil
+	}
+	if !t.tracker.commit(t.ssi, t.commit) {
+		t.Rollback()
+		return util.SerializationFailure
+	}
+	return nil
+}
+
+func (t *Transaction) commit() {
 	t.storage.Lock()
 	defer t.storage.UnLock()
-	deleteKeys := make([][]byte, 0)
-	// writeKey: TxnWrite_version(8字节)
-	writeKey := GetPrefixTxnWriteKey(t.transactionState.Version)
-	// 前缀扫描 获得当前事务 写入的所有操作记录;
-	// 因为不知道当前事务写了哪些具体的数据,因此需要扫描匹配;
-	// writeKey: TxnWrite_version(8字节)_key
-	pairs := t.storage.ScanPrefix(writeKey, false)
-	for _, pair := range pairs {
-		deleteKeys = append(deleteKeys, pair.Key)
-	}
-	// 所有具体的操作记录, 原封不动的进行删除;
-	for _, key := range deleteKeys {
+	t.settle()
+	for _, version := range t.transactionState.versions() {
+		deleteKeys := make([][]byte, 0)
+		// writeKey: TxnWrite_version(8字节)
+		writeKey := GetPrefixTxnWriteKey(version)
+		// 前缀扫描 获得当前事务 写入的所有操作记录;
+		// 因为不知道当前事务写了哪些具体的数据,因此需要扫描匹配;
+		// writeKey: TxnWrite_version(8字节)_key
+		pairs := t.storage.ScanPrefix(writeKey, false)
+		for _, pair := range pairs {
+			deleteKeys = append(deleteKeys, pair.Key)
+		}
+		// 所有具体的操作记录, 原封不动的进行删除;
+		for _, key := range deleteKeys {
+			t.storage.Delete(key)
+		}
+		// key: TenActive_version(8字节); 删除掉当前事务,不再是活跃事务;
+		key := GetTenActiveKey(version)
 		t.storage.Delete(key)
 	}
-	// key: TenActive_version(8字节); 删除掉当前事务,不再是活跃事务;
-	key := GetTenActiveKey(t.transactionState.Version)
-	t.storage.Delete(key)
 }
 
+// Rollback 回滚事务; 可串行化事务在释放存储锁之后再从 ssi 中去掉, 与提交时先取 ssi 锁再取存储锁的顺序一致;
 func (t *Transaction) Rollback() {
+	t.rollback()
+	if t.ssi != nil {
+		t.tracker.rollback(t.ssi)
+	}
+}
+
+func (t *Transaction) rollback() {
 	t.storage.Lock()
 	defer t.storage.UnLock()
+	t.release()
+	for _, version := range t.transactionState.versions() {
+		t.undo(version)
+	}
+}
+
+// undo 删除版本号 version 写入的全部数据, 并将其移出活跃事务; 调用方持有存储锁;
+func (t *Transaction) undo(version Version) {
 	deleteKeys := make([][]byte, 0)
 	// key: TxnWrite_version(8字节)
-	key := GetPrefixTxnWriteKey(t.transactionState.Version)
+	key := GetPrefixTxnWriteKey(version)
 	// 前缀扫描 获得当前事务 写入的所有操作记录;
 	pairs := t.storage.ScanPrefix(key, false)
 	for _, pair := range pairs {
@@ -142,7 +351,7 @@ func (t *Transaction) Rollback() {
 		// txnWriteKeyValue: 获得涉及具体key;
 		txnWriteKeyValue := GetTxnWriteKeyValue(pair.Key)
 		// versionKey: KeyVersion_key_version(8字节), 定向删除具体的记录;
-		versionKey := GetKeyVersionKey(txnWriteKeyValue, t.transactionState.Version)
+		versionKey := GetKeyVersionKey(txnWriteKeyValue, version)
 		deleteKeys = append(deleteKeys, versionKey)
 	}
 	for _, key := range deleteKeys {
@@ -150,20 +359,191 @@ func (t *Transaction) Rollback() {
 		t.storage.Delete(key)
 	}
 	// tenActiveKey: TenActive_version(8字节); 删除掉当前事务,不再是活跃事务;
-	tenActiveKey := GetTenActiveKey(t.transactionState.Version)
+	tenActiveKey := GetTenActiveKey(version)
 	t.storage.Delete(tenActiveKey)
 }
 
-func (t *Transaction) Set(key []byte, value []byte) error {
+// Hold 登记当前事务依赖 key 的当前版本; 多个事务可以同时登记同一个 key:
+// 1. key 已经被并发的事务改写(最新版本不可见)时返回 util.WriteConflict;
+// 2. 登记之后, 登记者对其不可见的事务(登记者仍然活跃, 或者在它开始之后才提交)改写 key 时返回 util.WriteConflict;
+// 与写入不同, 登记不产生新的版本, 登记同一个 key 的事务之间不冲突;
+// 提交之后登记仍然保留, 直到对所有活跃事务都可见, 避免开始得更早的事务改写登记者依赖的版本;
+func (t *Transaction) Hold(key []byte) error {
+	t.storage.Lock()
+	defer t.storage.UnLock()
+	holdKey := GetTxnHoldKey(key, t.transactionState.Version)
+	if t.storage.Get(holdKey) != nil {
+		return nil
+	}
+	resultPairs := t.storage.Scan(&RangeBounds{
+		StartKey: GetKeyVersionKey(key, 0),
+		EndKey:   GetKeyVersionKey(key, math.MaxInt64),
+	})
+	if len(resultPairs) > 0 && !t.transactionState.isVisible(SplitKeyVersion(resultPairs[len(resultPairs)-1].Key)) {
+		return util.WriteConflict
+	}
+	t.holders(key)
+	t.storage.Set(holdKey, []byte{})
+	t.holds = append(t.holds, holdKey)
+	return nil
+}
+
+// held key 是否被当前事务不可见的其他事务登记; 调用方持有存储锁;
+func (t *Transaction) held(key []byte) bool {
+	for _, version := range t.holders(key) {
+		if !t.transactionState.isVisible(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// holders 登记了 key 的事务的版本号; 同时删除失效的登记: 已经提交并且提交点不晚于最小的活跃版本号,
+// 即所有活跃事务都在登记者提交之后才开始; 以及登记者既没有提交也不再活跃(异常退出留下); 调用方持有存储锁;
+func (t *Transaction) holders(key []byte) []Version {
+	prefix := append([]byte(TxnHold), key...)
+	resultPairs := t.storage.Scan(&RangeBounds{
+		StartKey: GetTxnHoldKey(key, 0),
+		EndKey:   GetTxnHoldKey(key, math.MaxInt64),
+	})
+	versions := make([]Version, 0, len(resultPairs))
+	oldestActive := Version(0)
+	for _, pair := range resultPairs {
+		// 前缀相同而更长的 key 也在扫描范围内;
+		if len(pair.Key) != len(prefix)+8 {
+			continue
+		}
+		version := SplitKeyVersion(pair.Key)
+		if len(pair.Value) == 0 {
+			// 仍未提交的登记;
+			if t.storage.Get(GetTenActiveKey(version)) == nil {
+				t.storage.Delete(pair.Key)
+				continue
+			}
+		} else {
+			if oldestActive == 0 {
+				oldestActive = t.oldestActive()
+			}
+			if Version(binary.LittleEndian.Uint64(pair.Value)) <= oldestActive {
+				t.storage.Delete(pair.Key)
+				continue
+			}
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// oldestActive 最小的活跃版本号, 没有活跃事务时为 math.MaxInt64; 调用方持有存储锁;
+func (t *Transaction) oldestActive() Version {
+	pairs := t.storage.Scan(&RangeBounds{
+		StartKey: GetTenActiveKey(0),
+		EndKey:   GetTenActiveKey(math.MaxInt64),
+	})
+	if len(pairs) == 0 {
+		return math.MaxInt64
+	}
+	return GetTenActiveKeyVersion(pairs[0].Key)
+}
+
+// settle 提交时将当前事务的登记改记为提交点(下一个待分配的版本号), 之后开始的事务的版本号不小于提交点; 调用方持有存储锁;
+func (t *Transaction) settle() {
+	commitPoint := t.storage.Get(GetNextVersionKey())
+	for _, holdKey := range t.holds {
+		t.storage.Set(holdKey, commitPoint)
+	}
+	t.holds = nil
+}
+
+// release 回滚时删除当前事务的登记; 调用方持有存储锁;
+func (t *Transaction) release() {
+	for _, holdKey := range t.holds {
+		t.storage.Delete(holdKey)
+	}
+	t.holds = nil
+}
+
+// Savepoint 返回当前的写入水位, 之后可以用 RollbackTo 撤销水位之后的写入;
+func (t *Transaction) Savepoint() Savepoint {
+	return Savepoint(len(t.undoLog))
+}
+
+// RollbackTo 逆序撤销保存点之后的写入: 保存点之前已经写过的 key 恢复为当时的值,
+// 保存点之后才写入的 key 删除版本记录与写入标记, 与整个事务回滚时相同; 事务仍然活跃;
+func (t *Transaction) RollbackTo(savepoint Savepoint) {
 	t.storage.Lock()
 	defer t.storage.UnLock()
-	return t.writeInner(key, value)
+	for i := len(t.undoLog) - 1; i >= int(savepoint); i-- {
+		entry := t.undoLog[i]
+		versionKey := GetKeyVersionKey(entry.key, entry.version)
+		if entry.written {
+			t.storage.Set(versionKey, entry.prior)
+		} else {
+			t.storage.Delete(versionKey)
+			t.storage.Delete(GetTxnWriteKey(entry.version, entry.key))
+		}
+	}
+	t.undoLog = t.undoLog[:savepoint]
+	// 水位之后建立的保存点随之失效;
+	for len(t.savepoints) > 0 && t.savepoints[len(t.savepoints)-1].savepoint > savepoint {
+		t.savepoints = t.savepoints[:len(t.savepoints)-1]
+	}
+}
+
+// SetSavepoint 以名字建立保存点; 与已有的保存点重名时, 之后按名字只能找到新建的这一个;
+func (t *Transaction) SetSavepoint(name string) {
+	t.savepoints = append(t.savepoints, namedSavepoint{name: name, savepoint: t.Savepoint()})
+}
+
+// RollbackToSavepoint 撤销保存点之后的写入, 并释放之后建立的保存点; 该保存点本身保留, 可以再次回滚到它;
+func (t *Transaction) RollbackToSavepoint(name string) error {
+	pos := t.findSavepoint(name)
+	if pos == -1 {
+		return util.Error("savepoint %s does not exist", name)
+	}
+	savepoint := t.savepoints[pos].savepoint
+	t.savepoints = t.savepoints[:pos+1]
+	t.RollbackTo(savepoint)
+	return nil
+}
+
+// ReleaseSavepoint 释放保存点以及之后建立的保存点, 保留它们之后的写入;
+func (t *Transaction) ReleaseSavepoint(name string) error {
+	pos := t.findSavepoint(name)
+	if pos == -1 {
+		return util.Error("savepoint %s does not exist", name)
+	}
+	t.savepoints = t.savepoints[:pos]
+	return nil
+}
+
+// findSavepoint 从最近建立的开始查找;
+func (t *Transaction) findSavepoint(name string) int {
+	for i := len(t.savepoints) - 1; i >= 0; i-- {
+		if t.savepoints[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Transaction) Set(key []byte, value []byte) error {
+	return t.write(key, value)
 }
 
 func (t *Transaction) Delete(key []byte) error {
+	return t.write(key, []byte{})
+}
+
+// write 写入成功之后再登记写集合, 登记在存储锁之外进行, 与提交时先取 ssi 锁再取存储锁的顺序一致;
+func (t *Transaction) write(key []byte, value []byte) error {
 	t.storage.Lock()
-	defer t.storage.UnLock()
-	return t.writeInner(key, []byte{})
+	err := t.writeInner(key, value)
+	t.storage.UnLock()
+	if err == nil && t.ssi != nil {
+		t.tracker.onWrite(t.ssi, key)
+	}
+	return err
 }
 
 func (t *Transaction) writeInner(key []byte, value []byte) error {
@@ -198,25 +578,49 @@ func (t *Transaction) writeInner(key []byte, value []byte) error {
 		if !t.transactionState.isVisible(keyVersion) {
 			return util.WriteConflict
 		}
+		// 4. 读已提交的事务刷新快照之后, 版本号更大的事务提交的数据可见, 可以覆盖;
+		//    但以原来的版本号写入会被已有的版本遮住, 因此换用新的版本号;
+		if keyVersion > t.transactionState.Version {
+			t.renew()
+		}
+	}
+	// 5. 对当前事务不可见的事务登记了依赖 key 的当前版本, 不能改写;
+	if t.held(key) {
+		return util.WriteConflict
+	}
+	// versionKey: KeyVersion_key_version(8字节), value
+	versionKey := GetKeyVersionKey(key, t.transactionState.Version)
+	// 记下当前事务之前写入的版本, 供回滚到保存点时恢复;
+	entry := undoEntry{key: key, version: t.transactionState.Version}
+	if own := t.storage.Scan(&RangeBounds{StartKey: versionKey, EndKey: GetKeyVersionKey(key, t.transactionState.Version+1)}); len(own) > 0 {
+		entry.prior, entry.written = own[0].Value, true
 	}
+	t.undoLog = append(t.undoLog, entry)
 	// writeKey: TxnWrite_version(8字节)_key; 当前事务的操作记录; 可供当前事务事后的前缀扫描查询;
 	writeKey := GetTxnWriteKey(t.transactionState.Version, key)
 	// 记录 当前事务(version) 写入了哪些 key 记录, 用于回滚事务;
 	t.storage.Set(writeKey, []byte{})
-	// versionKey: KeyVersion_key_version(8字节), value
-	versionKey := GetKeyVersionKey(key, t.transactionState.Version)
 	t.storage.Set(versionKey, value)
 	return nil
 }
 
 func (t *Transaction) Get(key []byte) []byte {
+	value := t.get(key)
+	if t.ssi != nil {
+		t.tracker.onRead(t.ssi, key)
+	}
+	return value
+}
+
+func (t *Transaction) get(key []byte) []byte {
 	t.storage.Lock()
 	defer t.storage.UnLock()
 	// version: 9
 	// 扫描的 version 的范围应该是 0-9
 	// KeyVersion_key_version0 - KeyVersion_key_version9
 	from := GetKeyVersionKey(key, 0)
-	to := GetKeyVersionKey(key, t.transactionState.Version+1)
+	// 读已提交的事务换用新版本号之后, 自己写入的版本可能大于快照;
+	to := GetKeyVersionKey(key, max(t.transactionState.snapshot, t.transactionState.Version)+1)
 	rangeBounds := &RangeBounds{
 		StartKey: from,
 		EndKey:   to,
@@ -238,12 +642,50 @@ func (t *Transaction) Get(key []byte) []byte {
 }
 
 func (t *Transaction) ScanPrefix(keyPrefix []byte, needValue bool) []ResultPair {
+	if t.ssi != nil {
+		t.tracker.onScan(t.ssi, keyPrefix)
+	}
 	// keyPrefix: key1  原生key性质, 需要进一步的组装;
 	// keyVersionKey: KeyVersion_key1
 	keyVersionKey := GetPrefixKeyVersionKey(keyPrefix)
 	// pair: [KeyVersion_row_user_version, value]
-	// 无序key;
+	// 无序key; 与并发的写入一样在存储锁内扫描;
+	t.storage.Lock()
 	resultPairs := t.storage.ScanPrefix(keyVersionKey, needValue)
+	t.storage.UnLock()
+	return t.visiblePairs(resultPairs, needValue)
+}
+
+// ScanRange 扫描以 keyPrefix 开头、落在 [start, end) 中的 key, end 为空时扫描到前缀的末尾, 结果按 key 有序;
+// 只读取范围内的版本, 不遍历整个前缀; 可串行化事务按整个前缀记录读集合, 只会多出冲突, 不会漏掉;
+// end 不能以范围内的 key 为前缀, 否则该 key 的版本会越过右边界, 自定界的保序编码满足这一点;
+func (t *Transaction) ScanRange(keyPrefix []byte, start []byte, end []byte) []ResultPair {
+	if t.ssi != nil {
+		t.tracker.onScan(t.ssi, keyPrefix)
+	}
+	rangeBounds := &RangeBounds{
+		StartKey: GetPrefixKeyVersionKey(start),
+		EndKey:   util.PrefixEnd(GetPrefixKeyVersionKey(keyPrefix)),
+	}
+	if end != nil {
+		rangeBounds.EndKey = GetPrefixKeyVersionKey(end)
+	}
+	t.storage.Lock()
+	resultPairs := t.storage.Scan(rangeBounds)
+	t.storage.UnLock()
+	// 比 start 短的 key 加上版本号之后可能落入扫描范围, 按原始 key 去掉;
+	inRange := make([]*ResultPair, 0, len(resultPairs))
+	for _, pair := range resultPairs {
+		key := GetRawKeyFromKeyVersion(pair.Key)
+		if bytes.HasPrefix(key, keyPrefix) && bytes.Compare(key, start) >= 0 {
+			inRange = append(inRange, pair)
+		}
+	}
+	return t.visiblePairs(inRange, true)
+}
+
+// visiblePairs 从按 key 与版本有序的多版本数据中取出每个 key 可见的最新版本, 去掉已删除的 key;
+func (t *Transaction) visiblePairs(resultPairs []*ResultPair, needValue bool) []ResultPair {
 	newResultPairs := make([]ResultPair, 0)
 	bTree := btree.New(3) // 树高为3;
 	for _, pair := range resultPairs {
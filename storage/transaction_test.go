@@ -26,8 +26,8 @@ func TestTransaction_SI_get(t *testing.T) {
 	t0.Commit()
 	wg := sync.WaitGroup{}
 	t1 := transactionManager.Begin()
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		assert.Equal(t, []byte("value1"), t1.Get([]byte("key1")))
 		time.Sleep(5 * time.Second)
@@ -273,6 +273,33 @@ func TestScan_isolation(t *testing.T) {
 	assert.Equal(t, data3, t1.ScanPrefix([]byte("bbca"), true))
 }
 
+// TestScan_range 只返回以前缀开头、落在 [start, end) 中的 key 的可见版本;
+// "ab" 比 start 短, 加上版本号之后落入存储层的扫描范围, 同样不返回;
+func TestScan_range(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t0 := transactionManager.Begin()
+	for _, key := range []string{"a", "ab", "ab\x00", "ab\x01", "ac", "ad", "b"} {
+		t0.Set([]byte(key), []byte("value-"+key))
+	}
+	t0.Commit()
+	t1 := transactionManager.Begin()
+	t1.Set([]byte("ab\x01"), []byte("value-ab1-1"))
+	t1.Delete([]byte("ac"))
+	t1.Commit()
+	t2 := transactionManager.Begin()
+	t2.Set([]byte("ab\x02"), []byte("value-ab2"))
+
+	t3 := transactionManager.Begin()
+	data := []ResultPair{
+		{Key: []byte("ab\x00"), Value: []byte("value-ab\x00")},
+		{Key: []byte("ab\x01"), Value: []byte("value-ab1-1")},
+	}
+	assert.Equal(t, data, t3.ScanRange([]byte("a"), []byte("ab\x00"), []byte("ad")))
+	data = append(data, ResultPair{Key: []byte("ad"), Value: []byte("value-ad")})
+	assert.Equal(t, data, t3.ScanRange([]byte("a"), []byte("ab\x00"), nil))
+	assert.Equal(t, []ResultPair{}, t3.ScanRange([]byte("a"), []byte("ae"), nil))
+}
+
 func TestTransaction_set(t *testing.T) {
 	transactionManager := NewTransactionManager(GetDiskStorage(txnDirPath))
 	t0 := transactionManager.Begin()
@@ -416,6 +443,220 @@ func TestPhantom_read(t *testing.T) {
 	assert.Equal(t, data1, t1.ScanPrefix([]byte("key"), true))
 }
 
+// TestWrite_skew 两个事务各自读到对方将要修改的数据: 值班表中至少保留一人, 两人同时请假;
+// 快照隔离下两个事务都能提交, 可串行化时后提交的事务失败;
+func TestWrite_skew(t *testing.T) {
+	onCall := func(txn *Transaction) int {
+		count := 0
+		for _, pair := range txn.ScanPrefix([]byte("doctor_"), true) {
+			if string(pair.Value) == "on" {
+				count++
+			}
+		}
+		return count
+	}
+	for _, level := range []IsolationLevel{SnapshotIsolation, Serializable} {
+		transactionManager := NewTransactionManager(NewMemoryStorage())
+		t0 := transactionManager.Begin()
+		t0.Set([]byte("doctor_alice"), []byte("on"))
+		t0.Set([]byte("doctor_bob"), []byte("on"))
+		t0.Commit()
+
+		t1 := transactionManager.BeginWith(level)
+		t2 := transactionManager.BeginWith(level)
+		assert.Equal(t, 2, onCall(t1))
+		assert.Equal(t, 2, onCall(t2))
+		assert.Nil(t, t1.Set([]byte("doctor_alice"), []byte("off")))
+		assert.Nil(t, t2.Set([]byte("doctor_bob"), []byte("off")))
+		err1, err2 := t1.Commit(), t2.Commit()
+
+		t3 := transactionManager.Begin()
+		if level == SnapshotIsolation {
+			assert.Nil(t, err1)
+			assert.Nil(t, err2)
+			assert.Equal(t, 0, onCall(t3))
+		} else {
+			assert.Equal(t, util.SerializationFailure, err1)
+			assert.Nil(t, err2)
+			assert.Equal(t, 1, onCall(t3))
+		}
+	}
+}
+
+// TestSerializable_concurrent 并发开启、提交、回滚可串行化事务, 不会因为加锁顺序不一致而死锁;
+func TestSerializable_concurrent(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				txn := transactionManager.BeginWith(Serializable)
+				txn.ScanPrefix([]byte("key"), true)
+				if err := txn.Set([]byte(fmt.Sprintf("key%d", (i+j)%4)), []byte("value")); err != nil || j%2 == 0 {
+					txn.Rollback()
+				} else {
+					_ = txn.Commit()
+				}
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("serializable transactions deadlocked")
+	}
+	assert.Empty(t, transactionManager.ssi.txns)
+}
+
+// TestSerializable_readOnly 只读事务与不相交的读写不会失败, 已提交的事务之后被清理;
+func TestSerializable_readOnly(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t0 := transactionManager.Begin()
+	t0.Set([]byte("key1"), []byte("value1"))
+	t0.Commit()
+
+	t1 := transactionManager.BeginWith(Serializable)
+	t2 := transactionManager.BeginWith(Serializable)
+	t3 := transactionManager.BeginWith(Serializable)
+	assert.Equal(t, []byte("value1"), t1.Get([]byte("key1")))
+	assert.Nil(t, t2.Set([]byte("key1"), []byte("value1-2")))
+	assert.Nil(t, t3.Set([]byte("key2"), []byte("value2")))
+	assert.Nil(t, t2.Commit())
+	assert.Nil(t, t3.Commit())
+	assert.Nil(t, t1.Commit())
+	assert.Empty(t, transactionManager.ssi.txns)
+}
+
+// TestSerializable_committedReader 读过 key2 的 t2 提交之后才开始的 t3 改写 key2, 两者没有重叠, 不构成反依赖;
+// 即使 t2 与更早的 t1 之间存在反依赖, 按 t1、t2、t3 的顺序执行是可串行化的, 三个事务都能提交;
+func TestSerializable_committedReader(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t1 := transactionManager.BeginWith(Serializable)
+	t2 := transactionManager.BeginWith(Serializable)
+	assert.Nil(t, t1.Get([]byte("key1")))
+	assert.Nil(t, t2.Set([]byte("key1"), []byte("value1")))
+	assert.Nil(t, t2.Get([]byte("key2")))
+	assert.Nil(t, t2.Commit())
+
+	t3 := transactionManager.BeginWith(Serializable)
+	assert.Nil(t, t3.Set([]byte("key2"), []byte("value2")))
+	assert.Nil(t, t3.Commit())
+	assert.Nil(t, t1.Commit())
+	assert.Empty(t, transactionManager.ssi.txns)
+}
+
+// TestRead_committed 读已提交的事务刷新快照之后读到其他事务已经提交的数据, 仍然读不到未提交的数据;
+func TestRead_committed(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t0 := transactionManager.Begin()
+	t0.Set([]byte("key1"), []byte("value1"))
+	t0.Set([]byte("key2"), []byte("value2"))
+	t0.Commit()
+
+	older := transactionManager.Begin()
+	t1 := transactionManager.BeginWith(ReadCommitted)
+	t2 := transactionManager.Begin()
+	t1.Set([]byte("key3"), []byte("value3-1"))
+	older.Set([]byte("key2"), []byte("value2-0"))
+	t2.Set([]byte("key1"), []byte("value1-2"))
+	t2.Commit()
+	// 同一条语句内快照不变;
+	assert.Equal(t, []byte("value1"), t1.Get([]byte("key1")))
+	assert.Equal(t, util.WriteConflict, t1.Set([]byte("key2"), []byte("value2-1")))
+
+	t1.Refresh()
+	assert.Equal(t, []byte("value1-2"), t1.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2"), t1.Get([]byte("key2")))
+	assert.Equal(t, []byte("value3-1"), t1.Get([]byte("key3")))
+	// 未提交的版本仍然冲突;
+	assert.Equal(t, util.WriteConflict, t1.Set([]byte("key2"), []byte("value2-1")))
+	older.Commit()
+	// 刷新之前 older 提交的数据不可见, 也不能覆盖;
+	assert.Equal(t, []byte("value2"), t1.Get([]byte("key2")))
+	assert.Equal(t, util.WriteConflict, t1.Set([]byte("key2"), []byte("value2-1")))
+	t1.Refresh()
+	assert.Equal(t, []byte("value2-0"), t1.Get([]byte("key2")))
+	assert.Nil(t, t1.Set([]byte("key2"), []byte("value2-1")))
+	t1.Commit()
+
+	t3 := transactionManager.Begin()
+	assert.Equal(t, []byte("value1-2"), t3.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2-1"), t3.Get([]byte("key2")))
+	// 快照隔离的事务不刷新快照;
+	t4 := transactionManager.Begin()
+	t3.Set([]byte("key3"), []byte("value3-3"))
+	t3.Commit()
+	t4.Refresh()
+	assert.Equal(t, []byte("value3-1"), t4.Get([]byte("key3")))
+}
+
+// 读已提交的事务覆盖版本号更大的事务已经提交的数据;
+func TestRead_committed_newer(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t0 := transactionManager.Begin()
+	t0.Set([]byte("key1"), []byte("value1"))
+	t0.Set([]byte("key2"), []byte("value2"))
+	t0.Commit()
+
+	t1 := transactionManager.BeginWith(ReadCommitted)
+	t1.Set([]byte("key2"), []byte("value2-1"))
+	t2 := transactionManager.Begin()
+	t2.Set([]byte("key1"), []byte("value1-2"))
+	t2.Commit()
+
+	t1.Refresh()
+	savepoint := t1.Savepoint()
+	assert.Nil(t, t1.Set([]byte("key1"), []byte("value1-1")))
+	assert.Equal(t, []byte("value1-1"), t1.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2-1"), t1.Get([]byte("key2")))
+	pairs := t1.ScanPrefix([]byte("key"), true)
+	assert.Equal(t, []byte("value1-1"), pairs[0].Value)
+	assert.Equal(t, []byte("value2-1"), pairs[1].Value)
+	// 回滚到保存点撤销新版本号下的写入, 之前的写入保留;
+	t1.RollbackTo(savepoint)
+	assert.Equal(t, []byte("value1-2"), t1.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2-1"), t1.Get([]byte("key2")))
+	assert.Nil(t, t1.Set([]byte("key1"), []byte("value1-1")))
+
+	// 提交之前两个版本号都不可见;
+	t3 := transactionManager.Begin()
+	assert.Equal(t, []byte("value1-2"), t3.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2"), t3.Get([]byte("key2")))
+	version := t1.Version()
+	assert.Nil(t, t1.Commit())
+	assert.Equal(t, version, t1.Version())
+	assert.Equal(t, []byte("value1-2"), t3.Get([]byte("key1")))
+	assert.Equal(t, util.WriteConflict, t3.Set([]byte("key1"), []byte("value1-3")))
+	t3.Rollback()
+
+	t4 := transactionManager.Begin()
+	assert.Equal(t, []byte("value1-1"), t4.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2-1"), t4.Get([]byte("key2")))
+	assert.Equal(t, []Version{t4.Version()}, t4.ScanActive())
+	t4.Commit()
+
+	// 回滚时撤销所有版本号下的写入;
+	t5 := transactionManager.BeginWith(ReadCommitted)
+	t5.Set([]byte("key2"), []byte("value2-5"))
+	t6 := transactionManager.Begin()
+	t6.Set([]byte("key1"), []byte("value1-6"))
+	t6.Commit()
+	t5.Refresh()
+	assert.Nil(t, t5.Set([]byte("key1"), []byte("value1-5")))
+	t5.Rollback()
+	t7 := transactionManager.Begin()
+	assert.Equal(t, []byte("value1-6"), t7.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2-1"), t7.Get([]byte("key2")))
+	assert.Equal(t, []Version{t7.Version()}, t7.ScanActive())
+}
+
 func TestTransaction_Rollback(t *testing.T) {
 	transactionManager := NewTransactionManager(GetDiskStorage(txnDirPath))
 	t0 := transactionManager.Begin()
@@ -435,3 +676,151 @@ func TestTransaction_Rollback(t *testing.T) {
 	assert.Equal(t, []byte("value2"), t2.Get([]byte("key2")))
 	assert.Equal(t, []byte("value3"), t2.Get([]byte("key3")))
 }
+
+func TestTransaction_NextSequence(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t1 := transactionManager.Begin()
+	t2 := transactionManager.Begin()
+	// 并发事务各自分配, 不会冲突, 也不会分配到相同的值;
+	assert.Equal(t, uint64(0), t1.NextSequence([]byte("seq"), 10))
+	assert.Equal(t, uint64(10), t2.NextSequence([]byte("seq"), 1))
+	assert.Equal(t, uint64(0), t2.NextSequence([]byte("other"), 1))
+	// 回滚不归还已分配的值;
+	t1.Rollback()
+	t2.Commit()
+	t3 := transactionManager.Begin()
+	assert.Equal(t, uint64(11), t3.NextSequence([]byte("seq"), 1))
+}
+
+func TestTransaction_RollbackTo(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t0 := transactionManager.Begin()
+	t0.Set([]byte("key1"), []byte("value1"))
+	t0.Set([]byte("key2"), []byte("value2"))
+	t0.Commit()
+
+	t1 := transactionManager.Begin()
+	t1.Set([]byte("key1"), []byte("value1-1"))
+	savepoint := t1.Savepoint()
+	// 保存点之前写过的 key 恢复为当时的值, 之后才写的 key 恢复为已提交的值或不存在;
+	t1.Set([]byte("key1"), []byte("value1-2"))
+	t1.Set([]byte("key1"), []byte("value1-3"))
+	t1.Delete([]byte("key2"))
+	t1.Set([]byte("key3"), []byte("value3"))
+	t1.RollbackTo(savepoint)
+	assert.Equal(t, []byte("value1-1"), t1.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2"), t1.Get([]byte("key2")))
+	assert.Nil(t, t1.Get([]byte("key3")))
+	// 回滚到保存点之后事务仍然可以继续写入并提交;
+	t1.Set([]byte("key3"), []byte("value3-1"))
+	t1.Commit()
+
+	t2 := transactionManager.Begin()
+	assert.Equal(t, []byte("value1-1"), t2.Get([]byte("key1")))
+	assert.Equal(t, []byte("value2"), t2.Get([]byte("key2")))
+	assert.Equal(t, []byte("value3-1"), t2.Get([]byte("key3")))
+	// 撤销的写入不再留下写入标记, 不会与其他事务冲突;
+	t3 := transactionManager.Begin()
+	t3.Set([]byte("key4"), []byte("value4"))
+	t3.RollbackTo(0)
+	assert.Nil(t, t2.Set([]byte("key4"), []byte("value4-2")))
+	t3.Commit()
+	t2.Commit()
+	assert.Equal(t, []byte("value4-2"), transactionManager.Begin().Get([]byte("key4")))
+}
+
+func TestTransaction_Hold(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t0 := transactionManager.Begin()
+	t0.Set([]byte("key1"), []byte("value1"))
+	t0.Commit()
+
+	// 登记同一个 key 的事务之间不冲突, 改写被其他活跃事务登记的 key 时冲突;
+	t1 := transactionManager.Begin()
+	t2 := transactionManager.Begin()
+	assert.Nil(t, t1.Hold([]byte("key1")))
+	assert.Nil(t, t1.Hold([]byte("key1")))
+	assert.Nil(t, t2.Hold([]byte("key1")))
+	assert.Nil(t, t2.Set([]byte("key10"), []byte("value10")))
+	t3 := transactionManager.Begin()
+	assert.Equal(t, util.WriteConflict, t3.Set([]byte("key1"), []byte("value1-3")))
+	t1.Commit()
+	assert.Equal(t, util.WriteConflict, t3.Set([]byte("key1"), []byte("value1-3")))
+	// 自己的登记不影响自己的写入;
+	assert.Nil(t, t2.Hold([]byte("key2")))
+	assert.Nil(t, t2.Set([]byte("key2"), []byte("value2")))
+	t2.Rollback()
+	// t1 已经提交, 但对在它提交之前开始的 t3 仍然不可见, t3 改写 key1 依然冲突;
+	assert.Equal(t, util.WriteConflict, t3.Set([]byte("key1"), []byte("value1-3")))
+	t3.Rollback()
+	t4 := transactionManager.Begin()
+	assert.Nil(t, t4.Set([]byte("key1"), []byte("value1-4")))
+
+	// key 已经被并发的事务改写时不能登记;
+	t5 := transactionManager.Begin()
+	assert.Equal(t, util.WriteConflict, t5.Hold([]byte("key1")))
+	t4.Commit()
+	assert.Equal(t, util.WriteConflict, t5.Hold([]byte("key1")))
+	t5.Rollback()
+
+	// 所有活跃事务都在登记者提交之后开始时, 登记失效并被删除;
+	t6 := transactionManager.Begin()
+	assert.Nil(t, t6.Hold([]byte("key1")))
+	t6.Commit()
+	assert.Len(t, t6.storage.ScanPrefix([]byte(TxnHold), false), 1)
+	t7 := transactionManager.Begin()
+	assert.Nil(t, t7.Set([]byte("key1"), []byte("value1-7")))
+	t7.Commit()
+	assert.Empty(t, t7.storage.ScanPrefix([]byte(TxnHold), false))
+}
+
+// 异常退出时仍然活跃的事务在重新启动时回滚, 留下的登记不会一直阻止改写;
+func TestReopen_hold(t *testing.T) {
+	transactionManager := NewTransactionManager(GetDiskStorage(txnDirPath))
+	t0 := transactionManager.Begin()
+	t0.Set([]byte("key1"), []byte("value1"))
+	t0.Commit()
+	t1 := transactionManager.Begin()
+	assert.Nil(t, t1.Hold([]byte("key1")))
+	assert.Nil(t, t1.Set([]byte("key2"), []byte("value2")))
+	// 没有提交或回滚就关闭;
+	assert.Nil(t, transactionManager.Close())
+
+	storage := NewDiskStorage(txnDirPath)
+	transactionManager = NewTransactionManager(storage)
+	defer transactionManager.Close()
+	assert.Empty(t, storage.ScanPrefix([]byte(TxnHold), false))
+	t2 := transactionManager.Begin()
+	assert.Equal(t, []Version{}, t2.transactionState.activeVersions)
+	assert.Nil(t, t2.Get([]byte("key2")))
+	assert.Nil(t, t2.Set([]byte("key1"), []byte("value1-2")))
+	t2.Commit()
+}
+
+func TestTransaction_Savepoint(t *testing.T) {
+	transactionManager := NewTransactionManager(NewMemoryStorage())
+	t1 := transactionManager.Begin()
+	t1.Set([]byte("key1"), []byte("value1"))
+	t1.SetSavepoint("a")
+	t1.Set([]byte("key1"), []byte("value1-1"))
+	t1.SetSavepoint("b")
+	t1.Set([]byte("key2"), []byte("value2"))
+	// 回滚到 a 之后 b 失效, a 仍然可以再次回滚;
+	assert.Nil(t, t1.RollbackToSavepoint("a"))
+	assert.Equal(t, []byte("value1"), t1.Get([]byte("key1")))
+	assert.Nil(t, t1.Get([]byte("key2")))
+	assert.Error(t, t1.RollbackToSavepoint("b"))
+	t1.Set([]byte("key3"), []byte("value3"))
+	assert.Nil(t, t1.RollbackToSavepoint("a"))
+	assert.Nil(t, t1.Get([]byte("key3")))
+	// 释放保存点保留之后的写入;
+	t1.Set([]byte("key3"), []byte("value3-1"))
+	assert.Nil(t, t1.ReleaseSavepoint("a"))
+	assert.Error(t, t1.ReleaseSavepoint("a"))
+	t1.Commit()
+
+	t2 := transactionManager.Begin()
+	assert.Equal(t, []byte("value1"), t2.Get([]byte("key1")))
+	assert.Nil(t, t2.Get([]byte("key2")))
+	assert.Equal(t, []byte("value3-1"), t2.Get([]byte("key3")))
+}
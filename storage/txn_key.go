@@ -9,12 +9,17 @@ var (
 	NextVersion = "NextVersion"
 	TenActive   = "TenActive_"
 	TxnWrite    = "TxnWrite_"
+	TxnHold     = "TxnHold_"
 	KeyVersion  = "KeyVersion_"
+	Sequence    = "Sequence_"
 )
 
 func GetNextVersionKey() []byte {
 	return []byte(NextVersion)
 }
+func GetSequenceKey(key []byte) []byte {
+	return append([]byte(Sequence), key...)
+}
 func GetTenActiveKey(version Version) []byte {
 	buffer := []byte(TenActive)
 	buf := make([]byte, 8)
@@ -52,6 +57,16 @@ func GetTxnWriteKeyValue(txnWriteKey []byte) []byte {
 	}
 	return txnWriteKey[len(TxnWrite)+8:]
 }
+
+// GetTxnHoldKey TxnHold_key_version(8B): 事务依赖 key 的当前版本, 不经过 MVCC; 回滚时删除, 提交后 value 为提交点;
+func GetTxnHoldKey(key []byte, version Version) []byte {
+	buffer := []byte(TxnHold)
+	buffer = append(buffer, key...)
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(version))
+	buffer = append(buffer, buf...)
+	return buffer
+}
 func GetKeyVersionKey(key []byte, version Version) []byte {
 	buffer := []byte(KeyVersion)
 	buffer = append(buffer, key...)